@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus/driver"
+	"github.com/BjornOnGit/payment-gateway/internal/db"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"go.uber.org/zap"
+)
+
+func main() {
+	// Initialize logger
+	serviceName := os.Getenv("LOG_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "outbox-relay"
+	}
+	logger, err := util.NewLogger(serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("starting outbox relay")
+
+	util.LoadEnv() // loads DB_URL etc
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		logger.Fatal("DB_URL not set")
+	}
+
+	interval := time.Second
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logger.Fatal("invalid OUTBOX_POLL_INTERVAL", zap.String("value", v))
+		}
+		interval = d
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Connect to database
+	conn, err := db.OpenPostgres(ctx, dsn)
+	if err != nil {
+		logger.Fatal("failed to connect to postgres", zap.Error(err))
+	}
+	defer conn.Close()
+
+	logger.Info("postgres connection successful")
+
+	// Initialize message bus (BUS_DRIVER: rabbitmq, kafka or memory)
+	busConfig := driver.ConfigFromEnv("outbox-relay")
+	msgBus, err := driver.Open(busConfig)
+	if err != nil {
+		logger.Fatal("failed to initialize message bus", zap.String("driver", busConfig.Driver), zap.Error(err))
+	}
+	defer msgBus.Close()
+	logger.Info("message bus initialized", zap.String("driver", busConfig.Driver))
+
+	relay := service.NewOutboxRelay(repo.NewPostgresTxManager(conn), msgBus, logger)
+
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx, interval)
+		close(done)
+	}()
+
+	// Wait for interrupt signal
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	logger.Info("shutting down gracefully...")
+	cancel()
+	<-done
+}
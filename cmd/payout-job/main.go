@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"go.uber.org/zap"
+)
+
+// payout-job pays due merchant balances out. By default it runs once and
+// exits, for cron; with PAYOUT_INTERVAL set it keeps running and pays out on
+// that interval. Payout events go through the outbox, so the outbox relay
+// publishes them.
+func main() {
+	// Initialize logger
+	serviceName := os.Getenv("LOG_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "payout-job"
+	}
+	logger, err := util.NewLogger(serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("starting payout job")
+
+	util.LoadEnv()
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		logger.Fatal("DB_URL not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Connect to database
+	conn, err := db.OpenPostgres(ctx, dsn)
+	if err != nil {
+		logger.Fatal("failed to connect to postgres", zap.Error(err))
+	}
+	defer conn.Close()
+
+	// Initialize connectors
+	connectors, err := connector.NewRegistryFromEnv()
+	if err != nil {
+		logger.Fatal("failed to configure connectors", zap.Error(err))
+	}
+	payoutConnector := os.Getenv("PAYOUT_CONNECTOR")
+	if payoutConnector != "" && !connectors.Has(payoutConnector) {
+		logger.Fatal("PAYOUT_CONNECTOR is not a registered connector", zap.String("connector", payoutConnector), zap.Strings("connectors", connectors.Names()))
+	}
+
+	payoutService := service.NewPayoutService(repo.NewPostgresPayoutRepository(conn), repo.NewPostgresTxManager(conn),
+		service.NewLedgerService(nil, logger), connectors, payoutConnector, logger)
+
+	interval := util.DurationEnv("PAYOUT_INTERVAL", 0)
+	if interval <= 0 {
+		if err := runPayouts(ctx, payoutService, logger); err != nil {
+			logger.Fatal("payout run failed", zap.Error(err))
+		}
+		logger.Info("payout job completed successfully")
+		return
+	}
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		logger.Info("shutting down gracefully...")
+		cancel()
+	}()
+
+	logger.Info("payout job running", zap.Duration("interval", interval))
+	for {
+		if err := runPayouts(ctx, payoutService, logger); err != nil && ctx.Err() == nil {
+			logger.Error("payout run failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func runPayouts(ctx context.Context, payoutService *service.PayoutService, logger *zap.Logger) error {
+	summary, err := payoutService.RunOnce(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	logger.Info("payout summary",
+		zap.Int("created", summary.Created),
+		zap.Int("paid", summary.Paid),
+		zap.Int("failed", summary.Failed),
+		zap.Int("pending", summary.Pending),
+		zap.Int("errors", summary.Errors),
+	)
+	return nil
+}
@@ -1,26 +1,37 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/statement"
 	"github.com/BjornOnGit/payment-gateway/internal/util"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
-type ReconRecord struct {
-	TransactionID string `json:"transaction_id"`
-	Expected      int64  `json:"expected_amount"`
-	Actual        int64  `json:"actual_amount"`
-	Diff          int64  `json:"difference"`
-}
-
-// Kept for backward compatibility but using AlertClient now
-
+// reconcile-job compares the transactions updated since its last run with
+// their settlements, verifies the ledger's invariants or, given bank
+// statement files, matches the statements against settlements:
+//
+//	reconcile-job
+//	reconcile-job -chunk 1000
+//	reconcile-job --check=ledger
+//	reconcile-job -format mt940 statements/2026-03-04.sta
 func main() {
+	check := flag.String("check", "transactions", "what to reconcile without statement files: transactions or ledger")
+	format := flag.String("format", "auto", "statement format: auto, csv, mt940 or camt.053")
+	chunkSize := flag.Int("chunk", service.DefaultReconChunkSize, "transactions checked per query")
+	flag.Parse()
+
 	// Initialize logger
 	serviceName := os.Getenv("LOG_SERVICE_NAME")
 	if serviceName == "" {
@@ -52,81 +63,179 @@ func main() {
 	alertWebhook := os.Getenv("ALERT_WEBHOOK_URL")
 	alertClient := util.NewAlertClient(alertWebhook, logger)
 
-	if err := runReconcile(ctx, db, logger, alertClient); err != nil {
-		logger.Fatal("reconciliation failed", zap.Error(err))
+	tolerance, err := statement.ToleranceFromEnv()
+	if err != nil {
+		logger.Fatal("invalid tolerance", zap.Error(err))
+	}
+	reconciler := service.NewReconciliationService(
+		repo.NewPostgresSettlementRepository(db),
+		repo.NewPostgresReconciliationRepository(db),
+		repo.NewPostgresReconciliationRunRepository(db),
+		tolerance,
+		logger,
+	)
+
+	if flag.NArg() > 0 {
+		f, err := statement.ParseFormat(*format)
+		if err != nil {
+			logger.Fatal("invalid format", zap.Error(err))
+		}
+		currencies, err := service.LoadCurrencyRegistry(ctx, repo.NewPostgresCurrencyRepository(db))
+		if err != nil {
+			logger.Fatal("failed to load currencies", zap.Error(err))
+		}
+		for _, path := range flag.Args() {
+			if err := importStatements(ctx, reconciler, path, f, currencies, logger, alertClient); err != nil {
+				logger.Fatal("statement import failed", zap.String("file", path), zap.Error(err))
+			}
+		}
+	} else {
+		switch *check {
+		case "transactions":
+			err = runReconcile(ctx, reconciler, *chunkSize, logger, alertClient)
+		case "ledger":
+			verifier := service.NewLedgerVerifier(repo.NewPostgresLedgerRepository(db), 0, logger)
+			err = verifyLedger(ctx, verifier, logger, alertClient)
+		default:
+			logger.Fatal("invalid check", zap.String("check", *check))
+		}
+		if err != nil {
+			logger.Fatal("reconciliation failed", zap.Error(err))
+		}
 	}
 
 	logger.Info("reconciliation job completed successfully")
 }
 
-func runReconcile(ctx context.Context, db *sql.DB, logger *zap.Logger, alertClient *util.AlertClient) error {
-	// Compare transactions.amount vs sum of settlement amounts
-	// Looking for completed or failed transactions
-	rows, err := db.QueryContext(ctx, `
-		SELECT t.id::text, t.amount::bigint, COALESCE(SUM(s.amount), 0)::bigint
-		FROM transactions t
-		LEFT JOIN settlements s ON s.external_reference = t.id::text
-		WHERE t.status IN ('completed', 'failed')
-		GROUP BY t.id, t.amount
-	`)
+// importStatements parses a statement file, detecting its format unless one
+// is given, and reconciles every statement in it. Exceptions raise one alert
+// per statement.
+func importStatements(
+	ctx context.Context,
+	reconciler *service.ReconciliationService,
+	path string,
+	format statement.Format,
+	currencies *model.CurrencyRegistry,
+	logger *zap.Logger,
+	alertClient *util.AlertClient,
+) error {
+	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
+	defer file.Close()
 
-	var mismatchCount int
-	var totalChecked int
+	r := bufio.NewReader(file)
+	if format == "" {
+		head, _ := r.Peek(512)
+		format = statement.Detect(path, head)
+	}
+	statements, err := statement.Parse(r, format, currencies)
+	if err != nil {
+		return err
+	}
 
-	for rows.Next() {
-		totalChecked++
-		var id string
-		var expected int64
-		var actual int64
-		if err := rows.Scan(&id, &expected, &actual); err != nil {
+	for _, st := range statements {
+		st.Source = path
+		summary, err := reconciler.ImportStatement(ctx, st)
+		if err != nil {
 			return err
 		}
-		if expected != actual {
-			mismatchCount++
-			diff := expected - actual
-			logger.Warn("reconciliation mismatch detected",
-				zap.String("transaction_id", id),
-				zap.Int64("expected", expected),
-				zap.Int64("actual", actual),
-				zap.Int64("difference", diff),
-			)
-
-			if err := insertReconciliationLog(ctx, db, id, expected, actual, diff); err != nil {
-				logger.Error("failed to insert reconciliation log", zap.String("transaction_id", id), zap.Error(err))
-				return err
-			}
-
-			// Send alert for mismatch
-			alertDetails := map[string]any{
-				"transaction_id":  id,
-				"expected_amount": expected,
-				"actual_amount":   actual,
-				"difference":      diff,
-			}
-			if err := alertClient.SendWarning(ctx, "reconcile-job",
-				"Transaction amount mismatch detected",
-				alertDetails); err != nil {
-				logger.Warn("failed to send alert", zap.String("transaction_id", id), zap.Error(err))
-			}
+		exceptions := summary.Mismatched + summary.Unmatched + summary.Missing
+		if exceptions == 0 {
+			continue
+		}
+		if err := alertClient.SendWarning(ctx, "reconcile-job",
+			fmt.Sprintf("Bank statement reconciliation found %d exceptions", exceptions),
+			map[string]any{
+				"file":         path,
+				"statement_id": st.ID,
+				"account":      st.Account,
+				"matched":      summary.Matched,
+				"mismatched":   summary.Mismatched,
+				"unmatched":    summary.Unmatched,
+				"missing":      summary.Missing,
+			}); err != nil {
+			logger.Warn("failed to send alert", zap.String("file", path), zap.Error(err))
 		}
 	}
-
-	logger.Info("reconciliation summary",
-		zap.Int("total_checked", totalChecked),
-		zap.Int("mismatches", mismatchCount),
-	)
-
 	return nil
 }
 
-func insertReconciliationLog(ctx context.Context, db *sql.DB, txID string, expected, actual, diff int64) error {
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO reconciliation_logs (id, entity_type, entity_id, expected_amount, actual_amount, status, notes, created_at)
-		VALUES (uuid_generate_v4(), 'transactions', $1, $2, $3, 'mismatch', $4, now())
-	`, txID, expected, actual, "automated-check")
+// runReconcile checks the transactions updated since the last run and
+// raises one alert summarising the mismatches it found.
+func runReconcile(
+	ctx context.Context,
+	reconciler *service.ReconciliationService,
+	chunkSize int,
+	logger *zap.Logger,
+	alertClient *util.AlertClient,
+) error {
+	run, err := reconciler.RunTransactionCheck(ctx, chunkSize)
+	if run == nil {
+		return err
+	}
+
+	currencies := make(map[string]any, len(run.Currencies))
+	for code, c := range run.Currencies {
+		logger.Info("reconciliation currency summary",
+			zap.String("currency", code),
+			zap.Int("checked", c.Checked),
+			zap.Int("matched", c.Matched),
+			zap.Int("mismatched", c.Mismatched),
+			zap.Int64("total_delta", c.Delta),
+		)
+		currencies[code] = c
+	}
+
+	if run.Mismatched > 0 || run.Errors > 0 {
+		if aErr := alertClient.SendWarning(ctx, "reconcile-job",
+			fmt.Sprintf("Reconciliation run found %d mismatches", run.Mismatched),
+			map[string]any{
+				"run_id":     run.ID,
+				"status":     run.Status,
+				"checked":    run.Checked,
+				"matched":    run.Matched,
+				"mismatched": run.Mismatched,
+				"errors":     run.Errors,
+				"currencies": currencies,
+			}); aErr != nil {
+			logger.Warn("failed to send alert", zap.String("run_id", run.ID.String()), zap.Error(aErr))
+		}
+	}
 	return err
 }
+
+// verifyLedger checks the ledger's invariants and raises a critical alert
+// for each one that is violated. Any violation fails the job.
+func verifyLedger(
+	ctx context.Context,
+	verifier *service.LedgerVerifier,
+	logger *zap.Logger,
+	alertClient *util.AlertClient,
+) error {
+	report, err := verifier.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	if report.OK() {
+		return nil
+	}
+
+	for _, kind := range model.LedgerViolations {
+		count := report.Counts[kind]
+		if count == 0 {
+			continue
+		}
+		if err := alertClient.SendCritical(ctx, "reconcile-job",
+			fmt.Sprintf("Ledger invariant %s violated %d times", kind, count),
+			map[string]any{
+				"check":      kind,
+				"count":      count,
+				"violations": report.Of(kind),
+			}); err != nil {
+			logger.Warn("failed to send alert", zap.String("check", string(kind)), zap.Error(err))
+		}
+	}
+	return fmt.Errorf("ledger has %d invariant violations", report.Total())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/export"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// settlement-export writes a settlement file for a payout batch or a date
+// range:
+//
+//	settlement-export -format pain.001 -payout <payout id> -out batch.xml
+//	settlement-export -from 2026-03-01 -to 2026-03-31 > march.csv
+func main() {
+	format := flag.String("format", "csv", "csv or pain.001")
+	from := flag.String("from", "", "start date (YYYY-MM-DD or RFC 3339)")
+	to := flag.String("to", "", "end date, inclusive for a plain date")
+	payout := flag.String("payout", "", "payout id to export instead of a date range")
+	out := flag.String("out", "", "output file (default stdout)")
+	flag.Parse()
+
+	// Initialize logger
+	serviceName := os.Getenv("LOG_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "settlement-export"
+	}
+	logger, err := util.NewLogger(serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	util.LoadEnv()
+
+	f, err := export.ParseFormat(*format)
+	if err != nil {
+		logger.Fatal("invalid format", zap.Error(err))
+	}
+	var filter model.SettlementExportFilter
+	if *payout != "" {
+		id, err := uuid.Parse(*payout)
+		if err != nil {
+			logger.Fatal("invalid payout id", zap.Error(err))
+		}
+		filter.PayoutID = &id
+	} else {
+		filter.From, filter.To, err = export.ParseRange(*from, *to)
+		if err != nil {
+			logger.Fatal("invalid date range", zap.Error(err))
+		}
+	}
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		logger.Fatal("DB_URL not set")
+	}
+
+	ctx := context.Background()
+	conn, err := db.OpenPostgres(ctx, dsn)
+	if err != nil {
+		logger.Fatal("failed to connect to postgres", zap.Error(err))
+	}
+	defer conn.Close()
+
+	currencies, err := service.LoadCurrencyRegistry(ctx, repo.NewPostgresCurrencyRepository(conn))
+	if err != nil {
+		logger.Fatal("failed to load currencies", zap.Error(err))
+	}
+	exportService := service.NewSettlementExportService(repo.NewPostgresSettlementRepository(conn), currencies, export.DebtorFromEnv(), logger)
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			logger.Fatal("failed to create output file", zap.Error(err))
+		}
+	}
+	bw := bufio.NewWriter(w)
+
+	summary, err := exportService.Export(ctx, bw, f, filter)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && w != os.Stdout {
+		err = w.Close()
+	}
+	if err != nil {
+		if *out != "" {
+			os.Remove(*out)
+		}
+		logger.Fatal("export failed", zap.Error(err))
+	}
+
+	for _, t := range summary.Totals {
+		fmt.Fprintf(os.Stderr, "%s: %d settlements, net %d (minor units)\n", t.Currency, t.Count, t.Amount)
+	}
+	fmt.Fprintf(os.Stderr, "message id %s, sha-256 %s\n", summary.MessageID, summary.Checksum)
+}
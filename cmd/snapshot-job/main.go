@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"go.uber.org/zap"
+)
+
+// snapshot-job writes end-of-day balance snapshots for every account, from
+// which balances at past times are computed. A day is snapshotted once it
+// has been over for SNAPSHOT_LAG, so postings still committing around
+// midnight are included. By default it runs once and exits, for cron; with
+// SNAPSHOT_INTERVAL set it keeps running and checks on that interval.
+func main() {
+	// Initialize logger
+	serviceName := os.Getenv("LOG_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "snapshot-job"
+	}
+	logger, err := util.NewLogger(serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("starting snapshot job")
+
+	util.LoadEnv()
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		logger.Fatal("DB_URL not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Connect to database
+	conn, err := db.OpenPostgres(ctx, dsn)
+	if err != nil {
+		logger.Fatal("failed to connect to postgres", zap.Error(err))
+	}
+	defer conn.Close()
+
+	accountService := service.NewAccountService(repo.NewPostgresAccountRepository(conn), nil, logger)
+	lag := util.DurationEnv("SNAPSHOT_LAG", 15*time.Minute)
+
+	interval := util.DurationEnv("SNAPSHOT_INTERVAL", 0)
+	if interval <= 0 {
+		if err := runSnapshots(ctx, accountService, lag, logger); err != nil {
+			logger.Fatal("snapshot run failed", zap.Error(err))
+		}
+		logger.Info("snapshot job completed successfully")
+		return
+	}
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		logger.Info("shutting down gracefully...")
+		cancel()
+	}()
+
+	logger.Info("snapshot job running", zap.Duration("interval", interval))
+	for {
+		if err := runSnapshots(ctx, accountService, lag, logger); err != nil && ctx.Err() == nil {
+			logger.Error("snapshot run failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func runSnapshots(ctx context.Context, accountService *service.AccountService, lag time.Duration, logger *zap.Logger) error {
+	summary, err := accountService.SnapshotBalances(ctx, time.Now().UTC(), lag)
+	if err != nil {
+		return err
+	}
+	logger.Info("snapshot summary",
+		zap.Int("days", len(summary.Days)),
+		zap.Int("balances", summary.Accounts),
+	)
+	return nil
+}
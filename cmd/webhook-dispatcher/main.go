@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/BjornOnGit/payment-gateway/internal/bus/driver"
+	"github.com/BjornOnGit/payment-gateway/internal/db"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/BjornOnGit/payment-gateway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+func main() {
+	// Initialize logger
+	serviceName := os.Getenv("LOG_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "webhook-dispatcher"
+	}
+	logger, err := util.NewLogger(serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("starting webhook dispatcher")
+
+	util.LoadEnv()
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		logger.Fatal("DB_URL not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Connect to database
+	conn, err := db.OpenPostgres(ctx, dsn)
+	if err != nil {
+		logger.Fatal("failed to connect to postgres", zap.Error(err))
+	}
+	defer conn.Close()
+
+	logger.Info("postgres connection successful")
+
+	// Initialize message bus (BUS_DRIVER: rabbitmq, kafka or memory)
+	busConfig := driver.ConfigFromEnv("webhook-dispatcher")
+	msgBus, err := driver.Open(busConfig)
+	if err != nil {
+		logger.Fatal("failed to initialize message bus", zap.String("driver", busConfig.Driver), zap.Error(err))
+	}
+	defer msgBus.Close()
+	logger.Info("message bus initialized", zap.String("driver", busConfig.Driver))
+
+	webhookService := service.NewWebhookService(
+		repo.NewPostgresWebhookDeliveryRepository(conn),
+		repo.NewPostgresMerchantRepository(conn),
+		repo.NewPostgresTransactionRepository(conn),
+		webhook.NewSender(util.DurationEnv("WEBHOOK_TIMEOUT", 10*time.Second)),
+		webhook.RetryPolicyFromEnv(),
+		logger,
+	)
+
+	opts := bus.SubscribeOptions{Group: "webhook-dispatcher", Prefetch: util.IntEnv("BUS_PREFETCH", 0)}
+	for _, topic := range service.WebhookTopics {
+		if err := bus.SubscribeWith(ctx, msgBus, topic, opts, func(ctx context.Context, topic, key string, payload []byte) error {
+			logger.Info("webhook-dispatcher received event", zap.String("topic", topic), zap.String("key", key))
+			return webhookService.HandleEvent(context.Background(), topic, payload)
+		}); err != nil {
+			logger.Fatal("failed to subscribe", zap.String("topic", topic), zap.Error(err))
+		}
+		logger.Info("webhook dispatcher subscribed", zap.String("topic", topic))
+	}
+
+	// Retry failed deliveries with backoff
+	go webhookService.RunRetries(ctx, util.DurationEnv("WEBHOOK_RETRY_INTERVAL", 10*time.Second))
+
+	// Wait for interrupt signal
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	logger.Info("shutting down gracefully...")
+	cancel()
+}
@@ -0,0 +1,13 @@
+-- 0011_ledger_entry_type.sql
+-- Each posting is written as a balanced pair of legs: a debit leg on one
+-- account and a credit leg on another. entry_type says which side a row is.
+
+ALTER TABLE ledger_entries
+ADD COLUMN IF NOT EXISTS entry_type VARCHAR(10) NOT NULL DEFAULT 'debit';
+
+ALTER TABLE ledger_entries
+ALTER COLUMN entry_type DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_debit_account_id ON ledger_entries(debit_account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_credit_account_id ON ledger_entries(credit_account_id);
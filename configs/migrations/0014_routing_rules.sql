@@ -0,0 +1,18 @@
+-- 0014_routing_rules.sql
+-- Declarative routing rules and the route chosen for each transaction
+
+CREATE TABLE IF NOT EXISTS routing_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    priority INT NOT NULL DEFAULT 100, -- lower is evaluated first
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    conditions JSONB NOT NULL DEFAULT '{}'::jsonb,
+    targets JSONB NOT NULL DEFAULT '[]'::jsonb, -- [{"connector": "...", "weight": 1}]
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_routing_rules_enabled_priority ON routing_rules(priority) WHERE enabled;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS route VARCHAR(100);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS routing_rule_id UUID; -- no FK: rules may come from a config file
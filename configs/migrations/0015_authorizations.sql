@@ -0,0 +1,23 @@
+-- 0015_authorizations.sql
+-- Two-step authorize/capture: capture method on transactions and the holds
+-- placed for manual-capture transactions
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS capture_method VARCHAR(20) NOT NULL DEFAULT 'automatic'; -- automatic, manual
+
+CREATE TABLE IF NOT EXISTS authorizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id),
+    connector VARCHAR(100) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'authorized', -- authorized, captured, voided, expired
+    hold_account_id UUID NOT NULL REFERENCES accounts(id),
+    expires_at TIMESTAMP NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_authorizations_expiry ON authorizations(expires_at) WHERE status = 'authorized';
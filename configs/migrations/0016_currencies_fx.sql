@@ -0,0 +1,57 @@
+-- 0016_currencies_fx.sql
+-- ISO 4217 currency registry, static FX rates and cross-currency settlement
+
+CREATE TABLE IF NOT EXISTS currencies (
+    code VARCHAR(3) PRIMARY KEY,
+    exponent SMALLINT NOT NULL CHECK (exponent BETWEEN 0 AND 4), -- minor units
+    name VARCHAR(100) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO currencies (code, exponent, name, enabled) VALUES
+    ('NGN', 2, 'Nigerian Naira', TRUE),
+    ('USD', 2, 'US Dollar', FALSE),
+    ('EUR', 2, 'Euro', FALSE),
+    ('GBP', 2, 'Pound Sterling', FALSE),
+    ('GHS', 2, 'Ghana Cedi', FALSE),
+    ('KES', 2, 'Kenyan Shilling', FALSE),
+    ('ZAR', 2, 'South African Rand', FALSE),
+    ('XOF', 0, 'CFA Franc BCEAO', FALSE),
+    ('JPY', 0, 'Yen', FALSE),
+    ('KWD', 3, 'Kuwaiti Dinar', FALSE),
+    ('BHD', 3, 'Bahraini Dinar', FALSE)
+ON CONFLICT (code) DO NOTHING;
+
+-- Rates are the price of one unit of from_currency in to_currency. The
+-- latest row with effective_at <= now is used.
+CREATE TABLE IF NOT EXISTS fx_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    to_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    rate NUMERIC(30, 12) NOT NULL CHECK (rate > 0),
+    source VARCHAR(100) NOT NULL DEFAULT 'manual',
+    effective_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_rates_pair ON fx_rates(from_currency, to_currency, effective_at DESC);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settlement_currency VARCHAR(10);
+UPDATE transactions SET settlement_currency = currency WHERE settlement_currency IS NULL;
+
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS currency VARCHAR(10);
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(30, 12);
+UPDATE settlements s SET currency = t.currency
+FROM transactions t
+WHERE s.currency IS NULL AND s.external_reference = t.id::text;
+
+-- The amount in the transaction's currency that a converted settlement paid
+-- out, for reconciling against the transaction
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS source_amount BIGINT;
+UPDATE settlements SET source_amount = CASE
+    WHEN fx_rate IS NULL THEN amount
+    ELSE (metadata->'fx'->'from'->>'amount')::bigint
+END
+WHERE source_amount IS NULL;
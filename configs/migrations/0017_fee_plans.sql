@@ -0,0 +1,52 @@
+-- 0017_fee_plans.sql
+-- Merchant fee plans and fee breakdown on settlements
+
+CREATE TABLE IF NOT EXISTS fee_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- At most one plan applies to merchants without an assignment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_plans_default ON fee_plans(is_default) WHERE is_default;
+
+-- One row per currency and volume tier. The tier with the highest
+-- min_monthly_volume not above the merchant's settled volume this month applies.
+CREATE TABLE IF NOT EXISTS fee_plan_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fee_plan_id UUID NOT NULL REFERENCES fee_plans(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    min_monthly_volume BIGINT NOT NULL DEFAULT 0 CHECK (min_monthly_volume >= 0),
+    percent_bps BIGINT NOT NULL DEFAULT 0 CHECK (percent_bps BETWEEN 0 AND 10000),
+    fixed_fee BIGINT NOT NULL DEFAULT 0 CHECK (fixed_fee >= 0),
+    min_fee BIGINT NOT NULL DEFAULT 0 CHECK (min_fee >= 0),
+    max_fee BIGINT CHECK (max_fee IS NULL OR max_fee >= min_fee),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (fee_plan_id, currency, min_monthly_volume)
+);
+
+CREATE TABLE IF NOT EXISTS merchant_fee_plans (
+    merchant_id UUID PRIMARY KEY,
+    fee_plan_id UUID NOT NULL REFERENCES fee_plans(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- amount stays the gross settled amount
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS fee_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS net_amount BIGINT;
+UPDATE settlements SET net_amount = amount - fee_amount WHERE net_amount IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_settlements_merchant_account_created ON settlements(merchant_account_id, created_at);
+
+-- Default plan: 1.5% + NGN 100, capped at NGN 2,000
+INSERT INTO fee_plans (id, name, description, is_default) VALUES
+    ('00000000-0000-0000-0000-00000000f001', 'standard', 'Default merchant pricing', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO fee_plan_rules (fee_plan_id, currency, min_monthly_volume, percent_bps, fixed_fee, min_fee, max_fee) VALUES
+    ('00000000-0000-0000-0000-00000000f001', 'NGN', 0, 150, 10000, 0, 200000)
+ON CONFLICT (fee_plan_id, currency, min_monthly_volume) DO NOTHING;
@@ -0,0 +1,51 @@
+-- 0018_merchants.sql
+-- Merchants become first-class records; fee plans are assigned on the merchant
+
+CREATE TABLE IF NOT EXISTS merchants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+    settlement_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    bank_details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    webhook_url TEXT,
+    fee_plan_id UUID REFERENCES fee_plans(id),
+    metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchants_status ON merchants(status);
+
+-- Existing merchants only exist as ids on transactions and accounts; keep them
+-- valid so their transactions and balances stay usable.
+INSERT INTO merchants (id, name, settlement_currency)
+SELECT owner_id, 'Merchant ' || LEFT(owner_id::text, 8),
+       COALESCE((SELECT code FROM currencies WHERE code = UPPER(MIN(a.currency))), 'NGN')
+FROM accounts a
+WHERE account_type = 'merchant'
+GROUP BY owner_id
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO merchants (id, name, settlement_currency)
+SELECT merchant_id, 'Merchant ' || LEFT(merchant_id::text, 8),
+       COALESCE((SELECT code FROM currencies WHERE code = UPPER(MIN(t.currency))), 'NGN')
+FROM transactions t
+GROUP BY merchant_id
+ON CONFLICT (id) DO NOTHING;
+
+-- Fee plan assignments move onto the merchant
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'merchant_fee_plans') THEN
+        INSERT INTO merchants (id, name, settlement_currency)
+        SELECT merchant_id, 'Merchant ' || LEFT(merchant_id::text, 8), 'NGN'
+        FROM merchant_fee_plans
+        ON CONFLICT (id) DO NOTHING;
+
+        UPDATE merchants m SET fee_plan_id = f.fee_plan_id
+        FROM merchant_fee_plans f
+        WHERE m.id = f.merchant_id AND m.fee_plan_id IS NULL;
+
+        DROP TABLE merchant_fee_plans;
+    END IF;
+END $$;
@@ -0,0 +1,33 @@
+-- 0019_webhooks.sql
+-- Merchant webhook signing secrets and the delivery log
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS webhook_secret TEXT;
+
+-- Existing merchants get a secret too; they obtain it by rotating
+UPDATE merchants
+SET webhook_secret = 'whsec_' || REPLACE(gen_random_uuid()::text || gen_random_uuid()::text, '-', '')
+WHERE webhook_secret IS NULL;
+
+-- One row per event and merchant. attempt_log keeps every attempt; the
+-- dispatcher retries pending rows once next_attempt_at has passed.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    event_type VARCHAR(100) NOT NULL,
+    event_key VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP,
+    last_status_code INT,
+    last_error TEXT,
+    attempt_log JSONB NOT NULL DEFAULT '[]'::jsonb,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (merchant_id, event_type, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_merchant ON webhook_deliveries(merchant_id, created_at DESC);
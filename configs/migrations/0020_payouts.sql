@@ -0,0 +1,38 @@
+-- 0020_payouts.sql
+-- Merchant payout schedules, payouts and the settlements they batch
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS payout_schedule VARCHAR(20) NOT NULL DEFAULT 'daily'
+    CHECK (payout_schedule IN ('daily', 'weekly', 'threshold', 'manual'));
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS payout_weekday SMALLINT NOT NULL DEFAULT 1
+    CHECK (payout_weekday BETWEEN 0 AND 6); -- 0 = Sunday
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS payout_threshold BIGINT NOT NULL DEFAULT 0
+    CHECK (payout_threshold >= 0);
+
+-- A payout debits the merchant account into the payout clearing account
+-- when it is created. It stays pending until the connector accepts it; a
+-- declined payout is reversed back to the merchant account.
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    merchant_account_id UUID NOT NULL REFERENCES accounts(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed')),
+    schedule VARCHAR(20) NOT NULL,
+    connector VARCHAR(50),
+    external_id VARCHAR(255),
+    bank_details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    settlement_count INT NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_merchant ON payouts(merchant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payouts_account ON payouts(merchant_account_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payouts_pending ON payouts(created_at) WHERE status = 'pending';
+
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS payout_id UUID REFERENCES payouts(id);
+CREATE INDEX IF NOT EXISTS idx_settlements_payout ON settlements(payout_id);
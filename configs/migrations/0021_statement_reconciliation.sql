@@ -0,0 +1,10 @@
+-- 0021_statement_reconciliation.sql
+-- Bank statement matching writes matched / unmatched / mismatch rows. The
+-- dedupe key identifies the statement line (or check) a row came from, so
+-- importing the same statement twice adds nothing.
+
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS dedupe_key VARCHAR(128);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reconciliation_logs_dedupe ON reconciliation_logs(dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_logs_entity ON reconciliation_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_logs_status ON reconciliation_logs(status, created_at DESC);
@@ -0,0 +1,27 @@
+-- 0022_reconciliation_cases.sql
+-- Reconciliation exceptions (mismatch / unmatched rows) are worked as
+-- cases: assigned to someone, annotated and resolved. Who did what is
+-- recorded in audit_logs.
+
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS assigned_to VARCHAR(255);
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP;
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS resolution VARCHAR(20); -- write_off / adjustment / refund
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS resolved_by VARCHAR(255);
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP;
+ALTER TABLE reconciliation_logs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_logs_assigned ON reconciliation_logs(assigned_to) WHERE status IN ('mismatch', 'unmatched');
+
+CREATE TABLE IF NOT EXISTS reconciliation_notes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reconciliation_id UUID NOT NULL REFERENCES reconciliation_logs(id),
+    author VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_notes_log ON reconciliation_notes(reconciliation_id, created_at);
+
+-- actor_id stays for user ids; actor holds any token subject
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity, entity_id, created_at);
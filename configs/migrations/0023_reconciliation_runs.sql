@@ -0,0 +1,27 @@
+-- 0023_reconciliation_runs.sql
+-- Each reconcile-job run over transactions is recorded with the keyset
+-- position (updated_at, id) it has safely processed up to. The next run
+-- starts from there, so a run only sees transactions changed since.
+
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(50) NOT NULL, -- 'transactions'
+    status VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'completed', 'failed', 'interrupted')),
+    from_updated_at TIMESTAMP,
+    from_id UUID,
+    high_water_updated_at TIMESTAMP,
+    high_water_id UUID,
+    checked INT NOT NULL DEFAULT 0,
+    matched INT NOT NULL DEFAULT 0,
+    mismatched INT NOT NULL DEFAULT 0,
+    errors INT NOT NULL DEFAULT 0,
+    summary JSONB NOT NULL DEFAULT '{}'::jsonb, -- per currency: checked, matched, mismatched, delta
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_kind ON reconciliation_runs(kind, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_updated_keyset ON transactions(updated_at, id);
@@ -0,0 +1,19 @@
+-- 0024_account_balance_snapshots.sql
+-- End-of-day balance of every account, written by snapshot-job. A snapshot
+-- at as_of (midnight UTC, the end of the day before) is the sum of the
+-- account's ledger legs created before as_of, so a balance at any time is
+-- the nearest earlier snapshot plus the legs after it.
+
+CREATE TABLE IF NOT EXISTS account_balance_snapshots (
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    as_of TIMESTAMP NOT NULL,
+    balance BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, as_of)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_balance_snapshots_as_of ON account_balance_snapshots(as_of);
+
+-- Legs of one account in a time range
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_debit_account_created ON ledger_entries(debit_account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_credit_account_created ON ledger_entries(credit_account_id, created_at);
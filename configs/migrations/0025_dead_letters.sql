@@ -0,0 +1,38 @@
+-- 0025_dead_letters.sql
+-- Messages dlq-monitor took off the dead-letter queues, kept for inspection
+-- and replay. The same message dead-lettered again (after a failed replay)
+-- updates its row: fingerprint hashes topic, key and payload.
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fingerprint VARCHAR(64) NOT NULL UNIQUE,
+    topic VARCHAR(255) NOT NULL, -- original topic, replays are published here
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload BYTEA NOT NULL,
+    retry_count INT NOT NULL DEFAULT 0,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    occurrences INT NOT NULL DEFAULT 1,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'replayed', 'replay_failed')),
+    replay_count INT NOT NULL DEFAULT 0,
+    last_replayed_at TIMESTAMP,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_topic ON dead_letters(topic, last_seen_at DESC);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_status ON dead_letters(status, last_seen_at DESC);
+
+-- One row per replay attempt
+CREATE TABLE IF NOT EXISTS dead_letter_replays (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dead_letter_id UUID NOT NULL REFERENCES dead_letters(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('published', 'failed')),
+    error TEXT,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letter_replays_dead_letter ON dead_letter_replays(dead_letter_id, created_at);
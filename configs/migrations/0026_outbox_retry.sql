@@ -0,0 +1,10 @@
+-- 0026_outbox_retry.sql
+-- Unroutable outbox messages (no queue subscribed to the topic yet) are
+-- retried with a growing delay instead of holding up the rest of the outbox.
+-- next_attempt_at is when the relay may pick a failed message up again.
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+-- Messages parked as unroutable (sent_at and last_error both set) were never
+-- delivered: put them back on the unsent queue
+UPDATE outbox SET sent_at = NULL WHERE sent_at IS NOT NULL AND last_error IS NOT NULL;
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type AccountHandler struct {
+	svc    *service.AccountService
+	logger *zap.Logger
+}
+
+func NewAccountHandler(s *service.AccountService, logger *zap.Logger) *AccountHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &AccountHandler{svc: s, logger: logger}
+}
+
+// Balance handles GET /v1/accounts/{id}/balance?as_of=. With as_of (RFC 3339,
+// or a date for the start of that day) it returns the ledger balance at that
+// time.
+func (h *AccountHandler) Balance(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid account id", http.StatusBadRequest)
+		return
+	}
+
+	asOf, err := parseTimeParam(r.URL.Query().Get("as_of"))
+	if err != nil {
+		http.Error(w, "invalid as_of", http.StatusBadRequest)
+		return
+	}
+
+	balance, err := h.svc.GetBalance(r.Context(), id, asOf)
+	if err != nil {
+		log.Error("failed to get account balance", zap.String("account_id", id.String()), zap.Error(err))
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(balance)
+}
+
+// Entries handles GET /v1/accounts/{id}/entries?from=&to=&limit=&offset=.
+// A plain date as to includes that whole day.
+func (h *AccountHandler) Entries(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid account id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := model.AccountEntryFilter{Limit: 10}
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+	if _, dateErr := time.Parse("2006-01-02", q.Get("to")); dateErr == nil {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	entries, err := h.svc.ListEntries(r.Context(), id, filter)
+	if err != nil {
+		log.Error("failed to list account entries", zap.String("account_id", id.String()), zap.Error(err))
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   entries,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// Statement handles GET /v1/accounts/{id}/statement?month=YYYY-MM&format=csv|json.
+// The month defaults to the current one. The statement is returned as an
+// attachment; the CSV's checksum is repeated in X-Export-Checksum.
+func (h *AccountHandler) Statement(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid account id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	month := time.Now().UTC()
+	if m := q.Get("month"); m != "" {
+		if month, err = time.Parse("2006-01", m); err != nil {
+			http.Error(w, "invalid month", http.StatusBadRequest)
+			return
+		}
+	}
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = "csv"
+	case "csv", "json":
+	default:
+		http.Error(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.svc.Statement(r.Context(), id, month)
+	if err != nil {
+		log.Error("failed to build account statement", zap.String("account_id", id.String()), zap.Error(err))
+		writeAccountError(w, err)
+		return
+	}
+	filename := "statement-" + id.String() + "-" + st.From.Format("2006-01") + "." + format
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(st)
+		return
+	}
+
+	// Buffer the file so a failure part-way still gets an error status
+	var buf bytes.Buffer
+	checksum, err := h.svc.WriteStatementCSV(&buf, st)
+	if err != nil {
+		log.Error("failed to write account statement", zap.String("account_id", id.String()), zap.Error(err))
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("X-Export-Checksum", "sha-256="+checksum)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAccountNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidAccountQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
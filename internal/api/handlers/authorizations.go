@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type AuthorizationHandler struct {
+	svc    *service.AuthorizationService
+	logger *zap.Logger
+}
+
+func NewAuthorizationHandler(s *service.AuthorizationService, logger *zap.Logger) *AuthorizationHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &AuthorizationHandler{svc: s, logger: logger}
+}
+
+// Capture handles POST /v1/transactions/{id}/capture. An empty body or an
+// amount of 0 captures the full authorized amount.
+func (h *AuthorizationHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	txID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Error("invalid transaction id", zap.String("id", r.PathValue("id")), zap.Error(err))
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	var payload dto.CaptureTransactionDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.svc.Capture(r.Context(), txID, payload.Amount)
+	if err != nil {
+		log.Error("failed to capture transaction", zap.String("transaction_id", txID.String()), zap.Error(err))
+		writeAuthorizationError(w, err)
+		return
+	}
+
+	log.Info("transaction captured", zap.String("id", tx.ID.String()), zap.Int64("amount", tx.Amount))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tx)
+}
+
+// Void handles POST /v1/transactions/{id}/void.
+func (h *AuthorizationHandler) Void(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	txID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Error("invalid transaction id", zap.String("id", r.PathValue("id")), zap.Error(err))
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.svc.Void(r.Context(), txID)
+	if err != nil {
+		log.Error("failed to void transaction", zap.String("transaction_id", txID.String()), zap.Error(err))
+		writeAuthorizationError(w, err)
+		return
+	}
+
+	log.Info("transaction voided", zap.String("id", tx.ID.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tx)
+}
+
+func writeAuthorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransactionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrTransactionNotAuthorized), errors.Is(err, service.ErrAuthorizationExpired):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrCaptureExceedsAuthorized), errors.Is(err, service.ErrConnectorDeclined):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrInvalidAmount):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, connector.ErrTimeout):
+		http.Error(w, "connector timed out", http.StatusGatewayTimeout)
+	case errors.Is(err, connector.ErrUnavailable), errors.Is(err, connector.ErrUnknownConnector):
+		http.Error(w, "connector unavailable", http.StatusBadGateway)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type DeadLetterHandler struct {
+	svc    *service.DeadLetterService
+	logger *zap.Logger
+}
+
+func NewDeadLetterHandler(s *service.DeadLetterService, logger *zap.Logger) *DeadLetterHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &DeadLetterHandler{svc: s, logger: logger}
+}
+
+// List handles GET /v1/admin/dead-letters?topic=&key=&status=&from=&to=&limit=&offset=.
+func (h *DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	q := r.URL.Query()
+	filter := model.DeadLetterFilter{
+		Topic:  q.Get("topic"),
+		Key:    q.Get("key"),
+		Status: model.DeadLetterStatus(q.Get("status")),
+		Limit:  10,
+	}
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	letters, err := h.svc.List(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to list dead letters", zap.Error(err))
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   letters,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// Get handles GET /v1/admin/dead-letters/{id}.
+func (h *DeadLetterHandler) Get(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get dead letter", zap.String("dead_letter_id", id.String()), zap.Error(err))
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Replay handles POST /v1/admin/dead-letters/{id}/replay. A replay that
+// could not be published is returned with 502.
+func (h *DeadLetterHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	replay, err := h.svc.Replay(r.Context(), id, actor(r))
+	if err != nil {
+		log.Error("failed to replay dead letter", zap.String("dead_letter_id", id.String()), zap.Error(err))
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if replay.Status != model.DeadLetterReplayPublished {
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(replay)
+}
+
+// ReplayBulk handles POST /v1/admin/dead-letters/replay, replaying every
+// dead letter matching the filter in the body.
+func (h *DeadLetterHandler) ReplayBulk(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	var payload dto.ReplayDeadLettersDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	filter := model.DeadLetterFilter{
+		Topic:  payload.Topic,
+		Key:    payload.Key,
+		Status: model.DeadLetterStatus(payload.Status),
+	}
+	var err error
+	if filter.From, err = parseTimeParam(payload.From); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(payload.To); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.svc.ReplayMatching(r.Context(), filter, actor(r))
+	if err != nil {
+		log.Error("failed to replay dead letters", zap.Error(err))
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrDeadLetterNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidDeadLetterQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrReplayUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type MerchantHandler struct {
+	svc    *service.MerchantService
+	logger *zap.Logger
+}
+
+func NewMerchantHandler(s *service.MerchantService, logger *zap.Logger) *MerchantHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &MerchantHandler{svc: s, logger: logger}
+}
+
+// Create handles POST /v1/merchants.
+func (h *MerchantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	var payload dto.CreateMerchantDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	merchant, err := h.svc.CreateMerchant(r.Context(), payload)
+	if err != nil {
+		log.Error("failed to create merchant", zap.Error(err))
+		writeMerchantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(merchant)
+}
+
+// GetByID handles GET /v1/merchants/{id}.
+func (h *MerchantHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid merchant id", http.StatusBadRequest)
+		return
+	}
+
+	merchant, err := h.svc.GetMerchant(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get merchant", zap.String("merchant_id", id.String()), zap.Error(err))
+		writeMerchantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(merchant)
+}
+
+// List handles GET /v1/merchants?status=&limit=&offset=.
+func (h *MerchantHandler) List(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	limit := 10
+	offset := 0
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	merchants, err := h.svc.ListMerchants(r.Context(), r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		log.Error("failed to list merchants", zap.Error(err))
+		writeMerchantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   merchants,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Update handles PATCH /v1/merchants/{id}.
+func (h *MerchantHandler) Update(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid merchant id", http.StatusBadRequest)
+		return
+	}
+
+	var payload dto.UpdateMerchantDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	merchant, err := h.svc.UpdateMerchant(r.Context(), id, payload)
+	if err != nil {
+		log.Error("failed to update merchant", zap.String("merchant_id", id.String()), zap.Error(err))
+		writeMerchantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(merchant)
+}
+
+// Delete handles DELETE /v1/merchants/{id}.
+func (h *MerchantHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid merchant id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.DeleteMerchant(r.Context(), id); err != nil {
+		log.Error("failed to delete merchant", zap.String("merchant_id", id.String()), zap.Error(err))
+		writeMerchantError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeMerchantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrMerchantNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidMerchant):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrMerchantInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
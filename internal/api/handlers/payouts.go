@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type PayoutHandler struct {
+	svc    *service.PayoutService
+	logger *zap.Logger
+}
+
+func NewPayoutHandler(s *service.PayoutService, logger *zap.Logger) *PayoutHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &PayoutHandler{svc: s, logger: logger}
+}
+
+// List handles GET /v1/payouts?merchant_id=&status=&limit=&offset=.
+func (h *PayoutHandler) List(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	q := r.URL.Query()
+	filter := model.PayoutFilter{
+		Status: model.PayoutStatus(q.Get("status")),
+		Limit:  10,
+	}
+	if m := q.Get("merchant_id"); m != "" {
+		id, err := uuid.Parse(m)
+		if err != nil {
+			http.Error(w, "invalid merchant_id", http.StatusBadRequest)
+			return
+		}
+		filter.MerchantID = &id
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	payouts, err := h.svc.ListPayouts(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to list payouts", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   payouts,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// Get handles GET /v1/payouts/{id}.
+func (h *PayoutHandler) Get(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid payout id", http.StatusBadRequest)
+		return
+	}
+
+	payout, err := h.svc.GetPayout(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get payout", zap.String("payout_id", id.String()), zap.Error(err))
+		writePayoutError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payout)
+}
+
+func writePayoutError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrPayoutNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
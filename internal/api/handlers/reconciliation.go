@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/api/middleware"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ReconciliationHandler struct {
+	svc    *service.ReconciliationCaseService
+	logger *zap.Logger
+}
+
+func NewReconciliationHandler(s *service.ReconciliationCaseService, logger *zap.Logger) *ReconciliationHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ReconciliationHandler{svc: s, logger: logger}
+}
+
+// List handles GET /v1/reconciliation?status=&entity_type=&assigned_to=&from=&to=&limit=&offset=.
+// Without a status it lists the open cases.
+func (h *ReconciliationHandler) List(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	q := r.URL.Query()
+	filter := model.ReconciliationFilter{
+		Status:     q.Get("status"),
+		EntityType: q.Get("entity_type"),
+		AssignedTo: q.Get("assigned_to"),
+		Limit:      10,
+	}
+	switch filter.Status {
+	case "", model.ReconMatched, model.ReconUnmatched, model.ReconMismatch, model.ReconResolved:
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	cases, err := h.svc.ListCases(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to list reconciliation cases", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   cases,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// Get handles GET /v1/reconciliation/{id}.
+func (h *ReconciliationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid reconciliation id", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.svc.GetCase(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get reconciliation case", zap.String("reconciliation_id", id.String()), zap.Error(err))
+		writeReconciliationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Assign handles POST /v1/reconciliation/{id}/assign.
+func (h *ReconciliationHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid reconciliation id", http.StatusBadRequest)
+		return
+	}
+	var payload dto.AssignReconciliationDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	l, err := h.svc.Assign(r.Context(), id, actor(r), payload)
+	if err != nil {
+		log.Error("failed to assign reconciliation case", zap.String("reconciliation_id", id.String()), zap.Error(err))
+		writeReconciliationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(l)
+}
+
+// AddNote handles POST /v1/reconciliation/{id}/notes.
+func (h *ReconciliationHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid reconciliation id", http.StatusBadRequest)
+		return
+	}
+	var payload dto.ReconciliationNoteDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	note, err := h.svc.AddNote(r.Context(), id, actor(r), payload)
+	if err != nil {
+		log.Error("failed to add reconciliation note", zap.String("reconciliation_id", id.String()), zap.Error(err))
+		writeReconciliationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// Resolve handles POST /v1/reconciliation/{id}/resolve.
+func (h *ReconciliationHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid reconciliation id", http.StatusBadRequest)
+		return
+	}
+	var payload dto.ResolveReconciliationDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	l, err := h.svc.Resolve(r.Context(), id, actor(r), payload)
+	if err != nil {
+		log.Error("failed to resolve reconciliation case", zap.String("reconciliation_id", id.String()), zap.Error(err))
+		writeReconciliationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(l)
+}
+
+// actor is who is making the request: the token subject, or "anonymous"
+// when authentication is disabled.
+func actor(r *http.Request) string {
+	if sub := middleware.GetUserIDFromContext(r.Context()); sub != "" {
+		return sub
+	}
+	return "anonymous"
+}
+
+// parseTimeParam accepts a date (YYYY-MM-DD, UTC) or an RFC 3339 time; an
+// empty string is the zero time.
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err
+}
+
+func writeReconciliationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrReconciliationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidReconciliation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrReconciliationClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
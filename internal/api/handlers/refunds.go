@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type RefundHandler struct {
+	svc    *service.RefundService
+	logger *zap.Logger
+}
+
+func NewRefundHandler(s *service.RefundService, logger *zap.Logger) *RefundHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &RefundHandler{svc: s, logger: logger}
+}
+
+// Create handles POST /v1/transactions/{id}/refunds.
+func (h *RefundHandler) Create(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	txID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Error("invalid transaction id", zap.String("id", r.PathValue("id")), zap.Error(err))
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	var payload dto.CreateRefundDTO
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error("invalid json", zap.Error(err))
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	refund, err := h.svc.CreateRefund(r.Context(), txID, payload)
+	if err != nil {
+		log.Error("failed to create refund", zap.String("transaction_id", txID.String()), zap.Error(err))
+		switch {
+		case errors.Is(err, service.ErrTransactionNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrTransactionNotRefundable):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrRefundExceedsRemaining):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, service.ErrInvalidAmount):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info("refund created", zap.String("id", refund.ID.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
+}
+
+// List handles GET /v1/transactions/{id}/refunds.
+func (h *RefundHandler) List(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	txID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Error("invalid transaction id", zap.String("id", r.PathValue("id")), zap.Error(err))
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	refunds, err := h.svc.ListRefunds(r.Context(), txID)
+	if err != nil {
+		log.Error("failed to list refunds", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data": refunds,
+	})
+}
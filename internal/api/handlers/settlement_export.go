@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BjornOnGit/payment-gateway/internal/export"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type SettlementExportHandler struct {
+	svc    *service.SettlementExportService
+	logger *zap.Logger
+}
+
+func NewSettlementExportHandler(s *service.SettlementExportService, logger *zap.Logger) *SettlementExportHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &SettlementExportHandler{svc: s, logger: logger}
+}
+
+// Export handles GET /v1/settlements/export?format=csv|pain.001 with either
+// payout_id= or from=&to=. The file is returned as an attachment; its
+// checksum and count are repeated in the X-Export-* headers.
+func (h *SettlementExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	q := r.URL.Query()
+	format, err := export.ParseFormat(q.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var filter model.SettlementExportFilter
+	if p := q.Get("payout_id"); p != "" {
+		id, err := uuid.Parse(p)
+		if err != nil {
+			http.Error(w, "invalid payout_id", http.StatusBadRequest)
+			return
+		}
+		filter.PayoutID = &id
+	} else {
+		filter.From, filter.To, err = export.ParseRange(q.Get("from"), q.Get("to"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Buffer the file so a failure part-way still gets an error status
+	var buf bytes.Buffer
+	summary, err := h.svc.Export(r.Context(), &buf, format, filter)
+	if err != nil {
+		log.Error("failed to export settlements", zap.Error(err))
+		writeExportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="settlements-`+summary.MessageID+"."+format.Extension()+`"`)
+	w.Header().Set("X-Export-Message-Id", summary.MessageID)
+	w.Header().Set("X-Export-Count", strconv.Itoa(summary.Count))
+	w.Header().Set("X-Export-Checksum", "sha-256="+summary.Checksum)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidExport), errors.Is(err, export.ErrUnknownFormat):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, export.ErrMissingBankDetails), errors.Is(err, export.ErrEmptyBatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/BjornOnGit/payment-gateway/internal/model"
 	"github.com/BjornOnGit/payment-gateway/internal/service"
 	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
 	"github.com/BjornOnGit/payment-gateway/internal/util"
@@ -40,7 +42,14 @@ func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	id, err := h.svc.CreateTransaction(r.Context(), payload)
 	if err != nil {
 		log.Error("failed to create transaction", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, model.ErrMerchantNotFound):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, model.ErrMerchantSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
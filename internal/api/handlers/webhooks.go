@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type WebhookHandler struct {
+	svc    *service.WebhookService
+	logger *zap.Logger
+}
+
+func NewWebhookHandler(s *service.WebhookService, logger *zap.Logger) *WebhookHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &WebhookHandler{svc: s, logger: logger}
+}
+
+// ListDeliveries handles GET /v1/webhooks/deliveries?merchant_id=&event_type=&status=&limit=&offset=.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	q := r.URL.Query()
+	filter := model.WebhookDeliveryFilter{
+		EventType: q.Get("event_type"),
+		Status:    model.WebhookDeliveryStatus(q.Get("status")),
+		Limit:     10,
+	}
+	if m := q.Get("merchant_id"); m != "" {
+		id, err := uuid.Parse(m)
+		if err != nil {
+			http.Error(w, "invalid merchant_id", http.StatusBadRequest)
+			return
+		}
+		filter.MerchantID = &id
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	deliveries, err := h.svc.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to list webhook deliveries", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":   deliveries,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// GetDelivery handles GET /v1/webhooks/deliveries/{id}.
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid delivery id", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.svc.GetDelivery(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get webhook delivery", zap.String("delivery_id", id.String()), zap.Error(err))
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// Redeliver handles POST /v1/webhooks/deliveries/{id}/redeliver.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	log := util.WithTraceFromContext(r.Context(), h.logger)
+	log.Info("received request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid delivery id", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.svc.Redeliver(r.Context(), id)
+	if err != nil {
+		log.Error("failed to redeliver webhook", zap.String("delivery_id", id.String()), zap.Error(err))
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookDeliveryNotFound), errors.Is(err, model.ErrMerchantNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -1,24 +1,36 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 
 	"github.com/BjornOnGit/payment-gateway/internal/api/handlers"
 	"github.com/BjornOnGit/payment-gateway/internal/api/middleware"
 	"github.com/BjornOnGit/payment-gateway/internal/auth"
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
 	"github.com/BjornOnGit/payment-gateway/internal/service"
 	"go.uber.org/zap"
 )
 
 type RouterConfig struct {
-	TxService        *service.TransactionService
-	SettlementSvc    *service.SettlementService   // optional - if nil, settlement endpoints disabled
-	AuthService      *service.AuthService         // optional - if nil, auth endpoints disabled
-	JWTManager       *auth.JWTManager             // optional - if nil, no auth middleware
-	IdempotencyStore *middleware.IdempotencyStore // optional - if nil, no idempotency middleware
-	OAuthServer      *auth.OAuthServer            // optional - if nil, /oauth/token disabled
-	Logger           *zap.Logger                  // optional - if nil, handlers use no-op logger
+	TxService         *service.TransactionService
+	SettlementSvc     *service.SettlementService         // optional - if nil, settlement endpoints disabled
+	RefundSvc         *service.RefundService             // optional - if nil, refund endpoints disabled
+	AuthorizationSvc  *service.AuthorizationService      // optional - if nil, capture/void endpoints disabled
+	MerchantSvc       *service.MerchantService           // optional - if nil, merchant endpoints disabled
+	WebhookSvc        *service.WebhookService            // optional - if nil, webhook delivery endpoints disabled
+	PayoutSvc         *service.PayoutService             // optional - if nil, payout endpoints disabled
+	ExportSvc         *service.SettlementExportService   // optional - if nil, settlement export disabled
+	ReconciliationSvc *service.ReconciliationCaseService // optional - if nil, reconciliation endpoints disabled
+	AccountSvc        *service.AccountService            // optional - if nil, account endpoints disabled
+	DeadLetterSvc     *service.DeadLetterService         // optional - if nil, dead-letter admin endpoints disabled
+	AuthService       *service.AuthService               // optional - if nil, auth endpoints disabled
+	JWTManager        *auth.JWTManager                   // optional - if nil, no auth middleware
+	IdempotencyStore  *middleware.IdempotencyStore       // optional - if nil, no idempotency middleware
+	OAuthServer       *auth.OAuthServer                  // optional - if nil, /oauth/token disabled
+	Bus               bus.HealthReporter                 // optional - if nil, /health does not report the message bus
+	Logger            *zap.Logger                        // optional - if nil, handlers use no-op logger
 }
 
 func NewRouter(txService *service.TransactionService) http.Handler {
@@ -26,11 +38,43 @@ func NewRouter(txService *service.TransactionService) http.Handler {
 }
 
 type apiRouter struct {
-	cfg       RouterConfig
-	txHandler *handlers.TransactionHandler
-	sHandler  *handlers.SettlementHandler
-	oauthH    *handlers.OAuthHandler
-	authH     *handlers.AuthHandler
+	cfg        RouterConfig
+	txHandler  *handlers.TransactionHandler
+	sHandler   *handlers.SettlementHandler
+	rHandler   *handlers.RefundHandler
+	azHandler  *handlers.AuthorizationHandler
+	mHandler   *handlers.MerchantHandler
+	whHandler  *handlers.WebhookHandler
+	pHandler   *handlers.PayoutHandler
+	exHandler  *handlers.SettlementExportHandler
+	recHandler *handlers.ReconciliationHandler
+	accHandler *handlers.AccountHandler
+	dlHandler  *handlers.DeadLetterHandler
+	oauthH     *handlers.OAuthHandler
+	authH      *handlers.AuthHandler
+}
+
+// health reports "ok", or "degraded" while the message bus is
+// disconnected. It still answers 200 then: the API keeps accepting requests
+// and their events wait in the outbox until the bus reconnects.
+func (ar *apiRouter) health(w http.ResponseWriter) {
+	if ar.cfg.Bus == nil {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ok"}`))
+		return
+	}
+
+	h := ar.cfg.Bus.Health()
+	status := "ok"
+	if !h.Connected {
+		status = "degraded"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"bus":    h,
+	})
 }
 
 func (ar *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,8 +82,7 @@ func (ar *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Public routes
 	if path == "/health" {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+		ar.health(w)
 		return
 	}
 
@@ -69,12 +112,40 @@ func (ar *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			ar.serveListTransactions(w, r)
 			return
 		}
+		if id, sub, ok := subresource(path, "/v1/transactions/"); ok {
+			r.SetPathValue("id", id)
+			if sub == "refunds" && ar.rHandler != nil {
+				if r.Method == http.MethodPost {
+					ar.serveCreateRefund(w, r)
+					return
+				}
+				if r.Method == http.MethodGet {
+					ar.withAuth(http.HandlerFunc(ar.rHandler.List)).ServeHTTP(w, r)
+					return
+				}
+			}
+			if (sub == "capture" || sub == "void") && ar.azHandler != nil && r.Method == http.MethodPost {
+				handler := ar.azHandler.Capture
+				if sub == "void" {
+					handler = ar.azHandler.Void
+				}
+				ar.serveIdempotent(w, r, http.HandlerFunc(handler))
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
 		if r.Method == http.MethodGet && strings.HasPrefix(path, "/v1/transactions/") && path != "/v1/transactions/list" {
 			ar.serveGetTransaction(w, r)
 			return
 		}
 	}
 
+	if path == "/v1/settlements/export" && ar.exHandler != nil && r.Method == http.MethodGet {
+		ar.withAuth(http.HandlerFunc(ar.exHandler.Export)).ServeHTTP(w, r)
+		return
+	}
+
 	// Settlement routes
 	if strings.HasPrefix(path, "/v1/settlements") && ar.cfg.SettlementSvc != nil {
 		if r.Method == http.MethodGet && path == "/v1/settlements/list" {
@@ -87,9 +158,207 @@ func (ar *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Merchant routes
+	if (path == "/v1/merchants" || strings.HasPrefix(path, "/v1/merchants/")) && ar.mHandler != nil {
+		ar.serveMerchants(w, r)
+		return
+	}
+
+	// Webhook delivery routes
+	if strings.HasPrefix(path, "/v1/webhooks/deliveries") && ar.whHandler != nil {
+		if r.Method == http.MethodGet && path == "/v1/webhooks/deliveries" {
+			ar.withAuth(http.HandlerFunc(ar.whHandler.ListDeliveries)).ServeHTTP(w, r)
+			return
+		}
+		if id, sub, ok := subresource(path, "/v1/webhooks/deliveries/"); ok {
+			if sub == "redeliver" && r.Method == http.MethodPost {
+				r.SetPathValue("id", id)
+				ar.serveIdempotent(w, r, http.HandlerFunc(ar.whHandler.Redeliver))
+				return
+			}
+		} else if id := strings.TrimPrefix(path, "/v1/webhooks/deliveries/"); id != path && id != "" && r.Method == http.MethodGet {
+			r.SetPathValue("id", id)
+			ar.withAuth(http.HandlerFunc(ar.whHandler.GetDelivery)).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	// Payout routes
+	if strings.HasPrefix(path, "/v1/payouts") && ar.pHandler != nil && r.Method == http.MethodGet {
+		if path == "/v1/payouts" {
+			ar.withAuth(http.HandlerFunc(ar.pHandler.List)).ServeHTTP(w, r)
+			return
+		}
+		if id := strings.TrimPrefix(path, "/v1/payouts/"); id != path && id != "" && !strings.Contains(id, "/") {
+			r.SetPathValue("id", id)
+			ar.withAuth(http.HandlerFunc(ar.pHandler.Get)).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	// Reconciliation case routes
+	if (path == "/v1/reconciliation" || strings.HasPrefix(path, "/v1/reconciliation/")) && ar.recHandler != nil {
+		ar.serveReconciliation(w, r)
+		return
+	}
+
+	// Account routes
+	if strings.HasPrefix(path, "/v1/accounts/") && ar.accHandler != nil {
+		ar.serveAccounts(w, r)
+		return
+	}
+
+	// Dead-letter admin routes
+	if (path == "/v1/admin/dead-letters" || strings.HasPrefix(path, "/v1/admin/dead-letters/")) && ar.dlHandler != nil {
+		ar.serveDeadLetters(w, r)
+		return
+	}
+
 	http.NotFound(w, r)
 }
 
+func (ar *apiRouter) serveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/v1/admin/dead-letters":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ar.withAuth(http.HandlerFunc(ar.dlHandler.List)).ServeHTTP(w, r)
+		return
+	case path == "/v1/admin/dead-letters/replay":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ar.serveIdempotent(w, r, http.HandlerFunc(ar.dlHandler.ReplayBulk))
+		return
+	}
+
+	if id, sub, ok := subresource(path, "/v1/admin/dead-letters/"); ok {
+		if sub != "replay" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.SetPathValue("id", id)
+		ar.serveIdempotent(w, r, http.HandlerFunc(ar.dlHandler.Replay))
+		return
+	}
+
+	id := strings.TrimPrefix(path, "/v1/admin/dead-letters/")
+	if id == "" || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	r.SetPathValue("id", id)
+	ar.withAuth(http.HandlerFunc(ar.dlHandler.Get)).ServeHTTP(w, r)
+}
+
+func (ar *apiRouter) serveAccounts(w http.ResponseWriter, r *http.Request) {
+	id, sub, ok := subresource(r.URL.Path, "/v1/accounts/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var handler http.HandlerFunc
+	switch sub {
+	case "balance":
+		handler = ar.accHandler.Balance
+	case "entries":
+		handler = ar.accHandler.Entries
+	case "statement":
+		handler = ar.accHandler.Statement
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.SetPathValue("id", id)
+	ar.withAuth(handler).ServeHTTP(w, r)
+}
+
+func (ar *apiRouter) serveReconciliation(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path == "/v1/reconciliation" {
+		if r.Method == http.MethodGet {
+			ar.withAuth(http.HandlerFunc(ar.recHandler.List)).ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if id, sub, ok := subresource(path, "/v1/reconciliation/"); ok {
+		r.SetPathValue("id", id)
+		var handler http.HandlerFunc
+		switch sub {
+		case "assign":
+			handler = ar.recHandler.Assign
+		case "notes":
+			handler = ar.recHandler.AddNote
+		case "resolve":
+			handler = ar.recHandler.Resolve
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ar.serveIdempotent(w, r, handler)
+		return
+	}
+
+	id := strings.TrimPrefix(path, "/v1/reconciliation/")
+	if id == "" || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	r.SetPathValue("id", id)
+	ar.withAuth(http.HandlerFunc(ar.recHandler.Get)).ServeHTTP(w, r)
+}
+
+func (ar *apiRouter) serveMerchants(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path == "/v1/merchants" {
+		switch r.Method {
+		case http.MethodPost:
+			ar.serveIdempotent(w, r, http.HandlerFunc(ar.mHandler.Create))
+		case http.MethodGet:
+			ar.withAuth(http.HandlerFunc(ar.mHandler.List)).ServeHTTP(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id := strings.TrimPrefix(path, "/v1/merchants/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	r.SetPathValue("id", id)
+	switch r.Method {
+	case http.MethodGet:
+		ar.withAuth(http.HandlerFunc(ar.mHandler.GetByID)).ServeHTTP(w, r)
+	case http.MethodPatch, http.MethodPut:
+		ar.withAuth(http.HandlerFunc(ar.mHandler.Update)).ServeHTTP(w, r)
+	case http.MethodDelete:
+		ar.withAuth(http.HandlerFunc(ar.mHandler.Delete)).ServeHTTP(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func (ar *apiRouter) serveCreateTransaction(w http.ResponseWriter, r *http.Request) {
 	var createTxHandler http.Handler = http.HandlerFunc(ar.txHandler.Create)
 
@@ -105,6 +374,20 @@ func (ar *apiRouter) serveCreateTransaction(w http.ResponseWriter, r *http.Reque
 	createTxHandler.ServeHTTP(w, r)
 }
 
+func (ar *apiRouter) serveCreateRefund(w http.ResponseWriter, r *http.Request) {
+	ar.serveIdempotent(w, r, http.HandlerFunc(ar.rHandler.Create))
+}
+
+// serveIdempotent wraps a mutating handler with the idempotency store (when
+// configured) and authentication.
+func (ar *apiRouter) serveIdempotent(w http.ResponseWriter, r *http.Request, handler http.Handler) {
+	if ar.cfg.IdempotencyStore != nil {
+		handler = ar.cfg.IdempotencyStore.NewIdempotencyMiddleware(handler)
+	}
+
+	ar.withAuth(handler).ServeHTTP(w, r)
+}
+
 func (ar *apiRouter) serveListTransactions(w http.ResponseWriter, r *http.Request) {
 	var handler http.Handler = http.HandlerFunc(ar.txHandler.List)
 
@@ -149,12 +432,74 @@ func (ar *apiRouter) serveGetSettlement(w http.ResponseWriter, r *http.Request)
 	handler.ServeHTTP(w, r)
 }
 
+// withAuth wraps handler with JWT authentication when a JWT manager is configured.
+func (ar *apiRouter) withAuth(handler http.Handler) http.Handler {
+	if ar.cfg.JWTManager == nil {
+		return handler
+	}
+	authn := &middleware.Authenticator{JWT: ar.cfg.JWTManager}
+	return authn.NewAuthMiddleware(handler)
+}
+
+// subresource splits paths like "/v1/transactions/{id}/refunds" into the id
+// and the trailing sub-resource.
+func subresource(path, prefix string) (id, sub string, ok bool) {
+	rest, found := strings.CutPrefix(path, prefix)
+	if !found {
+		return "", "", false
+	}
+	id, sub, ok = strings.Cut(rest, "/")
+	return id, sub, ok && id != "" && sub != ""
+}
+
 func NewRouterWithConfig(cfg RouterConfig) http.Handler {
 	txHandler := handlers.NewTransactionHandler(cfg.TxService, cfg.Logger)
 	var sHandler *handlers.SettlementHandler
 	if cfg.SettlementSvc != nil {
 		sHandler = handlers.NewSettlementHandler(cfg.SettlementSvc, cfg.Logger)
 	}
+	var rHandler *handlers.RefundHandler
+	if cfg.RefundSvc != nil {
+		rHandler = handlers.NewRefundHandler(cfg.RefundSvc, cfg.Logger)
+	}
+	var azHandler *handlers.AuthorizationHandler
+	if cfg.AuthorizationSvc != nil {
+		azHandler = handlers.NewAuthorizationHandler(cfg.AuthorizationSvc, cfg.Logger)
+	}
+	var mHandler *handlers.MerchantHandler
+	if cfg.MerchantSvc != nil {
+		mHandler = handlers.NewMerchantHandler(cfg.MerchantSvc, cfg.Logger)
+	}
+
+	var whHandler *handlers.WebhookHandler
+	if cfg.WebhookSvc != nil {
+		whHandler = handlers.NewWebhookHandler(cfg.WebhookSvc, cfg.Logger)
+	}
+
+	var pHandler *handlers.PayoutHandler
+	if cfg.PayoutSvc != nil {
+		pHandler = handlers.NewPayoutHandler(cfg.PayoutSvc, cfg.Logger)
+	}
+
+	var exHandler *handlers.SettlementExportHandler
+	if cfg.ExportSvc != nil {
+		exHandler = handlers.NewSettlementExportHandler(cfg.ExportSvc, cfg.Logger)
+	}
+
+	var recHandler *handlers.ReconciliationHandler
+	if cfg.ReconciliationSvc != nil {
+		recHandler = handlers.NewReconciliationHandler(cfg.ReconciliationSvc, cfg.Logger)
+	}
+
+	var accHandler *handlers.AccountHandler
+	if cfg.AccountSvc != nil {
+		accHandler = handlers.NewAccountHandler(cfg.AccountSvc, cfg.Logger)
+	}
+
+	var dlHandler *handlers.DeadLetterHandler
+	if cfg.DeadLetterSvc != nil {
+		dlHandler = handlers.NewDeadLetterHandler(cfg.DeadLetterSvc, cfg.Logger)
+	}
 
 	oauthHandler := handlers.NewOAuthHandler(cfg.OAuthServer, cfg.Logger)
 	var authHandler *handlers.AuthHandler
@@ -163,10 +508,19 @@ func NewRouterWithConfig(cfg RouterConfig) http.Handler {
 	}
 
 	return &apiRouter{
-		cfg:       cfg,
-		txHandler: txHandler,
-		sHandler:  sHandler,
-		oauthH:    oauthHandler,
-		authH:     authHandler,
+		cfg:        cfg,
+		txHandler:  txHandler,
+		sHandler:   sHandler,
+		rHandler:   rHandler,
+		azHandler:  azHandler,
+		mHandler:   mHandler,
+		whHandler:  whHandler,
+		pHandler:   pHandler,
+		exHandler:  exHandler,
+		recHandler: recHandler,
+		accHandler: accHandler,
+		dlHandler:  dlHandler,
+		oauthH:     oauthHandler,
+		authH:      authHandler,
 	}
 }
@@ -0,0 +1,44 @@
+package bus
+
+import (
+	"context"
+	"strings"
+)
+
+// DLQPrefix is prepended to a topic to name its dead-letter topic.
+const DLQPrefix = "dlq."
+
+// DLQTopic returns the dead-letter topic for topic.
+func DLQTopic(topic string) string {
+	return DLQPrefix + topic
+}
+
+// OriginalTopic returns the topic a dead-letter topic collects messages
+// for, or topic itself when it is not a dead-letter topic.
+func OriginalTopic(topic string) string {
+	return strings.TrimPrefix(topic, DLQPrefix)
+}
+
+// DeadLetterInfo is what the transport knows about why a message handed to
+// a dead-letter subscriber was dead-lettered. Fields it does not know are
+// left empty.
+type DeadLetterInfo struct {
+	Topic      string // the topic the message was first published on
+	Reason     string // e.g. "rejected" or "expired"
+	Error      string // the last handler error
+	RetryCount int
+}
+
+type deadLetterKey struct{}
+
+// WithDeadLetterInfo returns a context carrying info for the handler.
+func WithDeadLetterInfo(ctx context.Context, info DeadLetterInfo) context.Context {
+	return context.WithValue(ctx, deadLetterKey{}, info)
+}
+
+// DeadLetterInfoFromContext returns the info a transport attached to a
+// dead-lettered message's context.
+func DeadLetterInfoFromContext(ctx context.Context) (DeadLetterInfo, bool) {
+	info, ok := ctx.Value(deadLetterKey{}).(DeadLetterInfo)
+	return info, ok
+}
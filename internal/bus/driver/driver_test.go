@@ -0,0 +1,38 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("BUS_DRIVER", "")
+	t.Setenv("RABBITMQ_URL", "")
+	t.Setenv("KAFKA_BROKERS", "")
+	cfg := ConfigFromEnv("settlement-worker")
+	if cfg.Driver != RabbitMQ || cfg.RabbitMQURL == "" || cfg.ClientID != "settlement-worker" {
+		t.Errorf("defaults = %+v", cfg)
+	}
+
+	t.Setenv("BUS_DRIVER", " Kafka ")
+	t.Setenv("KAFKA_BROKERS", "kafka-1:9092, kafka-2:9092,")
+	cfg = ConfigFromEnv("api")
+	if cfg.Driver != Kafka {
+		t.Errorf("driver = %q", cfg.Driver)
+	}
+	if want := []string{"kafka-1:9092", "kafka-2:9092"}; !reflect.DeepEqual(cfg.KafkaBrokers, want) {
+		t.Errorf("brokers = %v, want %v", cfg.KafkaBrokers, want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	b, err := Open(Config{Driver: Memory})
+	if err != nil || b == nil {
+		t.Fatalf("memory: %v, %v", b, err)
+	}
+	b.Close()
+
+	if b, err := Open(Config{Driver: "nats"}); err == nil || b != nil {
+		t.Errorf("unknown driver = %v, %v", b, err)
+	}
+}
@@ -0,0 +1,16 @@
+package bus
+
+import "time"
+
+// Health is the state of a transport's connection to its broker.
+type Health struct {
+	Connected  bool      `json:"connected"`
+	Since      time.Time `json:"since"` // when it last connected or lost the connection
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// HealthReporter is a transport that can report its connection state.
+type HealthReporter interface {
+	Health() Health
+}
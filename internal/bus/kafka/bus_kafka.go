@@ -0,0 +1,235 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/IBM/sarama"
+)
+
+// KafkaBus publishes with one idempotent producer and consumes each
+// subscription in a consumer group of its own client. Kafka has no
+// delayed delivery, so a failed message is produced to a retry topic per
+// attempt and consumed again once its delay is up; after the last attempt
+// it goes to the topic's DLQ topic. Offsets are committed only once a
+// message is handled or handed on, so a crash redelivers it.
+type KafkaBus struct {
+	brokers  []string
+	clientID string
+	producer sarama.SyncProducer
+
+	// ctx is cancelled by Close; wg tracks the consume loops
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	groups []sarama.ConsumerGroup
+	health bus.Health
+	closed bool
+}
+
+// Backoff between attempts to rejoin a consumer group after an error.
+const (
+	consumeBaseDelay = time.Second
+	consumeMaxDelay  = 30 * time.Second
+)
+
+// NewKafkaBus creates a new Kafka bus instance. clientID identifies the
+// service to the brokers and is the consumer group of subscriptions that
+// name none.
+func NewKafkaBus(brokers []string, clientID string) (*KafkaBus, error) {
+	producer, err := sarama.NewSyncProducer(brokers, newConfig(clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KafkaBus{
+		brokers:  brokers,
+		clientID: clientID,
+		producer: producer,
+		ctx:      ctx,
+		cancel:   cancel,
+		health:   bus.Health{Connected: true, Since: time.Now().UTC()},
+	}, nil
+}
+
+func newConfig(clientID string) *sarama.Config {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = clientID
+	cfg.Version = sarama.V3_6_0_0
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Retry.Max = 5
+	// No duplicates from the producer's own retries
+	cfg.Producer.Idempotent = true
+	cfg.Net.MaxOpenRequests = 1
+	// A group with no committed offset yet starts from the oldest message, so
+	// whatever was produced before its first subscription is not skipped
+	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	cfg.Consumer.Offsets.AutoCommit.Enable = false
+	return cfg
+}
+
+// Publish publishes a message to a topic and waits, bounded by ctx, until
+// every in-sync replica has it. Messages carry the ID bus.WithMessageID
+// asks for, or a new one.
+func (b *KafkaBus) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	return b.send(ctx, producerMessage(ctx, topic, key, payload))
+}
+
+func (b *KafkaBus) send(ctx context.Context, msg *sarama.ProducerMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := b.producer.SendMessage(msg)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		b.record(err)
+		if err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", msg.Topic, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for publish ack: %w", ctx.Err())
+	}
+}
+
+// Subscribe subscribes to a topic with a handler function, retrying failed
+// messages with bus.DefaultRetryPolicy
+func (b *KafkaBus) Subscribe(ctx context.Context, topic string, handler bus.HandlerFn) error {
+	return b.SubscribeWith(ctx, topic, bus.SubscribeOptions{}, handler)
+}
+
+// SubscribeWith subscribes to a topic with a handler function in the
+// consumer group opts.Group, or the bus's client ID. Consumers of a group
+// split the topic's partitions between them. Consuming stops when ctx is
+// done or the bus is closed.
+func (b *KafkaBus) SubscribeWith(ctx context.Context, topic string, opts bus.SubscribeOptions, handler bus.HandlerFn) error {
+	group := opts.Group
+	if group == "" {
+		group = b.clientID
+	}
+	policy := opts.Retry.Normalize()
+
+	cfg := newConfig(b.clientID)
+	if opts.Prefetch > 0 {
+		cfg.ChannelBufferSize = opts.Prefetch
+	}
+	cg, err := sarama.NewConsumerGroup(b.brokers, group, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to join consumer group %s: %w", group, err)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		cg.Close()
+		return errors.New("bus closed")
+	}
+	b.groups = append(b.groups, cg)
+	b.mu.Unlock()
+
+	h := &consumerHandler{bus: b, topic: topic, group: group, policy: policy, handler: handler}
+	topics := []string{topic}
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		topics = append(topics, retryTopic(group, topic, attempt))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(b.ctx, cancel)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer stop()
+		defer cancel()
+
+		delay := consumeBaseDelay
+		for ctx.Err() == nil {
+			// Returns at every rebalance; join again. A message that could
+			// not be handed on ends the session too: back off as after an
+			// error, or the group would rejoin and fail on it in a hot loop
+			err := cg.Consume(ctx, topics, h)
+			if claimErr := h.takeClaimErr(); err == nil {
+				err = claimErr
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("[%s] consume error, rejoining %s in %s: %v", topic, group, delay, err)
+				b.record(err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+				}
+				delay = min(delay*2, consumeMaxDelay)
+				continue
+			}
+			delay = consumeBaseDelay
+		}
+	}()
+
+	return nil
+}
+
+// record notes the outcome of talking to the brokers for Health.
+func (b *KafkaBus) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if !b.health.Connected {
+			b.health = bus.Health{Connected: true, Since: time.Now().UTC(), Reconnects: b.health.Reconnects + 1}
+		}
+		return
+	}
+	if b.health.Connected {
+		b.health.Connected = false
+		b.health.Since = time.Now().UTC()
+	}
+	b.health.LastError = err.Error()
+}
+
+// Health reports whether the last exchange with the brokers succeeded.
+func (b *KafkaBus) Health() bus.Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.health
+}
+
+// Close stops consuming, waits for the messages being handled to finish
+// and commit, and closes the consumer groups and the producer.
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	groups := b.groups
+	b.mu.Unlock()
+
+	b.cancel()
+	b.wg.Wait()
+
+	var errs []error
+	for _, cg := range groups {
+		errs = append(errs, cg.Close())
+	}
+	errs = append(errs, b.producer.Close())
+	return errors.Join(errs...)
+}
@@ -2,60 +2,181 @@ package kafka
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/BjornOnGit/payment-gateway/internal/bus"
 	"github.com/IBM/sarama"
 )
 
-type KafkaConsumer struct {
-	group sarama.ConsumerGroup
-}
-
-func NewKafkaConsumer(brokers []string, groupID string) (*KafkaConsumer, error) {
-	cfg := sarama.NewConfig()
-	cfg.Version = sarama.V3_6_0_0
-	cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+// Headers set on messages this package publishes, retries or
+// dead-letters. The dead-letter ones match the RabbitMQ bus's so the DLQ
+// monitor reads both alike.
+const (
+	headerMessageID     = "message-id"
+	headerAttempt       = "x-attempt"
+	headerNotBefore     = "x-not-before" // unix milliseconds
+	headerOriginalTopic = "x-original-topic"
+	headerError         = "x-error"
+	headerRetryCount    = "x-retry-count"
+)
 
-	group, err := sarama.NewConsumerGroup(brokers, groupID, cfg)
-	if err != nil {
-		return nil, err
-	}
-	return &KafkaConsumer{group: group}, nil
-}
+// forwardTimeout bounds producing a failed message to a retry or DLQ
+// topic before its offset is committed.
+const forwardTimeout = 30 * time.Second
 
-func (kc *KafkaConsumer) Subscribe(ctx context.Context, topic string, handler bus.HandlerFn) error {
-	go func() {
-		for {
-			err := kc.group.Consume(context.Background(), []string{topic}, &consumerHandler{handler})
-			if err != nil {
-				log.Println("Kafka consume error:", err)
-			}
-		}
-	}()
-	return nil
+// retryTopic is where a group parks a topic's messages before the given
+// retry, e.g. settlement-worker.settlement.requested.retry.1.
+func retryTopic(group, topic string, retry int) string {
+	return fmt.Sprintf("%s.%s.retry.%d", group, topic, retry)
 }
 
+// consumerHandler handles the messages of one subscription, from its topic
+// and its retry topics.
 type consumerHandler struct {
+	bus     *KafkaBus
+	topic   string
+	group   string
+	policy  bus.RetryPolicy
 	handler bus.HandlerFn
+
+	// claimErr is why a claim ended its session, if it did
+	mu       sync.Mutex
+	claimErr error
 }
 
 func (h *consumerHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
 func (h *consumerHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 
 func (h *consumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		ctx := context.Background()
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if err := h.handle(sess, msg); err != nil {
+				// Uncommitted: the next session of the group starts from it
+				h.mu.Lock()
+				h.claimErr = err
+				h.mu.Unlock()
+				return err
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
 
-		// Extract key from Kafka message (convert []byte to string)
-		key := string(msg.Key)
-		topic := msg.Topic
+// takeClaimErr returns and clears the error a claim ended the last session
+// with. Sarama only logs it, and Consume returns nil.
+func (h *consumerHandler) takeClaimErr() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.claimErr
+	h.claimErr = nil
+	return err
+}
 
-		if err := h.handler(ctx, topic, key, msg.Value); err != nil {
-			// No auto-retry — leave record uncommitted
-			continue
+// handle runs the handler on msg and commits its offset once it succeeded
+// or the message was handed to a retry or DLQ topic.
+func (h *consumerHandler) handle(sess sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) error {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, rh := range msg.Headers {
+		headers[string(rh.Key)] = string(rh.Value)
+	}
+
+	// A retried message waits out its delay, holding up its partition.
+	// Messages in one retry topic all wait about as long, so one is held up
+	// by at most the jitter of one ahead.
+	if ms, err := strconv.ParseInt(headers[headerNotBefore], 10, 64); err == nil {
+		if wait := time.Until(time.UnixMilli(ms)); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-sess.Context().Done():
+				t.Stop()
+				return nil
+			}
 		}
-		sess.MarkMessage(msg, "")
 	}
+
+	attempt := 1
+	if n, err := strconv.Atoi(headers[headerAttempt]); err == nil && n > 0 {
+		attempt = n
+	}
+	key := string(msg.Key)
+
+	// Create a new context for each message, so a handler finishes even
+	// while the bus shuts down
+	msgCtx := bus.WithAttempt(context.Background(), attempt)
+	if id := headers[headerMessageID]; id != "" {
+		msgCtx = bus.WithMessageInfo(msgCtx, bus.MessageInfo{ID: id, PublishedAt: msg.Timestamp})
+	}
+	if strings.HasPrefix(h.topic, bus.DLQPrefix) {
+		msgCtx = bus.WithDeadLetterInfo(msgCtx, deadLetterInfo(h.topic, headers))
+	}
+
+	if err := h.handler(msgCtx, h.topic, key, msg.Value); err != nil {
+		if attempt >= h.policy.MaxAttempts {
+			log.Printf("[%s] giving up after %d attempts, sending to DLQ: %v", h.topic, attempt, err)
+			// Its DLQ subscriber starts counting attempts afresh
+			delete(headers, headerAttempt)
+			delete(headers, headerNotBefore)
+			headers[headerOriginalTopic] = h.topic
+			headers[headerError] = err.Error()
+			headers[headerRetryCount] = strconv.Itoa(attempt - 1)
+			if pubErr := h.forward(bus.DLQTopic(h.topic), msg, headers); pubErr != nil {
+				return fmt.Errorf("failed to publish to DLQ: %w", pubErr)
+			}
+		} else {
+			delay := h.policy.Delay(attempt)
+			log.Printf("[%s] handler error (attempt %d/%d), retrying in %s: %v", h.topic, attempt, h.policy.MaxAttempts, delay, err)
+			headers[headerAttempt] = strconv.Itoa(attempt + 1)
+			headers[headerNotBefore] = strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)
+			if pubErr := h.forward(retryTopic(h.group, h.topic, attempt), msg, headers); pubErr != nil {
+				return fmt.Errorf("failed to schedule retry: %w", pubErr)
+			}
+		}
+	}
+
+	sess.MarkMessage(msg, "")
+	sess.Commit()
 	return nil
 }
+
+// forward produces msg to topic with headers, keeping its key and
+// timestamp. It is not cut short by the session ending, so a message being
+// handed on at shutdown still is.
+func (h *consumerHandler) forward(topic string, msg *sarama.ConsumerMessage, headers map[string]string) error {
+	out := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(msg.Value),
+		Timestamp: msg.Timestamp,
+	}
+	if len(msg.Key) > 0 {
+		out.Key = sarama.ByteEncoder(msg.Key)
+	}
+	for k, v := range headers {
+		out.Headers = append(out.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+	defer cancel()
+	return h.bus.send(ctx, out)
+}
+
+// deadLetterInfo reads why a message arriving on a DLQ topic was
+// dead-lettered from the headers handle sets.
+func deadLetterInfo(topic string, headers map[string]string) bus.DeadLetterInfo {
+	info := bus.DeadLetterInfo{Topic: bus.OriginalTopic(topic), Error: headers[headerError]}
+	if t := headers[headerOriginalTopic]; t != "" {
+		info.Topic = t
+		info.Reason = "rejected"
+	}
+	info.RetryCount, _ = strconv.Atoi(headers[headerRetryCount])
+	return info
+}
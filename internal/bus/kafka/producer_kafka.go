@@ -1,35 +1,47 @@
 package kafka
 
 import (
-    "context"
-    "github.com/IBM/sarama"
+	"context"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
 )
 
 type KafkaProducer struct {
-    producer sarama.SyncProducer
+	producer sarama.SyncProducer
 }
 
 func NewKafkaProducer(brokers []string) (*KafkaProducer, error) {
-    cfg := sarama.NewConfig()
-    cfg.Producer.Return.Successes = true
-    cfg.Producer.Return.Errors = true
-    cfg.Producer.RequiredAcks = sarama.WaitForAll
-    cfg.Producer.Retry.Max = 5
-
-    p, err := sarama.NewSyncProducer(brokers, cfg)
-    if err != nil {
-        return nil, err
-    }
+	p, err := sarama.NewSyncProducer(brokers, newConfig("payment-gateway"))
+	if err != nil {
+		return nil, err
+	}
 
-    return &KafkaProducer{producer: p}, nil
+	return &KafkaProducer{producer: p}, nil
 }
 
 func (kp *KafkaProducer) Publish(ctx context.Context, topic, key string, payload []byte) error {
-    msg := &sarama.ProducerMessage{
-        Topic: topic,
-        Key:   sarama.StringEncoder(key),
-        Value: sarama.ByteEncoder(payload),
-    }
-    _, _, err := kp.producer.SendMessage(msg)
-    return err
+	_, _, err := kp.producer.SendMessage(producerMessage(ctx, topic, key, payload))
+	return err
+}
+
+// producerMessage builds the message Publish sends: keyed when key is set,
+// with an ID (the one ctx asks for, or a new one) and a timestamp.
+func producerMessage(ctx context.Context, topic, key string, payload []byte) *sarama.ProducerMessage {
+	id := bus.MessageIDFromContext(ctx)
+	if id == "" {
+		id = uuid.NewString()
+	}
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(payload),
+		Headers:   []sarama.RecordHeader{{Key: []byte(headerMessageID), Value: []byte(id)}},
+		Timestamp: time.Now().UTC(),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	return msg
 }
@@ -0,0 +1,50 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnroutable is returned by Publish when the transport knows no
+// subscriber queue will receive the message.
+var ErrUnroutable = errors.New("bus: message unroutable")
+
+// MessageInfo identifies a delivered message. A message keeps its ID and
+// publish time across redeliveries and retries, so handlers can dedupe on
+// the ID.
+type MessageInfo struct {
+	ID          string
+	PublishedAt time.Time
+}
+
+type messageInfoKey struct{}
+
+// WithMessageInfo returns a context carrying info for the handler.
+func WithMessageInfo(ctx context.Context, info MessageInfo) context.Context {
+	return context.WithValue(ctx, messageInfoKey{}, info)
+}
+
+// MessageInfoFromContext returns the info a transport attached to a
+// delivered message's context.
+func MessageInfoFromContext(ctx context.Context) (MessageInfo, bool) {
+	info, ok := ctx.Value(messageInfoKey{}).(MessageInfo)
+	return info, ok
+}
+
+type messageIDKey struct{}
+
+// WithMessageID returns a context asking Publish to give the message id
+// rather than a new one, e.g. so that publishing a message again after a
+// crash yields a duplicate consumers can recognise. It is separate from
+// the delivered message's info so that a handler publishing with its own
+// context does not pass the ID on.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, id)
+}
+
+// MessageIDFromContext returns the ID WithMessageID asked for, or "".
+func MessageIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(messageIDKey{}).(string)
+	return id
+}
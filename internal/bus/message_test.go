@@ -0,0 +1,30 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessageContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := MessageInfoFromContext(ctx); ok {
+		t.Error("info without WithMessageInfo")
+	}
+	if id := MessageIDFromContext(ctx); id != "" {
+		t.Errorf("id without WithMessageID = %q", id)
+	}
+
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	ctx = WithMessageInfo(ctx, MessageInfo{ID: "m1", PublishedAt: at})
+	if info, ok := MessageInfoFromContext(ctx); !ok || info.ID != "m1" || !info.PublishedAt.Equal(at) {
+		t.Errorf("info = %+v, %v", info, ok)
+	}
+	// A handler publishing with its delivery's context gets a new ID
+	if id := MessageIDFromContext(ctx); id != "" {
+		t.Errorf("delivered message's ID leaked into publishing: %q", id)
+	}
+	if id := MessageIDFromContext(WithMessageID(ctx, "outbox-1")); id != "outbox-1" {
+		t.Errorf("id = %q", id)
+	}
+}
@@ -4,41 +4,79 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// RabbitMQBus publishes and consumes on separate channels of one
+// connection. When the connection or either channel is lost it reconnects
+// in the background and resubscribes every handler (see reconnect.go).
 type RabbitMQBus struct {
-	conn *amqp.Connection
-	ch   *amqp.Channel
-	url  string
+	url string
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	pub    *confirmer
+	subCh  *amqp.Channel
+	health bus.Health
+	closed bool
+	done   chan struct{}
+
+	// subMu serialises subscribing, including resubscribing after a
+	// reconnect, and guards subs
+	subMu sync.Mutex
+	subs  []subscription
 }
 
-// NewRabbitMQBus creates a new RabbitMQ bus instance
+// subscription is a registered handler, kept to resubscribe it after a
+// reconnect.
+type subscription struct {
+	topic   string
+	opts    bus.SubscribeOptions
+	handler bus.HandlerFn
+}
+
+// defaultPrefetch is how many unacknowledged messages a subscriber holds
+// when its SubscribeOptions do not say. Bounding it spreads a backlog
+// across the consumers of a group instead of the first to connect.
+const defaultPrefetch = 10
+
+// NewRabbitMQBus creates a new RabbitMQ bus instance. The first connection
+// must succeed; later ones are retried until Close.
 func NewRabbitMQBus(url string) (*RabbitMQBus, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	b := &RabbitMQBus{
+		url:  url,
+		done: make(chan struct{}),
 	}
-
-	ch, err := conn.Channel()
+	l, err := dial(url)
 	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to create channel: %w", err)
+		return nil, err
 	}
+	b.use(l)
+	b.health = bus.Health{Connected: true, Since: time.Now().UTC()}
 
-	return &RabbitMQBus{
-		conn: conn,
-		ch:   ch,
-		url:  url,
-	}, nil
+	go b.supervise(l.lost)
+	return b, nil
 }
 
-// Publish publishes a message to a topic
+// Publish publishes a message to a topic and waits, bounded by ctx, for the
+// broker to confirm it. A message no queue is bound to receive is an
+// ErrUnroutable error. Messages carry the ID bus.WithMessageID asks for, or
+// a new one.
 func (b *RabbitMQBus) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	pub, err := b.publisher()
+	if err != nil {
+		return err
+	}
+
 	// Declare the exchange (topic-based)
-	err := b.ch.ExchangeDeclare(
+	err = pub.ch.ExchangeDeclare(
 		topic,   // name
 		"topic", // kind
 		true,    // durable
@@ -58,28 +96,53 @@ func (b *RabbitMQBus) Publish(ctx context.Context, topic, key string, payload []
 		routingKey = topic
 	}
 
-	err = b.ch.PublishWithContext(
-		ctx,
-		topic,      // exchange
-		routingKey, // routing key
-		false,      // mandatory
-		false,      // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        payload,
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+	// Mandatory and confirmed: returns once the broker has the message,
+	// persistent, in at least one queue
+	if err := pub.publish(ctx, topic, routingKey, publishing(ctx, payload)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
 	}
-
 	return nil
 }
 
-// Subscribe subscribes to a topic with a handler function
+// Subscribe subscribes to a topic with a handler function, retrying failed
+// messages with bus.DefaultRetryPolicy
 func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.HandlerFn) error {
+	return b.SubscribeWith(ctx, topic, bus.SubscribeOptions{}, handler)
+}
+
+// SubscribeWith subscribes to a topic with a handler function. A message
+// whose handler fails is parked in a wait queue for the policy's delay and
+// then redelivered; after opts.Retry.MaxAttempts deliveries it goes to the
+// topic's DLQ. Subscribers with the same opts.Group consume one durable
+// queue, <group>.<topic>, and each message goes to one of them.
+func (b *RabbitMQBus) SubscribeWith(ctx context.Context, topic string, opts bus.SubscribeOptions, handler bus.HandlerFn) error {
+	sub := subscription{topic: topic, opts: opts, handler: handler}
+
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	b.mu.RLock()
+	ch := b.subCh
+	b.mu.RUnlock()
+	if ch == nil {
+		return ErrNotConnected
+	}
+
+	if err := b.consume(ch, sub); err != nil {
+		return err
+	}
+	b.subs = append(b.subs, sub)
+	return nil
+}
+
+// consume declares a subscription's topology on ch and starts handling its
+// messages. The caller holds subMu.
+func (b *RabbitMQBus) consume(ch *amqp.Channel, sub subscription) error {
+	topic, opts, handler := sub.topic, sub.opts, sub.handler
+	policy := opts.Retry.Normalize()
+
 	// Declare the exchange
-	err := b.ch.ExchangeDeclare(
+	err := ch.ExchangeDeclare(
 		topic,   // name
 		"topic", // kind
 		true,    // durable
@@ -94,7 +157,7 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 
 	// Declare DLQ exchange for dead letters
 	dlqExchange := "dlq." + topic
-	err = b.ch.ExchangeDeclare(
+	err = ch.ExchangeDeclare(
 		dlqExchange, // name
 		"topic",     // kind
 		true,        // durable
@@ -108,7 +171,7 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 	}
 
 	// Declare DLQ queue
-	dlqQueue, err := b.ch.QueueDeclare(
+	dlqQueue, err := ch.QueueDeclare(
 		dlqExchange, // name - use exchange name for DLQ queue
 		true,        // durable
 		false,       // delete when unused
@@ -121,7 +184,7 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 	}
 
 	// Bind DLQ queue to DLQ exchange
-	err = b.ch.QueueBind(
+	err = ch.QueueBind(
 		dlqQueue.Name, // queue name
 		"#",           // routing key
 		dlqExchange,   // exchange
@@ -132,24 +195,35 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 		return fmt.Errorf("failed to bind DLQ queue: %w", err)
 	}
 
-	// Declare a queue unique to this consumer with DLQ configuration
-	q, err := b.ch.QueueDeclare(
-		"",    // name - empty string means auto-generate a unique queue name
-		false, // durable
-		true,  // delete when unused
-		true,  // exclusive - only this connection can use it
-		false, // no-wait
-		amqp.Table{
-			"x-dead-letter-exchange": dlqExchange, // Send rejected messages to DLQ
-			"x-message-ttl":          300000,      // 5 minutes TTL for retry messages
-		}, // arguments
+	// Declare the consumer queue with DLQ configuration. A consumer group
+	// shares a durable queue named after it, which keeps messages for the
+	// group while none of its consumers is running. Otherwise the queue is
+	// unique to this consumer; it is named here rather than by the server
+	// because its wait queues are named after it, and names starting with
+	// "amq." are reserved.
+	name, durable := topic+"."+uuid.NewString(), false
+	args := amqp.Table{
+		"x-dead-letter-exchange": dlqExchange, // Send rejected messages to DLQ
+		"x-message-ttl":          300000,      // 5 minutes TTL for unconsumed messages
+	}
+	if opts.Group != "" {
+		name, durable = opts.Group+"."+topic, true
+		delete(args, "x-message-ttl")
+	}
+	q, err := ch.QueueDeclare(
+		name,     // name
+		durable,  // durable
+		!durable, // delete when unused
+		!durable, // exclusive - only this connection can use it
+		false,    // no-wait
+		args,     // arguments
 	)
 	if err != nil {
 		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
 	// Bind the queue to the exchange using wildcard binding (match all routing keys)
-	err = b.ch.QueueBind(
+	err = ch.QueueBind(
 		q.Name, // queue name
 		"#",    // routing key - # matches all routing keys in topic exchange
 		topic,  // exchange
@@ -160,8 +234,19 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 		return fmt.Errorf("failed to bind queue: %w", err)
 	}
 
+	prefetch := opts.Prefetch
+	if prefetch <= 0 {
+		prefetch = defaultPrefetch
+	}
+
+	// Qos applies to the consumers started on the channel after it, which
+	// is why subscribing is serialised
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
 	// Start consuming messages
-	msgs, err := b.ch.Consume(
+	msgs, err := ch.Consume(
 		q.Name, // queue
 		"",     // consumer
 		false,  // auto-ack
@@ -177,40 +262,45 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 	// Start goroutine to handle messages
 	go func() {
 		for m := range msgs {
+			attempt := deliveryAttempt(m.Headers)
+			key := deliveryKey(m)
+
 			// Create a new context for each message (not tied to HTTP context)
-			msgCtx := context.Background()
-
-			// Track retry count from x-death header
-			retryCount := 0
-			if m.Headers != nil {
-				if xDeath, ok := m.Headers["x-death"].([]interface{}); ok && len(xDeath) > 0 {
-					if death, ok := xDeath[0].(amqp.Table); ok {
-						if count, ok := death["count"].(int64); ok {
-							retryCount = int(count)
-						}
-					}
-				}
+			msgCtx := bus.WithAttempt(context.Background(), attempt)
+			if m.MessageId != "" {
+				msgCtx = bus.WithMessageInfo(msgCtx, bus.MessageInfo{ID: m.MessageId, PublishedAt: m.Timestamp})
+			}
+			if strings.HasPrefix(topic, bus.DLQPrefix) {
+				msgCtx = bus.WithDeadLetterInfo(msgCtx, deadLetterInfo(topic, m.Headers))
+			}
+
+			err := handler(msgCtx, topic, key, m.Body)
+			if err == nil {
+				// ACK the message on success
+				m.Ack(false)
+				continue
 			}
 
-			// Store retry count in context for handler to use
-			msgCtx = context.WithValue(msgCtx, "retry_count", retryCount)
-
-			// RabbitMQ routing key is used as the key parameter
-			if err := handler(msgCtx, topic, m.RoutingKey, m.Body); err != nil {
-				// Check if we've exceeded max retries (3 attempts)
-				if retryCount >= 3 {
-					// Send to DLQ by rejecting without requeue
-					log.Printf("[%s] max retries exceeded (%d), sending to DLQ: %v", topic, retryCount, err)
-					m.Nack(false, false) // don't requeue, will go to DLQ
-				} else {
-					// Requeue for retry
-					log.Printf("[%s] handler error (retry %d/3): %v", topic, retryCount, err)
-					m.Nack(false, true) // requeue for retry
+			if attempt >= policy.MaxAttempts {
+				log.Printf("[%s] giving up after %d attempts, sending to DLQ: %v", topic, attempt, err)
+				if pubErr := b.publishDeadLetter(topic, m, key, attempt-1, err); pubErr != nil {
+					// Fall back to the queue's dead-letter exchange, without the error
+					log.Printf("[%s] failed to publish to DLQ: %v", topic, pubErr)
+					m.Nack(false, false)
+					continue
 				}
+				m.Ack(false)
 				continue
 			}
 
-			// ACK the message on success
+			delay := policy.Delay(attempt)
+			log.Printf("[%s] handler error (attempt %d/%d), retrying in %s: %v", topic, attempt, policy.MaxAttempts, delay, err)
+			if pubErr := b.scheduleRetry(q.Name, durable, m, key, attempt, delay, policy); pubErr != nil {
+				// Redeliver now rather than lose the message
+				log.Printf("[%s] failed to schedule retry: %v", topic, pubErr)
+				m.Nack(false, true)
+				continue
+			}
 			m.Ack(false)
 		}
 	}()
@@ -218,13 +308,152 @@ func (b *RabbitMQBus) Subscribe(ctx context.Context, topic string, handler bus.H
 	return nil
 }
 
-// Close closes the RabbitMQ connection
-func (b *RabbitMQBus) Close() error {
-	if b.ch != nil {
-		b.ch.Close()
+// republishTimeout bounds the wait for the broker to confirm a retry or a
+// dead letter before the delivery it replaces is acked.
+const republishTimeout = 30 * time.Second
+
+// waitQueueGrace is how long a wait queue outlives the longest delay of the
+// messages parked in it, so it is gone soon after its consumer is.
+const waitQueueGrace = 10 * time.Minute
+
+// scheduleRetry parks a failed message in the wait queue for its attempt,
+// which dead-letters it back to queue once delay expires. The wait queue
+// routes through the default exchange rather than the topic exchange so
+// that only the queue whose handler failed sees the message again.
+//
+// Messages in one wait queue all wait about as long, so a message with a
+// shorter jittered delay is held up by at most the jitter of one ahead.
+func (b *RabbitMQBus) scheduleRetry(queue string, durable bool, m amqp.Delivery, key string, attempt int, delay time.Duration, policy bus.RetryPolicy) error {
+	pub, err := b.publisher()
+	if err != nil {
+		return err
+	}
+
+	// Declared on every retry: its arguments never change and redeclaring
+	// resets its expiry
+	wait, err := pub.ch.QueueDeclare(
+		fmt.Sprintf("%s.retry.%d", queue, attempt), // name
+		durable, // durable - as the queue it feeds
+		false,   // delete when unused
+		false,   // exclusive
+		false,   // no-wait
+		amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+			"x-expires":                 (policy.MaxDelay(attempt) + waitQueueGrace).Milliseconds(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare wait queue: %w", err)
+	}
+
+	headers := amqp.Table{}
+	for k, v := range m.Headers {
+		headers[k] = v
 	}
-	if b.conn != nil {
-		return b.conn.Close()
+	headers[headerAttempt] = int64(attempt + 1)
+	headers[headerRoutingKey] = key
+
+	ctx, cancel := context.WithTimeout(context.Background(), republishTimeout)
+	defer cancel()
+	return pub.publish(ctx, "", wait.Name, amqp.Publishing{
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.MessageId,
+		Timestamp:    m.Timestamp,
+		Headers:      headers,
+		Expiration:   strconv.FormatInt(max(delay.Milliseconds(), 1), 10),
+		Body:         m.Body,
+	})
+}
+
+// deliveryAttempt returns which delivery of a message this is, 1 for the
+// first.
+func deliveryAttempt(headers amqp.Table) int {
+	switch n := headers[headerAttempt].(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
 	}
-	return nil
+	return 1
+}
+
+// deliveryKey returns the key a message was published with. A retried
+// message arrives routed by queue name, so its key travels in a header.
+func deliveryKey(m amqp.Delivery) string {
+	if k, ok := m.Headers[headerRoutingKey].(string); ok {
+		return k
+	}
+	return m.RoutingKey
+}
+
+// publishDeadLetter sends a message that exhausted its retries to the
+// topic's DLQ exchange, recording the handler error in its headers.
+func (b *RabbitMQBus) publishDeadLetter(topic string, m amqp.Delivery, key string, retryCount int, handlerErr error) error {
+	headers := amqp.Table{}
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	// Its DLQ subscriber starts counting attempts afresh
+	delete(headers, headerAttempt)
+	headers[headerOriginalTopic] = topic
+	headers[headerError] = handlerErr.Error()
+	headers[headerRetryCount] = int64(retryCount)
+
+	pub, err := b.publisher()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), republishTimeout)
+	defer cancel()
+	return pub.publish(ctx, bus.DLQTopic(topic), key, amqp.Publishing{
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.MessageId,
+		Timestamp:    m.Timestamp,
+		Headers:      headers,
+		Body:         m.Body,
+	})
+}
+
+// Headers set on messages this bus retries or dead-letters itself.
+const (
+	headerOriginalTopic = "x-original-topic"
+	headerError         = "x-error"
+	headerRetryCount    = "x-retry-count"
+	headerAttempt       = "x-attempt"
+	headerRoutingKey    = "x-routing-key"
+)
+
+// deadLetterInfo reads why a message arriving on a DLQ topic was
+// dead-lettered: from the headers publishDeadLetter sets or, for messages
+// RabbitMQ dead-lettered itself (e.g. on TTL expiry), from x-death.
+func deadLetterInfo(topic string, headers amqp.Table) bus.DeadLetterInfo {
+	info := bus.DeadLetterInfo{Topic: bus.OriginalTopic(topic)}
+	if t, ok := headers[headerOriginalTopic].(string); ok && t != "" {
+		info.Topic = t
+		info.Reason = "rejected"
+	}
+	if e, ok := headers[headerError].(string); ok {
+		info.Error = e
+	}
+	switch n := headers[headerRetryCount].(type) {
+	case int64:
+		info.RetryCount = int(n)
+	case int32:
+		info.RetryCount = int(n)
+	}
+
+	if xDeath, ok := headers["x-death"].([]interface{}); ok && len(xDeath) > 0 {
+		if death, ok := xDeath[0].(amqp.Table); ok {
+			if reason, ok := death["reason"].(string); ok && info.Reason == "" {
+				info.Reason = reason
+			}
+			if count, ok := death["count"].(int64); ok && info.RetryCount == 0 {
+				info.RetryCount = int(count)
+			}
+		}
+	}
+	return info
 }
@@ -0,0 +1,111 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	// ErrNacked is returned when the broker refuses to take a message.
+	ErrNacked = errors.New("rabbitmq: message nacked by broker")
+	// ErrUnroutable is returned when no queue is bound to receive a message.
+	ErrUnroutable = bus.ErrUnroutable
+)
+
+// confirmer publishes on a channel in confirm mode and waits for the
+// broker to take each message. Messages are published mandatory, so one
+// that no queue receives comes back as unroutable instead of vanishing.
+//
+// Publishes are serialised: with one message in flight a return can only
+// belong to it. The broker sends a message's return before its ack and the
+// client delivers them in order, so while the return channel is unread the
+// ack cannot arrive.
+type confirmer struct {
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
+func newConfirmer(ch *amqp.Channel) (*confirmer, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return &confirmer{ch: ch, returns: ch.NotifyReturn(make(chan amqp.Return))}, nil
+}
+
+// publish sends msg and waits, bounded by ctx, until the broker confirms
+// it.
+func (c *confirmer) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	c.mu.Lock()
+	dc, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, true, false, msg)
+	if err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	select {
+	case r, ok := <-c.returns:
+		if ok {
+			// The ack follows; wait for it so the next publish starts clean
+			dc.Wait()
+		}
+		c.mu.Unlock()
+		if !ok {
+			return ErrNotConnected
+		}
+		return fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, exchange, r.ReplyCode, r.ReplyText)
+	case <-dc.Done():
+		c.mu.Unlock()
+		if dc.Acked() {
+			return nil
+		}
+		if c.ch.IsClosed() {
+			// Outstanding confirms are failed when the channel closes
+			return ErrNotConnected
+		}
+		return ErrNacked
+	case <-ctx.Done():
+		// Keep reading until the broker answers, or the client would block
+		// on delivering a return nobody reads
+		go func() {
+			c.settle(dc)
+			c.mu.Unlock()
+		}()
+		return fmt.Errorf("waiting for publish confirm: %w", ctx.Err())
+	}
+}
+
+// settle waits out a publish its caller stopped waiting for.
+func (c *confirmer) settle(dc *amqp.DeferredConfirmation) {
+	select {
+	case _, ok := <-c.returns:
+		if ok {
+			dc.Wait()
+		}
+	case <-dc.Done():
+	}
+}
+
+// publishing returns the properties every message this package publishes
+// carries: persistent, with an ID (the one ctx asks for, or a new one) and
+// a timestamp.
+func publishing(ctx context.Context, payload []byte) amqp.Publishing {
+	id := bus.MessageIDFromContext(ctx)
+	if id == "" {
+		id = uuid.NewString()
+	}
+	return amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    id,
+		Timestamp:    time.Now().UTC(),
+		Body:         payload,
+	}
+}
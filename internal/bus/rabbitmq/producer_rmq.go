@@ -1,35 +1,38 @@
 package rabbitmq
 
 import (
-    "context"
+	"context"
 
-    amqp "github.com/rabbitmq/amqp091-go"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type RabbitProducer struct {
-    ch *amqp.Channel
+	pub *confirmer
 }
 
 func NewRabbitProducer(url string) (*RabbitProducer, error) {
-    conn, err := amqp.Dial(url)
-    if err != nil {
-        return nil, err
-    }
-    ch, err := conn.Channel()
-    if err != nil {
-        return nil, err
-    }
-    return &RabbitProducer{ch: ch}, nil
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	pub, err := newConfirmer(ch)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &RabbitProducer{pub: pub}, nil
 }
 
+// Publish publishes to an existing exchange and waits, bounded by ctx, for
+// the broker to confirm the message. See RabbitMQBus.Publish.
 func (p *RabbitProducer) Publish(ctx context.Context, topic, key string, payload []byte) error {
-    return p.ch.PublishWithContext(ctx,
-        topic, // Exchange
-        key,   // Routing key
-        false, false,
-        amqp.Publishing{
-            ContentType: "application/json",
-            Body:        payload,
-        },
-    )
+	return p.pub.publish(ctx,
+		topic, // Exchange
+		key,   // Routing key
+		publishing(ctx, payload),
+	)
 }
@@ -0,0 +1,213 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConnected is returned while the bus is reconnecting and after it
+// is closed.
+var ErrNotConnected = errors.New("rabbitmq: not connected")
+
+// Backoff between reconnect attempts.
+const (
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+)
+
+// link is one connection with its publish and consume channels. lost
+// receives once when any of the three closes.
+type link struct {
+	conn *amqp.Connection
+	pub  *confirmer
+	sub  *amqp.Channel
+	lost chan *amqp.Error
+}
+
+func dial(url string) (*link, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	pub, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+	confirms, err := newConfirmer(pub)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sub, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	l := &link{conn: conn, pub: confirms, sub: sub, lost: make(chan *amqp.Error, 1)}
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	pubClosed := pub.NotifyClose(make(chan *amqp.Error, 1))
+	subClosed := sub.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		// The error is nil when the close was ours
+		var err *amqp.Error
+		select {
+		case err = <-connClosed:
+		case err = <-pubClosed:
+		case err = <-subClosed:
+		}
+		l.lost <- err
+	}()
+	return l, nil
+}
+
+// use makes l the bus's current connection. The caller holds b.mu.
+func (b *RabbitMQBus) use(l *link) {
+	if l == nil {
+		b.conn, b.pub, b.subCh = nil, nil, nil
+		return
+	}
+	b.conn, b.pub, b.subCh = l.conn, l.pub, l.sub
+}
+
+// supervise waits for the current connection to be lost, then reconnects,
+// until the bus is closed.
+func (b *RabbitMQBus) supervise(lost <-chan *amqp.Error) {
+	for {
+		var amqpErr *amqp.Error
+		select {
+		case <-b.done:
+			return
+		case amqpErr = <-lost:
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		// Losing one channel leaves the connection open; drop all of it
+		conn := b.conn
+		b.use(nil)
+		reason := "connection closed"
+		if amqpErr != nil {
+			reason = amqpErr.Error()
+		}
+		b.health = bus.Health{Since: time.Now().UTC(), Reconnects: b.health.Reconnects, LastError: reason}
+		b.mu.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+
+		log.Printf("[rabbitmq] connection lost, reconnecting: %s", reason)
+		if lost = b.reconnect(); lost == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials with backoff until it has connected and resubscribed
+// every handler. It returns nil if the bus is closed first.
+func (b *RabbitMQBus) reconnect() <-chan *amqp.Error {
+	delay := reconnectBaseDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-b.done:
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+
+		l, err := dial(b.url)
+		if err == nil {
+			var ok bool
+			if ok, err = b.resume(l); err == nil && !ok {
+				return nil
+			}
+		}
+		if err != nil {
+			log.Printf("[rabbitmq] reconnect attempt %d failed: %v", attempt, err)
+			b.mu.Lock()
+			b.health.LastError = err.Error()
+			b.mu.Unlock()
+			continue
+		}
+
+		log.Printf("[rabbitmq] reconnected after %d attempts, %d subscriptions restored", attempt, b.subscriptions())
+		return l.lost
+	}
+}
+
+// resume redeclares the topology of every subscription on l, restarts its
+// consumer and makes l current. It reports false if the bus was closed
+// meanwhile.
+func (b *RabbitMQBus) resume(l *link) (bool, error) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	for _, sub := range b.subs {
+		if err := b.consume(l.sub, sub); err != nil {
+			l.conn.Close()
+			return false, fmt.Errorf("failed to resubscribe to %s: %w", sub.topic, err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		l.conn.Close()
+		return false, nil
+	}
+	b.use(l)
+	b.health = bus.Health{Connected: true, Since: time.Now().UTC(), Reconnects: b.health.Reconnects + 1}
+	return true, nil
+}
+
+func (b *RabbitMQBus) subscriptions() int {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+	return len(b.subs)
+}
+
+// publisher returns the confirm-mode channel to publish on.
+func (b *RabbitMQBus) publisher() (*confirmer, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.pub == nil {
+		return nil, ErrNotConnected
+	}
+	return b.pub, nil
+}
+
+// Health reports whether the bus is connected and how often it has
+// reconnected.
+func (b *RabbitMQBus) Health() bus.Health {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.health
+}
+
+// Close closes the RabbitMQ connection and stops reconnecting
+func (b *RabbitMQBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	b.health.Connected = false
+
+	conn := b.conn
+	b.use(nil)
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
@@ -0,0 +1,83 @@
+package bus
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy says how often and how far apart a transport redelivers a
+// message whose handler failed before dead-lettering it.
+type RetryPolicy struct {
+	MaxAttempts int           // deliveries including the first; 1 disables retries
+	BaseDelay   time.Duration // wait before the first retry
+	Multiplier  float64       // growth of the wait from one retry to the next
+	Jitter      float64       // fraction of the wait randomised either way, 0 to 1
+}
+
+// DefaultRetryPolicy retries three times, after about 1s, 2s and 4s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// Normalize returns DefaultRetryPolicy for the zero policy. Otherwise it
+// fills the unset fields of p from DefaultRetryPolicy and clamps Jitter to
+// [0, 1].
+func (p RetryPolicy) Normalize() RetryPolicy {
+	if p == (RetryPolicy{}) {
+		return DefaultRetryPolicy
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	return p
+}
+
+// Backoff is the wait before the given retry (1 for the first) without
+// jitter.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	return time.Duration(float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(retry-1)))
+}
+
+// MaxDelay is the longest Delay can return for the given retry.
+func (p RetryPolicy) MaxDelay(retry int) time.Duration {
+	return time.Duration(float64(p.Backoff(retry)) * (1 + p.Jitter))
+}
+
+// Delay is the wait before the given retry, Backoff moved by up to Jitter
+// of itself either way.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := float64(p.Backoff(retry))
+	return time.Duration(d + d*p.Jitter*(2*rand.Float64()-1))
+}
+
+type attemptKey struct{}
+
+// WithAttempt returns a context telling the handler which delivery of the
+// message this is, 1 for the first.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// AttemptFromContext returns the delivery attempt a transport attached to a
+// message's context, or 1 when it attached none.
+func AttemptFromContext(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,52 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNormalize(t *testing.T) {
+	if got := (RetryPolicy{}).Normalize(); got != DefaultRetryPolicy {
+		t.Errorf("zero policy = %+v, want %+v", got, DefaultRetryPolicy)
+	}
+
+	p := RetryPolicy{MaxAttempts: 1, BaseDelay: 5 * time.Second, Multiplier: 0.5, Jitter: 3}.Normalize()
+	want := RetryPolicy{MaxAttempts: 1, BaseDelay: 5 * time.Second, Multiplier: DefaultRetryPolicy.Multiplier, Jitter: 1}
+	if p != want {
+		t.Errorf("Normalize = %+v, want %+v", p, want)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, Multiplier: 3, Jitter: 0.25}
+
+	for retry, want := range map[int]time.Duration{0: time.Second, 1: time.Second, 2: 3 * time.Second, 3: 9 * time.Second} {
+		if got := p.Backoff(retry); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", retry, got, want)
+		}
+	}
+	if got := p.MaxDelay(2); got != 3750*time.Millisecond {
+		t.Errorf("MaxDelay(2) = %s", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(2); d < 2250*time.Millisecond || d > p.MaxDelay(2) {
+			t.Fatalf("Delay(2) = %s, outside 2.25s..3.75s", d)
+		}
+	}
+
+	p.Jitter = 0
+	if got := p.Delay(3); got != 9*time.Second {
+		t.Errorf("Delay without jitter = %s", got)
+	}
+}
+
+func TestAttemptFromContext(t *testing.T) {
+	if got := AttemptFromContext(context.Background()); got != 1 {
+		t.Errorf("without attempt = %d, want 1", got)
+	}
+	if got := AttemptFromContext(WithAttempt(context.Background(), 3)); got != 3 {
+		t.Errorf("attempt = %d, want 3", got)
+	}
+}
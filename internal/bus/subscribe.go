@@ -0,0 +1,30 @@
+package bus
+
+import "context"
+
+// SubscribeOptions tune how a transport delivers a subscription's messages.
+// The zero value gives the subscriber its own copy of every message, with
+// the transport's default prefetch and DefaultRetryPolicy.
+type SubscribeOptions struct {
+	// Group names a consumer group. Subscribers of a topic in the same group
+	// share one durable queue and each message goes to one of them; messages
+	// published while none is running wait for the next.
+	Group string
+	// Prefetch is how many unacknowledged messages a subscriber may hold.
+	Prefetch int
+	Retry    RetryPolicy
+}
+
+// OptionSubscriber is a Consumer that honours SubscribeOptions.
+type OptionSubscriber interface {
+	SubscribeWith(ctx context.Context, topic string, opts SubscribeOptions, handler HandlerFn) error
+}
+
+// SubscribeWith subscribes handler to topic with opts when c supports them
+// and with a plain Subscribe otherwise.
+func SubscribeWith(ctx context.Context, c Consumer, topic string, opts SubscribeOptions, handler HandlerFn) error {
+	if s, ok := c.(OptionSubscriber); ok {
+		return s.SubscribeWith(ctx, topic, opts, handler)
+	}
+	return c.Subscribe(ctx, topic, handler)
+}
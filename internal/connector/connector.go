@@ -0,0 +1,99 @@
+// Package connector abstracts the acquirers and processors the gateway talks
+// to. Each provider implements Connector; a Registry maps route names to
+// connectors.
+package connector
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTimeout means the provider did not answer in time. The outcome is
+	// unknown, so callers should retry with the same Reference.
+	ErrTimeout = errors.New("connector timeout")
+	// ErrUnavailable means the provider could not be reached.
+	ErrUnavailable = errors.New("connector unavailable")
+	// ErrUnknownConnector is returned by Registry.Get for unregistered names.
+	ErrUnknownConnector = errors.New("unknown connector")
+)
+
+type Operation string
+
+const (
+	OperationAuthorize Operation = "authorize"
+	OperationCapture   Operation = "capture"
+	OperationVoid      Operation = "void"
+	OperationRefund    Operation = "refund"
+	OperationPayout    Operation = "payout"
+	OperationStatus    Operation = "status"
+)
+
+type ResultStatus string
+
+const (
+	ResultApproved ResultStatus = "approved"
+	ResultDeclined ResultStatus = "declined"
+	ResultPending  ResultStatus = "pending"
+)
+
+// Request is what the gateway sends to a provider. Reference is our own
+// idempotency key for the operation (transaction, refund or payout ID) and
+// ExternalID refers to an earlier provider operation, e.g. the authorization
+// being captured.
+type Request struct {
+	Reference     string
+	TransactionID uuid.UUID
+	MerchantID    uuid.UUID
+	Amount        int64 // smallest currency unit
+	Currency      string
+	ExternalID    string
+	Metadata      map[string]any
+}
+
+// Response is the provider's answer. A decline is a Response, not an error;
+// errors are reserved for transport failures where the outcome is unknown.
+type Response struct {
+	Connector   string       `json:"connector"`
+	Operation   Operation    `json:"operation"`
+	Status      ResultStatus `json:"status"`
+	ExternalID  string       `json:"external_id,omitempty"`
+	Code        string       `json:"code,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	Amount      int64        `json:"amount"`
+	Currency    string       `json:"currency"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+func (r *Response) Approved() bool {
+	return r != nil && r.Status == ResultApproved
+}
+
+// Metadata flattens the response for storage in JSONB metadata columns.
+func (r *Response) Metadata() map[string]any {
+	return map[string]any{
+		"connector":    r.Connector,
+		"operation":    string(r.Operation),
+		"status":       string(r.Status),
+		"external_id":  r.ExternalID,
+		"code":         r.Code,
+		"message":      r.Message,
+		"amount":       r.Amount,
+		"currency":     r.Currency,
+		"processed_at": r.ProcessedAt,
+	}
+}
+
+// Connector is implemented by every acquirer/processor integration.
+type Connector interface {
+	Name() string
+	Authorize(ctx context.Context, req *Request) (*Response, error)
+	Capture(ctx context.Context, req *Request) (*Response, error)
+	Void(ctx context.Context, req *Request) (*Response, error)
+	Refund(ctx context.Context, req *Request) (*Response, error)
+	Payout(ctx context.Context, req *Request) (*Response, error)
+	Status(ctx context.Context, externalID string) (*Response, error)
+}
@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Registry holds the connectors available for routing.
+type Registry struct {
+	mu          sync.RWMutex
+	connectors  map[string]Connector
+	defaultName string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{connectors: make(map[string]Connector)}
+}
+
+// Register adds c under c.Name(). The first connector registered becomes the
+// default unless SetDefault is called.
+func (r *Registry) Register(c Connector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connectors[c.Name()] = c
+	if r.defaultName == "" {
+		r.defaultName = c.Name()
+	}
+}
+
+func (r *Registry) SetDefault(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.connectors[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownConnector, name)
+	}
+	r.defaultName = name
+	return nil
+}
+
+func (r *Registry) Get(name string) (Connector, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.connectors[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConnector, name)
+	}
+	return c, nil
+}
+
+// Default returns the default connector, or ErrUnknownConnector if the
+// registry is empty.
+func (r *Registry) Default() (Connector, error) {
+	return r.Get(r.DefaultName())
+}
+
+func (r *Registry) DefaultName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.connectors[name]
+	return ok
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.connectors))
+	for name := range r.connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRegistryFromEnv builds the registry used by the cmd binaries: the
+// sandbox connector configured from SANDBOX_* variables, with
+// DEFAULT_CONNECTOR selecting the default when set.
+func NewRegistryFromEnv() (*Registry, error) {
+	cfg, err := SandboxConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	r := NewRegistry()
+	r.Register(NewSandboxConnector(cfg))
+	if name := os.Getenv("DEFAULT_CONNECTOR"); name != "" {
+		if err := r.SetDefault(name); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
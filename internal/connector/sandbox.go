@@ -0,0 +1,230 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const SandboxName = "sandbox"
+
+// SandboxOutcomeKey is the request metadata key that forces an outcome for
+// every operation. "sandbox_<operation>_outcome" (e.g. sandbox_capture_outcome)
+// forces it for a single operation and takes precedence.
+const SandboxOutcomeKey = "sandbox_outcome"
+
+type SandboxOutcome string
+
+const (
+	SandboxApprove     SandboxOutcome = "approve"
+	SandboxDecline     SandboxOutcome = "decline"
+	SandboxTimeout     SandboxOutcome = "timeout"
+	SandboxUnavailable SandboxOutcome = "unavailable"
+)
+
+const sandboxIDPrefix = "sbx_"
+
+// SandboxConfig makes the sandbox deterministic: the outcome of a request is
+// picked from metadata first, then from the last two digits of the amount
+// (in minor units), and defaults to approve.
+type SandboxConfig struct {
+	Name string
+	// Latency is added to every call.
+	Latency time.Duration
+	// TimeoutAfter is how long a simulated timeout blocks before failing.
+	TimeoutAfter time.Duration
+	// AmountOutcomes maps amount % 100 to an outcome.
+	AmountOutcomes map[int64]SandboxOutcome
+}
+
+// DefaultSandboxConfig declines amounts ending in 51 (insufficient funds),
+// times out on 91 (issuer unavailable) and is unreachable on 96.
+func DefaultSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		Name:         SandboxName,
+		TimeoutAfter: 2 * time.Second,
+		AmountOutcomes: map[int64]SandboxOutcome{
+			51: SandboxDecline,
+			91: SandboxTimeout,
+			96: SandboxUnavailable,
+		},
+	}
+}
+
+// SandboxConfigFromEnv starts from DefaultSandboxConfig and applies
+// SANDBOX_CONNECTOR_NAME, SANDBOX_LATENCY, SANDBOX_TIMEOUT_AFTER,
+// SANDBOX_DECLINE_SUFFIXES and SANDBOX_TIMEOUT_SUFFIXES (comma separated
+// two-digit amount suffixes).
+func SandboxConfigFromEnv() (SandboxConfig, error) {
+	cfg := DefaultSandboxConfig()
+
+	if v := os.Getenv("SANDBOX_CONNECTOR_NAME"); v != "" {
+		cfg.Name = v
+	}
+	if v := os.Getenv("SANDBOX_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SANDBOX_LATENCY: %w", err)
+		}
+		cfg.Latency = d
+	}
+	if v := os.Getenv("SANDBOX_TIMEOUT_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SANDBOX_TIMEOUT_AFTER: %w", err)
+		}
+		cfg.TimeoutAfter = d
+	}
+	for env, outcome := range map[string]SandboxOutcome{
+		"SANDBOX_DECLINE_SUFFIXES": SandboxDecline,
+		"SANDBOX_TIMEOUT_SUFFIXES": SandboxTimeout,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		// An explicit list replaces the defaults for that outcome
+		for suffix, o := range cfg.AmountOutcomes {
+			if o == outcome {
+				delete(cfg.AmountOutcomes, suffix)
+			}
+		}
+		for _, part := range strings.Split(v, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || n < 0 || n > 99 {
+				return cfg, fmt.Errorf("invalid %s entry %q", env, part)
+			}
+			cfg.AmountOutcomes[n] = outcome
+		}
+	}
+
+	return cfg, nil
+}
+
+// SandboxConnector simulates a provider for development and tests. It is
+// stateless so any process can capture or refund what another authorized.
+type SandboxConnector struct {
+	cfg SandboxConfig
+}
+
+func NewSandboxConnector(cfg SandboxConfig) *SandboxConnector {
+	if cfg.Name == "" {
+		cfg.Name = SandboxName
+	}
+	if cfg.AmountOutcomes == nil {
+		cfg.AmountOutcomes = map[int64]SandboxOutcome{}
+	}
+	return &SandboxConnector{cfg: cfg}
+}
+
+func (c *SandboxConnector) Name() string {
+	return c.cfg.Name
+}
+
+func (c *SandboxConnector) Authorize(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, OperationAuthorize, req)
+}
+
+func (c *SandboxConnector) Capture(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, OperationCapture, req)
+}
+
+func (c *SandboxConnector) Void(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, OperationVoid, req)
+}
+
+func (c *SandboxConnector) Refund(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, OperationRefund, req)
+}
+
+func (c *SandboxConnector) Payout(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, OperationPayout, req)
+}
+
+// Status reports any ID issued by the sandbox as approved.
+func (c *SandboxConnector) Status(ctx context.Context, externalID string) (*Response, error) {
+	if err := c.wait(ctx, c.cfg.Latency); err != nil {
+		return nil, err
+	}
+	resp := &Response{
+		Connector:   c.cfg.Name,
+		Operation:   OperationStatus,
+		Status:      ResultApproved,
+		ExternalID:  externalID,
+		ProcessedAt: time.Now().UTC(),
+	}
+	if !strings.HasPrefix(externalID, sandboxIDPrefix) {
+		resp.Status = ResultDeclined
+		resp.Code = "not_found"
+		resp.Message = "unknown sandbox reference"
+	}
+	return resp, nil
+}
+
+func (c *SandboxConnector) do(ctx context.Context, op Operation, req *Request) (*Response, error) {
+	if err := c.wait(ctx, c.cfg.Latency); err != nil {
+		return nil, err
+	}
+
+	switch c.outcome(op, req) {
+	case SandboxTimeout:
+		if err := c.wait(ctx, c.cfg.TimeoutAfter); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: sandbox %s timed out", ErrTimeout, op)
+	case SandboxUnavailable:
+		return nil, fmt.Errorf("%w: sandbox %s unavailable", ErrUnavailable, op)
+	case SandboxDecline:
+		return c.response(op, req, ResultDeclined, "51", "declined by sandbox"), nil
+	default:
+		return c.response(op, req, ResultApproved, "00", "approved"), nil
+	}
+}
+
+func (c *SandboxConnector) outcome(op Operation, req *Request) SandboxOutcome {
+	if req.Metadata != nil {
+		if v, ok := req.Metadata["sandbox_"+string(op)+"_outcome"].(string); ok && v != "" {
+			return SandboxOutcome(v)
+		}
+		if v, ok := req.Metadata[SandboxOutcomeKey].(string); ok && v != "" {
+			return SandboxOutcome(v)
+		}
+	}
+	if o, ok := c.cfg.AmountOutcomes[req.Amount%100]; ok {
+		return o
+	}
+	return SandboxApprove
+}
+
+func (c *SandboxConnector) response(op Operation, req *Request, status ResultStatus, code, msg string) *Response {
+	return &Response{
+		Connector:   c.cfg.Name,
+		Operation:   op,
+		Status:      status,
+		ExternalID:  sandboxIDPrefix + uuid.NewString(),
+		Code:        code,
+		Message:     msg,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		ProcessedAt: time.Now().UTC(),
+	}
+}
+
+func (c *SandboxConnector) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrTimeout, ctx.Err())
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxOutcomes(t *testing.T) {
+	c := NewSandboxConnector(DefaultSandboxConfig())
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		req      *Request
+		approved bool
+		err      error
+	}{
+		{"approve by default", &Request{Amount: 10000}, true, nil},
+		{"decline on suffix 51", &Request{Amount: 10051}, false, nil},
+		{"unavailable on suffix 96", &Request{Amount: 10096}, false, ErrUnavailable},
+		{"metadata overrides amount", &Request{Amount: 10051, Metadata: map[string]any{SandboxOutcomeKey: "approve"}}, true, nil},
+		{"metadata forces decline", &Request{Amount: 10000, Metadata: map[string]any{SandboxOutcomeKey: "decline"}}, false, nil},
+	}
+
+	for _, tc := range cases {
+		resp, err := c.Authorize(ctx, tc.req)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%s: got error %v, want %v", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if resp.Approved() != tc.approved {
+			t.Errorf("%s: approved = %v, want %v", tc.name, resp.Approved(), tc.approved)
+		}
+		if !strings.HasPrefix(resp.ExternalID, sandboxIDPrefix) {
+			t.Errorf("%s: external id %q lacks sandbox prefix", tc.name, resp.ExternalID)
+		}
+	}
+}
+
+func TestSandboxPerOperationOutcome(t *testing.T) {
+	c := NewSandboxConnector(DefaultSandboxConfig())
+	req := &Request{Amount: 10000, Metadata: map[string]any{"sandbox_capture_outcome": "decline"}}
+
+	auth, err := c.Authorize(context.Background(), req)
+	if err != nil || !auth.Approved() {
+		t.Fatalf("authorize: got %+v, %v", auth, err)
+	}
+	capture, err := c.Capture(context.Background(), req)
+	if err != nil || capture.Approved() {
+		t.Fatalf("capture: got %+v, %v; want decline", capture, err)
+	}
+}
+
+func TestSandboxTimeout(t *testing.T) {
+	cfg := DefaultSandboxConfig()
+	cfg.TimeoutAfter = time.Millisecond
+	c := NewSandboxConnector(cfg)
+
+	if _, err := c.Authorize(context.Background(), &Request{Amount: 10091}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+}
+
+func TestRegistryDefault(t *testing.T) {
+	r := NewRegistry()
+	if _, err := r.Default(); !errors.Is(err, ErrUnknownConnector) {
+		t.Fatalf("empty registry: got %v, want ErrUnknownConnector", err)
+	}
+
+	r.Register(NewSandboxConnector(SandboxConfig{Name: "a"}))
+	r.Register(NewSandboxConnector(SandboxConfig{Name: "b"}))
+	if got := r.DefaultName(); got != "a" {
+		t.Fatalf("default = %q, want first registered", got)
+	}
+	if err := r.SetDefault("b"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := r.Default(); c.Name() != "b" {
+		t.Fatalf("default = %q, want b", c.Name())
+	}
+	if err := r.SetDefault("missing"); !errors.Is(err, ErrUnknownConnector) {
+		t.Fatalf("got %v, want ErrUnknownConnector", err)
+	}
+}
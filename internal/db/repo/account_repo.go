@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/BjornOnGit/payment-gateway/internal/model"
 	"github.com/google/uuid"
@@ -10,26 +11,38 @@ import (
 
 type AccountRepository interface {
 	GetOrCreateMerchantAccount(ctx context.Context, merchantID uuid.UUID, currency string) (*model.Accounts, error)
+	GetOrCreateSystemAccount(ctx context.Context, ownerID uuid.UUID, currency string) (*model.Accounts, error)
+	GetOrCreateUserAccount(ctx context.Context, userID uuid.UUID, currency string) (*model.Accounts, error)
 	GetByOwnerID(ctx context.Context, ownerID uuid.UUID, accountType model.AccountType) (*model.Accounts, error)
+	GetByOwnerAndCurrency(ctx context.Context, ownerID uuid.UUID, accountType model.AccountType, currency string) (*model.Accounts, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Accounts, error)
+	GetBalance(ctx context.Context, accountID uuid.UUID) (*model.AccountBalance, error)
+	ListEntries(ctx context.Context, accountID uuid.UUID, filter model.AccountEntryFilter) ([]*model.AccountEntry, error)
+	ListEntriesBetween(ctx context.Context, accountID uuid.UUID, from, to time.Time) ([]*model.AccountEntry, error)
+	BalanceAt(ctx context.Context, accountID uuid.UUID, t time.Time) (int64, error)
+	SnapshotBalances(ctx context.Context, asOf time.Time) (int, error)
+	LatestSnapshot(ctx context.Context) (time.Time, error)
+	FirstEntryAt(ctx context.Context) (time.Time, error)
 }
 
 type PostgresAccountRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewPostgresAccountRepository(db *sql.DB) *PostgresAccountRepository {
+func NewPostgresAccountRepository(db DBTX) *PostgresAccountRepository {
 	return &PostgresAccountRepository{db: db}
 }
 
-// GetOrCreateMerchantAccount retrieves or creates a merchant account
+// GetOrCreateMerchantAccount retrieves or creates the merchant's account in
+// the given currency. A merchant has one account per settlement currency.
 func (r *PostgresAccountRepository) GetOrCreateMerchantAccount(
 	ctx context.Context,
 	merchantID uuid.UUID,
 	currency string,
 ) (*model.Accounts, error) {
 	// Try to get existing account
-	account, err := r.GetByOwnerID(ctx, merchantID, model.AccountTypeMerchant)
-	if err != nil && err != sql.ErrNoRows {
+	account, err := r.GetByOwnerAndCurrency(ctx, merchantID, model.AccountTypeMerchant, currency)
+	if err != nil {
 		return nil, err
 	}
 
@@ -38,11 +51,64 @@ func (r *PostgresAccountRepository) GetOrCreateMerchantAccount(
 		return account, nil
 	}
 
-	// Create new merchant account
+	// Only registered merchants get accounts, so a mistyped id can't open one
+	var known bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM merchants WHERE id = $1)`, merchantID).Scan(&known); err != nil {
+		return nil, err
+	}
+	if !known {
+		return nil, model.ErrMerchantNotFound
+	}
+
+	return r.create(ctx, merchantID, model.AccountTypeMerchant, currency)
+}
+
+// GetOrCreateSystemAccount retrieves or creates the system account owned by
+// ownerID in the given currency (e.g. the clearing account for NGN).
+func (r *PostgresAccountRepository) GetOrCreateSystemAccount(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	currency string,
+) (*model.Accounts, error) {
+	account, err := r.GetByOwnerAndCurrency(ctx, ownerID, model.AccountTypeSystem, currency)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		return account, nil
+	}
+
+	return r.create(ctx, ownerID, model.AccountTypeSystem, currency)
+}
+
+// GetOrCreateUserAccount retrieves or creates the customer account for
+// userID in the given currency.
+func (r *PostgresAccountRepository) GetOrCreateUserAccount(
+	ctx context.Context,
+	userID uuid.UUID,
+	currency string,
+) (*model.Accounts, error) {
+	account, err := r.GetByOwnerAndCurrency(ctx, userID, model.AccountTypeUser, currency)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		return account, nil
+	}
+
+	return r.create(ctx, userID, model.AccountTypeUser, currency)
+}
+
+func (r *PostgresAccountRepository) create(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	accountType model.AccountType,
+	currency string,
+) (*model.Accounts, error) {
 	newAccount := &model.Accounts{
 		ID:          uuid.New(),
-		OwnerID:     merchantID,
-		AccountType: model.AccountTypeMerchant,
+		OwnerID:     ownerID,
+		AccountType: accountType,
 		Currency:    currency,
 	}
 
@@ -52,12 +118,12 @@ func (r *PostgresAccountRepository) GetOrCreateMerchantAccount(
 		RETURNING created_at, updated_at
 	`
 
-	err = r.db.QueryRowContext(
+	err := r.db.QueryRowContext(
 		ctx,
 		query,
 		newAccount.ID,
 		newAccount.OwnerID,
-		"merchant",
+		string(accountType),
 		newAccount.AccountType,
 		newAccount.Currency,
 	).Scan(&newAccount.CreatedAt, &newAccount.UpdatedAt)
@@ -101,3 +167,261 @@ func (r *PostgresAccountRepository) GetByOwnerID(
 
 	return &account, nil
 }
+
+// GetByOwnerAndCurrency retrieves an account by owner ID, account type and currency
+func (r *PostgresAccountRepository) GetByOwnerAndCurrency(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	accountType model.AccountType,
+	currency string,
+) (*model.Accounts, error) {
+	query := `
+		SELECT id, owner_id, account_type, currency, created_at, updated_at
+		FROM accounts
+		WHERE owner_id = $1 AND account_type = $2 AND currency = $3
+		LIMIT 1
+	`
+
+	var account model.Accounts
+	err := r.db.QueryRowContext(ctx, query, ownerID, accountType, currency).Scan(
+		&account.ID,
+		&account.OwnerID,
+		&account.AccountType,
+		&account.Currency,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetByID retrieves an account by its ID
+func (r *PostgresAccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Accounts, error) {
+	query := `
+		SELECT id, owner_id, account_type, currency, created_at, updated_at
+		FROM accounts
+		WHERE id = $1
+	`
+
+	var account model.Accounts
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&account.ID,
+		&account.OwnerID,
+		&account.AccountType,
+		&account.Currency,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetBalance returns the account's available, pending and reserved
+// amounts. An account with no balance row has nothing available.
+func (r *PostgresAccountRepository) GetBalance(ctx context.Context, accountID uuid.UUID) (*model.AccountBalance, error) {
+	query := `
+		SELECT
+			a.currency,
+			COALESCE((SELECT balance FROM account_balances WHERE account_id = a.id), 0),
+			COALESCE((
+				SELECT SUM(COALESCE(net_amount, amount - fee_amount)) FROM settlements
+				WHERE merchant_account_id = a.id AND status = 'pending'
+			), 0)::bigint,
+			COALESCE((
+				SELECT SUM(amount) FROM authorizations
+				WHERE hold_account_id = a.id AND status = 'authorized'
+			), 0)::bigint
+			+ COALESCE((
+				SELECT SUM(amount) FROM payouts
+				WHERE merchant_account_id = a.id AND status = 'pending'
+			), 0)::bigint,
+			NOW()
+		FROM accounts a
+		WHERE a.id = $1
+	`
+
+	balance := model.AccountBalance{AccountID: accountID}
+	var pending, reserved int64
+	err := r.db.QueryRowContext(ctx, query, accountID).Scan(
+		&balance.Currency,
+		&balance.Available,
+		&pending,
+		&reserved,
+		&balance.AsOf,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	balance.Pending, balance.Reserved = &pending, &reserved
+	return &balance, nil
+}
+
+// accountEntriesQuery selects an account's legs created in [$2, $3), either
+// bound NULL for open, with the running balance over the account's whole
+// history.
+const accountEntriesQuery = `
+	SELECT id, transaction_id, entry_type, amount, currency, description, balance, created_at
+	FROM (
+		SELECT
+			id, transaction_id, entry_type,
+			CASE WHEN entry_type = 'debit' THEN -amount ELSE amount END AS amount,
+			currency, COALESCE(description, '') AS description, created_at,
+			SUM(CASE WHEN entry_type = 'debit' THEN -amount ELSE amount END)
+				OVER (ORDER BY created_at, id)::bigint AS balance
+		FROM ledger_entries
+		WHERE (entry_type = 'debit' AND debit_account_id = $1)
+			OR (entry_type = 'credit' AND credit_account_id = $1)
+	) e
+	WHERE ($2::timestamp IS NULL OR created_at >= $2)
+		AND ($3::timestamp IS NULL OR created_at < $3)
+	ORDER BY created_at, id
+`
+
+// ListEntries returns a page of the account's legs in posting order.
+func (r *PostgresAccountRepository) ListEntries(
+	ctx context.Context,
+	accountID uuid.UUID,
+	filter model.AccountEntryFilter,
+) ([]*model.AccountEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 10
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return r.listEntries(ctx, accountEntriesQuery+` LIMIT $4 OFFSET $5`,
+		accountID, nullTime(filter.From), nullTime(filter.To), filter.Limit, filter.Offset)
+}
+
+// ListEntriesBetween returns all of the account's legs created in
+// [from, to) in posting order.
+func (r *PostgresAccountRepository) ListEntriesBetween(
+	ctx context.Context,
+	accountID uuid.UUID,
+	from, to time.Time,
+) ([]*model.AccountEntry, error) {
+	return r.listEntries(ctx, accountEntriesQuery, accountID, nullTime(from), nullTime(to))
+}
+
+func (r *PostgresAccountRepository) listEntries(ctx context.Context, query string, args ...any) ([]*model.AccountEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*model.AccountEntry{}
+	for rows.Next() {
+		var e model.AccountEntry
+		var txID uuid.NullUUID
+		if err := rows.Scan(
+			&e.ID,
+			&txID,
+			&e.EntryType,
+			&e.Amount,
+			&e.Currency,
+			&e.Description,
+			&e.Balance,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		e.TransactionID = txID.UUID
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// BalanceAt returns the account's ledger balance at t: the sum of its legs
+// created before t, starting from the latest snapshot at or before t.
+func (r *PostgresAccountRepository) BalanceAt(ctx context.Context, accountID uuid.UUID, t time.Time) (int64, error) {
+	query := `
+		WITH snapshot AS (
+			SELECT as_of, balance
+			FROM account_balance_snapshots
+			WHERE account_id = $1 AND as_of <= $2
+			ORDER BY as_of DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT balance FROM snapshot), 0) + COALESCE((
+			SELECT SUM(CASE WHEN entry_type = 'debit' THEN -amount ELSE amount END)
+			FROM ledger_entries
+			WHERE ((entry_type = 'debit' AND debit_account_id = $1)
+				OR (entry_type = 'credit' AND credit_account_id = $1))
+				AND created_at >= COALESCE((SELECT as_of FROM snapshot), '-infinity'::timestamp)
+				AND created_at < $2
+		), 0)::bigint
+	`
+
+	var balance int64
+	err := r.db.QueryRowContext(ctx, query, accountID, t).Scan(&balance)
+	return balance, err
+}
+
+// SnapshotBalances records every account's balance at asOf, carrying each
+// one forward from its previous snapshot. Snapshotting the same asOf again
+// overwrites it. It returns the number of accounts snapshotted.
+func (r *PostgresAccountRepository) SnapshotBalances(ctx context.Context, asOf time.Time) (int, error) {
+	query := `
+		INSERT INTO account_balance_snapshots (account_id, as_of, balance, created_at)
+		SELECT a.id, $1, COALESCE(prev.balance, 0) + COALESCE((
+			SELECT SUM(CASE WHEN e.entry_type = 'debit' THEN -e.amount ELSE e.amount END)
+			FROM ledger_entries e
+			WHERE ((e.entry_type = 'debit' AND e.debit_account_id = a.id)
+				OR (e.entry_type = 'credit' AND e.credit_account_id = a.id))
+				AND e.created_at >= COALESCE(prev.as_of, '-infinity'::timestamp)
+				AND e.created_at < $1
+		), 0), NOW()
+		FROM accounts a
+		LEFT JOIN LATERAL (
+			SELECT as_of, balance
+			FROM account_balance_snapshots s
+			WHERE s.account_id = a.id AND s.as_of < $1
+			ORDER BY s.as_of DESC
+			LIMIT 1
+		) prev ON true
+		ON CONFLICT (account_id, as_of)
+		DO UPDATE SET balance = EXCLUDED.balance, created_at = NOW()
+	`
+
+	res, err := r.db.ExecContext(ctx, query, asOf)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// LatestSnapshot returns the as_of of the most recent snapshot, or the zero
+// time if none has been taken.
+func (r *PostgresAccountRepository) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	var asOf sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MAX(as_of) FROM account_balance_snapshots`).Scan(&asOf)
+	return asOf.Time, err
+}
+
+// FirstEntryAt returns when the first ledger leg was created, or the zero
+// time for an empty ledger.
+func (r *PostgresAccountRepository) FirstEntryAt(ctx context.Context) (time.Time, error) {
+	var first sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(created_at) FROM ledger_entries`).Scan(&first)
+	return first.Time, err
+}
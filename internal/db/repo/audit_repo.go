@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, a *model.AuditLogs) error
+	ListByEntity(ctx context.Context, entity string, entityID uuid.UUID) ([]*model.AuditLogs, error)
+}
+
+type PostgresAuditRepository struct {
+	db DBTX
+}
+
+func NewPostgresAuditRepository(db DBTX) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+func (r *PostgresAuditRepository) Create(ctx context.Context, a *model.AuditLogs) error {
+	query := `
+		INSERT INTO audit_logs (id, actor_id, actor, action, entity, entity_id, details, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		a.ID,
+		nullUUID(a.ActorID),
+		a.Actor,
+		a.Action,
+		a.Entity,
+		nullUUID(a.EntityID),
+		a.Details,
+		a.CreatedAt,
+	)
+	return err
+}
+
+// ListByEntity returns the audit trail of one entity, oldest first.
+func (r *PostgresAuditRepository) ListByEntity(ctx context.Context, entity string, entityID uuid.UUID) ([]*model.AuditLogs, error) {
+	query := `
+		SELECT id, actor_id, COALESCE(actor, ''), action, COALESCE(entity, ''), entity_id, COALESCE(details, ''), created_at
+		FROM audit_logs
+		WHERE entity = $1 AND entity_id = $2
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, entity, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*model.AuditLogs
+	for rows.Next() {
+		var a model.AuditLogs
+		var actorID, id uuid.NullUUID
+		if err := rows.Scan(&a.ID, &actorID, &a.Actor, &a.Action, &a.Entity, &id, &a.Details, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.ActorID, a.EntityID = actorID.UUID, id.UUID
+		logs = append(logs, &a)
+	}
+	return logs, rows.Err()
+}
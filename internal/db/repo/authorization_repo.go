@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type AuthorizationRepository interface {
+	Create(ctx context.Context, auth *model.Authorization) error
+	GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*model.Authorization, error)
+	GetByTransactionIDForUpdate(ctx context.Context, transactionID uuid.UUID) (*model.Authorization, error)
+	ListExpiredForUpdate(ctx context.Context, now time.Time, limit int) ([]*model.Authorization, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status model.AuthorizationStatus, capturedAmount int64) error
+	MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error
+}
+
+type PostgresAuthorizationRepository struct {
+	db DBTX
+}
+
+func NewPostgresAuthorizationRepository(db DBTX) *PostgresAuthorizationRepository {
+	return &PostgresAuthorizationRepository{db: db}
+}
+
+const authorizationColumns = `
+	id, transaction_id, connector, external_id, amount, captured_amount, currency,
+	status, hold_account_id, expires_at, metadata, created_at, updated_at
+`
+
+func (r *PostgresAuthorizationRepository) Create(ctx context.Context, auth *model.Authorization) error {
+	query := `
+		INSERT INTO authorizations (id, transaction_id, connector, external_id, amount, captured_amount, currency,
+			status, hold_account_id, expires_at, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	metadata := auth.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		query,
+		auth.ID,
+		auth.TransactionID,
+		auth.Connector,
+		auth.ExternalID,
+		auth.Amount,
+		auth.CapturedAmount,
+		auth.Currency,
+		auth.Status,
+		auth.HoldAccountID,
+		auth.ExpiresAt,
+		metadataJSON,
+		auth.CreatedAt,
+		auth.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PostgresAuthorizationRepository) GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*model.Authorization, error) {
+	return r.get(ctx, `SELECT `+authorizationColumns+` FROM authorizations WHERE transaction_id = $1`, transactionID)
+}
+
+// GetByTransactionIDForUpdate locks the authorization row until the
+// surrounding transaction ends.
+func (r *PostgresAuthorizationRepository) GetByTransactionIDForUpdate(ctx context.Context, transactionID uuid.UUID) (*model.Authorization, error) {
+	return r.get(ctx, `SELECT `+authorizationColumns+` FROM authorizations WHERE transaction_id = $1 FOR UPDATE`, transactionID)
+}
+
+func (r *PostgresAuthorizationRepository) get(ctx context.Context, query string, id uuid.UUID) (*model.Authorization, error) {
+	auth, err := scanAuthorization(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// ListExpiredForUpdate locks up to limit open authorizations whose expiry
+// has passed. Rows locked by a concurrent sweeper are skipped. Must be
+// called inside a transaction.
+func (r *PostgresAuthorizationRepository) ListExpiredForUpdate(ctx context.Context, now time.Time, limit int) ([]*model.Authorization, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `SELECT ` + authorizationColumns + `
+		FROM authorizations
+		WHERE status = 'authorized' AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var auths []*model.Authorization
+	for rows.Next() {
+		auth, err := scanAuthorization(rows)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, auth)
+	}
+
+	return auths, rows.Err()
+}
+
+func (r *PostgresAuthorizationRepository) UpdateStatus(
+	ctx context.Context,
+	id uuid.UUID,
+	status model.AuthorizationStatus,
+	capturedAmount int64,
+) error {
+	query := `
+		UPDATE authorizations SET status = $1, captured_amount = $2, updated_at = NOW() WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, status, capturedAmount, id)
+	return err
+}
+
+// MergeMetadata shallow-merges patch into the authorization's metadata.
+func (r *PostgresAuthorizationRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error {
+	query := `
+		UPDATE authorizations SET metadata = metadata || $1::jsonb, updated_at = NOW() WHERE id = $2
+	`
+
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, patchJSON, id)
+	return err
+}
+
+func scanAuthorization(row rowScanner) (*model.Authorization, error) {
+	var auth model.Authorization
+	var metadataJSON []byte
+
+	err := row.Scan(
+		&auth.ID,
+		&auth.TransactionID,
+		&auth.Connector,
+		&auth.ExternalID,
+		&auth.Amount,
+		&auth.CapturedAmount,
+		&auth.Currency,
+		&auth.Status,
+		&auth.HoldAccountID,
+		&auth.ExpiresAt,
+		&metadataJSON,
+		&auth.CreatedAt,
+		&auth.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &auth.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &auth, nil
+}
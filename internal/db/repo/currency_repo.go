@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+type CurrencyRepository interface {
+	List(ctx context.Context) ([]model.Currency, error)
+}
+
+type PostgresCurrencyRepository struct {
+	db DBTX
+}
+
+func NewPostgresCurrencyRepository(db DBTX) *PostgresCurrencyRepository {
+	return &PostgresCurrencyRepository{db: db}
+}
+
+func (r *PostgresCurrencyRepository) List(ctx context.Context) ([]model.Currency, error) {
+	query := `SELECT code, exponent, name, enabled FROM currencies ORDER BY code`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var currencies []model.Currency
+	for rows.Next() {
+		var c model.Currency
+		if err := rows.Scan(&c.Code, &c.Exponent, &c.Name, &c.Enabled); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, c)
+	}
+
+	return currencies, rows.Err()
+}
@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type DeadLetterRepository interface {
+	Record(ctx context.Context, d *model.DeadLetter) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.DeadLetter, error)
+	List(ctx context.Context, filter model.DeadLetterFilter) ([]*model.DeadLetter, error)
+	RecordReplay(ctx context.Context, replay *model.DeadLetterReplay) error
+	ListReplays(ctx context.Context, deadLetterID uuid.UUID) ([]*model.DeadLetterReplay, error)
+}
+
+type PostgresDeadLetterRepository struct {
+	db DBTX
+}
+
+func NewPostgresDeadLetterRepository(db DBTX) *PostgresDeadLetterRepository {
+	return &PostgresDeadLetterRepository{db: db}
+}
+
+const deadLetterColumns = `
+	id, fingerprint, topic, message_key, payload, retry_count, reason, last_error, occurrences,
+	status, replay_count, last_replayed_at, first_seen_at, last_seen_at, updated_at`
+
+// Record stores a message taken off a DLQ. A message already stored (by
+// fingerprint) has its occurrences bumped, its retry details refreshed and
+// goes back to pending. d is updated from the stored row.
+func (r *PostgresDeadLetterRepository) Record(ctx context.Context, d *model.DeadLetter) error {
+	query := `
+		INSERT INTO dead_letters (id, fingerprint, topic, message_key, payload, retry_count, reason, last_error,
+			status, first_seen_at, last_seen_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending', $9, $9, $9)
+		ON CONFLICT (fingerprint) DO UPDATE SET
+			occurrences = dead_letters.occurrences + 1,
+			retry_count = EXCLUDED.retry_count,
+			reason = EXCLUDED.reason,
+			last_error = CASE WHEN EXCLUDED.last_error = '' THEN dead_letters.last_error ELSE EXCLUDED.last_error END,
+			status = 'pending',
+			last_seen_at = EXCLUDED.last_seen_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING ` + deadLetterColumns
+
+	stored, err := scanDeadLetter(r.db.QueryRowContext(ctx, query,
+		d.ID,
+		d.Fingerprint,
+		d.Topic,
+		d.Key,
+		d.Payload,
+		d.RetryCount,
+		d.Reason,
+		d.LastError,
+		d.LastSeenAt,
+	))
+	if err != nil {
+		return err
+	}
+	*d = *stored
+	return nil
+}
+
+func (r *PostgresDeadLetterRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.DeadLetter, error) {
+	d, err := scanDeadLetter(r.db.QueryRowContext(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// List returns the dead letters matching filter, most recently seen first.
+func (r *PostgresDeadLetterRepository) List(ctx context.Context, filter model.DeadLetterFilter) ([]*model.DeadLetter, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letters
+		WHERE ($1 = '' OR topic = $1)
+			AND ($2 = '' OR message_key = $2)
+			AND ($3 = '' OR status = $3)
+			AND ($4::timestamp IS NULL OR last_seen_at >= $4)
+			AND ($5::timestamp IS NULL OR last_seen_at < $5)
+		ORDER BY last_seen_at DESC, id
+		LIMIT $6 OFFSET $7
+	`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		filter.Topic,
+		filter.Key,
+		string(filter.Status),
+		nullTime(filter.From),
+		nullTime(filter.To),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*model.DeadLetter
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// RecordReplay stores a replay attempt and sets the dead letter's status
+// from its outcome.
+func (r *PostgresDeadLetterRepository) RecordReplay(ctx context.Context, replay *model.DeadLetterReplay) error {
+	query := `
+		WITH replay AS (
+			INSERT INTO dead_letter_replays (id, dead_letter_id, status, error, actor, created_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+			RETURNING dead_letter_id
+		)
+		UPDATE dead_letters
+		SET status = $7, replay_count = replay_count + 1, last_replayed_at = $6, updated_at = $6
+		WHERE id = (SELECT dead_letter_id FROM replay)
+	`
+
+	status := model.DeadLetterReplayed
+	if replay.Status != model.DeadLetterReplayPublished {
+		status = model.DeadLetterReplayFailed
+	}
+	_, err := r.db.ExecContext(ctx, query,
+		replay.ID,
+		replay.DeadLetterID,
+		string(replay.Status),
+		replay.Error,
+		replay.Actor,
+		replay.CreatedAt,
+		string(status),
+	)
+	return err
+}
+
+// ListReplays returns a dead letter's replay attempts, oldest first.
+func (r *PostgresDeadLetterRepository) ListReplays(ctx context.Context, deadLetterID uuid.UUID) ([]*model.DeadLetterReplay, error) {
+	query := `
+		SELECT id, dead_letter_id, status, COALESCE(error, ''), actor, created_at
+		FROM dead_letter_replays
+		WHERE dead_letter_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, deadLetterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replays := []*model.DeadLetterReplay{}
+	for rows.Next() {
+		var rp model.DeadLetterReplay
+		if err := rows.Scan(&rp.ID, &rp.DeadLetterID, &rp.Status, &rp.Error, &rp.Actor, &rp.CreatedAt); err != nil {
+			return nil, err
+		}
+		replays = append(replays, &rp)
+	}
+	return replays, rows.Err()
+}
+
+func scanDeadLetter(row rowScanner) (*model.DeadLetter, error) {
+	var d model.DeadLetter
+	var lastReplayed sql.NullTime
+	err := row.Scan(
+		&d.ID,
+		&d.Fingerprint,
+		&d.Topic,
+		&d.Key,
+		&d.Payload,
+		&d.RetryCount,
+		&d.Reason,
+		&d.LastError,
+		&d.Occurrences,
+		&d.Status,
+		&d.ReplayCount,
+		&lastReplayed,
+		&d.FirstSeenAt,
+		&d.LastSeenAt,
+		&d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if lastReplayed.Valid {
+		d.LastReplayedAt = &lastReplayed.Time
+	}
+	return &d, nil
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type FeePlanRepository interface {
+	GetForMerchant(ctx context.Context, merchantID uuid.UUID) (*model.FeePlan, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.FeePlan, error)
+}
+
+type PostgresFeePlanRepository struct {
+	db DBTX
+}
+
+func NewPostgresFeePlanRepository(db DBTX) *PostgresFeePlanRepository {
+	return &PostgresFeePlanRepository{db: db}
+}
+
+// GetForMerchant returns the plan assigned to the merchant, falling back to
+// the default plan. It returns nil when neither exists.
+func (r *PostgresFeePlanRepository) GetForMerchant(ctx context.Context, merchantID uuid.UUID) (*model.FeePlan, error) {
+	query := `
+		SELECT p.id, p.name, COALESCE(p.description, ''), p.is_default, p.created_at, p.updated_at
+		FROM fee_plans p
+		LEFT JOIN merchants m ON m.fee_plan_id = p.id AND m.id = $1
+		WHERE m.id IS NOT NULL OR p.is_default
+		ORDER BY m.id IS NULL
+		LIMIT 1
+	`
+
+	return r.get(ctx, query, merchantID)
+}
+
+func (r *PostgresFeePlanRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.FeePlan, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), is_default, created_at, updated_at
+		FROM fee_plans
+		WHERE id = $1
+	`
+
+	return r.get(ctx, query, id)
+}
+
+func (r *PostgresFeePlanRepository) get(ctx context.Context, query string, arg any) (*model.FeePlan, error) {
+	var p model.FeePlan
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		&p.IsDefault,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := r.listRules(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Rules = rules
+	return &p, nil
+}
+
+func (r *PostgresFeePlanRepository) listRules(ctx context.Context, planID uuid.UUID) ([]model.FeeRule, error) {
+	query := `
+		SELECT id, currency, min_monthly_volume, percent_bps, fixed_fee, min_fee, max_fee
+		FROM fee_plan_rules
+		WHERE fee_plan_id = $1
+		ORDER BY currency, min_monthly_volume
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []model.FeeRule
+	for rows.Next() {
+		var rule model.FeeRule
+		var maxFee sql.NullInt64
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.Currency,
+			&rule.MinMonthlyVolume,
+			&rule.PercentBps,
+			&rule.FixedFee,
+			&rule.MinFee,
+			&maxFee,
+		); err != nil {
+			return nil, err
+		}
+		if maxFee.Valid {
+			rule.MaxFee = &maxFee.Int64
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
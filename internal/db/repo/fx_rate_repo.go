@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+type FXRateRepository interface {
+	Rate(ctx context.Context, from, to string, at time.Time) (*model.FXRate, error)
+}
+
+type PostgresFXRateRepository struct {
+	db DBTX
+}
+
+func NewPostgresFXRateRepository(db DBTX) *PostgresFXRateRepository {
+	return &PostgresFXRateRepository{db: db}
+}
+
+// Rate returns the latest rate for the pair effective at the given time, or
+// nil if none has been loaded.
+func (r *PostgresFXRateRepository) Rate(ctx context.Context, from, to string, at time.Time) (*model.FXRate, error) {
+	query := `
+		SELECT from_currency, to_currency, rate::text, source, effective_at
+		FROM fx_rates
+		WHERE from_currency = $1 AND to_currency = $2 AND effective_at <= $3
+		ORDER BY effective_at DESC
+		LIMIT 1
+	`
+
+	var rate model.FXRate
+	err := r.db.QueryRowContext(ctx, query, from, to, at).Scan(&rate.From, &rate.To, &rate.Rate, &rate.Source, &rate.AsOf)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
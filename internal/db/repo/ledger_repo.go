@@ -0,0 +1,294 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type LedgerRepository interface {
+	CreateEntries(ctx context.Context, entries []*model.LedgerEntries) error
+	ApplyBalanceDelta(ctx context.Context, accountID uuid.UUID, delta int64) error
+	LockBalance(ctx context.Context, accountID uuid.UUID) (int64, error)
+	ListByTransaction(ctx context.Context, transactionID uuid.UUID) ([]*model.LedgerEntries, error)
+	ListUnbalanced(ctx context.Context) ([]*model.LedgerViolation, error)
+	ListBalanceDrift(ctx context.Context) ([]*model.LedgerViolation, error)
+	ListMissingAccounts(ctx context.Context) ([]*model.LedgerViolation, error)
+	ListCurrencyMismatches(ctx context.Context) ([]*model.LedgerViolation, error)
+}
+
+type PostgresLedgerRepository struct {
+	db DBTX
+}
+
+func NewPostgresLedgerRepository(db DBTX) *PostgresLedgerRepository {
+	return &PostgresLedgerRepository{db: db}
+}
+
+// CreateEntries inserts ledger legs. A debit leg only sets debit_account_id
+// and a credit leg only sets credit_account_id.
+func (r *PostgresLedgerRepository) CreateEntries(ctx context.Context, entries []*model.LedgerEntries) error {
+	query := `
+		INSERT INTO ledger_entries (id, debit_account_id, credit_account_id, amount, currency, transaction_id, description, metadata, entry_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	for _, e := range entries {
+		metadata := e.Metadata
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		metadataJSON, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.db.ExecContext(
+			ctx,
+			query,
+			e.ID,
+			nullUUID(e.DebitAccountID),
+			nullUUID(e.CreditAccountID),
+			e.Amount,
+			e.Currency,
+			nullUUID(e.TransactionID),
+			e.Description,
+			metadataJSON,
+			e.EntryType,
+			e.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyBalanceDelta atomically adds delta to the account's balance, creating
+// the balance row on first use.
+func (r *PostgresLedgerRepository) ApplyBalanceDelta(ctx context.Context, accountID uuid.UUID, delta int64) error {
+	query := `
+		INSERT INTO account_balances (account_id, balance, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (account_id)
+		DO UPDATE SET balance = account_balances.balance + EXCLUDED.balance, updated_at = NOW()
+	`
+
+	_, err := r.db.ExecContext(ctx, query, accountID, delta)
+	return err
+}
+
+// LockBalance returns the account's balance and locks its balance row until
+// the surrounding transaction ends, so concurrent postings or payouts wait.
+// Accounts without a balance row have a balance of 0.
+func (r *PostgresLedgerRepository) LockBalance(ctx context.Context, accountID uuid.UUID) (int64, error) {
+	query := `SELECT balance FROM account_balances WHERE account_id = $1 FOR UPDATE`
+
+	var balance int64
+	err := r.db.QueryRowContext(ctx, query, accountID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return balance, err
+}
+
+func (r *PostgresLedgerRepository) ListByTransaction(
+	ctx context.Context,
+	transactionID uuid.UUID,
+) ([]*model.LedgerEntries, error) {
+	query := `
+		SELECT
+			id, debit_account_id, credit_account_id, amount, currency,
+			transaction_id, COALESCE(description, ''), metadata, entry_type, created_at
+		FROM ledger_entries
+		WHERE transaction_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*model.LedgerEntries
+
+	for rows.Next() {
+		var e model.LedgerEntries
+		var debitID, creditID, txID uuid.NullUUID
+		var metadataJSON []byte
+
+		err := rows.Scan(
+			&e.ID,
+			&debitID,
+			&creditID,
+			&e.Amount,
+			&e.Currency,
+			&txID,
+			&e.Description,
+			&metadataJSON,
+			&e.EntryType,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.DebitAccountID = debitID.UUID
+		e.CreditAccountID = creditID.UUID
+		e.TransactionID = txID.UUID
+
+		// Unmarshal metadata from JSON
+		if metadataJSON != nil {
+			if err := json.Unmarshal(metadataJSON, &e.Metadata); err != nil {
+				return nil, err
+			}
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ledgerLegAccount and ledgerLegAmount give the account a leg posts to and
+// its signed effect on that account's balance: credits add, debits subtract.
+const (
+	ledgerLegAccount = `CASE WHEN entry_type = 'debit' THEN debit_account_id ELSE credit_account_id END`
+	ledgerLegAmount  = `CASE WHEN entry_type = 'debit' THEN -amount ELSE amount END`
+)
+
+// ListUnbalanced returns each transaction and currency whose legs do not net
+// to zero. Legs without a transaction are summed together.
+func (r *PostgresLedgerRepository) ListUnbalanced(ctx context.Context) ([]*model.LedgerViolation, error) {
+	query := `
+		SELECT transaction_id, currency, SUM(` + ledgerLegAmount + `)::bigint
+		FROM ledger_entries
+		GROUP BY transaction_id, currency
+		HAVING SUM(` + ledgerLegAmount + `) <> 0
+		ORDER BY transaction_id, currency
+	`
+
+	return r.listViolations(ctx, query, func(rows *sql.Rows) (*model.LedgerViolation, error) {
+		v := &model.LedgerViolation{Kind: model.LedgerUnbalanced}
+		var txID uuid.NullUUID
+		if err := rows.Scan(&txID, &v.Currency, &v.Actual); err != nil {
+			return nil, err
+		}
+		v.TransactionID = txID.UUID
+		return v, nil
+	})
+}
+
+// ListBalanceDrift returns each account whose account_balances row differs
+// from the net of its legs. A missing balance row counts as 0.
+func (r *PostgresLedgerRepository) ListBalanceDrift(ctx context.Context) ([]*model.LedgerViolation, error) {
+	query := `
+		WITH ledger AS (
+			SELECT ` + ledgerLegAccount + ` AS account_id, SUM(` + ledgerLegAmount + `)::bigint AS total
+			FROM ledger_entries
+			GROUP BY 1
+		)
+		SELECT COALESCE(b.account_id, l.account_id), COALESCE(a.currency, ''), COALESCE(b.balance, 0), COALESCE(l.total, 0)
+		FROM account_balances b
+		FULL OUTER JOIN ledger l ON l.account_id = b.account_id
+		LEFT JOIN accounts a ON a.id = COALESCE(b.account_id, l.account_id)
+		WHERE COALESCE(b.account_id, l.account_id) IS NOT NULL
+			AND COALESCE(b.balance, 0) <> COALESCE(l.total, 0)
+		ORDER BY 1
+	`
+
+	return r.listViolations(ctx, query, func(rows *sql.Rows) (*model.LedgerViolation, error) {
+		v := &model.LedgerViolation{Kind: model.LedgerBalanceDrift}
+		if err := rows.Scan(&v.AccountID, &v.Currency, &v.Expected, &v.Actual); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+}
+
+// ListMissingAccounts returns legs that post to no account or to one that
+// does not exist.
+func (r *PostgresLedgerRepository) ListMissingAccounts(ctx context.Context) ([]*model.LedgerViolation, error) {
+	query := `
+		SELECT e.id, e.transaction_id, ` + ledgerLegAccount + `, e.currency, e.amount
+		FROM ledger_entries e
+		LEFT JOIN accounts a ON a.id = (` + ledgerLegAccount + `)
+		WHERE a.id IS NULL
+		ORDER BY e.created_at, e.id
+	`
+
+	return r.listViolations(ctx, query, func(rows *sql.Rows) (*model.LedgerViolation, error) {
+		v := &model.LedgerViolation{Kind: model.LedgerMissingAccount}
+		var txID, accountID uuid.NullUUID
+		if err := rows.Scan(&v.EntryID, &txID, &accountID, &v.Currency, &v.Actual); err != nil {
+			return nil, err
+		}
+		v.TransactionID = txID.UUID
+		v.AccountID = accountID.UUID
+		return v, nil
+	})
+}
+
+// ListCurrencyMismatches returns legs in a different currency from the
+// account they post to.
+func (r *PostgresLedgerRepository) ListCurrencyMismatches(ctx context.Context) ([]*model.LedgerViolation, error) {
+	query := `
+		SELECT e.id, e.transaction_id, a.id, e.currency, a.currency, e.amount
+		FROM ledger_entries e
+		JOIN accounts a ON a.id = (` + ledgerLegAccount + `)
+		WHERE e.currency <> a.currency
+		ORDER BY e.created_at, e.id
+	`
+
+	return r.listViolations(ctx, query, func(rows *sql.Rows) (*model.LedgerViolation, error) {
+		v := &model.LedgerViolation{Kind: model.LedgerCurrencyMismatch}
+		var txID uuid.NullUUID
+		if err := rows.Scan(&v.EntryID, &txID, &v.AccountID, &v.Currency, &v.AccountCurrency, &v.Actual); err != nil {
+			return nil, err
+		}
+		v.TransactionID = txID.UUID
+		return v, nil
+	})
+}
+
+func (r *PostgresLedgerRepository) listViolations(
+	ctx context.Context,
+	query string,
+	scan func(rows *sql.Rows) (*model.LedgerViolation, error),
+) ([]*model.LedgerViolation, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []*model.LedgerViolation
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// nullUUID maps uuid.Nil to SQL NULL.
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// nullUUIDPtr maps a nil pointer (or uuid.Nil) to SQL NULL.
+func nullUUIDPtr(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return nullUUID(*id)
+}
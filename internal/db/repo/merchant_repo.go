@@ -0,0 +1,237 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type MerchantRepository interface {
+	Create(ctx context.Context, m *model.Merchant) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Merchant, error)
+	List(ctx context.Context, status model.MerchantStatus, limit, offset int) ([]*model.Merchant, error)
+	Update(ctx context.Context, m *model.Merchant) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	SetWebhookSecret(ctx context.Context, id uuid.UUID, secret string) error
+}
+
+type PostgresMerchantRepository struct {
+	db DBTX
+}
+
+func NewPostgresMerchantRepository(db DBTX) *PostgresMerchantRepository {
+	return &PostgresMerchantRepository{db: db}
+}
+
+const merchantColumns = `
+	id, name, status, settlement_currency, bank_details, COALESCE(webhook_url, ''),
+	COALESCE(webhook_secret, ''), fee_plan_id, payout_schedule, payout_weekday, payout_threshold,
+	metadata, created_at, updated_at
+`
+
+func (r *PostgresMerchantRepository) Create(ctx context.Context, m *model.Merchant) error {
+	query := `
+		INSERT INTO merchants (id, name, status, settlement_currency, bank_details, webhook_url, webhook_secret, fee_plan_id,
+			payout_schedule, payout_weekday, payout_threshold, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14)
+	`
+
+	bankJSON, metadataJSON, err := marshalMerchant(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
+		m.ID,
+		m.Name,
+		m.Status,
+		m.SettlementCurrency,
+		bankJSON,
+		m.WebhookURL,
+		m.WebhookSecret,
+		nullUUIDPtr(m.FeePlanID),
+		m.Payout.Schedule,
+		int(m.Payout.Weekday),
+		m.Payout.Threshold,
+		metadataJSON,
+		m.CreatedAt,
+		m.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PostgresMerchantRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Merchant, error) {
+	query := `SELECT ` + merchantColumns + ` FROM merchants WHERE id = $1`
+
+	m, err := scanMerchant(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// List returns merchants newest first, optionally filtered by status.
+func (r *PostgresMerchantRepository) List(ctx context.Context, status model.MerchantStatus, limit, offset int) ([]*model.Merchant, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := `
+		SELECT ` + merchantColumns + `
+		FROM merchants
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, string(status), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merchants []*model.Merchant
+	for rows.Next() {
+		m, err := scanMerchant(rows)
+		if err != nil {
+			return nil, err
+		}
+		merchants = append(merchants, m)
+	}
+
+	return merchants, rows.Err()
+}
+
+// Update saves the merchant's editable fields. The webhook secret is only
+// changed through SetWebhookSecret.
+func (r *PostgresMerchantRepository) Update(ctx context.Context, m *model.Merchant) error {
+	query := `
+		UPDATE merchants
+		SET name = $2, status = $3, settlement_currency = $4, bank_details = $5,
+			webhook_url = NULLIF($6, ''), fee_plan_id = $7, payout_schedule = $8, payout_weekday = $9,
+			payout_threshold = $10, metadata = $11, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	bankJSON, metadataJSON, err := marshalMerchant(m)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.QueryRowContext(ctx, query,
+		m.ID,
+		m.Name,
+		m.Status,
+		m.SettlementCurrency,
+		bankJSON,
+		m.WebhookURL,
+		nullUUIDPtr(m.FeePlanID),
+		m.Payout.Schedule,
+		int(m.Payout.Weekday),
+		m.Payout.Threshold,
+		metadataJSON,
+	).Scan(&m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return model.ErrMerchantNotFound
+	}
+	return err
+}
+
+func (r *PostgresMerchantRepository) SetWebhookSecret(ctx context.Context, id uuid.UUID, secret string) error {
+	query := `UPDATE merchants SET webhook_secret = $2, updated_at = NOW() WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id, secret)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return model.ErrMerchantNotFound
+	}
+	return nil
+}
+
+// Delete removes a merchant that has never transacted. It reports false when
+// the merchant doesn't exist or still has transactions or accounts.
+func (r *PostgresMerchantRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM merchants
+		WHERE id = $1
+			AND NOT EXISTS (SELECT 1 FROM transactions WHERE merchant_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM accounts WHERE owner_id = $1)
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanMerchant(row rowScanner) (*model.Merchant, error) {
+	var m model.Merchant
+	var bankJSON, metadataJSON []byte
+	var feePlanID uuid.NullUUID
+	var payoutWeekday int
+
+	if err := row.Scan(
+		&m.ID,
+		&m.Name,
+		&m.Status,
+		&m.SettlementCurrency,
+		&bankJSON,
+		&m.WebhookURL,
+		&m.WebhookSecret,
+		&feePlanID,
+		&m.Payout.Schedule,
+		&payoutWeekday,
+		&m.Payout.Threshold,
+		&metadataJSON,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if feePlanID.Valid {
+		m.FeePlanID = &feePlanID.UUID
+	}
+	m.Payout.Weekday = time.Weekday(payoutWeekday)
+	if bankJSON != nil {
+		if err := json.Unmarshal(bankJSON, &m.BankDetails); err != nil {
+			return nil, err
+		}
+	}
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &m.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+func marshalMerchant(m *model.Merchant) (bankJSON, metadataJSON []byte, err error) {
+	bankJSON, err = json.Marshal(m.BankDetails)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := m.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadataJSON, err = json.Marshal(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bankJSON, metadataJSON, nil
+}
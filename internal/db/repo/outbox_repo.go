@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, msg *model.OutboxMessage) error
+	FetchUnsent(ctx context.Context, limit int) ([]*model.OutboxMessage, error)
+	MarkSent(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error
+}
+
+type PostgresOutboxRepository struct {
+	db DBTX
+}
+
+func NewPostgresOutboxRepository(db DBTX) *PostgresOutboxRepository {
+	return &PostgresOutboxRepository{db: db}
+}
+
+func (r *PostgresOutboxRepository) Enqueue(ctx context.Context, msg *model.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (id, topic, message_key, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, msg.ID, msg.Topic, msg.Key, msg.Payload, msg.CreatedAt)
+	return err
+}
+
+// FetchUnsent locks up to limit unsent messages in insertion order, leaving
+// out failed ones whose next attempt is not due yet. Rows locked by a
+// concurrent relay are skipped, so several relays can drain the outbox in
+// parallel. Must be called inside a transaction.
+func (r *PostgresOutboxRepository) FetchUnsent(ctx context.Context, limit int) ([]*model.OutboxMessage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, topic, message_key, payload, attempts, COALESCE(last_error, ''), created_at
+		FROM outbox
+		WHERE sent_at IS NULL AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*model.OutboxMessage
+
+	for rows.Next() {
+		var m model.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Payload, &m.Attempts, &m.LastError, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+func (r *PostgresOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE outbox SET sent_at = NOW(), attempts = attempts + 1, last_error = NULL, next_attempt_at = NULL WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed records a failed publish. The message is retried on the next
+// pass, or not before nextAttemptAt when it is set.
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, sql.NullString{String: lastError, Valid: lastError != ""}, nextAttemptAt, id)
+	return err
+}
@@ -0,0 +1,349 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type PayoutRepository interface {
+	Create(ctx context.Context, p *model.Payout) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Payout, error)
+	List(ctx context.Context, filter model.PayoutFilter) ([]*model.Payout, error)
+	ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]*model.Payout, error)
+	ListPayable(ctx context.Context) ([]*model.PayableAccount, error)
+	LastCreatedAt(ctx context.Context, merchantAccountID uuid.UUID) (time.Time, error)
+	AssignSettlements(ctx context.Context, payoutID, merchantAccountID uuid.UUID) (int, error)
+	SetConnector(ctx context.Context, id uuid.UUID, connectorName, externalID string) error
+	MarkPaid(ctx context.Context, id uuid.UUID, externalID string, paidAt time.Time, metadata map[string]any) (bool, error)
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string, metadata map[string]any) (bool, error)
+}
+
+type PostgresPayoutRepository struct {
+	db DBTX
+}
+
+func NewPostgresPayoutRepository(db DBTX) *PostgresPayoutRepository {
+	return &PostgresPayoutRepository{db: db}
+}
+
+const payoutColumns = `
+	id, merchant_id, merchant_account_id, amount, currency, status, schedule, COALESCE(connector, ''),
+	COALESCE(external_id, ''), bank_details, settlement_count, COALESCE(failure_reason, ''), metadata,
+	paid_at, created_at, updated_at
+`
+
+func (r *PostgresPayoutRepository) Create(ctx context.Context, p *model.Payout) error {
+	query := `
+		INSERT INTO payouts (id, merchant_id, merchant_account_id, amount, currency, status, schedule, connector, bank_details, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12)
+	`
+
+	bankJSON, err := json.Marshal(p.BankDetails)
+	if err != nil {
+		return err
+	}
+	metadata := p.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
+		p.ID,
+		p.MerchantID,
+		p.MerchantAccountID,
+		p.Amount,
+		p.Currency,
+		p.Status,
+		p.Schedule,
+		p.Connector,
+		bankJSON,
+		metadataJSON,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PostgresPayoutRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Payout, error) {
+	query := `SELECT ` + payoutColumns + ` FROM payouts WHERE id = $1`
+
+	p, err := scanPayout(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// List returns payouts newest first.
+func (r *PostgresPayoutRepository) List(ctx context.Context, filter model.PayoutFilter) ([]*model.Payout, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := `
+		SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE ($1::uuid IS NULL OR merchant_id = $1)
+			AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	return r.query(ctx, query, nullUUIDPtr(filter.MerchantID), string(filter.Status), filter.Limit, filter.Offset)
+}
+
+// ListPending returns payouts created before createdBefore that the
+// connector has not accepted or declined yet, oldest first.
+func (r *PostgresPayoutRepository) ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]*model.Payout, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE status = 'pending' AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	return r.query(ctx, query, createdBefore, limit)
+}
+
+// ListPayable returns the accounts of active merchants with automatic
+// payouts and a positive balance, with the time of their last payout.
+func (r *PostgresPayoutRepository) ListPayable(ctx context.Context) ([]*model.PayableAccount, error) {
+	query := `
+		SELECT m.id, a.id, a.currency, b.balance, m.payout_schedule, m.payout_weekday, m.payout_threshold,
+			m.bank_details, last.created_at
+		FROM accounts a
+		JOIN merchants m ON m.id = a.owner_id
+		JOIN account_balances b ON b.account_id = a.id
+		LEFT JOIN LATERAL (
+			SELECT created_at FROM payouts p
+			WHERE p.merchant_account_id = a.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) last ON TRUE
+		WHERE a.account_type = 'merchant'
+			AND m.status = 'active'
+			AND m.payout_schedule <> 'manual'
+			AND b.balance > 0
+		ORDER BY a.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*model.PayableAccount
+	for rows.Next() {
+		var a model.PayableAccount
+		var weekday int
+		var bankJSON []byte
+		var last sql.NullTime
+		if err := rows.Scan(
+			&a.MerchantID,
+			&a.AccountID,
+			&a.Currency,
+			&a.Balance,
+			&a.Policy.Schedule,
+			&weekday,
+			&a.Policy.Threshold,
+			&bankJSON,
+			&last,
+		); err != nil {
+			return nil, err
+		}
+		a.Policy.Weekday = time.Weekday(weekday)
+		if last.Valid {
+			a.LastPayoutAt = last.Time
+		}
+		if bankJSON != nil {
+			if err := json.Unmarshal(bankJSON, &a.BankDetails); err != nil {
+				return nil, err
+			}
+		}
+		accounts = append(accounts, &a)
+	}
+
+	return accounts, rows.Err()
+}
+
+// LastCreatedAt returns when the account's latest payout was created, or the
+// zero time if it has none.
+func (r *PostgresPayoutRepository) LastCreatedAt(ctx context.Context, merchantAccountID uuid.UUID) (time.Time, error) {
+	query := `SELECT MAX(created_at) FROM payouts WHERE merchant_account_id = $1`
+
+	var last sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, merchantAccountID).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+	return last.Time, nil
+}
+
+// AssignSettlements batches the account's successful settlements that
+// haven't been paid out into the payout and records how many there were.
+func (r *PostgresPayoutRepository) AssignSettlements(ctx context.Context, payoutID, merchantAccountID uuid.UUID) (int, error) {
+	query := `
+		WITH batched AS (
+			UPDATE settlements SET payout_id = $1, updated_at = NOW()
+			WHERE merchant_account_id = $2 AND status = 'success' AND payout_id IS NULL
+			RETURNING id
+		)
+		UPDATE payouts SET settlement_count = (SELECT COUNT(*) FROM batched), updated_at = NOW()
+		WHERE id = $1
+		RETURNING settlement_count
+	`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, payoutID, merchantAccountID).Scan(&count)
+	return count, err
+}
+
+// SetConnector records which connector the payout was sent through and,
+// once known, the provider's reference for it.
+func (r *PostgresPayoutRepository) SetConnector(ctx context.Context, id uuid.UUID, connectorName, externalID string) error {
+	query := `
+		UPDATE payouts
+		SET connector = $2, external_id = COALESCE(NULLIF($3, ''), external_id), updated_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id, connectorName, externalID)
+	return err
+}
+
+// MarkPaid completes a pending payout. It reports false if the payout was
+// no longer pending.
+func (r *PostgresPayoutRepository) MarkPaid(ctx context.Context, id uuid.UUID, externalID string, paidAt time.Time, metadata map[string]any) (bool, error) {
+	query := `
+		UPDATE payouts
+		SET status = 'paid', external_id = COALESCE(NULLIF($2, ''), external_id), paid_at = $3,
+			metadata = metadata || $4::jsonb, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	metadataJSON, err := marshalPatch(metadata)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, query, id, externalID, paidAt, metadataJSON)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MarkFailed fails a pending payout and releases its settlements so they are
+// batched into the next one. It reports false if the payout was no longer
+// pending. Call it in the same transaction as the reversal posting.
+func (r *PostgresPayoutRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string, metadata map[string]any) (bool, error) {
+	query := `
+		UPDATE payouts
+		SET status = 'failed', failure_reason = $2, metadata = metadata || $3::jsonb, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	metadataJSON, err := marshalPatch(metadata)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, query, id, reason, metadataJSON)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE settlements SET payout_id = NULL, updated_at = NOW() WHERE payout_id = $1`, id)
+	return err == nil, err
+}
+
+func (r *PostgresPayoutRepository) query(ctx context.Context, query string, args ...any) ([]*model.Payout, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payouts []*model.Payout
+	for rows.Next() {
+		p, err := scanPayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, p)
+	}
+
+	return payouts, rows.Err()
+}
+
+func marshalPatch(patch map[string]any) ([]byte, error) {
+	if patch == nil {
+		patch = map[string]any{}
+	}
+	return json.Marshal(patch)
+}
+
+func scanPayout(row rowScanner) (*model.Payout, error) {
+	var p model.Payout
+	var bankJSON, metadataJSON []byte
+	var paidAt sql.NullTime
+
+	if err := row.Scan(
+		&p.ID,
+		&p.MerchantID,
+		&p.MerchantAccountID,
+		&p.Amount,
+		&p.Currency,
+		&p.Status,
+		&p.Schedule,
+		&p.Connector,
+		&p.ExternalID,
+		&bankJSON,
+		&p.SettlementCount,
+		&p.FailureReason,
+		&metadataJSON,
+		&paidAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if paidAt.Valid {
+		p.PaidAt = &paidAt.Time
+	}
+	if bankJSON != nil {
+		if err := json.Unmarshal(bankJSON, &p.BankDetails); err != nil {
+			return nil, err
+		}
+	}
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &p.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,356 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type ReconciliationRepository interface {
+	Create(ctx context.Context, l *model.ReconciliationLogs) (bool, error)
+	MatchedKey(ctx context.Context, settlementID uuid.UUID) (string, error)
+	CaseForRefund(ctx context.Context, refundID uuid.UUID) (uuid.UUID, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error)
+	List(ctx context.Context, filter model.ReconciliationFilter) ([]*model.ReconciliationLogs, error)
+	Assign(ctx context.Context, id uuid.UUID, assignee string, at time.Time) (bool, error)
+	Resolve(ctx context.Context, id uuid.UUID, resolution model.ReconResolution, resolvedBy string, at time.Time, metadata map[string]any) (bool, error)
+	AddNote(ctx context.Context, note *model.ReconciliationNote) error
+	ListNotes(ctx context.Context, reconciliationID uuid.UUID) ([]*model.ReconciliationNote, error)
+	ListTransactionTotals(ctx context.Context, after model.ReconCursor, before time.Time, limit int) ([]*model.TransactionTotal, error)
+}
+
+type PostgresReconciliationRepository struct {
+	db DBTX
+}
+
+func NewPostgresReconciliationRepository(db DBTX) *PostgresReconciliationRepository {
+	return &PostgresReconciliationRepository{db: db}
+}
+
+const reconciliationColumns = `
+	id, entity_type, entity_id, COALESCE(expected_amount, 0), COALESCE(actual_amount, 0), status,
+	COALESCE(notes, ''), metadata, COALESCE(assigned_to, ''), assigned_at, COALESCE(resolution, ''),
+	COALESCE(resolved_by, ''), resolved_at, created_at, updated_at
+`
+
+// Create inserts a log row. A row whose dedupe key is already recorded is
+// skipped and Create reports false.
+func (r *PostgresReconciliationRepository) Create(ctx context.Context, l *model.ReconciliationLogs) (bool, error) {
+	query := `
+		INSERT INTO reconciliation_logs (id, entity_type, entity_id, expected_amount, actual_amount, status, notes, metadata, dedupe_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $10)
+		ON CONFLICT (dedupe_key) DO NOTHING
+	`
+
+	metadataJSON, err := marshalPatch(l.Metadata)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, query,
+		l.ID,
+		l.EntityType,
+		nullUUID(l.EntityID),
+		l.ExpectedAmount,
+		l.ActualAmount,
+		l.Status,
+		l.Notes,
+		metadataJSON,
+		l.DedupeKey,
+		l.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MatchedKey returns the dedupe key of the statement line a settlement was
+// already reconciled against (matched, mismatched or resolved), or "" if
+// none was.
+func (r *PostgresReconciliationRepository) MatchedKey(ctx context.Context, settlementID uuid.UUID) (string, error) {
+	query := `
+		SELECT COALESCE(dedupe_key, '')
+		FROM reconciliation_logs
+		WHERE entity_type = 'settlements' AND entity_id = $1
+			AND status IN ('matched', 'mismatch', 'resolved')
+			AND dedupe_key LIKE 'stmt:%'
+		ORDER BY created_at
+		LIMIT 1
+	`
+
+	var key string
+	err := r.db.QueryRowContext(ctx, query, settlementID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return key, err
+}
+
+// CaseForRefund returns the resolved case a refund was linked to, or
+// uuid.Nil if none was.
+func (r *PostgresReconciliationRepository) CaseForRefund(ctx context.Context, refundID uuid.UUID) (uuid.UUID, error) {
+	query := `
+		SELECT id
+		FROM reconciliation_logs
+		WHERE status = 'resolved' AND resolution = 'refund'
+			AND metadata->'resolution'->>'refund_id' = $1
+		ORDER BY resolved_at
+		LIMIT 1
+	`
+
+	var id uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, refundID.String()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	return id, err
+}
+
+func (r *PostgresReconciliationRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	return r.get(ctx, `SELECT `+reconciliationColumns+` FROM reconciliation_logs WHERE id = $1`, id)
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *PostgresReconciliationRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	return r.get(ctx, `SELECT `+reconciliationColumns+` FROM reconciliation_logs WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *PostgresReconciliationRepository) get(ctx context.Context, query string, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	l, err := scanReconciliationLog(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+// List returns the rows matching filter, newest first. Without a status it
+// returns the open cases.
+func (r *PostgresReconciliationRepository) List(ctx context.Context, filter model.ReconciliationFilter) ([]*model.ReconciliationLogs, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := `
+		SELECT ` + reconciliationColumns + `
+		FROM reconciliation_logs
+		WHERE CASE WHEN $1 = '' THEN status IN ('mismatch', 'unmatched') ELSE status = $1 END
+			AND ($2 = '' OR entity_type = $2)
+			AND ($3 = '' OR assigned_to = $3)
+			AND ($4::timestamp IS NULL OR created_at >= $4)
+			AND ($5::timestamp IS NULL OR created_at < $5)
+		ORDER BY created_at DESC, id
+		LIMIT $6 OFFSET $7
+	`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		filter.Status,
+		filter.EntityType,
+		filter.AssignedTo,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*model.ReconciliationLogs
+	for rows.Next() {
+		l, err := scanReconciliationLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// Assign sets (or, with an empty assignee, clears) the assignee of an open
+// case. It reports false if the case is not open.
+func (r *PostgresReconciliationRepository) Assign(ctx context.Context, id uuid.UUID, assignee string, at time.Time) (bool, error) {
+	query := `
+		UPDATE reconciliation_logs
+		SET assigned_to = NULLIF($2, ''),
+			assigned_at = CASE WHEN $2 = '' THEN NULL ELSE $3::timestamp END,
+			updated_at = $3
+		WHERE id = $1 AND status IN ('mismatch', 'unmatched')
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, assignee, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Resolve closes an open case and merges metadata into the row's metadata.
+// It reports false if the case is not open.
+func (r *PostgresReconciliationRepository) Resolve(
+	ctx context.Context,
+	id uuid.UUID,
+	resolution model.ReconResolution,
+	resolvedBy string,
+	at time.Time,
+	metadata map[string]any,
+) (bool, error) {
+	query := `
+		UPDATE reconciliation_logs
+		SET status = 'resolved', resolution = $2, resolved_by = $3, resolved_at = $4, updated_at = $4,
+			metadata = metadata || $5::jsonb
+		WHERE id = $1 AND status IN ('mismatch', 'unmatched')
+	`
+
+	patch, err := marshalPatch(metadata)
+	if err != nil {
+		return false, err
+	}
+	res, err := r.db.ExecContext(ctx, query, id, string(resolution), resolvedBy, at, patch)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresReconciliationRepository) AddNote(ctx context.Context, note *model.ReconciliationNote) error {
+	query := `
+		INSERT INTO reconciliation_notes (id, reconciliation_id, author, body, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, note.ID, note.ReconciliationID, note.Author, note.Body, note.CreatedAt)
+	return err
+}
+
+// ListNotes returns a case's notes, oldest first.
+func (r *PostgresReconciliationRepository) ListNotes(ctx context.Context, reconciliationID uuid.UUID) ([]*model.ReconciliationNote, error) {
+	query := `
+		SELECT id, reconciliation_id, author, body, created_at
+		FROM reconciliation_notes
+		WHERE reconciliation_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, reconciliationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*model.ReconciliationNote
+	for rows.Next() {
+		var n model.ReconciliationNote
+		if err := rows.Scan(&n.ID, &n.ReconciliationID, &n.Author, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, &n)
+	}
+	return notes, rows.Err()
+}
+
+// ListTransactionTotals returns up to limit finished transactions with the
+// sum of their successful settlements, in (updated_at, id) order after the
+// cursor and updated before the given time. Failed attempts have settlement
+// rows of their own and are left out; a failed transaction expects nothing
+// settled. Settlements count with their source amount, in the transaction's
+// currency whatever currency they paid out in.
+func (r *PostgresReconciliationRepository) ListTransactionTotals(
+	ctx context.Context,
+	after model.ReconCursor,
+	before time.Time,
+	limit int,
+) ([]*model.TransactionTotal, error) {
+	query := `
+		SELECT t.id,
+			CASE WHEN t.status = 'failed' THEN 0 ELSE t.amount END::bigint,
+			t.currency, t.updated_at,
+			COALESCE(SUM(COALESCE(s.source_amount, s.amount)), 0)::bigint
+		FROM transactions t
+		LEFT JOIN settlements s ON s.external_reference = t.id::text AND s.status = 'success'
+		WHERE t.status IN ('completed', 'failed', 'partially_refunded', 'refunded')
+			AND (t.updated_at, t.id) > ($1, $2)
+			AND t.updated_at < $3
+		GROUP BY t.id, t.amount, t.status, t.currency, t.updated_at
+		ORDER BY t.updated_at, t.id
+		LIMIT $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, after.UpdatedAt, after.ID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*model.TransactionTotal
+	for rows.Next() {
+		var t model.TransactionTotal
+		if err := rows.Scan(&t.TransactionID, &t.Amount, &t.Currency, &t.UpdatedAt, &t.Settled); err != nil {
+			return nil, err
+		}
+		totals = append(totals, &t)
+	}
+	return totals, rows.Err()
+}
+
+func scanReconciliationLog(row rowScanner) (*model.ReconciliationLogs, error) {
+	var l model.ReconciliationLogs
+	var entityID uuid.NullUUID
+	var metadataJSON []byte
+	var assignedAt, resolvedAt sql.NullTime
+
+	err := row.Scan(
+		&l.ID,
+		&l.EntityType,
+		&entityID,
+		&l.ExpectedAmount,
+		&l.ActualAmount,
+		&l.Status,
+		&l.Notes,
+		&metadataJSON,
+		&l.AssignedTo,
+		&assignedAt,
+		&l.Resolution,
+		&l.ResolvedBy,
+		&resolvedAt,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	l.EntityID = entityID.UUID
+	if assignedAt.Valid {
+		l.AssignedAt = &assignedAt.Time
+	}
+	if resolvedAt.Valid {
+		l.ResolvedAt = &resolvedAt.Time
+	}
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &l.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &l, nil
+}
+
+// nullTime maps the zero time to SQL NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type ReconciliationRunRepository interface {
+	Create(ctx context.Context, run *model.ReconciliationRun) error
+	Checkpoint(ctx context.Context, run *model.ReconciliationRun) error
+	Finish(ctx context.Context, run *model.ReconciliationRun) error
+	LastHighWater(ctx context.Context, kind string) (model.ReconCursor, error)
+	InterruptRunning(ctx context.Context, kind string) (int, error)
+}
+
+type PostgresReconciliationRunRepository struct {
+	db DBTX
+}
+
+func NewPostgresReconciliationRunRepository(db DBTX) *PostgresReconciliationRunRepository {
+	return &PostgresReconciliationRunRepository{db: db}
+}
+
+func (r *PostgresReconciliationRunRepository) Create(ctx context.Context, run *model.ReconciliationRun) error {
+	query := `
+		INSERT INTO reconciliation_runs (id, kind, status, from_updated_at, from_id, high_water_updated_at, high_water_id, started_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $4, $5, $6, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		run.ID,
+		run.Kind,
+		string(run.Status),
+		nullTime(run.From.UpdatedAt),
+		nullUUID(run.From.ID),
+		run.StartedAt,
+	)
+	return err
+}
+
+// Checkpoint saves the run's high-water mark and counts so far.
+func (r *PostgresReconciliationRunRepository) Checkpoint(ctx context.Context, run *model.ReconciliationRun) error {
+	query := `
+		UPDATE reconciliation_runs
+		SET high_water_updated_at = $2, high_water_id = $3, checked = $4, matched = $5, mismatched = $6,
+			errors = $7, summary = $8, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	summary, err := json.Marshal(run.Currencies)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, query,
+		run.ID,
+		nullTime(run.HighWater.UpdatedAt),
+		nullUUID(run.HighWater.ID),
+		run.Checked,
+		run.Matched,
+		run.Mismatched,
+		run.Errors,
+		summaryJSON(summary),
+	)
+	return err
+}
+
+// Finish saves the final counts and the run's status and error.
+func (r *PostgresReconciliationRunRepository) Finish(ctx context.Context, run *model.ReconciliationRun) error {
+	if err := r.Checkpoint(ctx, run); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE reconciliation_runs
+		SET status = $2, error = NULLIF($3, ''), finished_at = $4, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, run.ID, string(run.Status), run.Error, run.FinishedAt)
+	return err
+}
+
+// LastHighWater returns the high-water mark of the latest run of kind that
+// recorded one, or the zero cursor if there is none.
+func (r *PostgresReconciliationRunRepository) LastHighWater(ctx context.Context, kind string) (model.ReconCursor, error) {
+	query := `
+		SELECT high_water_updated_at, high_water_id
+		FROM reconciliation_runs
+		WHERE kind = $1 AND high_water_updated_at IS NOT NULL
+		ORDER BY started_at DESC
+		LIMIT 1
+	`
+
+	var updatedAt sql.NullTime
+	var id uuid.NullUUID
+	err := r.db.QueryRowContext(ctx, query, kind).Scan(&updatedAt, &id)
+	if err == sql.ErrNoRows {
+		return model.ReconCursor{}, nil
+	}
+	if err != nil {
+		return model.ReconCursor{}, err
+	}
+	return model.ReconCursor{UpdatedAt: updatedAt.Time, ID: id.UUID}, nil
+}
+
+// InterruptRunning marks runs of kind still recorded as running, left by a
+// job that died, as interrupted.
+func (r *PostgresReconciliationRunRepository) InterruptRunning(ctx context.Context, kind string) (int, error) {
+	query := `
+		UPDATE reconciliation_runs
+		SET status = 'interrupted', finished_at = $2, updated_at = NOW()
+		WHERE kind = $1 AND status = 'running'
+	`
+
+	res, err := r.db.ExecContext(ctx, query, kind, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func summaryJSON(b []byte) []byte {
+	if string(b) == "null" {
+		return []byte("{}")
+	}
+	return b
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type RefundRepository interface {
+	Create(ctx context.Context, refund *model.Refund) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Refund, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Refund, error)
+	ListByTransaction(ctx context.Context, transactionID uuid.UUID) ([]*model.Refund, error)
+	SumActiveByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error)
+	SumCompletedByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status model.RefundStatus) error
+	MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error
+}
+
+type PostgresRefundRepository struct {
+	db DBTX
+}
+
+func NewPostgresRefundRepository(db DBTX) *PostgresRefundRepository {
+	return &PostgresRefundRepository{db: db}
+}
+
+const refundColumns = `
+	id, transaction_id, amount, currency, status,
+	COALESCE(reason, ''), metadata, created_at, updated_at
+`
+
+func (r *PostgresRefundRepository) Create(ctx context.Context, refund *model.Refund) error {
+	query := `
+		INSERT INTO refunds (id, transaction_id, amount, currency, status, reason, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	metadata := refund.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		query,
+		refund.ID,
+		refund.TransactionID,
+		refund.Amount,
+		refund.Currency,
+		refund.Status,
+		refund.Reason,
+		metadataJSON,
+		refund.CreatedAt,
+		refund.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PostgresRefundRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Refund, error) {
+	return r.get(ctx, `SELECT `+refundColumns+` FROM refunds WHERE id = $1`, id)
+}
+
+// GetByIDForUpdate locks the refund row until the surrounding transaction ends.
+func (r *PostgresRefundRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Refund, error) {
+	return r.get(ctx, `SELECT `+refundColumns+` FROM refunds WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *PostgresRefundRepository) get(ctx context.Context, query string, id uuid.UUID) (*model.Refund, error) {
+	refund, err := scanRefund(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (r *PostgresRefundRepository) ListByTransaction(ctx context.Context, transactionID uuid.UUID) ([]*model.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE transaction_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []*model.Refund
+
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+// SumActiveByTransaction totals pending and completed refunds, i.e. everything
+// that counts against the transaction's refundable amount.
+func (r *PostgresRefundRepository) SumActiveByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)::bigint
+		FROM refunds
+		WHERE transaction_id = $1 AND status <> 'failed'
+	`
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, query, transactionID).Scan(&total)
+	return total, err
+}
+
+// SumCompletedByTransaction totals refunds that have actually been paid out.
+func (r *PostgresRefundRepository) SumCompletedByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)::bigint
+		FROM refunds
+		WHERE transaction_id = $1 AND status = 'completed'
+	`
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, query, transactionID).Scan(&total)
+	return total, err
+}
+
+func (r *PostgresRefundRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status model.RefundStatus) error {
+	query := `
+		UPDATE refunds SET status = $1, updated_at = NOW() WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	return err
+}
+
+// MergeMetadata shallow-merges patch into the refund's metadata.
+func (r *PostgresRefundRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error {
+	query := `
+		UPDATE refunds SET metadata = metadata || $1::jsonb, updated_at = NOW() WHERE id = $2
+	`
+
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, patchJSON, id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRefund(row rowScanner) (*model.Refund, error) {
+	var refund model.Refund
+	var metadataJSON []byte
+
+	err := row.Scan(
+		&refund.ID,
+		&refund.TransactionID,
+		&refund.Amount,
+		&refund.Currency,
+		&refund.Status,
+		&refund.Reason,
+		&metadataJSON,
+		&refund.CreatedAt,
+		&refund.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal metadata from JSON
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &refund.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &refund, nil
+}
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+type RoutingRuleRepository interface {
+	ListEnabled(ctx context.Context) ([]*model.RoutingRule, error)
+}
+
+type PostgresRoutingRuleRepository struct {
+	db DBTX
+}
+
+func NewPostgresRoutingRuleRepository(db DBTX) *PostgresRoutingRuleRepository {
+	return &PostgresRoutingRuleRepository{db: db}
+}
+
+// ListEnabled returns enabled rules in evaluation order.
+func (r *PostgresRoutingRuleRepository) ListEnabled(ctx context.Context) ([]*model.RoutingRule, error) {
+	query := `
+		SELECT id, name, priority, enabled, conditions, targets, created_at, updated_at
+		FROM routing_rules
+		WHERE enabled
+		ORDER BY priority, name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*model.RoutingRule
+	for rows.Next() {
+		var rule model.RoutingRule
+		var conditionsJSON, targetsJSON []byte
+
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.Name,
+			&rule.Priority,
+			&rule.Enabled,
+			&conditionsJSON,
+			&targetsJSON,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(conditionsJSON, &rule.Conditions); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(targetsJSON, &rule.Targets); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, rows.Err()
+}
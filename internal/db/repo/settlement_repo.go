@@ -4,30 +4,48 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
 )
 
 type SettlementRepository interface {
 	CreateSettlementAttempt(ctx context.Context, s *model.Settlements) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, status model.SettlementStatus) error
+	MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error
+	GetSuccessfulByReference(ctx context.Context, externalReference string) (*model.Settlements, error)
+	GetPendingByReference(ctx context.Context, externalReference string) (*model.Settlements, error)
 	GetByID(ctx context.Context, id string) (*model.Settlements, error)
+	SumSuccessfulSince(ctx context.Context, merchantAccountID uuid.UUID, since time.Time) (int64, error)
 	List(ctx context.Context, limit int, offset int) ([]*model.Settlements, error)
+	ListForExport(ctx context.Context, filter model.SettlementExportFilter) ([]*model.SettlementExportLine, error)
+	ListUnreconciled(ctx context.Context, currency string, from, to time.Time) ([]*model.Settlements, error)
 }
 
 type PostgresSettlementRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewPostgresSettlementRepository(db *sql.DB) *PostgresSettlementRepository {
+func NewPostgresSettlementRepository(db DBTX) *PostgresSettlementRepository {
 	return &PostgresSettlementRepository{db: db}
 }
 
 func (r *PostgresSettlementRepository) CreateSettlementAttempt(ctx context.Context, s *model.Settlements) error {
 	query := `
-		INSERT INTO settlements (id, merchant_account_id, external_reference, status, amount, metadata, attempts, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO settlements (id, merchant_account_id, external_reference, status, amount, currency, fx_rate, source_amount, fee_amount, net_amount, metadata, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::numeric, $8, $9, $10, $11, $12, $13, $14)
 	`
 
+	// Settlements without a fee net to their gross amount
+	if s.NetAmount == 0 && s.FeeAmount == 0 {
+		s.NetAmount = s.Amount
+	}
+	// Settlements without a conversion are in the transaction's currency
+	if s.SourceAmount == 0 && s.FXRate == "" {
+		s.SourceAmount = s.Amount
+	}
+
 	// Marshal metadata to JSON
 	metadataJSON, err := json.Marshal(s.Metadata)
 	if err != nil {
@@ -42,6 +60,11 @@ func (r *PostgresSettlementRepository) CreateSettlementAttempt(ctx context.Conte
 		s.ExternalReference,
 		s.Status,
 		s.Amount,
+		s.Currency,
+		s.FXRate,
+		s.SourceAmount,
+		s.FeeAmount,
+		s.NetAmount,
 		metadataJSON,
 		s.Attempts,
 		s.CreatedAt,
@@ -50,6 +73,44 @@ func (r *PostgresSettlementRepository) CreateSettlementAttempt(ctx context.Conte
 	return err
 }
 
+func (r *PostgresSettlementRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status model.SettlementStatus) error {
+	query := `
+		UPDATE settlements SET status = $1, updated_at = NOW() WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	return err
+}
+
+// MergeMetadata shallow-merges patch into the settlement's metadata.
+func (r *PostgresSettlementRepository) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error {
+	query := `
+		UPDATE settlements SET metadata = metadata || $1::jsonb, updated_at = NOW() WHERE id = $2
+	`
+
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, patchJSON, id)
+	return err
+}
+
+// SumSuccessfulSince returns the gross amount successfully settled to a
+// merchant account since the given time, used for volume-tiered fees.
+func (r *PostgresSettlementRepository) SumSuccessfulSince(ctx context.Context, merchantAccountID uuid.UUID, since time.Time) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM settlements
+		WHERE merchant_account_id = $1 AND status = 'success' AND created_at >= $2
+	`
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, query, merchantAccountID, since).Scan(&total)
+	return total, err
+}
+
 func (r *PostgresSettlementRepository) GetByID(
 	ctx context.Context,
 	id string,
@@ -57,20 +118,73 @@ func (r *PostgresSettlementRepository) GetByID(
 	query := `
 		SELECT
 			id, merchant_account_id, external_reference, status, amount,
+			COALESCE(currency, ''), COALESCE(fx_rate::text, ''), COALESCE(source_amount, amount),
+			fee_amount, COALESCE(net_amount, amount - fee_amount),
 			metadata, attempts, created_at, updated_at
 		FROM settlements
 		WHERE id = $1
 	`
 
+	return r.get(ctx, query, id)
+}
+
+// GetSuccessfulByReference returns the latest successful settlement for an
+// external reference (the transaction ID).
+func (r *PostgresSettlementRepository) GetSuccessfulByReference(
+	ctx context.Context,
+	externalReference string,
+) (*model.Settlements, error) {
+	query := `
+		SELECT
+			id, merchant_account_id, external_reference, status, amount,
+			COALESCE(currency, ''), COALESCE(fx_rate::text, ''), COALESCE(source_amount, amount),
+			fee_amount, COALESCE(net_amount, amount - fee_amount),
+			metadata, attempts, created_at, updated_at
+		FROM settlements
+		WHERE external_reference = $1 AND status = 'success'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	return r.get(ctx, query, externalReference)
+}
+
+// GetPendingByReference returns the latest settlement attempt for an
+// external reference (the transaction ID) that has no outcome yet.
+func (r *PostgresSettlementRepository) GetPendingByReference(
+	ctx context.Context,
+	externalReference string,
+) (*model.Settlements, error) {
+	query := `
+		SELECT
+			id, merchant_account_id, external_reference, status, amount,
+			COALESCE(currency, ''), COALESCE(fx_rate::text, ''), COALESCE(source_amount, amount),
+			fee_amount, COALESCE(net_amount, amount - fee_amount),
+			metadata, attempts, created_at, updated_at
+		FROM settlements
+		WHERE external_reference = $1 AND status = 'pending'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	return r.get(ctx, query, externalReference)
+}
+
+func (r *PostgresSettlementRepository) get(ctx context.Context, query string, arg any) (*model.Settlements, error) {
 	var s model.Settlements
 	var metadataJSON []byte
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
 		&s.ID,
 		&s.MerchantAccountID,
 		&s.ExternalReference,
 		&s.Status,
 		&s.Amount,
+		&s.Currency,
+		&s.FXRate,
+		&s.SourceAmount,
+		&s.FeeAmount,
+		&s.NetAmount,
 		&metadataJSON,
 		&s.Attempts,
 		&s.CreatedAt,
@@ -112,6 +226,8 @@ func (r *PostgresSettlementRepository) List(
 	query := `
 		SELECT
 			id, merchant_account_id, external_reference, status, amount,
+			COALESCE(currency, ''), COALESCE(fx_rate::text, ''), COALESCE(source_amount, amount),
+			fee_amount, COALESCE(net_amount, amount - fee_amount),
 			metadata, attempts, created_at, updated_at
 		FROM settlements
 		ORDER BY created_at DESC
@@ -136,6 +252,11 @@ func (r *PostgresSettlementRepository) List(
 			&s.ExternalReference,
 			&s.Status,
 			&s.Amount,
+			&s.Currency,
+			&s.FXRate,
+			&s.SourceAmount,
+			&s.FeeAmount,
+			&s.NetAmount,
 			&metadataJSON,
 			&s.Attempts,
 			&s.CreatedAt,
@@ -161,3 +282,149 @@ func (r *PostgresSettlementRepository) List(
 
 	return settlements, nil
 }
+
+// ListForExport returns the successful settlements with a positive net
+// amount in a payout batch, or created in the filter's date range, oldest
+// first, with the merchant each one is paid to.
+func (r *PostgresSettlementRepository) ListForExport(
+	ctx context.Context,
+	filter model.SettlementExportFilter,
+) ([]*model.SettlementExportLine, error) {
+	query := `
+		SELECT
+			s.id, s.merchant_account_id, s.external_reference, s.status, s.amount,
+			COALESCE(s.currency, ''), COALESCE(s.fx_rate::text, ''), COALESCE(s.source_amount, s.amount),
+			s.fee_amount, COALESCE(s.net_amount, s.amount - s.fee_amount),
+			s.metadata, s.attempts, s.created_at, s.updated_at,
+			s.payout_id, m.id, m.name, m.bank_details
+		FROM settlements s
+		JOIN accounts a ON a.id = s.merchant_account_id
+		JOIN merchants m ON m.id = a.owner_id
+		WHERE s.status = 'success'
+			AND COALESCE(s.net_amount, s.amount - s.fee_amount) > 0
+			AND CASE WHEN $1::uuid IS NOT NULL
+				THEN s.payout_id = $1
+				ELSE s.created_at >= $2 AND s.created_at < $3
+			END
+		ORDER BY s.created_at, s.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, nullUUIDPtr(filter.PayoutID), filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*model.SettlementExportLine
+	for rows.Next() {
+		var s model.Settlements
+		var line model.SettlementExportLine
+		var metadataJSON, bankJSON []byte
+		var payoutID uuid.NullUUID
+
+		if err := rows.Scan(
+			&s.ID,
+			&s.MerchantAccountID,
+			&s.ExternalReference,
+			&s.Status,
+			&s.Amount,
+			&s.Currency,
+			&s.FXRate,
+			&s.SourceAmount,
+			&s.FeeAmount,
+			&s.NetAmount,
+			&metadataJSON,
+			&s.Attempts,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+			&payoutID,
+			&line.MerchantID,
+			&line.MerchantName,
+			&bankJSON,
+		); err != nil {
+			return nil, err
+		}
+		if metadataJSON != nil {
+			if err := json.Unmarshal(metadataJSON, &s.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if bankJSON != nil {
+			if err := json.Unmarshal(bankJSON, &line.BankDetails); err != nil {
+				return nil, err
+			}
+		}
+		if payoutID.Valid {
+			line.PayoutID = &payoutID.UUID
+		}
+		line.Settlement = &s
+		lines = append(lines, &line)
+	}
+
+	return lines, rows.Err()
+}
+
+// ListUnreconciled returns the successful settlements in a currency created
+// in [from, to) that no statement line has been reconciled against yet,
+// oldest first.
+func (r *PostgresSettlementRepository) ListUnreconciled(
+	ctx context.Context,
+	currency string,
+	from, to time.Time,
+) ([]*model.Settlements, error) {
+	query := `
+		SELECT
+			s.id, s.merchant_account_id, s.external_reference, s.status, s.amount,
+			COALESCE(s.currency, ''), COALESCE(s.fx_rate::text, ''), COALESCE(s.source_amount, s.amount),
+			s.fee_amount, COALESCE(s.net_amount, s.amount - s.fee_amount),
+			s.metadata, s.attempts, s.created_at, s.updated_at
+		FROM settlements s
+		WHERE s.status = 'success'
+			AND s.currency = $1
+			AND s.created_at >= $2 AND s.created_at < $3
+			AND NOT EXISTS (
+				SELECT 1 FROM reconciliation_logs l
+				WHERE l.entity_type = 'settlements' AND l.entity_id = s.id
+					AND l.status IN ('matched', 'mismatch', 'resolved')
+			)
+		ORDER BY s.created_at, s.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, currency, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settlements []*model.Settlements
+	for rows.Next() {
+		var s model.Settlements
+		var metadataJSON []byte
+
+		if err := rows.Scan(
+			&s.ID,
+			&s.MerchantAccountID,
+			&s.ExternalReference,
+			&s.Status,
+			&s.Amount,
+			&s.Currency,
+			&s.FXRate,
+			&s.SourceAmount,
+			&s.FeeAmount,
+			&s.NetAmount,
+			&metadataJSON,
+			&s.Attempts,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if metadataJSON != nil {
+			if err := json.Unmarshal(metadataJSON, &s.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		settlements = append(settlements, &s)
+	}
+	return settlements, rows.Err()
+}
@@ -12,22 +12,25 @@ import (
 type TransactionRepository interface {
 	CreateTransaction(ctx context.Context, tx *model.Transaction) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Transaction, error)
-	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Transaction, error)
+	TransitionStatus(ctx context.Context, id uuid.UUID, from, to model.TransactionStatus) error
+	SetRoute(ctx context.Context, id uuid.UUID, route string, ruleID *uuid.UUID) error
+	UpdateAmount(ctx context.Context, id uuid.UUID, amount int64) error
 	List(ctx context.Context, limit int, offset int) ([]*model.Transaction, error)
 }
 
 type PostgresTransactionRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewPostgresTransactionRepository(db *sql.DB) *PostgresTransactionRepository {
+func NewPostgresTransactionRepository(db DBTX) *PostgresTransactionRepository {
 	return &PostgresTransactionRepository{db: db}
 }
 
 func (r *PostgresTransactionRepository) CreateTransaction(ctx context.Context, tx *model.Transaction) error {
 	query := `
-        INSERT INTO transactions (id, amount, currency, user_id, merchant_id, status, metadata, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO transactions (id, amount, currency, user_id, merchant_id, status, metadata, capture_method, settlement_currency, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
     `
 
 	// Marshal metadata to JSON for database storage
@@ -42,6 +45,15 @@ func (r *PostgresTransactionRepository) CreateTransaction(ctx context.Context, t
 		metadataJSON = []byte("{}")
 	}
 
+	captureMethod := tx.CaptureMethod
+	if captureMethod == "" {
+		captureMethod = model.CaptureAutomatic
+	}
+	settlementCurrency := tx.SettlementCurrency
+	if settlementCurrency == "" {
+		settlementCurrency = tx.Currency
+	}
+
 	_, err := r.db.ExecContext(
 		ctx,
 		query,
@@ -52,18 +64,73 @@ func (r *PostgresTransactionRepository) CreateTransaction(ctx context.Context, t
 		tx.MerchantID,
 		tx.Status,
 		metadataJSON,
+		captureMethod,
+		settlementCurrency,
 		tx.CreatedAt,
 		tx.UpdatedAt,
 	)
 	return err
 }
 
-func (r *PostgresTransactionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+// TransitionStatus moves a transaction from one status to another with a
+// compare-and-set, so duplicate or out-of-order messages can't regress it.
+// It returns an *model.InvalidTransitionError if the state machine forbids
+// the change or the stored status is no longer from, and sql.ErrNoRows if the
+// transaction doesn't exist.
+func (r *PostgresTransactionRepository) TransitionStatus(
+	ctx context.Context,
+	id uuid.UUID,
+	from, to model.TransactionStatus,
+) error {
+	if err := model.ValidateTransition(from, to); err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3
+    `
+
+	res, err := r.db.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		return nil
+	}
+
+	var current model.TransactionStatus
+	if err := r.db.QueryRowContext(ctx, `SELECT status FROM transactions WHERE id = $1`, id).Scan(&current); err != nil {
+		return err
+	}
+	return &model.InvalidTransitionError{From: from, To: to, Current: current}
+}
+
+// SetRoute records the routing decision on the transaction.
+func (r *PostgresTransactionRepository) SetRoute(ctx context.Context, id uuid.UUID, route string, ruleID *uuid.UUID) error {
+	query := `
+        UPDATE transactions SET route = $1, routing_rule_id = $2, updated_at = NOW() WHERE id = $3
+    `
+
+	var rule uuid.NullUUID
+	if ruleID != nil {
+		rule = nullUUID(*ruleID)
+	}
+
+	_, err := r.db.ExecContext(ctx, query, route, rule, id)
+	return err
+}
+
+// UpdateAmount sets the transaction amount, e.g. after a partial capture.
+func (r *PostgresTransactionRepository) UpdateAmount(ctx context.Context, id uuid.UUID, amount int64) error {
 	query := `
-        UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2
+        UPDATE transactions SET amount = $1, updated_at = NOW() WHERE id = $2
     `
 
-	_, err := r.db.ExecContext(ctx, query, status, id)
+	_, err := r.db.ExecContext(ctx, query, amount, id)
 	return err
 }
 
@@ -75,13 +142,37 @@ func (r *PostgresTransactionRepository) GetByID(
 	query := `
         SELECT 
             id, amount, currency, user_id, merchant_id, status,
-            metadata, created_at, updated_at
+            metadata, capture_method, COALESCE(settlement_currency, currency), COALESCE(route, ''), routing_rule_id, created_at, updated_at
+        FROM transactions
+        WHERE id = $1
+    `
+
+	return r.get(ctx, query, id)
+}
+
+// GetByIDForUpdate locks the transaction row until the surrounding SQL
+// transaction ends. Use it to serialize changes that depend on the row.
+func (r *PostgresTransactionRepository) GetByIDForUpdate(
+	ctx context.Context,
+	id uuid.UUID,
+) (*model.Transaction, error) {
+
+	query := `
+        SELECT 
+            id, amount, currency, user_id, merchant_id, status,
+            metadata, capture_method, COALESCE(settlement_currency, currency), COALESCE(route, ''), routing_rule_id, created_at, updated_at
         FROM transactions
         WHERE id = $1
+        FOR UPDATE
     `
 
+	return r.get(ctx, query, id)
+}
+
+func (r *PostgresTransactionRepository) get(ctx context.Context, query string, id uuid.UUID) (*model.Transaction, error) {
 	var t model.Transaction
 	var metadataJSON []byte
+	var ruleID uuid.NullUUID
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&t.ID,
@@ -91,6 +182,10 @@ func (r *PostgresTransactionRepository) GetByID(
 		&t.MerchantID,
 		&t.Status,
 		&metadataJSON,
+		&t.CaptureMethod,
+		&t.SettlementCurrency,
+		&t.Route,
+		&ruleID,
 		&t.CreatedAt,
 		&t.UpdatedAt,
 	)
@@ -108,6 +203,9 @@ func (r *PostgresTransactionRepository) GetByID(
 			return nil, err
 		}
 	}
+	if ruleID.Valid {
+		t.RoutingRuleID = &ruleID.UUID
+	}
 
 	return &t, nil
 }
@@ -130,7 +228,7 @@ func (r *PostgresTransactionRepository) List(
 	query := `
         SELECT 
             id, amount, currency, user_id, merchant_id, status,
-            metadata, created_at, updated_at
+            metadata, capture_method, COALESCE(settlement_currency, currency), COALESCE(route, ''), routing_rule_id, created_at, updated_at
         FROM transactions
         ORDER BY created_at DESC
         LIMIT $1 OFFSET $2
@@ -147,6 +245,7 @@ func (r *PostgresTransactionRepository) List(
 	for rows.Next() {
 		var t model.Transaction
 		var metadataJSON []byte
+		var ruleID uuid.NullUUID
 
 		err := rows.Scan(
 			&t.ID,
@@ -156,6 +255,10 @@ func (r *PostgresTransactionRepository) List(
 			&t.MerchantID,
 			&t.Status,
 			&metadataJSON,
+			&t.CaptureMethod,
+			&t.SettlementCurrency,
+			&t.Route,
+			&ruleID,
 			&t.CreatedAt,
 			&t.UpdatedAt,
 		)
@@ -169,6 +272,9 @@ func (r *PostgresTransactionRepository) List(
 				return nil, err
 			}
 		}
+		if ruleID.Valid {
+			t.RoutingRuleID = &ruleID.UUID
+		}
 
 		transactions = append(transactions, &t)
 	}
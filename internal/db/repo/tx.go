@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx so repositories can run
+// either standalone or inside a shared SQL transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxRepositories groups repositories bound to a single SQL transaction.
+type TxRepositories struct {
+	Transactions   TransactionRepository
+	Settlements    SettlementRepository
+	Accounts       AccountRepository
+	Ledger         LedgerRepository
+	Outbox         OutboxRepository
+	Refunds        RefundRepository
+	Authorizations AuthorizationRepository
+	Payouts        PayoutRepository
+	Reconciliation ReconciliationRepository
+	Audit          AuditRepository
+}
+
+func newTxRepositories(tx *sql.Tx) *TxRepositories {
+	return &TxRepositories{
+		Transactions:   NewPostgresTransactionRepository(tx),
+		Settlements:    NewPostgresSettlementRepository(tx),
+		Accounts:       NewPostgresAccountRepository(tx),
+		Ledger:         NewPostgresLedgerRepository(tx),
+		Outbox:         NewPostgresOutboxRepository(tx),
+		Refunds:        NewPostgresRefundRepository(tx),
+		Authorizations: NewPostgresAuthorizationRepository(tx),
+		Payouts:        NewPostgresPayoutRepository(tx),
+		Reconciliation: NewPostgresReconciliationRepository(tx),
+		Audit:          NewPostgresAuditRepository(tx),
+	}
+}
+
+// TxManager runs a unit of work atomically.
+type TxManager interface {
+	WithTx(ctx context.Context, fn func(r *TxRepositories) error) error
+}
+
+type PostgresTxManager struct {
+	db *sql.DB
+}
+
+func NewPostgresTxManager(db *sql.DB) *PostgresTxManager {
+	return &PostgresTxManager{db: db}
+}
+
+// WithTx begins a transaction, hands tx-bound repositories to fn and commits
+// if fn returns nil. Any error (or panic) rolls the transaction back.
+func (m *PostgresTxManager) WithTx(ctx context.Context, fn func(r *TxRepositories) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(newTxRepositories(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, d *model.WebhookDelivery) (bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error)
+	List(ctx context.Context, filter model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id uuid.UUID, attempt model.WebhookAttempt, status model.WebhookDeliveryStatus, nextAttemptAt *time.Time) error
+	Reschedule(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type PostgresWebhookDeliveryRepository struct {
+	db DBTX
+}
+
+func NewPostgresWebhookDeliveryRepository(db DBTX) *PostgresWebhookDeliveryRepository {
+	return &PostgresWebhookDeliveryRepository{db: db}
+}
+
+const webhookDeliveryColumns = `
+	id, merchant_id, event_type, event_key, url, payload, status, attempts, next_attempt_at,
+	COALESCE(last_status_code, 0), COALESCE(last_error, ''), attempt_log, delivered_at, created_at, updated_at
+`
+
+// Create inserts a delivery. It reports false when the same event was
+// already recorded for the merchant.
+func (r *PostgresWebhookDeliveryRepository) Create(ctx context.Context, d *model.WebhookDelivery) (bool, error) {
+	query := `
+		INSERT INTO webhook_deliveries (id, merchant_id, event_type, event_key, url, payload, status, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (merchant_id, event_type, event_key) DO NOTHING
+	`
+
+	res, err := r.db.ExecContext(ctx, query,
+		d.ID,
+		d.MerchantID,
+		d.EventType,
+		d.EventKey,
+		d.URL,
+		[]byte(d.Payload),
+		d.Status,
+		d.NextAttemptAt,
+		d.CreatedAt,
+		d.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresWebhookDeliveryRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// List returns deliveries newest first.
+func (r *PostgresWebhookDeliveryRepository) List(ctx context.Context, filter model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE ($1::uuid IS NULL OR merchant_id = $1)
+			AND ($2 = '' OR event_type = $2)
+			AND ($3 = '' OR status = $3)
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		nullUUIDPtr(filter.MerchantID),
+		filter.EventType,
+		string(filter.Status),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimDue picks up to limit pending deliveries whose next attempt is due
+// and pushes their next_attempt_at out by lease, so concurrent dispatchers
+// don't send the same delivery. A dispatcher that dies mid-attempt leaves
+// the row to be retried once the lease runs out.
+func (r *PostgresWebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.QueryContext(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt appends an attempt to the log and moves the delivery to its
+// next state. nextAttemptAt is nil once the delivery is final.
+func (r *PostgresWebhookDeliveryRepository) RecordAttempt(
+	ctx context.Context,
+	id uuid.UUID,
+	attempt model.WebhookAttempt,
+	status model.WebhookDeliveryStatus,
+	nextAttemptAt *time.Time,
+) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1,
+			attempt_log = attempt_log || $2::jsonb,
+			status = $3,
+			next_attempt_at = $4,
+			last_status_code = NULLIF($5, 0),
+			last_error = NULLIF($6, ''),
+			delivered_at = CASE WHEN $3 = 'succeeded' THEN $7 ELSE delivered_at END,
+			updated_at = NOW()
+		WHERE id = $1
+	`
+
+	attemptJSON, err := json.Marshal([]model.WebhookAttempt{attempt})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, id, attemptJSON, status, nextAttemptAt, attempt.StatusCode, attempt.Error, attempt.At)
+	return err
+}
+
+// Reschedule puts a delivery back in the queue, e.g. for a manual redelivery
+// of one that already succeeded or failed.
+func (r *PostgresWebhookDeliveryRepository) Reschedule(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', next_attempt_at = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id, at)
+	return err
+}
+
+func scanWebhookDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var payload, attemptLog []byte
+	var nextAttemptAt, deliveredAt sql.NullTime
+
+	if err := row.Scan(
+		&d.ID,
+		&d.MerchantID,
+		&d.EventType,
+		&d.EventKey,
+		&d.URL,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&nextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&attemptLog,
+		&deliveredAt,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	if attemptLog != nil {
+		if err := json.Unmarshal(attemptLog, &d.AttemptLog); err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var statementCSVHeader = []string{
+	"record", "entry_id", "transaction_id", "posted_at", "description", "debit", "credit", "balance",
+}
+
+// WriteAccountStatementCSV writes an account statement: a header, an
+// opening row, one entry row per ledger leg, a closing row with the period's
+// debit and credit totals and an account row. Amounts are decimals in major
+// units. Like the settlement files it ends with a SHA-256 checksum, which is
+// returned.
+func WriteAccountStatementCSV(w io.Writer, st *model.AccountStatement, currencies *model.CurrencyRegistry) (string, error) {
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	amount := func(minor int64) (string, error) {
+		return formatAmount(currencies, minor, st.Currency)
+	}
+
+	cw := &checksumWriter{w: w, h: sha256.New()}
+	out := csv.NewWriter(cw)
+	if err := out.Write(statementCSVHeader); err != nil {
+		return "", err
+	}
+
+	opening, err := amount(st.OpeningBalance)
+	if err != nil {
+		return "", err
+	}
+	if err := out.Write([]string{
+		"opening", "", "", st.From.UTC().Format(time.RFC3339), "opening balance", "", "", opening,
+	}); err != nil {
+		return "", err
+	}
+
+	for _, e := range st.Entries {
+		var debit, credit string
+		if e.Amount < 0 {
+			debit, err = amount(-e.Amount)
+		} else {
+			credit, err = amount(e.Amount)
+		}
+		if err != nil {
+			return "", err
+		}
+		balance, err := amount(e.Balance)
+		if err != nil {
+			return "", err
+		}
+		if err := out.Write([]string{
+			"entry",
+			e.ID.String(),
+			e.TransactionID.String(),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.Description,
+			debit,
+			credit,
+			balance,
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	debits, err := amount(st.Debits)
+	if err != nil {
+		return "", err
+	}
+	credits, err := amount(st.Credits)
+	if err != nil {
+		return "", err
+	}
+	closing, err := amount(st.ClosingBalance)
+	if err != nil {
+		return "", err
+	}
+	if err := out.Write([]string{
+		"closing", "", "", st.To.UTC().Format(time.RFC3339), "closing balance", debits, credits, closing,
+	}); err != nil {
+		return "", err
+	}
+	if err := out.Write([]string{
+		"account",
+		st.AccountID.String(),
+		st.OwnerID.String(),
+		string(st.AccountType),
+		st.Currency,
+		st.GeneratedAt.UTC().Format(time.RFC3339),
+	}); err != nil {
+		return "", err
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return "", err
+	}
+
+	checksum := hex.EncodeToString(cw.h.Sum(nil))
+	if _, err := fmt.Fprintf(w, "checksum,SHA-256,%s\n", checksum); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var csvHeader = []string{
+	"record", "settlement_id", "transaction_id", "payout_id", "merchant_id", "merchant_name",
+	"account_name", "account_number", "bank_code", "iban", "bic",
+	"currency", "gross_amount", "fee_amount", "net_amount", "settled_at",
+}
+
+// writeCSV writes a header, one detail row per settlement, a total row per
+// currency and a file row with the message id and overall count. Amounts are
+// decimals in major units.
+func writeCSV(w io.Writer, lines []*model.SettlementExportLine, summary *Summary, opts Options) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		s := l.Settlement
+		gross, err := formatAmount(opts.Currencies, s.Amount, s.Currency)
+		if err != nil {
+			return err
+		}
+		fee, err := formatAmount(opts.Currencies, s.FeeAmount, s.Currency)
+		if err != nil {
+			return err
+		}
+		net, err := formatAmount(opts.Currencies, s.NetAmount, s.Currency)
+		if err != nil {
+			return err
+		}
+		payoutID := ""
+		if l.PayoutID != nil {
+			payoutID = l.PayoutID.String()
+		}
+
+		if err := cw.Write([]string{
+			"detail",
+			s.ID.String(),
+			s.ExternalReference,
+			payoutID,
+			l.MerchantID.String(),
+			l.MerchantName,
+			l.BankDetails.AccountName,
+			l.BankDetails.AccountNumber,
+			l.BankDetails.BankCode,
+			l.BankDetails.IBAN,
+			l.BankDetails.BIC,
+			s.Currency,
+			gross,
+			fee,
+			net,
+			s.CreatedAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range summary.Totals {
+		amount, err := formatAmount(opts.Currencies, t.Amount, t.Currency)
+		if err != nil {
+			return err
+		}
+		if err := cw.Write([]string{"total", t.Currency, strconv.Itoa(t.Count), amount}); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write([]string{
+		"file", summary.MessageID, opts.CreatedAt.UTC().Format(time.RFC3339), strconv.Itoa(summary.Count),
+	}); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
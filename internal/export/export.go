@@ -0,0 +1,238 @@
+// Package export writes settlement batches as files for banks: CSV and ISO
+// 20022 pain.001 customer credit transfer initiations. Every file carries
+// control totals and ends with a SHA-256 checksum of the bytes before it.
+// Account statements for merchants are written the same way.
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var (
+	ErrUnknownFormat      = errors.New("unknown export format")
+	ErrMissingBankDetails = errors.New("missing bank details")
+	ErrEmptyBatch         = errors.New("no settlements to export")
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatPain001 Format = "pain.001"
+)
+
+// ParseFormat accepts "csv" (the default for an empty string), "pain.001"
+// and "pain001".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "pain.001", "pain001":
+		return FormatPain001, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// ContentType is the MIME type of files in the format.
+func (f Format) ContentType() string {
+	if f == FormatPain001 {
+		return "application/xml"
+	}
+	return "text/csv"
+}
+
+// Extension is the file name extension for the format.
+func (f Format) Extension() string {
+	if f == FormatPain001 {
+		return "xml"
+	}
+	return "csv"
+}
+
+// Options describe the file being written.
+type Options struct {
+	MessageID  string    // unique per file, at most 35 characters
+	CreatedAt  time.Time // defaults to now
+	Debtor     model.BankDetails
+	Currencies *model.CurrencyRegistry // for decimal amounts; nil uses the defaults
+}
+
+// DebtorFromEnv reads the account transfers are paid from out of
+// EXPORT_DEBTOR_NAME, EXPORT_DEBTOR_IBAN, EXPORT_DEBTOR_ACCOUNT_NUMBER,
+// EXPORT_DEBTOR_BIC and EXPORT_DEBTOR_BANK_CODE.
+func DebtorFromEnv() model.BankDetails {
+	return model.BankDetails{
+		AccountName:   os.Getenv("EXPORT_DEBTOR_NAME"),
+		IBAN:          os.Getenv("EXPORT_DEBTOR_IBAN"),
+		AccountNumber: os.Getenv("EXPORT_DEBTOR_ACCOUNT_NUMBER"),
+		BIC:           os.Getenv("EXPORT_DEBTOR_BIC"),
+		BankCode:      os.Getenv("EXPORT_DEBTOR_BANK_CODE"),
+	}
+}
+
+// ParseRange parses an export date range. Each bound is RFC 3339 or a date
+// (YYYY-MM-DD, UTC); a date as the upper bound includes that whole day. The
+// returned range is [from, to).
+func ParseRange(from, to string) (time.Time, time.Time, error) {
+	start, _, err := parseBound(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+	end, isDate, err := parseBound(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+	}
+	if isDate {
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+	return start, end, nil
+}
+
+func parseBound(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), false, err
+}
+
+// Total is the control total for one currency.
+type Total struct {
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	Amount   int64  `json:"amount"` // net, in minor units
+}
+
+// Summary describes a written file.
+type Summary struct {
+	MessageID string  `json:"message_id"`
+	Count     int     `json:"count"`
+	Totals    []Total `json:"totals"` // ordered by currency
+	Checksum  string  `json:"checksum"`
+}
+
+// Write writes lines in the given format. Amounts are the settlements' net
+// amounts, the sum paid to each merchant.
+func Write(w io.Writer, format Format, lines []*model.SettlementExportLine, opts Options) (*Summary, error) {
+	if opts.CreatedAt.IsZero() {
+		opts.CreatedAt = time.Now().UTC()
+	}
+	if opts.Currencies == nil {
+		opts.Currencies = model.DefaultCurrencyRegistry()
+	}
+	if opts.MessageID == "" {
+		opts.MessageID = "STL" + opts.CreatedAt.Format("20060102150405")
+	}
+	if len(opts.MessageID) > 35 {
+		return nil, fmt.Errorf("message id %q longer than 35 characters", opts.MessageID)
+	}
+
+	summary := &Summary{MessageID: opts.MessageID, Count: len(lines), Totals: totals(lines)}
+	cw := &checksumWriter{w: w, h: sha256.New()}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = writeCSV(cw, lines, summary, opts)
+	case FormatPain001:
+		err = writePain001(cw, lines, summary, opts)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary.Checksum = hex.EncodeToString(cw.h.Sum(nil))
+
+	// The checksum trailer is written to w only, outside the hashed bytes
+	switch format {
+	case FormatCSV:
+		_, err = fmt.Fprintf(w, "checksum,SHA-256,%s\n", summary.Checksum)
+	case FormatPain001:
+		_, err = fmt.Fprintf(w, "<!-- SHA-256: %s -->\n", summary.Checksum)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Verify checks the checksum trailer of a file produced by Write.
+func Verify(file []byte) error {
+	body := strings.TrimRight(string(file), "\n")
+	i := strings.LastIndex(body, "\n")
+	if i < 0 {
+		return errors.New("no checksum trailer")
+	}
+	content, trailer := body[:i+1], body[i+1:]
+
+	var want string
+	switch {
+	case strings.HasPrefix(trailer, "checksum,SHA-256,"):
+		want = strings.TrimPrefix(trailer, "checksum,SHA-256,")
+	case strings.HasPrefix(trailer, "<!-- SHA-256: "):
+		want = strings.TrimSuffix(strings.TrimPrefix(trailer, "<!-- SHA-256: "), " -->")
+	default:
+		return errors.New("no checksum trailer")
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch: file has %s, content hashes to %s", want, got)
+	}
+	return nil
+}
+
+func totals(lines []*model.SettlementExportLine) []Total {
+	byCurrency := make(map[string]*Total)
+	for _, l := range lines {
+		t, ok := byCurrency[l.Settlement.Currency]
+		if !ok {
+			t = &Total{Currency: l.Settlement.Currency}
+			byCurrency[l.Settlement.Currency] = t
+		}
+		t.Count++
+		t.Amount += l.Settlement.NetAmount
+	}
+
+	list := make([]Total, 0, len(byCurrency))
+	for _, t := range byCurrency {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+func formatAmount(currencies *model.CurrencyRegistry, minor int64, currency string) (string, error) {
+	c, err := currencies.Get(currency)
+	if err != nil {
+		return "", err
+	}
+	return c.FormatAmount(minor), nil
+}
+
+// checksumWriter hashes everything written through it.
+type checksumWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.h.Write(p[:n])
+	return n, err
+}
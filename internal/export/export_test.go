@@ -0,0 +1,232 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+var createdAt = time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+
+func testLines() []*model.SettlementExportLine {
+	line := func(amount, fee int64, currency string, bank model.BankDetails) *model.SettlementExportLine {
+		return &model.SettlementExportLine{
+			Settlement: &model.Settlements{
+				ID:                uuid.New(),
+				ExternalReference: uuid.NewString(),
+				Status:            string(model.SettlementSuccess),
+				Amount:            amount,
+				FeeAmount:         fee,
+				NetAmount:         amount - fee,
+				Currency:          currency,
+				CreatedAt:         createdAt.Add(-time.Hour),
+			},
+			MerchantID:   uuid.New(),
+			MerchantName: "Acme Stores",
+			BankDetails:  bank,
+		}
+	}
+	ngn := model.BankDetails{AccountName: "Acme Stores Ltd", AccountNumber: "0123456789", BankCode: "058"}
+	eur := model.BankDetails{AccountName: "Acme GmbH", IBAN: "DE89370400440532013000", BIC: "COBADEFFXXX"}
+	return []*model.SettlementExportLine{
+		line(1000000, 25000, "NGN", ngn), // 9750.00
+		line(250050, 5050, "NGN", ngn),   // 2450.00
+		line(12345, 0, "EUR", eur),       // 123.45
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		MessageID: "STL-TEST-1",
+		CreatedAt: createdAt,
+		Debtor:    model.BankDetails{AccountName: "Gateway Ltd", IBAN: "GB29NWBK60161331926819", BIC: "NWBKGB2L"},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FormatCSV, "CSV": FormatCSV, "pain.001": FormatPain001, "pain001": FormatPain001} {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("mt101"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseFormat(mt101) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	from, to, err := ParseRange("2026-03-01", "2026-03-31")
+	if err != nil {
+		t.Fatalf("ParseRange: %v", err)
+	}
+	if !from.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("range = [%s, %s), want the whole of March", from, to)
+	}
+	if _, to, _ := ParseRange("2026-03-01", "2026-03-01T12:00:00Z"); !to.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("timestamp upper bound = %s, want it unchanged", to)
+	}
+	if _, _, err := ParseRange("2026-03-02", "2026-03-01"); err == nil {
+		t.Error("accepted an inverted range")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	summary, err := Write(&buf, FormatCSV, testLines(), testOptions())
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if summary.Count != 3 || len(summary.Totals) != 2 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if summary.Totals[0] != (Total{Currency: "EUR", Count: 1, Amount: 12345}) ||
+		summary.Totals[1] != (Total{Currency: "NGN", Count: 2, Amount: 1220000}) {
+		t.Errorf("totals = %+v", summary.Totals)
+	}
+	if err := Verify(buf.Bytes()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	r := csv.NewReader(strings.NewReader(buf.String()))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	// header, 3 details, 2 totals, file, checksum
+	if len(records) != 8 {
+		t.Fatalf("got %d records, want 8", len(records))
+	}
+	if got := records[1][14]; got != "9750.00" {
+		t.Errorf("net amount = %q, want 9750.00", got)
+	}
+	if got := records[5]; strings.Join(got, ",") != "total,NGN,2,12200.00" {
+		t.Errorf("NGN total row = %v", got)
+	}
+	if got := records[7][2]; got != summary.Checksum {
+		t.Errorf("checksum row = %q, want %q", got, summary.Checksum)
+	}
+}
+
+func TestWritePain001(t *testing.T) {
+	var buf bytes.Buffer
+	summary, err := Write(&buf, FormatPain001, testLines(), testOptions())
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := Verify(buf.Bytes()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	var doc painDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("parse xml: %v", err)
+	}
+	hdr := doc.Init.GrpHdr
+	if hdr.MsgId != "STL-TEST-1" || hdr.NbOfTxs != 3 || hdr.CtrlSum != "12323.45" {
+		t.Errorf("group header = %+v", hdr)
+	}
+	if len(doc.Init.PmtInf) != 2 {
+		t.Fatalf("got %d payment blocks, want one per currency", len(doc.Init.PmtInf))
+	}
+
+	eur, ngn := doc.Init.PmtInf[0], doc.Init.PmtInf[1]
+	if eur.CtrlSum != "123.45" || eur.NbOfTxs != 1 || eur.DbtrAcct.Ccy != "EUR" {
+		t.Errorf("EUR block = %+v", eur)
+	}
+	if ngn.CtrlSum != "12200.00" || ngn.NbOfTxs != 2 || len(ngn.CdtTrfTxInf) != 2 {
+		t.Errorf("NGN block = %+v", ngn)
+	}
+
+	tx := ngn.CdtTrfTxInf[0]
+	if tx.Amt.InstdAmt.Ccy != "NGN" || tx.Amt.InstdAmt.Value != "9750.00" {
+		t.Errorf("amount = %+v", tx.Amt.InstdAmt)
+	}
+	if tx.CdtrAcct.Id.Othr == nil || tx.CdtrAcct.Id.Othr.Id != "0123456789" {
+		t.Errorf("creditor account = %+v", tx.CdtrAcct.Id)
+	}
+	if tx.CdtrAgt.FinInstnId.ClrSysMmbId == nil || tx.CdtrAgt.FinInstnId.ClrSysMmbId.MmbId != "058" {
+		t.Errorf("creditor agent = %+v", tx.CdtrAgt.FinInstnId)
+	}
+	if len(tx.PmtId.EndToEndId) > 35 {
+		t.Errorf("EndToEndId %q longer than 35", tx.PmtId.EndToEndId)
+	}
+	if summary.Checksum == "" {
+		t.Error("empty checksum")
+	}
+}
+
+func TestWritePain001RequiresBankDetails(t *testing.T) {
+	lines := testLines()
+	lines[1].BankDetails = model.BankDetails{AccountName: "No Account"}
+	if _, err := Write(&bytes.Buffer{}, FormatPain001, lines, testOptions()); !errors.Is(err, ErrMissingBankDetails) {
+		t.Errorf("error = %v, want ErrMissingBankDetails", err)
+	}
+	if _, err := Write(&bytes.Buffer{}, FormatPain001, nil, testOptions()); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("error = %v, want ErrEmptyBatch", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Write(&buf, FormatCSV, testLines(), testOptions()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	tampered := strings.Replace(buf.String(), "9750.00", "9790.00", 1)
+	if err := Verify([]byte(tampered)); err == nil {
+		t.Error("Verify accepted a modified file")
+	}
+}
+
+func TestWriteAccountStatementCSV(t *testing.T) {
+	account := &model.Accounts{ID: uuid.New(), OwnerID: uuid.New(), AccountType: model.AccountTypeMerchant, Currency: "NGN"}
+	from, to := model.StatementMonth(createdAt)
+	st := model.NewAccountStatement(account, from, to, 100000, []*model.AccountEntry{
+		{ID: uuid.New(), Amount: 975000, Balance: 1075000, Description: "settlement", CreatedAt: createdAt},
+		{ID: uuid.New(), Amount: -1075000, Balance: 0, Description: "payout", CreatedAt: createdAt.Add(time.Hour)},
+	})
+
+	var buf bytes.Buffer
+	checksum, err := WriteAccountStatementCSV(&buf, st, nil)
+	if err != nil {
+		t.Fatalf("WriteAccountStatementCSV: %v", err)
+	}
+	if err := Verify(buf.Bytes()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	r := csv.NewReader(strings.NewReader(buf.String()))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	// header, opening, 2 entries, closing, account, checksum
+	if len(records) != 7 {
+		t.Fatalf("got %d records, want 7", len(records))
+	}
+	if got := strings.Join(records[1][5:], ","); got != ",,1000.00" {
+		t.Errorf("opening amounts = %q", got)
+	}
+	if got := strings.Join(records[2][5:], ","); got != ",9750.00,10750.00" {
+		t.Errorf("credit entry amounts = %q", got)
+	}
+	if got := strings.Join(records[3][5:], ","); got != "10750.00,,0.00" {
+		t.Errorf("debit entry amounts = %q", got)
+	}
+	if got := strings.Join(records[4][5:], ","); got != "10750.00,9750.00,0.00" {
+		t.Errorf("closing amounts = %q", got)
+	}
+	if got := records[6][2]; got != checksum {
+		t.Errorf("checksum row = %q, want %q", got, checksum)
+	}
+}
@@ -0,0 +1,231 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+const pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// pain.001.001.03 elements, in schema order. Only the fields the gateway
+// fills are modelled.
+type painDocument struct {
+	XMLName xml.Name       `xml:"Document"`
+	Xmlns   string         `xml:"xmlns,attr"`
+	Init    painInitiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type painInitiation struct {
+	GrpHdr painGroupHeader  `xml:"GrpHdr"`
+	PmtInf []painPaymentInf `xml:"PmtInf"`
+}
+
+type painGroupHeader struct {
+	MsgId    string    `xml:"MsgId"`
+	CreDtTm  string    `xml:"CreDtTm"`
+	NbOfTxs  int       `xml:"NbOfTxs"`
+	CtrlSum  string    `xml:"CtrlSum"`
+	InitgPty painParty `xml:"InitgPty"`
+}
+
+type painPaymentInf struct {
+	PmtInfId    string            `xml:"PmtInfId"`
+	PmtMtd      string            `xml:"PmtMtd"`
+	BtchBookg   bool              `xml:"BtchBookg"`
+	NbOfTxs     int               `xml:"NbOfTxs"`
+	CtrlSum     string            `xml:"CtrlSum"`
+	ReqdExctnDt string            `xml:"ReqdExctnDt"`
+	Dbtr        painParty         `xml:"Dbtr"`
+	DbtrAcct    painAccount       `xml:"DbtrAcct"`
+	DbtrAgt     painAgent         `xml:"DbtrAgt"`
+	CdtTrfTxInf []painTransaction `xml:"CdtTrfTxInf"`
+}
+
+type painTransaction struct {
+	PmtId    painPaymentID  `xml:"PmtId"`
+	Amt      painAmount     `xml:"Amt"`
+	CdtrAgt  painAgent      `xml:"CdtrAgt"`
+	Cdtr     painParty      `xml:"Cdtr"`
+	CdtrAcct painAccount    `xml:"CdtrAcct"`
+	RmtInf   painRemittance `xml:"RmtInf"`
+}
+
+type painPaymentID struct {
+	InstrId    string `xml:"InstrId"`
+	EndToEndId string `xml:"EndToEndId"`
+}
+
+type painAmount struct {
+	InstdAmt painInstructedAmount `xml:"InstdAmt"`
+}
+
+type painInstructedAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type painParty struct {
+	Nm string `xml:"Nm"`
+}
+
+type painAccount struct {
+	Id  painAccountID `xml:"Id"`
+	Ccy string        `xml:"Ccy,omitempty"`
+}
+
+type painAccountID struct {
+	IBAN string     `xml:"IBAN,omitempty"`
+	Othr *painOther `xml:"Othr,omitempty"`
+}
+
+type painOther struct {
+	Id string `xml:"Id"`
+}
+
+type painAgent struct {
+	FinInstnId painInstitution `xml:"FinInstnId"`
+}
+
+type painInstitution struct {
+	BIC         string              `xml:"BIC,omitempty"`
+	ClrSysMmbId *painClearingMember `xml:"ClrSysMmbId,omitempty"`
+	Othr        *painOther          `xml:"Othr,omitempty"`
+}
+
+type painClearingMember struct {
+	MmbId string `xml:"MmbId"`
+}
+
+type painRemittance struct {
+	Ustrd string `xml:"Ustrd"`
+}
+
+// writePain001 writes one payment information block per currency, each
+// with its own NbOfTxs and CtrlSum; the group header carries the totals
+// over all of them.
+func writePain001(w io.Writer, lines []*model.SettlementExportLine, summary *Summary, opts Options) error {
+	if opts.Debtor.AccountName == "" || (opts.Debtor.IBAN == "" && opts.Debtor.AccountNumber == "") {
+		return fmt.Errorf("%w: debtor name and account are required for pain.001", ErrMissingBankDetails)
+	}
+
+	if len(lines) == 0 {
+		// A pain.001 message needs at least one payment
+		return ErrEmptyBatch
+	}
+
+	byCurrency := make(map[string][]*model.SettlementExportLine)
+	for _, l := range lines {
+		if l.BankDetails.Empty() {
+			return fmt.Errorf("%w: merchant %s has no account for settlement %s", ErrMissingBankDetails, l.MerchantID, l.Settlement.ID)
+		}
+		byCurrency[l.Settlement.Currency] = append(byCurrency[l.Settlement.Currency], l)
+	}
+
+	doc := painDocument{
+		Xmlns: pain001Namespace,
+		Init: painInitiation{
+			GrpHdr: painGroupHeader{
+				MsgId:    opts.MessageID,
+				CreDtTm:  opts.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+				NbOfTxs:  summary.Count,
+				InitgPty: painParty{Nm: truncate(opts.Debtor.AccountName, 140)},
+			},
+		},
+	}
+
+	// ISO 20022 control sums add the decimal amounts regardless of currency
+	groupSum := new(big.Rat)
+	for i, t := range summary.Totals {
+		ctrlSum, err := formatAmount(opts.Currencies, t.Amount, t.Currency)
+		if err != nil {
+			return err
+		}
+		sum, _ := new(big.Rat).SetString(ctrlSum)
+		groupSum.Add(groupSum, sum)
+
+		block := painPaymentInf{
+			PmtInfId:    truncate(fmt.Sprintf("%s-%d", opts.MessageID, i+1), 35),
+			PmtMtd:      "TRF",
+			BtchBookg:   true,
+			NbOfTxs:     t.Count,
+			CtrlSum:     ctrlSum,
+			ReqdExctnDt: opts.CreatedAt.UTC().Format("2006-01-02"),
+			Dbtr:        painParty{Nm: truncate(opts.Debtor.AccountName, 140)},
+			DbtrAcct:    account(opts.Debtor, t.Currency),
+			DbtrAgt:     agent(opts.Debtor),
+		}
+		for _, l := range byCurrency[t.Currency] {
+			s := l.Settlement
+			amount, err := formatAmount(opts.Currencies, s.NetAmount, s.Currency)
+			if err != nil {
+				return err
+			}
+			name := l.BankDetails.AccountName
+			if name == "" {
+				name = l.MerchantName
+			}
+			ref := strings.ReplaceAll(s.ID.String(), "-", "")
+
+			block.CdtTrfTxInf = append(block.CdtTrfTxInf, painTransaction{
+				PmtId:    painPaymentID{InstrId: ref, EndToEndId: ref},
+				Amt:      painAmount{InstdAmt: painInstructedAmount{Ccy: s.Currency, Value: amount}},
+				CdtrAgt:  agent(l.BankDetails),
+				Cdtr:     painParty{Nm: truncate(name, 140)},
+				CdtrAcct: account(l.BankDetails, ""),
+				RmtInf:   painRemittance{Ustrd: truncate("Settlement "+s.ID.String()+" txn "+s.ExternalReference, 140)},
+			})
+		}
+		doc.Init.PmtInf = append(doc.Init.PmtInf, block)
+	}
+	doc.Init.GrpHdr.CtrlSum = ratString(groupSum)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func account(b model.BankDetails, currency string) painAccount {
+	if b.IBAN != "" {
+		return painAccount{Id: painAccountID{IBAN: b.IBAN}, Ccy: currency}
+	}
+	return painAccount{Id: painAccountID{Othr: &painOther{Id: b.AccountNumber}}, Ccy: currency}
+}
+
+// agent identifies the bank by BIC, else by its clearing system member id
+// (the local bank code).
+func agent(b model.BankDetails) painAgent {
+	switch {
+	case b.BIC != "":
+		return painAgent{FinInstnId: painInstitution{BIC: b.BIC}}
+	case b.BankCode != "":
+		return painAgent{FinInstnId: painInstitution{ClrSysMmbId: &painClearingMember{MmbId: b.BankCode}}}
+	}
+	return painAgent{FinInstnId: painInstitution{Othr: &painOther{Id: "NOTPROVIDED"}}}
+}
+
+// ratString renders a sum of decimal amounts with as many decimals as it
+// needs (at most 5, the schema's limit).
+func ratString(r *big.Rat) string {
+	s := r.FloatString(5)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
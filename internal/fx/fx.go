@@ -0,0 +1,240 @@
+// Package fx converts money between currencies. A RateProvider supplies
+// rates; Converter applies them with each currency's minor-unit exponent and
+// records what was applied.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var ErrRateNotFound = errors.New("fx rate not found")
+
+// rateScale is the number of decimals kept for derived (inverse) rates.
+const rateScale = 12
+
+// RateProvider returns the rate to convert one unit of from into to.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (*model.FXRate, error)
+}
+
+// StaticProvider serves a fixed set of rates. Inverse pairs are derived when
+// only one direction is configured.
+type StaticProvider struct {
+	rates map[string]model.FXRate
+}
+
+func NewStaticProvider(rates ...model.FXRate) (*StaticProvider, error) {
+	p := &StaticProvider{rates: make(map[string]model.FXRate, len(rates))}
+	for _, r := range rates {
+		if _, ok := new(big.Rat).SetString(r.Rate); !ok {
+			return nil, fmt.Errorf("invalid rate %q for %s/%s", r.Rate, r.From, r.To)
+		}
+		r.From, r.To = strings.ToUpper(r.From), strings.ToUpper(r.To)
+		if r.Source == "" {
+			r.Source = "static"
+		}
+		p.rates[pairKey(r.From, r.To)] = r
+	}
+	return p, nil
+}
+
+// LoadStaticProvider reads a JSON array of model.FXRate from path.
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fx rates: %w", err)
+	}
+	var rates []model.FXRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("parse fx rates %s: %w", path, err)
+	}
+	for i := range rates {
+		if rates[i].Source == "" {
+			rates[i].Source = "file:" + path
+		}
+	}
+	return NewStaticProvider(rates...)
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (*model.FXRate, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return &model.FXRate{From: from, To: to, Rate: "1", Source: "identity", AsOf: time.Now().UTC()}, nil
+	}
+
+	if r, ok := p.rates[pairKey(from, to)]; ok {
+		return &r, nil
+	}
+	if r, ok := p.rates[pairKey(to, from)]; ok {
+		return invert(&r)
+	}
+	return nil, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
+
+// RateStore is the storage behind DBProvider, implemented by
+// repo.PostgresFXRateRepository.
+type RateStore interface {
+	Rate(ctx context.Context, from, to string, at time.Time) (*model.FXRate, error)
+}
+
+// DBProvider serves the latest effective rate from the fx_rates table,
+// deriving the inverse when only the opposite pair is stored.
+type DBProvider struct {
+	store RateStore
+}
+
+func NewDBProvider(store RateStore) *DBProvider {
+	return &DBProvider{store: store}
+}
+
+func (p *DBProvider) Rate(ctx context.Context, from, to string) (*model.FXRate, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	now := time.Now().UTC()
+	if from == to {
+		return &model.FXRate{From: from, To: to, Rate: "1", Source: "identity", AsOf: now}, nil
+	}
+
+	r, err := p.store.Rate(ctx, from, to, now)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return r, nil
+	}
+	r, err = p.store.Rate(ctx, to, from, now)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return invert(r)
+	}
+	return nil, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
+
+// Converter applies rates between currencies known to a registry.
+type Converter struct {
+	provider   RateProvider
+	currencies *model.CurrencyRegistry
+}
+
+func NewConverter(provider RateProvider, currencies *model.CurrencyRegistry) *Converter {
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	if provider == nil {
+		provider, _ = NewStaticProvider()
+	}
+	return &Converter{provider: provider, currencies: currencies}
+}
+
+// Convert converts m into currency to at the provider's current rate and
+// returns the conversion that was applied. Converting to the same currency
+// is a no-op with a rate of 1.
+func (c *Converter) Convert(ctx context.Context, m model.Money, to string) (model.Money, *model.FXConversion, error) {
+	rate, err := c.provider.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return model.Money{}, nil, err
+	}
+	converted, err := c.ConvertAt(m, to, rate.Rate)
+	if err != nil {
+		return model.Money{}, nil, err
+	}
+	return converted, &model.FXConversion{
+		From:   m,
+		To:     converted,
+		Rate:   rate.Rate,
+		Source: rate.Source,
+		AsOf:   rate.AsOf,
+	}, nil
+}
+
+// ConvertAt converts m into currency to at a given rate, e.g. the rate
+// recorded on a settlement when refunding it. Results are rounded half away
+// from zero to the target currency's minor unit.
+func (c *Converter) ConvertAt(m model.Money, to, rate string) (model.Money, error) {
+	from, err := c.currencies.Get(m.Currency)
+	if err != nil {
+		return model.Money{}, err
+	}
+	target, err := c.currencies.Get(to)
+	if err != nil {
+		return model.Money{}, err
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return model.Money{}, fmt.Errorf("invalid fx rate %q", rate)
+	}
+
+	// minor_to = minor_from * rate * 10^(exp_to - exp_from)
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), r)
+	if d := target.Exponent - from.Exponent; d > 0 {
+		v.Mul(v, new(big.Rat).SetInt(pow10(d)))
+	} else if d < 0 {
+		v.Quo(v, new(big.Rat).SetInt(pow10(-d)))
+	}
+
+	amount, err := roundHalfAway(v)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return model.NewMoney(amount, target.Code), nil
+}
+
+func invert(r *model.FXRate) (*model.FXRate, error) {
+	v, ok := new(big.Rat).SetString(r.Rate)
+	if !ok || v.Sign() == 0 {
+		return nil, fmt.Errorf("invalid fx rate %q for %s/%s", r.Rate, r.From, r.To)
+	}
+	return &model.FXRate{
+		From:   r.To,
+		To:     r.From,
+		Rate:   strings.TrimRight(strings.TrimRight(new(big.Rat).Inv(v).FloatString(rateScale), "0"), "."),
+		Source: r.Source + " (inverse)",
+		AsOf:   r.AsOf,
+	}, nil
+}
+
+func roundHalfAway(v *big.Rat) (int64, error) {
+	num := new(big.Int).Abs(v.Num())
+	q, rem := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("converted amount overflows int64")
+	}
+	return q.Int64(), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// NewConverterFromEnv uses the rates in FX_RATES_FILE when set and the
+// fx_rates table (through store) otherwise.
+func NewConverterFromEnv(store RateStore, currencies *model.CurrencyRegistry) (*Converter, error) {
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		p, err := LoadStaticProvider(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewConverter(p, currencies), nil
+	}
+	return NewConverter(NewDBProvider(store), currencies), nil
+}
+
+func pairKey(from, to string) string {
+	return from + "/" + to
+}
@@ -0,0 +1,63 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+func TestConverterConvert(t *testing.T) {
+	provider, err := NewStaticProvider(
+		model.FXRate{From: "USD", To: "NGN", Rate: "1550.25"},
+		model.FXRate{From: "USD", To: "JPY", Rate: "151.5"},
+		model.FXRate{From: "KWD", To: "USD", Rate: "3.25"},
+	)
+	if err != nil {
+		t.Fatalf("NewStaticProvider: %v", err)
+	}
+	c := NewConverter(provider, nil)
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		from model.Money
+		to   string
+		want model.Money
+	}{
+		{"same exponent", model.NewMoney(1000, "USD"), "NGN", model.NewMoney(1550250, "NGN")},
+		{"to zero exponent", model.NewMoney(1001, "USD"), "JPY", model.NewMoney(1517, "JPY")},
+		{"from three decimals", model.NewMoney(1000, "KWD"), "USD", model.NewMoney(325, "USD")},
+		{"inverse rate", model.NewMoney(1550250, "NGN"), "USD", model.NewMoney(1000, "USD")},
+		{"rounds half away", model.NewMoney(2, "KWD"), "USD", model.NewMoney(1, "USD")},
+		{"identity", model.NewMoney(4200, "NGN"), "NGN", model.NewMoney(4200, "NGN")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, conversion, err := c.Convert(ctx, tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Convert(%+v, %s) = %+v, want %+v", tc.from, tc.to, got, tc.want)
+			}
+			if conversion.From != tc.from || conversion.To != got || conversion.Rate == "" {
+				t.Fatalf("conversion not recorded: %+v", conversion)
+			}
+		})
+	}
+
+	if _, _, err := c.Convert(ctx, model.NewMoney(100, "GBP"), "NGN"); !errors.Is(err, ErrRateNotFound) {
+		t.Fatalf("missing pair err = %v, want ErrRateNotFound", err)
+	}
+}
+
+func TestConverterConvertAtRejectsBadRate(t *testing.T) {
+	c := NewConverter(nil, nil)
+	for _, rate := range []string{"", "abc", "0", "-1.5"} {
+		if _, err := c.ConvertAt(model.NewMoney(100, "USD"), "NGN", rate); err == nil {
+			t.Errorf("ConvertAt with rate %q: expected error", rate)
+		}
+	}
+}
@@ -14,6 +14,29 @@ const (
     AccountTypeSystem   AccountType = "system"
 )
 
+// Well-known owners for system accounts. One account per currency is kept
+// for each of them.
+var (
+    // SystemClearingOwnerID owns the clearing accounts that hold funds
+    // collected from payers until they are settled to merchants.
+    SystemClearingOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+    // SystemHoldsOwnerID owns the accounts that carry authorization holds
+    // placed on customer accounts until they are captured, voided or expire.
+    SystemHoldsOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+    // SystemFXOwnerID owns the FX position accounts that balance each side of
+    // a cross-currency settlement or refund.
+    SystemFXOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+    // SystemFeeRevenueOwnerID owns the revenue accounts that merchant fees
+    // are posted to.
+    SystemFeeRevenueOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000004")
+    // SystemPayoutClearingOwnerID owns the accounts merchant balances move
+    // to when a payout is created, until the bank transfer is confirmed.
+    SystemPayoutClearingOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000005")
+    // SystemWriteOffOwnerID owns the accounts reconciliation differences are
+    // written off to.
+    SystemWriteOffOwnerID = uuid.MustParse("00000000-0000-0000-0000-000000000006")
+)
+
 type Accounts struct {
     ID        uuid.UUID   `json:"id" db:"id"`
     OwnerID   uuid.UUID   `json:"owner_id" db:"owner_id"` // user or merchant id
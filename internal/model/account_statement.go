@@ -0,0 +1,102 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountBalance splits an account's money three ways. Available is the
+// ledger balance, what can be spent or paid out. Reserved has left the
+// balance but not the gateway yet: open authorization holds on a customer
+// account and pending payouts from a merchant account. Pending has not
+// reached the balance yet: a merchant's settlements that are still being
+// processed, net of fees. For a past AsOf only Available is known, and
+// Pending and Reserved are left out.
+type AccountBalance struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Currency  string    `json:"currency"`
+	Available int64     `json:"available"`
+	Pending   *int64    `json:"pending,omitempty"`
+	Reserved  *int64    `json:"reserved,omitempty"`
+	AsOf      time.Time `json:"as_of"`
+}
+
+// AccountEntry is one ledger leg seen from the account it posts to. Amount
+// is signed (credits positive, debits negative) and Balance is the
+// account's balance after the leg.
+type AccountEntry struct {
+	ID            uuid.UUID       `json:"id"`
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	EntryType     LedgerEntryType `json:"entry_type"`
+	Amount        int64           `json:"amount"`
+	Currency      string          `json:"currency"`
+	Description   string          `json:"description"`
+	Balance       int64           `json:"balance"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AccountEntryFilter selects an account's entries created in [From, To).
+// A zero bound is open.
+type AccountEntryFilter struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// AccountStatement covers an account's entries in [From, To).
+type AccountStatement struct {
+	AccountID      uuid.UUID       `json:"account_id"`
+	OwnerID        uuid.UUID       `json:"owner_id"`
+	AccountType    AccountType     `json:"account_type"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance int64           `json:"opening_balance"`
+	ClosingBalance int64           `json:"closing_balance"`
+	Credits        int64           `json:"total_credits"`
+	Debits         int64           `json:"total_debits"` // positive
+	CreditCount    int             `json:"credit_count"`
+	DebitCount     int             `json:"debit_count"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	Entries        []*AccountEntry `json:"entries"`
+}
+
+// NewAccountStatement totals entries, which must be in posting order,
+// from the opening balance.
+func NewAccountStatement(account *Accounts, from, to time.Time, opening int64, entries []*AccountEntry) *AccountStatement {
+	st := &AccountStatement{
+		AccountID:      account.ID,
+		OwnerID:        account.OwnerID,
+		AccountType:    account.AccountType,
+		Currency:       account.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		GeneratedAt:    time.Now().UTC(),
+		Entries:        entries,
+	}
+	if st.Entries == nil {
+		st.Entries = []*AccountEntry{}
+	}
+	for _, e := range entries {
+		if e.Amount >= 0 {
+			st.Credits += e.Amount
+			st.CreditCount++
+		} else {
+			st.Debits -= e.Amount
+			st.DebitCount++
+		}
+		st.ClosingBalance += e.Amount
+	}
+	return st
+}
+
+// StatementMonth returns the calendar month (UTC) containing t as [from, to).
+func StatementMonth(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
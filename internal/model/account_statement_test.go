@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewAccountStatement(t *testing.T) {
+	account := &Accounts{ID: uuid.New(), AccountType: AccountTypeMerchant, Currency: "NGN"}
+	from, to := StatementMonth(time.Date(2026, 3, 17, 9, 30, 0, 0, time.UTC))
+	if !from.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("StatementMonth = %s, %s", from, to)
+	}
+
+	st := NewAccountStatement(account, from, to, 5000, []*AccountEntry{
+		{Amount: 2000, Balance: 7000},
+		{Amount: -1500, Balance: 5500},
+		{Amount: 300, Balance: 5800},
+	})
+	if st.ClosingBalance != 5800 {
+		t.Errorf("closing balance = %d, want 5800", st.ClosingBalance)
+	}
+	if st.Credits != 2300 || st.CreditCount != 2 || st.Debits != 1500 || st.DebitCount != 1 {
+		t.Errorf("totals = %d credits (%d), %d debits (%d)", st.Credits, st.CreditCount, st.Debits, st.DebitCount)
+	}
+
+	empty := NewAccountStatement(account, from, to, 5000, nil)
+	if empty.ClosingBalance != 5000 || empty.Entries == nil {
+		t.Errorf("empty statement = %+v", empty)
+	}
+}
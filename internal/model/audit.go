@@ -8,6 +8,7 @@ import (
 type AuditLogs struct {
 	ID uuid.UUID `json:"id" db:"id"`
 	ActorID uuid.UUID `json:"actor_id" db:"actor_id"`
+	Actor string    `json:"actor" db:"actor"` // token subject, which need not be a UUID
 	Action string    `json:"action" db:"action"`
 	Entity string    `json:"entity" db:"entity"`
 	EntityID uuid.UUID `json:"entity_id" db:"entity_id"`
@@ -18,11 +19,18 @@ type AuditLogs struct {
 type ReconciliationLogs struct {
 	ID uuid.UUID `json:"id" db:"id"`
 	EntityType string    `json:"entity_type" db:"entity_type"`
-	EntityID uuid.UUID `json:"entity_id" db:"entity_id"`
+	EntityID uuid.UUID `json:"entity_id" db:"entity_id"` // uuid.Nil for statement lines with no settlement
 	ExpectedAmount int64 `json:"expected_amount" db:"expected_amount"`
 	ActualAmount int64 `json:"actual_amount" db:"actual_amount"`
 	Status string    `json:"status" db:"status"`
 	Notes string    `json:"notes" db:"notes"`
-	Metadata string    `json:"metadata" db:"metadata"`
+	Metadata map[string]any `json:"metadata" db:"metadata"`
+	DedupeKey string `json:"-" db:"dedupe_key"`
+	AssignedTo string `json:"assigned_to,omitempty" db:"assigned_to"`
+	AssignedAt *time.Time `json:"assigned_at,omitempty" db:"assigned_at"`
+	Resolution ReconResolution `json:"resolution,omitempty" db:"resolution"`
+	ResolvedBy string `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
\ No newline at end of file
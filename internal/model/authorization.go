@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuthorizationStatus string
+
+const (
+	AuthorizationAuthorized AuthorizationStatus = "authorized"
+	AuthorizationCaptured   AuthorizationStatus = "captured"
+	AuthorizationVoided     AuthorizationStatus = "voided"
+	AuthorizationExpired    AuthorizationStatus = "expired"
+)
+
+// Authorization is the hold placed for a manual-capture transaction. Amount
+// is what the connector authorized; CapturedAmount is set on capture and
+// becomes the transaction amount.
+type Authorization struct {
+	ID             uuid.UUID           `json:"id" db:"id"`
+	TransactionID  uuid.UUID           `json:"transaction_id" db:"transaction_id"`
+	Connector      string              `json:"connector" db:"connector"`
+	ExternalID     string              `json:"external_id" db:"external_id"`
+	Amount         int64               `json:"amount" db:"amount"`
+	CapturedAmount int64               `json:"captured_amount" db:"captured_amount"`
+	Currency       string              `json:"currency" db:"currency"`
+	Status         AuthorizationStatus `json:"status" db:"status"`
+	HoldAccountID  uuid.UUID           `json:"hold_account_id" db:"hold_account_id"` // customer account the hold was placed on
+	ExpiresAt      time.Time           `json:"expires_at" db:"expires_at"`
+	Metadata       map[string]any      `json:"metadata" db:"metadata"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// SnapshotDays returns the end-of-day boundaries (midnight UTC) still to be
+// snapshotted: each one after last, or from the day of first when there is
+// no snapshot yet, up to the latest midnight at least lag before now. They
+// are returned in order, since each snapshot builds on the one before.
+func SnapshotDays(last, first, now time.Time, lag time.Duration) []time.Time {
+	var next time.Time
+	switch {
+	case !last.IsZero():
+		next = midnight(last).AddDate(0, 0, 1)
+	case !first.IsZero():
+		next = midnight(first).AddDate(0, 0, 1)
+	default:
+		return nil
+	}
+
+	end := midnight(now.Add(-lag))
+	var days []time.Time
+	for d := next; !d.After(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+func midnight(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotDays(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	now := time.Date(2026, 3, 5, 0, 10, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		last, first time.Time
+		lag         time.Duration
+		want        []time.Time
+	}{
+		{"nothing posted", time.Time{}, time.Time{}, 0, nil},
+		{"first run", time.Time{}, day(2).Add(13 * time.Hour), 0, []time.Time{day(3), day(4), day(5)}},
+		{"up to date", day(5), day(1), 0, nil},
+		{"catching up", day(3), day(1), 0, []time.Time{day(4), day(5)}},
+		{"day not closed for lag", day(3), day(1), 30 * time.Minute, []time.Time{day(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SnapshotDays(tt.last, tt.first, now, tt.lag)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("day %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyDisabled = errors.New("currency not enabled")
+)
+
+// Currency is an ISO 4217 currency. Exponent is the number of minor units
+// (2 for NGN kobo, 0 for JPY, 3 for KWD); amounts are always stored in minor
+// units.
+type Currency struct {
+	Code     string `json:"code" db:"code"`
+	Exponent int    `json:"exponent" db:"exponent"`
+	Name     string `json:"name" db:"name"`
+	Enabled  bool   `json:"enabled" db:"enabled"`
+}
+
+// FormatAmount renders a minor-unit amount as a decimal string, e.g.
+// 123456 NGN -> "1234.56".
+func (c Currency) FormatAmount(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if c.Exponent == 0 {
+		return sign + strconv.FormatInt(minor, 10)
+	}
+	s := fmt.Sprintf("%0*d", c.Exponent+1, minor)
+	return sign + s[:len(s)-c.Exponent] + "." + s[len(s)-c.Exponent:]
+}
+
+// ParseAmount converts a decimal string in major units into minor units. It
+// rejects more fractional digits than the currency's exponent allows.
+func (c Currency) ParseAmount(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > c.Exponent {
+		return 0, fmt.Errorf("%s allows at most %d decimal places", c.Code, c.Exponent)
+	}
+	frac += strings.Repeat("0", c.Exponent-len(frac))
+
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// isoCurrencies seeds the registry. Only NGN is enabled by default; others
+// are switched on through configuration or the currencies table.
+var isoCurrencies = []Currency{
+	{Code: "NGN", Exponent: 2, Name: "Nigerian Naira", Enabled: true},
+	{Code: "USD", Exponent: 2, Name: "US Dollar"},
+	{Code: "EUR", Exponent: 2, Name: "Euro"},
+	{Code: "GBP", Exponent: 2, Name: "Pound Sterling"},
+	{Code: "GHS", Exponent: 2, Name: "Ghana Cedi"},
+	{Code: "KES", Exponent: 2, Name: "Kenyan Shilling"},
+	{Code: "ZAR", Exponent: 2, Name: "South African Rand"},
+	{Code: "XOF", Exponent: 0, Name: "CFA Franc BCEAO"},
+	{Code: "JPY", Exponent: 0, Name: "Yen"},
+	{Code: "KWD", Exponent: 3, Name: "Kuwaiti Dinar"},
+	{Code: "BHD", Exponent: 3, Name: "Bahraini Dinar"},
+}
+
+// CurrencyRegistry is the set of known currencies and which of them are
+// accepted. It is safe for concurrent use.
+type CurrencyRegistry struct {
+	mu         sync.RWMutex
+	currencies map[string]Currency
+}
+
+func NewCurrencyRegistry(currencies ...Currency) *CurrencyRegistry {
+	r := &CurrencyRegistry{currencies: make(map[string]Currency, len(currencies))}
+	for _, c := range currencies {
+		r.Set(c)
+	}
+	return r
+}
+
+// DefaultCurrencyRegistry returns a registry seeded with the built-in ISO
+// 4217 list.
+func DefaultCurrencyRegistry() *CurrencyRegistry {
+	return NewCurrencyRegistry(isoCurrencies...)
+}
+
+// Set adds or replaces a currency.
+func (r *CurrencyRegistry) Set(c Currency) {
+	c.Code = strings.ToUpper(c.Code)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.currencies[c.Code] = c
+}
+
+// Enable switches on the given codes, which must already be known.
+func (r *CurrencyRegistry) Enable(codes ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		c, ok := r.currencies[code]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+		}
+		c.Enabled = true
+		r.currencies[code] = c
+	}
+	return nil
+}
+
+// Get returns a known currency whether or not it is enabled.
+func (r *CurrencyRegistry) Get(code string) (Currency, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Validate returns the currency if it is known and enabled.
+func (r *CurrencyRegistry) Validate(code string) (Currency, error) {
+	c, err := r.Get(code)
+	if err != nil {
+		return c, err
+	}
+	if !c.Enabled {
+		return c, fmt.Errorf("%w: %s", ErrCurrencyDisabled, c.Code)
+	}
+	return c, nil
+}
+
+// List returns every known currency ordered by code.
+func (r *CurrencyRegistry) List() []Currency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Currency, 0, len(r.currencies))
+	for _, c := range r.currencies {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
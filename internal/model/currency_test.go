@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCurrencyFormatAndParse(t *testing.T) {
+	cases := []struct {
+		currency Currency
+		minor    int64
+		major    string
+	}{
+		{Currency{Code: "NGN", Exponent: 2}, 123456, "1234.56"},
+		{Currency{Code: "NGN", Exponent: 2}, 5, "0.05"},
+		{Currency{Code: "NGN", Exponent: 2}, -150, "-1.50"},
+		{Currency{Code: "JPY", Exponent: 0}, 1500, "1500"},
+		{Currency{Code: "KWD", Exponent: 3}, 1234, "1.234"},
+	}
+	for _, tc := range cases {
+		if got := tc.currency.FormatAmount(tc.minor); got != tc.major {
+			t.Errorf("%s FormatAmount(%d) = %q, want %q", tc.currency.Code, tc.minor, got, tc.major)
+		}
+		got, err := tc.currency.ParseAmount(tc.major)
+		if err != nil || got != tc.minor {
+			t.Errorf("%s ParseAmount(%q) = %d, %v, want %d", tc.currency.Code, tc.major, got, err, tc.minor)
+		}
+	}
+
+	if _, err := (Currency{Code: "JPY", Exponent: 0}).ParseAmount("1.5"); err == nil {
+		t.Error("expected JPY to reject fractional amounts")
+	}
+	if got, _ := (Currency{Code: "USD", Exponent: 2}).ParseAmount("12.5"); got != 1250 {
+		t.Errorf("ParseAmount(12.5) = %d, want 1250", got)
+	}
+}
+
+func TestCurrencyRegistryValidate(t *testing.T) {
+	r := DefaultCurrencyRegistry()
+
+	if c, err := r.Validate("ngn"); err != nil || c.Code != "NGN" || c.Exponent != 2 {
+		t.Fatalf("Validate(ngn) = %+v, %v", c, err)
+	}
+	if _, err := r.Validate("USD"); !errors.Is(err, ErrCurrencyDisabled) {
+		t.Fatalf("Validate(USD) err = %v, want ErrCurrencyDisabled", err)
+	}
+	if _, err := r.Validate("XXX"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("Validate(XXX) err = %v, want ErrUnknownCurrency", err)
+	}
+
+	if err := r.Enable("usd", " jpy"); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if _, err := r.Validate("USD"); err != nil {
+		t.Fatalf("Validate(USD) after Enable: %v", err)
+	}
+	if err := r.Enable("XXX"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("Enable(XXX) err = %v, want ErrUnknownCurrency", err)
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := NewMoney(1000, "ngn")
+	sum, err := a.Add(NewMoney(250, "NGN"))
+	if err != nil || sum != NewMoney(1250, "NGN") {
+		t.Fatalf("Add = %+v, %v", sum, err)
+	}
+	diff, err := a.Sub(NewMoney(1500, "NGN"))
+	if err != nil || diff.Amount != -500 || diff.IsPositive() {
+		t.Fatalf("Sub = %+v, %v", diff, err)
+	}
+	if _, err := a.Add(NewMoney(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Add across currencies err = %v, want ErrCurrencyMismatch", err)
+	}
+}
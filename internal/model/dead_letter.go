@@ -0,0 +1,129 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeadLetterStatus string
+
+const (
+	DeadLetterPending      DeadLetterStatus = "pending"       // awaiting inspection or replay
+	DeadLetterReplayed     DeadLetterStatus = "replayed"      // published back onto its topic
+	DeadLetterReplayFailed DeadLetterStatus = "replay_failed" // the last replay could not be published
+)
+
+func (s DeadLetterStatus) Valid() bool {
+	switch s {
+	case DeadLetterPending, DeadLetterReplayed, DeadLetterReplayFailed:
+		return true
+	}
+	return false
+}
+
+// DeadLetter is a message that exhausted its retries. Occurrences counts
+// how often the same message (by Fingerprint) reached a DLQ; a message that
+// fails again after a replay goes back to pending.
+type DeadLetter struct {
+	ID             uuid.UUID        `json:"id"`
+	Fingerprint    string           `json:"fingerprint"`
+	Topic          string           `json:"topic"`
+	Key            string           `json:"key"`
+	Payload        []byte           `json:"-"`
+	RetryCount     int              `json:"retry_count"`
+	Reason         string           `json:"reason,omitempty"`
+	LastError      string           `json:"last_error,omitempty"`
+	Occurrences    int              `json:"occurrences"`
+	Status         DeadLetterStatus `json:"status"`
+	ReplayCount    int              `json:"replay_count"`
+	LastReplayedAt *time.Time       `json:"last_replayed_at,omitempty"`
+	FirstSeenAt    time.Time        `json:"first_seen_at"`
+	LastSeenAt     time.Time        `json:"last_seen_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// MarshalJSON adds the payload: as JSON when it is JSON, which every event
+// published by the gateway is, and base64 otherwise.
+func (d DeadLetter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.view())
+}
+
+type deadLetterView struct {
+	deadLetter
+	Payload         any    `json:"payload"`
+	PayloadEncoding string `json:"payload_encoding"`
+}
+
+// deadLetter has DeadLetter's fields without its MarshalJSON.
+type deadLetter DeadLetter
+
+func (d DeadLetter) view() deadLetterView {
+	v := deadLetterView{deadLetter: deadLetter(d)}
+	if json.Valid(d.Payload) {
+		v.Payload, v.PayloadEncoding = json.RawMessage(d.Payload), "json"
+	} else {
+		v.Payload, v.PayloadEncoding = base64.StdEncoding.EncodeToString(d.Payload), "base64"
+	}
+	return v
+}
+
+// DeadLetterFingerprint identifies a message by its topic, key and payload.
+func DeadLetterFingerprint(topic, key string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DeadLetterFilter selects dead letters last seen in [From, To). Zero
+// fields match everything.
+type DeadLetterFilter struct {
+	Topic  string
+	Key    string
+	Status DeadLetterStatus
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type DeadLetterReplayStatus string
+
+const (
+	DeadLetterReplayPublished DeadLetterReplayStatus = "published"
+	DeadLetterReplayError     DeadLetterReplayStatus = "failed"
+)
+
+// DeadLetterReplay is one attempt to publish a dead letter back onto its
+// topic.
+type DeadLetterReplay struct {
+	ID           uuid.UUID              `json:"id"`
+	DeadLetterID uuid.UUID              `json:"dead_letter_id"`
+	Status       DeadLetterReplayStatus `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	Actor        string                 `json:"actor"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// DeadLetterDetail is a dead letter with its replay history.
+type DeadLetterDetail struct {
+	*DeadLetter
+	Replays []*DeadLetterReplay `json:"replays"`
+}
+
+// MarshalJSON keeps the promoted DeadLetter.MarshalJSON from dropping
+// Replays.
+func (d DeadLetterDetail) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		deadLetterView
+		Replays []*DeadLetterReplay `json:"replays"`
+	}{d.DeadLetter.view(), d.Replays})
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeadLetterFingerprint(t *testing.T) {
+	a := DeadLetterFingerprint("settlement.requested", "k1", []byte(`{"a":1}`))
+	if a != DeadLetterFingerprint("settlement.requested", "k1", []byte(`{"a":1}`)) {
+		t.Error("fingerprint is not stable")
+	}
+	// The separator keeps topic, key and payload apart
+	if a == DeadLetterFingerprint("settlement.requested", "k", []byte(`1{"a":1}`)) {
+		t.Error("different messages share a fingerprint")
+	}
+}
+
+func TestDeadLetterMarshalJSON(t *testing.T) {
+	decode := func(d DeadLetter) map[string]any {
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var out map[string]any
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		return out
+	}
+
+	out := decode(DeadLetter{Topic: "refund.requested", Payload: []byte(`{"refund_id":"r1"}`), Status: DeadLetterPending})
+	if out["payload_encoding"] != "json" || out["payload"].(map[string]any)["refund_id"] != "r1" {
+		t.Errorf("json payload = %v (%v)", out["payload"], out["payload_encoding"])
+	}
+	if out["topic"] != "refund.requested" || out["status"] != "pending" {
+		t.Errorf("fields = %v", out)
+	}
+
+	out = decode(DeadLetter{Payload: []byte{0xff, 0x00}})
+	if out["payload_encoding"] != "base64" || out["payload"] != "/wA=" {
+		t.Errorf("binary payload = %v (%v)", out["payload"], out["payload_encoding"])
+	}
+}
+
+func TestDeadLetterDetailMarshalJSON(t *testing.T) {
+	d := DeadLetterDetail{
+		DeadLetter: &DeadLetter{Topic: "refund.requested", Payload: []byte(`{}`)},
+		Replays:    []*DeadLetterReplay{{Status: DeadLetterReplayPublished, Actor: "ops"}},
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out struct {
+		Topic   string
+		Payload map[string]any
+		Replays []DeadLetterReplay
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if out.Topic != "refund.requested" || out.Payload == nil || len(out.Replays) != 1 {
+		t.Errorf("detail = %s", b)
+	}
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoFeeRule = errors.New("no fee rule for currency")
+
+// FeePlan is a merchant pricing schedule. A plan has one or more rules per
+// currency; rules with a higher MinMonthlyVolume form volume tiers.
+type FeePlan struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description,omitempty" db:"description"`
+	IsDefault   bool      `json:"is_default" db:"is_default"` // applies to merchants without a plan
+	Rules       []FeeRule `json:"rules"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FeeRule prices settlements in one currency once the merchant's settled
+// volume for the month reaches MinMonthlyVolume. The fee is
+// amount * PercentBps / 10000 + FixedFee, bounded by MinFee and MaxFee.
+// All amounts are in minor units of Currency.
+type FeeRule struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	Currency         string    `json:"currency" db:"currency"`
+	MinMonthlyVolume int64     `json:"min_monthly_volume" db:"min_monthly_volume"`
+	PercentBps       int64     `json:"percent_bps" db:"percent_bps"` // 150 = 1.5%
+	FixedFee         int64     `json:"fixed_fee" db:"fixed_fee"`
+	MinFee           int64     `json:"min_fee" db:"min_fee"`
+	MaxFee           *int64    `json:"max_fee,omitempty" db:"max_fee"` // cap; nil means uncapped
+}
+
+// Fee computes the fee for amount. It never exceeds the amount itself.
+func (r FeeRule) Fee(amount int64) int64 {
+	// Round half up on the percentage component.
+	fee := (amount*r.PercentBps+5000)/10000 + r.FixedFee
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	if r.MaxFee != nil && fee > *r.MaxFee {
+		fee = *r.MaxFee
+	}
+	if fee > amount {
+		fee = amount
+	}
+	if fee < 0 {
+		fee = 0
+	}
+	return fee
+}
+
+// Validate checks the rule is well formed.
+func (r FeeRule) Validate() error {
+	switch {
+	case r.Currency == "":
+		return errors.New("fee rule currency is required")
+	case r.PercentBps < 0 || r.PercentBps > 10000:
+		return fmt.Errorf("fee rule percent_bps %d out of range", r.PercentBps)
+	case r.FixedFee < 0 || r.MinFee < 0 || r.MinMonthlyVolume < 0:
+		return errors.New("fee rule amounts must not be negative")
+	case r.MaxFee != nil && *r.MaxFee < r.MinFee:
+		return errors.New("fee rule max_fee is below min_fee")
+	}
+	return nil
+}
+
+// Rule returns the tier that applies to a currency at the given monthly
+// volume: the matching rule with the highest MinMonthlyVolume not above it.
+func (p *FeePlan) Rule(currency string, monthlyVolume int64) (*FeeRule, error) {
+	var best *FeeRule
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !strings.EqualFold(r.Currency, currency) || r.MinMonthlyVolume > monthlyVolume {
+			continue
+		}
+		if best == nil || r.MinMonthlyVolume > best.MinMonthlyVolume {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: plan %s has no %s rule", ErrNoFeeRule, p.Name, currency)
+	}
+	return best, nil
+}
+
+// Quote prices a gross settlement amount under the plan.
+func (p *FeePlan) Quote(gross Money, monthlyVolume int64) (*FeeQuote, error) {
+	rule, err := p.Rule(gross.Currency, monthlyVolume)
+	if err != nil {
+		return nil, err
+	}
+	fee := rule.Fee(gross.Amount)
+	return &FeeQuote{
+		Gross:         gross,
+		Fee:           NewMoney(fee, gross.Currency),
+		Net:           NewMoney(gross.Amount-fee, gross.Currency),
+		PlanID:        p.ID,
+		RuleID:        rule.ID,
+		MonthlyVolume: monthlyVolume,
+	}, nil
+}
+
+// FeeQuote is the fee charged on one settlement, recorded in its metadata.
+type FeeQuote struct {
+	Gross         Money     `json:"gross"`
+	Fee           Money     `json:"fee"`
+	Net           Money     `json:"net"`
+	PlanID        uuid.UUID `json:"plan_id"`
+	RuleID        uuid.UUID `json:"rule_id"`
+	MonthlyVolume int64     `json:"monthly_volume"`
+}
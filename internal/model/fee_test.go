@@ -0,0 +1,65 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFeeRuleFee(t *testing.T) {
+	capped := int64(200000)
+	cases := []struct {
+		name   string
+		rule   FeeRule
+		amount int64
+		want   int64
+	}{
+		{"percentage and fixed", FeeRule{PercentBps: 150, FixedFee: 10000}, 1000000, 25000},
+		{"percentage rounds half up", FeeRule{PercentBps: 150}, 1001, 15},
+		{"capped", FeeRule{PercentBps: 150, FixedFee: 10000, MaxFee: &capped}, 100000000, 200000},
+		{"minimum", FeeRule{PercentBps: 100, MinFee: 5000}, 10000, 5000},
+		{"never above amount", FeeRule{FixedFee: 10000}, 4000, 4000},
+		{"free", FeeRule{}, 10000, 0},
+	}
+	for _, tc := range cases {
+		if got := tc.rule.Fee(tc.amount); got != tc.want {
+			t.Errorf("%s: Fee(%d) = %d, want %d", tc.name, tc.amount, got, tc.want)
+		}
+	}
+}
+
+func TestFeePlanQuoteTiers(t *testing.T) {
+	plan := &FeePlan{
+		Name: "tiered",
+		Rules: []FeeRule{
+			{Currency: "NGN", MinMonthlyVolume: 0, PercentBps: 150},
+			{Currency: "NGN", MinMonthlyVolume: 10_000_000, PercentBps: 100},
+			{Currency: "NGN", MinMonthlyVolume: 100_000_000, PercentBps: 50},
+			{Currency: "USD", PercentBps: 290, FixedFee: 30},
+		},
+	}
+
+	cases := []struct {
+		volume int64
+		gross  Money
+		fee    int64
+	}{
+		{0, NewMoney(100000, "NGN"), 1500},
+		{9_999_999, NewMoney(100000, "NGN"), 1500},
+		{10_000_000, NewMoney(100000, "NGN"), 1000},
+		{500_000_000, NewMoney(100000, "NGN"), 500},
+		{0, NewMoney(10000, "usd"), 320},
+	}
+	for _, tc := range cases {
+		q, err := plan.Quote(tc.gross, tc.volume)
+		if err != nil {
+			t.Fatalf("Quote(%+v, %d): %v", tc.gross, tc.volume, err)
+		}
+		if q.Fee.Amount != tc.fee || q.Net.Amount != tc.gross.Amount-tc.fee || q.Gross != tc.gross {
+			t.Errorf("Quote(%+v, %d) = %+v, want fee %d", tc.gross, tc.volume, q, tc.fee)
+		}
+	}
+
+	if _, err := plan.Quote(NewMoney(100, "GBP"), 0); !errors.Is(err, ErrNoFeeRule) {
+		t.Fatalf("Quote(GBP) err = %v, want ErrNoFeeRule", err)
+	}
+}
@@ -23,4 +23,13 @@ type LedgerEntries struct {
 	Metadata map[string] any `json:"metadata" db:"metadata"`
 	EntryType LedgerEntryType `json:"entry_type" db:"entry_type"`
 	CreatedAt time.Time   `json:"created_at" db:"created_at"`
-}
\ No newline at end of file
+}
+
+// AccountID returns the account this leg posts to: the debit account for
+// debit legs and the credit account for credit legs.
+func (e *LedgerEntries) AccountID() uuid.UUID {
+	if e.EntryType == LedgerDebit {
+		return e.DebitAccountID
+	}
+	return e.CreditAccountID
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// LedgerViolationKind names a ledger invariant.
+type LedgerViolationKind string
+
+const (
+	// LedgerUnbalanced: a transaction's legs in one currency do not sum to
+	// zero. Legs with no transaction are checked together.
+	LedgerUnbalanced LedgerViolationKind = "unbalanced_transaction"
+	// LedgerBalanceDrift: account_balances differs from the sum of the
+	// account's legs.
+	LedgerBalanceDrift LedgerViolationKind = "balance_drift"
+	// LedgerMissingAccount: a leg has no account or one that does not exist.
+	LedgerMissingAccount LedgerViolationKind = "missing_account"
+	// LedgerCurrencyMismatch: a leg's currency differs from its account's.
+	LedgerCurrencyMismatch LedgerViolationKind = "currency_mismatch"
+)
+
+// LedgerViolations lists every invariant kind in the order they are checked.
+var LedgerViolations = []LedgerViolationKind{
+	LedgerUnbalanced,
+	LedgerBalanceDrift,
+	LedgerMissingAccount,
+	LedgerCurrencyMismatch,
+}
+
+// LedgerViolation is one broken invariant. Which fields are set depends on
+// the kind. For an unbalanced transaction Actual is the net of its legs;
+// for balance drift Expected is the stored balance and Actual the net of
+// the account's legs; for the per-leg kinds EntryID names the leg and
+// Actual is its amount.
+type LedgerViolation struct {
+	Kind            LedgerViolationKind `json:"kind"`
+	TransactionID   uuid.UUID           `json:"transaction_id"`
+	AccountID       uuid.UUID           `json:"account_id"`
+	EntryID         uuid.UUID           `json:"entry_id"`
+	Currency        string              `json:"currency,omitempty"`
+	AccountCurrency string              `json:"account_currency,omitempty"`
+	Expected        int64               `json:"expected"`
+	Actual          int64               `json:"actual"`
+}
+
+// LedgerReport is the outcome of a ledger check. Counts holds the number
+// of violations of each kind; Violations keeps at most Limit of each.
+type LedgerReport struct {
+	Counts     map[LedgerViolationKind]int `json:"counts"`
+	Violations []*LedgerViolation          `json:"violations"`
+	Limit      int                         `json:"limit"`
+}
+
+func NewLedgerReport(limit int) *LedgerReport {
+	return &LedgerReport{Counts: make(map[LedgerViolationKind]int), Limit: limit}
+}
+
+// Add counts v and keeps it while fewer than Limit of its kind are kept.
+func (r *LedgerReport) Add(v *LedgerViolation) {
+	r.Counts[v.Kind]++
+	if r.Limit <= 0 || r.Counts[v.Kind] <= r.Limit {
+		r.Violations = append(r.Violations, v)
+	}
+}
+
+// Total is the number of violations found.
+func (r *LedgerReport) Total() int {
+	n := 0
+	for _, c := range r.Counts {
+		n += c
+	}
+	return n
+}
+
+// OK reports whether every invariant holds.
+func (r *LedgerReport) OK() bool {
+	return r.Total() == 0
+}
+
+// Of returns the kept violations of one kind.
+func (r *LedgerReport) Of(kind LedgerViolationKind) []*LedgerViolation {
+	var out []*LedgerViolation
+	for _, v := range r.Violations {
+		if v.Kind == kind {
+			out = append(out, v)
+		}
+	}
+	return out
+}
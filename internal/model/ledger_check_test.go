@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestLedgerReportLimit(t *testing.T) {
+	r := NewLedgerReport(2)
+	if !r.OK() {
+		t.Fatal("empty report is not OK")
+	}
+	for i := 0; i < 3; i++ {
+		r.Add(&LedgerViolation{Kind: LedgerUnbalanced, Actual: int64(i)})
+	}
+	r.Add(&LedgerViolation{Kind: LedgerBalanceDrift})
+
+	if r.OK() || r.Total() != 4 {
+		t.Errorf("Total() = %d, OK() = %v", r.Total(), r.OK())
+	}
+	if r.Counts[LedgerUnbalanced] != 3 {
+		t.Errorf("unbalanced count = %d, want 3", r.Counts[LedgerUnbalanced])
+	}
+	if got := r.Of(LedgerUnbalanced); len(got) != 2 || got[1].Actual != 1 {
+		t.Errorf("kept unbalanced = %d, want the first 2", len(got))
+	}
+	if got := r.Of(LedgerBalanceDrift); len(got) != 1 {
+		t.Errorf("kept drift = %d, want 1", len(got))
+	}
+}
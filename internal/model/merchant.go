@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMerchantNotFound  = errors.New("merchant not found")
+	ErrMerchantSuspended = errors.New("merchant is suspended")
+)
+
+type MerchantStatus string
+
+const (
+	MerchantActive    MerchantStatus = "active"
+	MerchantSuspended MerchantStatus = "suspended"
+)
+
+func (s MerchantStatus) Valid() bool {
+	return s == MerchantActive || s == MerchantSuspended
+}
+
+// BankDetails is where a merchant's payouts are sent.
+type BankDetails struct {
+	BankName      string `json:"bank_name,omitempty"`
+	BankCode      string `json:"bank_code,omitempty"`
+	AccountNumber string `json:"account_number,omitempty"`
+	AccountName   string `json:"account_name,omitempty"`
+	IBAN          string `json:"iban,omitempty"`
+	BIC           string `json:"bic,omitempty"`
+}
+
+// Empty reports whether no account to pay out to has been configured.
+func (b BankDetails) Empty() bool {
+	return b.AccountNumber == "" && b.IBAN == ""
+}
+
+type Merchant struct {
+	ID                 uuid.UUID      `json:"id" db:"id"`
+	Name               string         `json:"name" db:"name"`
+	Status             MerchantStatus `json:"status" db:"status"`
+	SettlementCurrency string         `json:"settlement_currency" db:"settlement_currency"`
+	BankDetails        BankDetails    `json:"bank_details" db:"bank_details"`
+	WebhookURL         string         `json:"webhook_url,omitempty" db:"webhook_url"`
+	WebhookSecret      string         `json:"webhook_secret,omitempty" db:"webhook_secret"` // only returned on create and rotation
+	FeePlanID          *uuid.UUID     `json:"fee_plan_id,omitempty" db:"fee_plan_id"`       // nil uses the default plan
+	Payout             PayoutPolicy   `json:"payout"`
+	Metadata           map[string]any `json:"metadata" db:"metadata"`
+	CreatedAt          time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at" db:"updated_at"`
+}
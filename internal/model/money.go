@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an amount in the minor units of its currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Format renders m in major units using the currency's exponent, e.g.
+// "1234.56 NGN".
+func (m Money) Format(c Currency) string {
+	return c.FormatAmount(m.Amount) + " " + m.Currency
+}
+
+// FXRate is the price of one unit of From in To, as a decimal string so it
+// round-trips without float error.
+type FXRate struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Rate   string    `json:"rate"`
+	Source string    `json:"source"`
+	AsOf   time.Time `json:"as_of"`
+}
+
+// FXConversion records a conversion that was applied, so the rate used for a
+// settlement can be reproduced (and reused for refunds).
+type FXConversion struct {
+	From   Money     `json:"from"`
+	To     Money     `json:"to"`
+	Rate   string    `json:"rate"`
+	Source string    `json:"source"`
+	AsOf   time.Time `json:"as_of"`
+}
+
+// Money returns the transaction amount with its currency.
+func (t *Transaction) Money() Money {
+	return NewMoney(t.Amount, t.Currency)
+}
+
+// Money returns the settled amount with its currency.
+func (s *Settlements) Money() Money {
+	return NewMoney(s.Amount, s.Currency)
+}
+
+// Money returns the leg amount with its currency.
+func (e *LedgerEntries) Money() Money {
+	return NewMoney(e.Amount, e.Currency)
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is a bus event waiting to be relayed by the outbox relay.
+type OutboxMessage struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Topic     string     `json:"topic" db:"topic"`
+	Key       string     `json:"key" db:"message_key"`
+	Payload   []byte     `json:"payload" db:"payload"`
+	Attempts  int        `json:"attempts" db:"attempts"`
+	LastError string     `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PayoutStatus string
+
+const (
+	PayoutPending PayoutStatus = "pending" // funds moved to payout clearing, not yet accepted by the connector
+	PayoutPaid    PayoutStatus = "paid"
+	PayoutFailed  PayoutStatus = "failed" // declined; funds returned to the merchant account
+)
+
+// PayoutSchedule is how often a merchant is paid out.
+type PayoutSchedule string
+
+const (
+	PayoutDaily     PayoutSchedule = "daily"     // once per UTC day
+	PayoutWeekly    PayoutSchedule = "weekly"    // once on PayoutWeekday
+	PayoutThreshold PayoutSchedule = "threshold" // whenever the balance reaches PayoutThreshold
+	PayoutManual    PayoutSchedule = "manual"    // never paid out automatically
+)
+
+func (s PayoutSchedule) Valid() bool {
+	switch s {
+	case PayoutDaily, PayoutWeekly, PayoutThreshold, PayoutManual:
+		return true
+	}
+	return false
+}
+
+// PayoutPolicy is a merchant's payout configuration.
+type PayoutPolicy struct {
+	Schedule  PayoutSchedule `json:"schedule"`
+	Weekday   time.Weekday   `json:"weekday"`   // for weekly
+	Threshold int64          `json:"threshold"` // for threshold; minimum balance for the others
+}
+
+// DefaultPayoutPolicy pays merchants out daily.
+func DefaultPayoutPolicy() PayoutPolicy {
+	return PayoutPolicy{Schedule: PayoutDaily, Weekday: time.Monday}
+}
+
+func (p PayoutPolicy) Validate() error {
+	if !p.Schedule.Valid() {
+		return fmt.Errorf("unknown payout schedule %q", p.Schedule)
+	}
+	if p.Weekday < time.Sunday || p.Weekday > time.Saturday {
+		return fmt.Errorf("payout weekday %d out of range", p.Weekday)
+	}
+	if p.Threshold < 0 {
+		return fmt.Errorf("payout threshold must not be negative")
+	}
+	if p.Schedule == PayoutThreshold && p.Threshold == 0 {
+		return fmt.Errorf("threshold payouts need a payout_threshold")
+	}
+	return nil
+}
+
+// Due reports whether an account with balance should be paid out at now.
+// lastPayout is the creation time of the account's previous payout (zero if
+// none). Daily and weekly payouts happen at most once per UTC day, and only
+// for balances of at least Threshold.
+func (p PayoutPolicy) Due(now time.Time, lastPayout time.Time, balance int64) bool {
+	if balance <= 0 || balance < p.Threshold {
+		return false
+	}
+	now = now.UTC()
+	paidToday := !lastPayout.IsZero() && sameDay(lastPayout.UTC(), now)
+
+	switch p.Schedule {
+	case PayoutDaily:
+		return !paidToday
+	case PayoutWeekly:
+		return now.Weekday() == p.Weekday && !paidToday
+	case PayoutThreshold:
+		return true
+	}
+	return false
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Payout moves a merchant account's available balance to the merchant's
+// bank account, batching the settlements it covers.
+type Payout struct {
+	ID                uuid.UUID      `json:"id" db:"id"`
+	MerchantID        uuid.UUID      `json:"merchant_id" db:"merchant_id"`
+	MerchantAccountID uuid.UUID      `json:"merchant_account_id" db:"merchant_account_id"`
+	Amount            int64          `json:"amount" db:"amount"`
+	Currency          string         `json:"currency" db:"currency"`
+	Status            PayoutStatus   `json:"status" db:"status"`
+	Schedule          PayoutSchedule `json:"schedule" db:"schedule"`
+	Connector         string         `json:"connector,omitempty" db:"connector"`
+	ExternalID        string         `json:"external_id,omitempty" db:"external_id"`
+	BankDetails       BankDetails    `json:"bank_details" db:"bank_details"`
+	SettlementCount   int            `json:"settlement_count" db:"settlement_count"`
+	FailureReason     string         `json:"failure_reason,omitempty" db:"failure_reason"`
+	Metadata          map[string]any `json:"metadata" db:"metadata"`
+	PaidAt            *time.Time     `json:"paid_at,omitempty" db:"paid_at"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+func (p *Payout) Money() Money {
+	return NewMoney(p.Amount, p.Currency)
+}
+
+// PayoutFilter narrows GET /v1/payouts.
+type PayoutFilter struct {
+	MerchantID *uuid.UUID
+	Status     PayoutStatus
+	Limit      int
+	Offset     int
+}
+
+// PayableAccount is a merchant account with a positive balance, as seen by
+// the payout job.
+type PayableAccount struct {
+	MerchantID   uuid.UUID
+	AccountID    uuid.UUID
+	Currency     string
+	Balance      int64
+	Policy       PayoutPolicy
+	BankDetails  BankDetails
+	LastPayoutAt time.Time
+}
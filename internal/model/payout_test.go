@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPayoutPolicyDue(t *testing.T) {
+	// 2026-03-04 is a Wednesday
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	earlierToday := now.Add(-2 * time.Hour)
+
+	cases := []struct {
+		name    string
+		policy  PayoutPolicy
+		last    time.Time
+		balance int64
+		want    bool
+	}{
+		{"daily first payout", PayoutPolicy{Schedule: PayoutDaily}, time.Time{}, 100, true},
+		{"daily paid yesterday", PayoutPolicy{Schedule: PayoutDaily}, yesterday, 100, true},
+		{"daily already paid today", PayoutPolicy{Schedule: PayoutDaily}, earlierToday, 100, false},
+		{"daily below minimum", PayoutPolicy{Schedule: PayoutDaily, Threshold: 500}, yesterday, 100, false},
+		{"empty balance", PayoutPolicy{Schedule: PayoutDaily}, time.Time{}, 0, false},
+		{"weekly on the day", PayoutPolicy{Schedule: PayoutWeekly, Weekday: time.Wednesday}, yesterday, 100, true},
+		{"weekly other day", PayoutPolicy{Schedule: PayoutWeekly, Weekday: time.Friday}, yesterday, 100, false},
+		{"threshold reached", PayoutPolicy{Schedule: PayoutThreshold, Threshold: 1000}, earlierToday, 1000, true},
+		{"threshold not reached", PayoutPolicy{Schedule: PayoutThreshold, Threshold: 1000}, time.Time{}, 999, false},
+		{"manual", PayoutPolicy{Schedule: PayoutManual}, time.Time{}, 100000, false},
+	}
+	for _, tc := range cases {
+		if got := tc.policy.Due(now, tc.last, tc.balance); got != tc.want {
+			t.Errorf("%s: Due = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPayoutPolicyValidate(t *testing.T) {
+	if err := (PayoutPolicy{Schedule: PayoutThreshold}).Validate(); err == nil {
+		t.Error("threshold schedule without threshold should be invalid")
+	}
+	if err := (PayoutPolicy{Schedule: "hourly"}).Validate(); err == nil {
+		t.Error("unknown schedule should be invalid")
+	}
+	if err := (PayoutPolicy{Schedule: PayoutWeekly, Weekday: time.Monday}).Validate(); err != nil {
+		t.Errorf("weekly policy: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reconciliation log statuses. Mismatch and unmatched rows are open cases
+// until they are resolved.
+const (
+	ReconMatched   = "matched"   // statement line and settlement agree
+	ReconUnmatched = "unmatched" // only one side exists
+	ReconMismatch  = "mismatch"  // both exist but amount, currency or date differ
+	ReconResolved  = "resolved"
+)
+
+// Reconciliation log entity types.
+const (
+	ReconEntityTransaction   = "transactions"
+	ReconEntitySettlement    = "settlements"
+	ReconEntityStatementLine = "statement_lines"
+)
+
+// ReconResolution is how a case was closed.
+type ReconResolution string
+
+const (
+	// ReconWriteOff books the difference to the write-off account.
+	ReconWriteOff ReconResolution = "write_off"
+	// ReconAdjustment posts a correcting ledger entry between two accounts.
+	ReconAdjustment ReconResolution = "adjustment"
+	// ReconRefund links the refund that explains the difference.
+	ReconRefund ReconResolution = "refund"
+)
+
+func (r ReconResolution) Valid() bool {
+	switch r {
+	case ReconWriteOff, ReconAdjustment, ReconRefund:
+		return true
+	}
+	return false
+}
+
+// ReconciliationFilter selects cases. An empty Status lists the open ones
+// (mismatch and unmatched).
+type ReconciliationFilter struct {
+	Status     string
+	EntityType string
+	AssignedTo string
+	From       time.Time // created at or after, if set
+	To         time.Time // created before, if set
+	Limit      int
+	Offset     int
+}
+
+type ReconciliationNote struct {
+	ID               uuid.UUID `json:"id"`
+	ReconciliationID uuid.UUID `json:"reconciliation_id"`
+	Author           string    `json:"author"`
+	Body             string    `json:"body"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ReconciliationCase is a log row with its notes and audit trail, oldest
+// first.
+type ReconciliationCase struct {
+	*ReconciliationLogs
+	Notes []*ReconciliationNote `json:"notes"`
+	Audit []*AuditLogs          `json:"audit"`
+}
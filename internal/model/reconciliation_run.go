@@ -0,0 +1,98 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReconRunStatus string
+
+const (
+	ReconRunRunning     ReconRunStatus = "running"
+	ReconRunCompleted   ReconRunStatus = "completed"
+	ReconRunFailed      ReconRunStatus = "failed"
+	ReconRunInterrupted ReconRunStatus = "interrupted" // superseded by a later run before finishing
+)
+
+// ReconRunTransactions is the run kind comparing transactions with their
+// settlements.
+const ReconRunTransactions = "transactions"
+
+// ReconCursor is a keyset position: rows are processed in (UpdatedAt, ID)
+// order and a cursor points at the last one handled. The zero cursor is
+// before every row.
+type ReconCursor struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (c ReconCursor) IsZero() bool {
+	return c.UpdatedAt.IsZero() && c.ID == uuid.Nil
+}
+
+// ReconCurrencySummary totals one currency within a run. Delta is the sum
+// of expected minus actual over the mismatches.
+type ReconCurrencySummary struct {
+	Checked    int   `json:"checked"`
+	Matched    int   `json:"matched"`
+	Mismatched int   `json:"mismatched"`
+	Delta      int64 `json:"delta"`
+}
+
+// ReconciliationRun records one reconcile-job pass. From is where it
+// started; HighWater is the position up to which every row was processed
+// without error, where the next run resumes.
+type ReconciliationRun struct {
+	ID         uuid.UUID                        `json:"id"`
+	Kind       string                           `json:"kind"`
+	Status     ReconRunStatus                   `json:"status"`
+	From       ReconCursor                      `json:"from"`
+	HighWater  ReconCursor                      `json:"high_water"`
+	Checked    int                              `json:"checked"`
+	Matched    int                              `json:"matched"`
+	Mismatched int                              `json:"mismatched"`
+	Errors     int                              `json:"errors"`
+	Currencies map[string]*ReconCurrencySummary `json:"currencies"`
+	Error      string                           `json:"error,omitempty"`
+	StartedAt  time.Time                        `json:"started_at"`
+	FinishedAt *time.Time                       `json:"finished_at,omitempty"`
+}
+
+// Record counts one checked row and reports whether it matched.
+func (r *ReconciliationRun) Record(currency string, expected, actual int64) bool {
+	if r.Currencies == nil {
+		r.Currencies = make(map[string]*ReconCurrencySummary)
+	}
+	c, ok := r.Currencies[currency]
+	if !ok {
+		c = &ReconCurrencySummary{}
+		r.Currencies[currency] = c
+	}
+
+	r.Checked++
+	c.Checked++
+	if expected == actual {
+		r.Matched++
+		c.Matched++
+		return true
+	}
+	r.Mismatched++
+	c.Mismatched++
+	c.Delta += expected - actual
+	return false
+}
+
+// TransactionTotal is a transaction with the sum of its successful
+// settlements, both in the transaction's currency.
+type TransactionTotal struct {
+	TransactionID uuid.UUID
+	Amount        int64
+	Currency      string
+	Settled       int64
+	UpdatedAt     time.Time
+}
+
+func (t *TransactionTotal) Cursor() ReconCursor {
+	return ReconCursor{UpdatedAt: t.UpdatedAt, ID: t.TransactionID}
+}
@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+func TestReconciliationRunRecord(t *testing.T) {
+	var run ReconciliationRun
+	if !run.Record("NGN", 1000, 1000) {
+		t.Error("equal amounts reported as a mismatch")
+	}
+	if run.Record("NGN", 1000, 400) {
+		t.Error("short settlement reported as a match")
+	}
+	run.Record("NGN", 500, 700)
+	run.Record("USD", 50, 0)
+
+	if run.Checked != 4 || run.Matched != 1 || run.Mismatched != 3 {
+		t.Errorf("run totals = %d checked, %d matched, %d mismatched", run.Checked, run.Matched, run.Mismatched)
+	}
+	ngn := run.Currencies["NGN"]
+	if *ngn != (ReconCurrencySummary{Checked: 3, Matched: 1, Mismatched: 2, Delta: 400}) {
+		t.Errorf("NGN summary = %+v", *ngn)
+	}
+	if usd := run.Currencies["USD"]; usd.Delta != 50 || usd.Checked != 1 {
+		t.Errorf("USD summary = %+v", *usd)
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundCompleted RefundStatus = "completed"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund returns part or all of a completed transaction to the payer.
+type Refund struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	TransactionID uuid.UUID      `json:"transaction_id" db:"transaction_id"`
+	Amount        int64          `json:"amount" db:"amount"` // smallest currency unit
+	Currency      string         `json:"currency" db:"currency"`
+	Status        RefundStatus   `json:"status" db:"status"`
+	Reason        string         `json:"reason,omitempty" db:"reason"`
+	Metadata      map[string]any `json:"metadata" db:"metadata"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+}
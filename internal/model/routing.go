@@ -0,0 +1,163 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoutingRule sends matching transactions to one of its targets. Rules are
+// evaluated in ascending priority; the first match wins.
+type RoutingRule struct {
+	ID         uuid.UUID         `json:"id" db:"id"`
+	Name       string            `json:"name" db:"name"`
+	Priority   int               `json:"priority" db:"priority"`
+	Enabled    bool              `json:"enabled" db:"enabled"`
+	Conditions RoutingConditions `json:"conditions" db:"conditions"`
+	Targets    []RouteTarget     `json:"targets" db:"targets"`
+	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// RoutingConditions are ANDed together; an empty field matches everything.
+type RoutingConditions struct {
+	Currencies  []string    `json:"currencies,omitempty"`
+	MinAmount   *int64      `json:"min_amount,omitempty"` // inclusive, smallest currency unit
+	MaxAmount   *int64      `json:"max_amount,omitempty"` // inclusive, smallest currency unit
+	MerchantIDs []uuid.UUID `json:"merchant_ids,omitempty"`
+	// Metadata requires each key to be present on the transaction; a
+	// non-empty value must also match the stringified metadata value.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	TimeOfDay *TimeWindow       `json:"time_of_day,omitempty"`
+}
+
+// TimeWindow is a daily window in "HH:MM" form. Start is inclusive and End
+// exclusive; a window whose End is before its Start wraps past midnight.
+type TimeWindow struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone,omitempty"` // IANA name, defaults to UTC
+}
+
+// RouteTarget is a connector a rule can route to. Weight controls the share
+// of traffic it receives as the primary route.
+type RouteTarget struct {
+	Connector string `json:"connector"`
+	Weight    int    `json:"weight"`
+}
+
+// Matches reports whether tx satisfies every condition of the rule at now.
+func (r *RoutingRule) Matches(tx *Transaction, now time.Time) bool {
+	c := r.Conditions
+
+	if len(c.Currencies) > 0 && !containsFold(c.Currencies, tx.Currency) {
+		return false
+	}
+	if c.MinAmount != nil && tx.Amount < *c.MinAmount {
+		return false
+	}
+	if c.MaxAmount != nil && tx.Amount > *c.MaxAmount {
+		return false
+	}
+	if len(c.MerchantIDs) > 0 {
+		found := false
+		for _, id := range c.MerchantIDs {
+			if id == tx.MerchantID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, want := range c.Metadata {
+		got, ok := tx.Metadata[key]
+		if !ok {
+			return false
+		}
+		if want != "" && fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	if c.TimeOfDay != nil {
+		in, err := c.TimeOfDay.Contains(now)
+		if err != nil || !in {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the rule can be evaluated.
+func (r *RoutingRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("routing rule: name is required")
+	}
+	if len(r.Targets) == 0 {
+		return fmt.Errorf("routing rule %q: at least one target is required", r.Name)
+	}
+	for _, t := range r.Targets {
+		if t.Connector == "" {
+			return fmt.Errorf("routing rule %q: target connector is required", r.Name)
+		}
+		if t.Weight < 0 {
+			return fmt.Errorf("routing rule %q: negative weight for %s", r.Name, t.Connector)
+		}
+	}
+	c := r.Conditions
+	if c.MinAmount != nil && c.MaxAmount != nil && *c.MinAmount > *c.MaxAmount {
+		return fmt.Errorf("routing rule %q: min_amount exceeds max_amount", r.Name)
+	}
+	if c.TimeOfDay != nil {
+		if _, err := c.TimeOfDay.Contains(time.Now()); err != nil {
+			return fmt.Errorf("routing rule %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w *TimeWindow) Contains(t time.Time) (bool, error) {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false, fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+		}
+	}
+	start, err := minuteOfDay(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := minuteOfDay(w.End)
+	if err != nil {
+		return false, err
+	}
+
+	local := t.In(loc)
+	m := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return m >= start && m < end, nil
+	}
+	return m >= start || m < end, nil
+}
+
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRoutingRuleMatches(t *testing.T) {
+	merchant := uuid.New()
+	lo, hi := int64(1000), int64(50000)
+	tx := &Transaction{
+		Amount:     2500,
+		Currency:   "ngn",
+		MerchantID: merchant,
+		Metadata:   map[string]any{"channel": "card", "risk": 3},
+	}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		cond RoutingConditions
+		want bool
+	}{
+		{"empty conditions", RoutingConditions{}, true},
+		{"currency case-insensitive", RoutingConditions{Currencies: []string{"NGN"}}, true},
+		{"other currency", RoutingConditions{Currencies: []string{"USD"}}, false},
+		{"inside amount band", RoutingConditions{MinAmount: &lo, MaxAmount: &hi}, true},
+		{"below amount band", RoutingConditions{MinAmount: &hi}, false},
+		{"merchant listed", RoutingConditions{MerchantIDs: []uuid.UUID{merchant}}, true},
+		{"merchant not listed", RoutingConditions{MerchantIDs: []uuid.UUID{uuid.New()}}, false},
+		{"metadata value", RoutingConditions{Metadata: map[string]string{"channel": "card"}}, true},
+		{"metadata non-string value", RoutingConditions{Metadata: map[string]string{"risk": "3"}}, true},
+		{"metadata key only", RoutingConditions{Metadata: map[string]string{"channel": ""}}, true},
+		{"metadata mismatch", RoutingConditions{Metadata: map[string]string{"channel": "bank"}}, false},
+		{"metadata missing", RoutingConditions{Metadata: map[string]string{"device": ""}}, false},
+		{"inside time window", RoutingConditions{TimeOfDay: &TimeWindow{Start: "09:00", End: "17:00"}}, true},
+		{"outside time window", RoutingConditions{TimeOfDay: &TimeWindow{Start: "22:00", End: "06:00"}}, false},
+		{"time window in zone", RoutingConditions{TimeOfDay: &TimeWindow{Start: "13:00", End: "14:00", Timezone: "Africa/Lagos"}}, true},
+	}
+
+	for _, tc := range cases {
+		rule := &RoutingRule{Name: tc.name, Conditions: tc.cond}
+		if got := rule.Matches(tx, noon); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTimeWindowWrapsMidnight(t *testing.T) {
+	w := &TimeWindow{Start: "22:00", End: "06:00"}
+	for hour, want := range map[int]bool{23: true, 2: true, 6: false, 12: false, 22: true} {
+		got, err := w.Contains(time.Date(2026, 1, 1, hour, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%02d:00: got %v, want %v", hour, got, want)
+		}
+	}
+}
+
+func TestRoutingRuleValidate(t *testing.T) {
+	if err := (&RoutingRule{Name: "no-targets"}).Validate(); err == nil {
+		t.Error("expected error for rule without targets")
+	}
+	bad := &RoutingRule{
+		Name:       "bad-window",
+		Targets:    []RouteTarget{{Connector: "sandbox", Weight: 1}},
+		Conditions: RoutingConditions{TimeOfDay: &TimeWindow{Start: "9am", End: "17:00"}},
+	}
+	if err := bad.Validate(); err == nil {
+		t.Error("expected error for malformed time window")
+	}
+}
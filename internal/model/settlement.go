@@ -19,7 +19,12 @@ type Settlements struct {
 	MerchantAccountID uuid.UUID `json:"merchant_account_id" db:"merchant_account_id"`
 	ExternalReference string `json:"external_reference" db:"external_reference"`
 	Status string `json:"status" db:"status"`
-	Amount int64 `json:"amount" db:"amount"`
+	Amount int64 `json:"amount" db:"amount"` // gross amount settled, before fees
+	Currency string `json:"currency" db:"currency"` // merchant settlement currency
+	FXRate string `json:"fx_rate,omitempty" db:"fx_rate"` // applied when Currency differs from the transaction's
+	SourceAmount int64 `json:"source_amount" db:"source_amount"` // Amount in the transaction's currency, before FXRate
+	FeeAmount int64 `json:"fee_amount" db:"fee_amount"`
+	NetAmount int64 `json:"net_amount" db:"net_amount"` // Amount - FeeAmount, credited to the merchant
 	Metadata map[string]any `json:"metadata" db:"metadata"`
 	Attempts int64 `json:"attempts" db:"attempts"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SettlementExportFilter selects the settlements in an export file: either
+// one payout batch or those created in [From, To).
+type SettlementExportFilter struct {
+	From     time.Time
+	To       time.Time
+	PayoutID *uuid.UUID
+}
+
+// SettlementExportLine is a successful settlement with the merchant it is
+// paid to.
+type SettlementExportLine struct {
+	Settlement   *Settlements
+	PayoutID     *uuid.UUID
+	MerchantID   uuid.UUID
+	MerchantName string
+	BankDetails  BankDetails
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type StatementDirection string
+
+const (
+	StatementCredit StatementDirection = "credit" // money in
+	StatementDebit  StatementDirection = "debit"  // money out
+)
+
+// Statement is a bank account statement imported from a file.
+type Statement struct {
+	ID       string           `json:"id"` // the bank's statement id
+	Account  string           `json:"account"`
+	Currency string           `json:"currency"`
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Format   string           `json:"format"`
+	Source   string           `json:"source"` // file name
+	Lines    []*StatementLine `json:"-"`
+}
+
+// StatementLine is one booked entry on a statement. Amount is positive in
+// the minor units of Currency; Direction says which way it went.
+type StatementLine struct {
+	Line          int                `json:"line"` // position in the statement, from 1
+	Reference     string             `json:"reference,omitempty"`
+	BankReference string             `json:"bank_reference,omitempty"`
+	Amount        int64              `json:"amount"`
+	Currency      string             `json:"currency"`
+	Direction     StatementDirection `json:"direction"`
+	ValueDate     time.Time          `json:"value_date"`
+	BookingDate   time.Time          `json:"booking_date,omitempty"`
+	Description   string             `json:"description,omitempty"`
+}
+
+var uuidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}`)
+
+// References returns the values that may identify the settlement: the
+// reference field, then any UUIDs in the reference or description (banks
+// often truncate references or move them into free text).
+func (l *StatementLine) References() []string {
+	var refs []string
+	seen := make(map[string]bool)
+	add := func(r string) {
+		if r != "" && !seen[r] {
+			seen[r] = true
+			refs = append(refs, r)
+		}
+	}
+	add(l.Reference)
+	for _, m := range uuidPattern.FindAllString(l.Reference+" "+l.Description, -1) {
+		m = strings.ToLower(m)
+		if len(m) == 32 {
+			m = m[:8] + "-" + m[8:12] + "-" + m[12:16] + "-" + m[16:20] + "-" + m[20:]
+		}
+		add(m)
+	}
+	return refs
+}
+
+// MatchTolerance bounds how far a statement line may differ from its
+// settlement and still match.
+type MatchTolerance struct {
+	Amount int64         // minor units
+	Date   time.Duration // between settlement day and value date
+}
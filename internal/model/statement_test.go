@@ -0,0 +1,20 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatementLineReferences(t *testing.T) {
+	l := &StatementLine{
+		Reference:   "3F2A8C1E5B7D",
+		Description: "EREF 3f2a8c1e5b7d4e9a9c3b2d1e0f4a6b8c card 3f2a8c1e-5b7d-4e9a-9c3b-2d1e0f4a6b8c",
+	}
+	want := []string{"3F2A8C1E5B7D", "3f2a8c1e-5b7d-4e9a-9c3b-2d1e0f4a6b8c"}
+	if got := l.References(); !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+	if got := (&StatementLine{}).References(); len(got) != 0 {
+		t.Errorf("empty line references = %v", got)
+	}
+}
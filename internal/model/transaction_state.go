@@ -0,0 +1,82 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is matched (via errors.Is) by every
+// *InvalidTransitionError.
+var ErrInvalidTransition = errors.New("invalid transaction status transition")
+
+// InvalidTransitionError reports a status change the state machine does not
+// allow, or a compare-and-set that lost because the row had already moved on.
+type InvalidTransitionError struct {
+	From TransactionStatus // status the caller expected
+	To   TransactionStatus
+	// Current is the status actually stored, when it differs from From.
+	Current TransactionStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if e.Current != "" && e.Current != e.From {
+		return fmt.Sprintf("%s: expected %s but transaction is %s (wanted %s)", ErrInvalidTransition, e.From, e.Current, e.To)
+	}
+	return fmt.Sprintf("%s: %s -> %s", ErrInvalidTransition, e.From, e.To)
+}
+
+func (e *InvalidTransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// transactionTransitions lists the statuses reachable from each status.
+// Statuses without an entry are terminal.
+var transactionTransitions = map[TransactionStatus][]TransactionStatus{
+	TransactionStatusPending: {
+		TransactionStatusProcessing,
+		TransactionStatusCancelled,
+		TransactionStatusFailed,
+	},
+	TransactionStatusProcessing: {
+		TransactionStatusAuthorized,
+		TransactionStatusCompleted,
+		TransactionStatusFailed,
+	},
+	TransactionStatusAuthorized: {
+		TransactionStatusCompleted,
+		TransactionStatusVoided,
+		TransactionStatusExpired,
+	},
+	TransactionStatusCompleted: {
+		TransactionStatusPartiallyRefunded,
+		TransactionStatusRefunded,
+	},
+	TransactionStatusPartiallyRefunded: {
+		TransactionStatusPartiallyRefunded,
+		TransactionStatusRefunded,
+	},
+}
+
+// CanTransitionTo reports whether the state machine allows s -> to.
+func (s TransactionStatus) CanTransitionTo(to TransactionStatus) bool {
+	for _, next := range transactionTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func (s TransactionStatus) IsTerminal() bool {
+	return len(transactionTransitions[s]) == 0
+}
+
+// ValidateTransition returns an *InvalidTransitionError if from -> to is not
+// allowed.
+func ValidateTransition(from, to TransactionStatus) error {
+	if !from.CanTransitionTo(to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from, to TransactionStatus
+		ok       bool
+	}{
+		{TransactionStatusPending, TransactionStatusProcessing, true},
+		{TransactionStatusPending, TransactionStatusCancelled, true},
+		{TransactionStatusProcessing, TransactionStatusCompleted, true},
+		{TransactionStatusProcessing, TransactionStatusFailed, true},
+		{TransactionStatusCompleted, TransactionStatusPartiallyRefunded, true},
+		{TransactionStatusCompleted, TransactionStatusRefunded, true},
+		{TransactionStatusPartiallyRefunded, TransactionStatusPartiallyRefunded, true},
+		{TransactionStatusPartiallyRefunded, TransactionStatusRefunded, true},
+		{TransactionStatusProcessing, TransactionStatusAuthorized, true},
+		{TransactionStatusAuthorized, TransactionStatusCompleted, true},
+		{TransactionStatusAuthorized, TransactionStatusVoided, true},
+		{TransactionStatusAuthorized, TransactionStatusExpired, true},
+
+		{TransactionStatusCompleted, TransactionStatusProcessing, false},
+		{TransactionStatusCompleted, TransactionStatusFailed, false},
+		{TransactionStatusFailed, TransactionStatusCompleted, false},
+		{TransactionStatusPending, TransactionStatusCompleted, false},
+		{TransactionStatusRefunded, TransactionStatusPartiallyRefunded, false},
+		{TransactionStatusCancelled, TransactionStatusProcessing, false},
+		{TransactionStatusPending, TransactionStatusAuthorized, false},
+		{TransactionStatusAuthorized, TransactionStatusFailed, false},
+		{TransactionStatusVoided, TransactionStatusCompleted, false},
+		{TransactionStatusExpired, TransactionStatusVoided, false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.from.CanTransitionTo(tc.to); got != tc.ok {
+			t.Errorf("%s -> %s: got %v, want %v", tc.from, tc.to, got, tc.ok)
+		}
+
+		err := ValidateTransition(tc.from, tc.to)
+		if tc.ok && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", tc.from, tc.to, err)
+		}
+		if !tc.ok {
+			var ite *InvalidTransitionError
+			if !errors.Is(err, ErrInvalidTransition) || !errors.As(err, &ite) {
+				t.Errorf("%s -> %s: expected InvalidTransitionError, got %v", tc.from, tc.to, err)
+			}
+		}
+	}
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	for _, s := range []TransactionStatus{TransactionStatusFailed, TransactionStatusCancelled, TransactionStatusRefunded, TransactionStatusVoided, TransactionStatusExpired} {
+		if !s.IsTerminal() {
+			t.Errorf("%s should be terminal", s)
+		}
+	}
+	if TransactionStatusCompleted.IsTerminal() {
+		t.Errorf("completed should not be terminal")
+	}
+}
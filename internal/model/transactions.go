@@ -10,35 +10,57 @@ import (
 type TransactionStatus string
 
 const (
-	TransactionStatusPending   TransactionStatus = "pending"
-	TransactionStatusCompleted TransactionStatus = "completed"
-	TransactionStatusFailed    TransactionStatus = "failed"
-	TransactionStatusCancelled TransactionStatus = "cancelled"
+	TransactionStatusPending           TransactionStatus = "pending"
+	TransactionStatusProcessing        TransactionStatus = "processing"
+	TransactionStatusAuthorized        TransactionStatus = "authorized"
+	TransactionStatusCompleted         TransactionStatus = "completed"
+	TransactionStatusFailed            TransactionStatus = "failed"
+	TransactionStatusCancelled         TransactionStatus = "cancelled"
+	TransactionStatusPartiallyRefunded TransactionStatus = "partially_refunded"
+	TransactionStatusRefunded          TransactionStatus = "refunded"
+	TransactionStatusVoided            TransactionStatus = "voided"
+	TransactionStatusExpired           TransactionStatus = "expired"
+)
+
+// CaptureMethod selects between a one-step sale and a two-step
+// authorize/capture flow.
+type CaptureMethod string
+
+const (
+	// CaptureAutomatic authorizes and captures in one go.
+	CaptureAutomatic CaptureMethod = "automatic"
+	// CaptureManual stops at authorized; the merchant captures or voids later.
+	CaptureManual CaptureMethod = "manual"
 )
 
 // Transaction is the domain model for a payment transaction.
 type Transaction struct {
-	ID         uuid.UUID         `json:"id" db:"id"`
-	Amount     int64             `json:"amount" db:"amount"` // stored in smallest currency unit (e.g., cents)
-	Currency   string            `json:"currency" db:"currency"`
-	UserID     uuid.UUID         `json:"user_id" db:"user_id"`
-	MerchantID uuid.UUID         `json:"merchant_id" db:"merchant_id"`
-	Status     TransactionStatus `json:"status" db:"status"`
-	Metadata   map[string]any    `json:"metadata" db:"metadata"`
-	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time         `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID         `json:"id" db:"id"`
+	Amount             int64             `json:"amount" db:"amount"` // stored in smallest currency unit (e.g., cents)
+	Currency           string            `json:"currency" db:"currency"`
+	UserID             uuid.UUID         `json:"user_id" db:"user_id"`
+	MerchantID         uuid.UUID         `json:"merchant_id" db:"merchant_id"`
+	Status             TransactionStatus `json:"status" db:"status"`
+	Metadata           map[string]any    `json:"metadata" db:"metadata"`
+	CaptureMethod      CaptureMethod     `json:"capture_method" db:"capture_method"`
+	SettlementCurrency string            `json:"settlement_currency" db:"settlement_currency"`   // defaults to Currency; FX applied when different
+	Route              string            `json:"route,omitempty" db:"route"`                     // connector chosen by the routing engine
+	RoutingRuleID      *uuid.UUID        `json:"routing_rule_id,omitempty" db:"routing_rule_id"` // nil when no rule matched
+	CreatedAt          time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at" db:"updated_at"`
 }
 
 func NewTransaction(amount int64, currency string, userID, merchantID uuid.UUID, status TransactionStatus) *Transaction {
 	return &Transaction{
-		ID:         uuid.New(),
-		Amount:     amount,
-		Currency:   currency,
-		UserID:     userID,
-		MerchantID: merchantID,
-		Status:     TransactionStatusPending,
-		Metadata:   map[string]any{},
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:            uuid.New(),
+		Amount:        amount,
+		Currency:      currency,
+		UserID:        userID,
+		MerchantID:    merchantID,
+		Status:        TransactionStatusPending,
+		Metadata:      map[string]any{},
+		CaptureMethod: CaptureAutomatic,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 }
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"   // waiting for its first or next attempt
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded" // endpoint returned 2xx
+	WebhookFailed    WebhookDeliveryStatus = "failed"    // attempts exhausted
+)
+
+// WebhookDelivery is one event sent to one merchant endpoint, with every
+// attempt recorded in AttemptLog.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	MerchantID     uuid.UUID             `json:"merchant_id" db:"merchant_id"`
+	EventType      string                `json:"event_type" db:"event_type"`
+	EventKey       string                `json:"event_key" db:"event_key"` // dedupes the same event, e.g. the settlement id
+	URL            string                `json:"url" db:"url"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string                `json:"last_error,omitempty" db:"last_error"`
+	AttemptLog     []WebhookAttempt      `json:"attempt_log" db:"attempt_log"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+type WebhookAttempt struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Manual     bool      `json:"manual,omitempty"` // triggered through the redeliver endpoint
+}
+
+// WebhookDeliveryFilter narrows GET /v1/webhooks/deliveries.
+type WebhookDeliveryFilter struct {
+	MerchantID *uuid.UUID
+	EventType  string
+	Status     WebhookDeliveryStatus
+	Limit      int
+	Offset     int
+}
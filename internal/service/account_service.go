@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/export"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrInvalidAccountQuery = errors.New("invalid account query")
+)
+
+// AccountService reads account balances, ledger entries and monthly
+// statements, and takes the end-of-day balance snapshots that past
+// balances are computed from.
+type AccountService struct {
+	accounts   repo.AccountRepository
+	currencies *model.CurrencyRegistry
+	logger     *zap.Logger
+}
+
+func NewAccountService(accountRepo repo.AccountRepository, currencies *model.CurrencyRegistry, logger *zap.Logger) *AccountService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	return &AccountService{accounts: accountRepo, currencies: currencies, logger: logger}
+}
+
+// GetBalance returns the account's available, pending and reserved amounts
+// or, for a non-zero asOf in the past, its ledger balance at that time.
+func (s *AccountService) GetBalance(ctx context.Context, id uuid.UUID, asOf time.Time) (*model.AccountBalance, error) {
+	if !asOf.IsZero() && asOf.Before(time.Now()) {
+		account, err := s.getAccount(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		available, err := s.accounts.BalanceAt(ctx, id, asOf)
+		if err != nil {
+			return nil, err
+		}
+		return &model.AccountBalance{AccountID: id, Currency: account.Currency, Available: available, AsOf: asOf}, nil
+	}
+
+	balance, err := s.accounts.GetBalance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if balance == nil {
+		return nil, ErrAccountNotFound
+	}
+	return balance, nil
+}
+
+// ListEntries returns a page of the account's ledger legs with the running
+// balance after each.
+func (s *AccountService) ListEntries(ctx context.Context, id uuid.UUID, filter model.AccountEntryFilter) ([]*model.AccountEntry, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidAccountQuery)
+	}
+	if _, err := s.getAccount(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.accounts.ListEntries(ctx, id, filter)
+}
+
+// Statement builds the account's statement for the calendar month (UTC)
+// containing month.
+func (s *AccountService) Statement(ctx context.Context, id uuid.UUID, month time.Time) (*model.AccountStatement, error) {
+	account, err := s.getAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := model.StatementMonth(month)
+	opening, err := s.accounts.BalanceAt(ctx, id, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute opening balance: %w", err)
+	}
+	entries, err := s.accounts.ListEntriesBetween(ctx, id, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entries: %w", err)
+	}
+	return model.NewAccountStatement(account, from, to, opening, entries), nil
+}
+
+// WriteStatementCSV writes st as CSV and returns the file's checksum.
+func (s *AccountService) WriteStatementCSV(w io.Writer, st *model.AccountStatement) (string, error) {
+	return export.WriteAccountStatementCSV(w, st, s.currencies)
+}
+
+func (s *AccountService) getAccount(ctx context.Context, id uuid.UUID) (*model.Accounts, error) {
+	account, err := s.accounts.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// SnapshotSummary reports the end-of-day boundaries a snapshot run wrote.
+type SnapshotSummary struct {
+	Days     []time.Time
+	Accounts int // balances written over all days
+}
+
+// SnapshotBalances writes the end-of-day balance of every account for each
+// day that has closed at least lag before now and has no snapshot yet,
+// oldest first, so a missed run is caught up on the next.
+func (s *AccountService) SnapshotBalances(ctx context.Context, now time.Time, lag time.Duration) (*SnapshotSummary, error) {
+	last, err := s.accounts.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest snapshot: %w", err)
+	}
+	var first time.Time
+	if last.IsZero() {
+		if first, err = s.accounts.FirstEntryAt(ctx); err != nil {
+			return nil, fmt.Errorf("failed to find first ledger entry: %w", err)
+		}
+	}
+
+	summary := &SnapshotSummary{}
+	for _, day := range model.SnapshotDays(last, first, now, lag) {
+		n, err := s.accounts.SnapshotBalances(ctx, day)
+		if err != nil {
+			return summary, fmt.Errorf("failed to snapshot balances at %s: %w", day.Format(time.RFC3339), err)
+		}
+		summary.Days = append(summary.Days, day)
+		summary.Accounts += n
+		s.logger.Info("balances snapshotted", zap.Time("as_of", day), zap.Int("accounts", n))
+	}
+	return summary, nil
+}
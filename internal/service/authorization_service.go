@@ -0,0 +1,513 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultAuthorizationTTL is how long an authorization can be captured.
+const DefaultAuthorizationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrTransactionNotAuthorized = errors.New("transaction is not authorized")
+	ErrAuthorizationExpired     = errors.New("authorization has expired")
+	ErrCaptureExceedsAuthorized = errors.New("capture amount exceeds authorized amount")
+	ErrConnectorDeclined        = errors.New("declined by connector")
+)
+
+// AuthorizationService runs the two-step flow for manual-capture
+// transactions: the settlement worker authorizes and places a hold, the
+// merchant captures or voids, and a sweeper expires what is left.
+type AuthorizationService struct {
+	txRepo     repo.TransactionRepository
+	authRepo   repo.AuthorizationRepository
+	txManager  repo.TxManager
+	ledger     *LedgerService
+	connectors *connector.Registry
+	fees       *FeeService
+	ttl        time.Duration
+	logger     *zap.Logger
+}
+
+func NewAuthorizationService(
+	txRepo repo.TransactionRepository,
+	authRepo repo.AuthorizationRepository,
+	txManager repo.TxManager,
+	ledger *LedgerService,
+	connectors *connector.Registry,
+	fees *FeeService,
+	ttl time.Duration,
+	logger *zap.Logger,
+) *AuthorizationService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if ledger == nil {
+		ledger = NewLedgerService(nil, logger)
+	}
+	if connectors == nil {
+		connectors = connector.NewRegistry()
+	}
+	if _, err := connectors.Default(); err != nil {
+		connectors.Register(connector.NewSandboxConnector(connector.DefaultSandboxConfig()))
+	}
+	if ttl <= 0 {
+		ttl = DefaultAuthorizationTTL
+	}
+	return &AuthorizationService{
+		txRepo:     txRepo,
+		authRepo:   authRepo,
+		txManager:  txManager,
+		ledger:     ledger,
+		connectors: connectors,
+		fees:       fees,
+		ttl:        ttl,
+		logger:     logger,
+	}
+}
+
+// Authorize places a hold for a manual-capture transaction in processing.
+// Candidates are tried in order while they are unavailable. An approval
+// moves the transaction to authorized and reserves the amount on the
+// customer account; a decline fails it. Other connector errors are returned
+// so the bus retries.
+func (s *AuthorizationService) Authorize(ctx context.Context, tx *model.Transaction, candidates []connector.Connector) error {
+	req := &connector.Request{
+		Reference:     tx.ID.String(),
+		TransactionID: tx.ID,
+		MerchantID:    tx.MerchantID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		Metadata:      tx.Metadata,
+	}
+
+	var conn connector.Connector
+	var resp *connector.Response
+	var err error
+	for i, c := range candidates {
+		conn = c
+		resp, err = conn.Authorize(ctx, req)
+		if !errors.Is(err, connector.ErrUnavailable) || i == len(candidates)-1 {
+			break
+		}
+		s.logger.Warn("connector unavailable, trying fallback",
+			zap.String("connector", conn.Name()),
+			zap.String("fallback", candidates[i+1].Name()),
+			zap.String("transaction_id", tx.ID.String()),
+		)
+	}
+	if err != nil {
+		s.logger.Warn("connector authorization failed", zap.String("connector", conn.Name()), zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		return fmt.Errorf("connector %s: %w", conn.Name(), err)
+	}
+
+	if !resp.Approved() {
+		err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+			if err := r.Transactions.TransitionStatus(ctx, tx.ID, model.TransactionStatusProcessing, model.TransactionStatusFailed); err != nil {
+				return err
+			}
+			return enqueueEvent(ctx, r, "transaction.declined", tx.ID.String(), map[string]any{
+				"transaction_id": tx.ID.String(),
+				"connector":      conn.Name(),
+				"code":           resp.Code,
+				"message":        resp.Message,
+				"declined_at":    time.Now().UTC(),
+			})
+		})
+		if errors.Is(err, model.ErrInvalidTransition) {
+			s.logger.Info("authorization superseded", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+			return nil
+		}
+		if err == nil {
+			s.logger.Warn("authorization declined", zap.String("transaction_id", tx.ID.String()), zap.String("code", resp.Code))
+		}
+		return err
+	}
+
+	now := time.Now().UTC()
+	auth := &model.Authorization{
+		ID:            uuid.New(),
+		TransactionID: tx.ID,
+		Connector:     conn.Name(),
+		ExternalID:    resp.ExternalID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		Status:        model.AuthorizationAuthorized,
+		ExpiresAt:     now.Add(s.ttl),
+		Metadata:      connectorMetadata(conn, []*connector.Response{resp}, nil),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	err = s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		if err := r.Transactions.TransitionStatus(ctx, tx.ID, model.TransactionStatusProcessing, model.TransactionStatusAuthorized); err != nil {
+			return err
+		}
+		customer, err := r.Accounts.GetOrCreateUserAccount(ctx, tx.UserID, tx.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to get/create customer account: %w", err)
+		}
+		auth.HoldAccountID = customer.ID
+		if err := r.Authorizations.Create(ctx, auth); err != nil {
+			return fmt.Errorf("failed to create authorization: %w", err)
+		}
+		if err := s.ledger.PostHold(ctx, r, auth); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, r, "transaction.authorized", tx.ID.String(), map[string]any{
+			"transaction_id":   tx.ID.String(),
+			"authorization_id": auth.ID.String(),
+			"amount":           auth.Amount,
+			"currency":         auth.Currency,
+			"expires_at":       auth.ExpiresAt,
+		})
+	})
+	if errors.Is(err, model.ErrInvalidTransition) {
+		s.logger.Info("authorization superseded", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("transaction authorized",
+		zap.String("transaction_id", tx.ID.String()),
+		zap.String("authorization_id", auth.ID.String()),
+		zap.Time("expires_at", auth.ExpiresAt),
+	)
+	return nil
+}
+
+// Capture captures amount (0 for the full authorized amount) of an
+// authorized transaction. The hold is released, a settlement is recorded for
+// the captured amount and the transaction completes with that amount.
+func (s *AuthorizationService) Capture(ctx context.Context, transactionID uuid.UUID, amount int64) (*model.Transaction, error) {
+	tx, auth, err := s.loadAuthorized(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+	if amount == 0 {
+		amount = auth.Amount
+	}
+	if amount > auth.Amount {
+		return nil, ErrCaptureExceedsAuthorized
+	}
+
+	conn, err := s.connectors.Get(auth.Connector)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.Capture(ctx, &connector.Request{
+		Reference:     tx.ID.String(),
+		TransactionID: tx.ID,
+		MerchantID:    tx.MerchantID,
+		Amount:        amount,
+		Currency:      tx.Currency,
+		ExternalID:    auth.ExternalID,
+		Metadata:      tx.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connector %s: %w", conn.Name(), err)
+	}
+	if !resp.Approved() {
+		return nil, fmt.Errorf("%w: %s %s", ErrConnectorDeclined, resp.Code, resp.Message)
+	}
+
+	now := time.Now().UTC()
+	settlement := &model.Settlements{
+		ID:                uuid.New(),
+		ExternalReference: tx.ID.String(),
+		Status:            string(model.SettlementSuccess),
+		Metadata:          connectorMetadata(conn, []*connector.Response{resp}, nil),
+		Attempts:          1,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	settlement.Metadata["authorization_id"] = auth.ID.String()
+
+	err = s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		locked, err := r.Authorizations.GetByTransactionIDForUpdate(ctx, tx.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch authorization: %w", err)
+		}
+		if locked == nil || locked.Status != model.AuthorizationAuthorized {
+			return ErrTransactionNotAuthorized
+		}
+		if err := r.Transactions.TransitionStatus(ctx, tx.ID, model.TransactionStatusAuthorized, model.TransactionStatusCompleted); err != nil {
+			return err
+		}
+		if amount != tx.Amount {
+			if err := r.Transactions.UpdateAmount(ctx, tx.ID, amount); err != nil {
+				return fmt.Errorf("failed to update transaction amount: %w", err)
+			}
+			tx.Amount = amount
+		}
+		if err := r.Authorizations.UpdateStatus(ctx, locked.ID, model.AuthorizationCaptured, amount); err != nil {
+			return fmt.Errorf("failed to update authorization: %w", err)
+		}
+		if err := s.ledger.ReleaseHold(ctx, r, locked, "captured"); err != nil {
+			return err
+		}
+
+		settled, conversion, err := s.ledger.SettlementAmount(ctx, tx)
+		if err != nil {
+			return err
+		}
+		applySettlementAmount(settlement, settled, conversion)
+
+		merchantAccount, err := r.Accounts.GetOrCreateMerchantAccount(ctx, tx.MerchantID, settled.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to get/create merchant account: %w", err)
+		}
+		settlement.MerchantAccountID = merchantAccount.ID
+		quote, err := s.fees.Quote(ctx, tx.MerchantID, merchantAccount.ID, settled)
+		if err != nil {
+			return err
+		}
+		applyFeeQuote(settlement, quote)
+		if err := r.Settlements.CreateSettlementAttempt(ctx, settlement); err != nil {
+			return fmt.Errorf("failed to create settlement: %w", err)
+		}
+		if err := s.ledger.PostSettlement(ctx, r, tx, settlement.ID, merchantAccount.ID, settled); err != nil {
+			return err
+		}
+		if err := s.ledger.PostFee(ctx, r, tx, settlement.ID, merchantAccount.ID, quote); err != nil {
+			return err
+		}
+
+		if err := enqueueEvent(ctx, r, "transaction.captured", tx.ID.String(), map[string]any{
+			"transaction_id":    tx.ID.String(),
+			"authorization_id":  locked.ID.String(),
+			"authorized_amount": locked.Amount,
+			"captured_amount":   amount,
+			"currency":          tx.Currency,
+			"captured_at":       now,
+		}); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, r, "settlement.completed", tx.ID.String(), settlementCompletedEvent(tx, settlement))
+	})
+	if errors.Is(err, model.ErrInvalidTransition) {
+		return nil, ErrTransactionNotAuthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	util.SettlementsSucceededTotal.Inc()
+	s.logger.Info("transaction captured", zap.String("transaction_id", tx.ID.String()), zap.Int64("amount", amount))
+
+	tx.Status = model.TransactionStatusCompleted
+	return tx, nil
+}
+
+// Void cancels an authorized transaction and releases its hold.
+func (s *AuthorizationService) Void(ctx context.Context, transactionID uuid.UUID) (*model.Transaction, error) {
+	tx, auth, err := s.loadAuthorized(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.connectors.Get(auth.Connector)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.Void(ctx, &connector.Request{
+		Reference:     tx.ID.String(),
+		TransactionID: tx.ID,
+		MerchantID:    tx.MerchantID,
+		Amount:        auth.Amount,
+		Currency:      auth.Currency,
+		ExternalID:    auth.ExternalID,
+		Metadata:      tx.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connector %s: %w", conn.Name(), err)
+	}
+	if !resp.Approved() {
+		return nil, fmt.Errorf("%w: %s %s", ErrConnectorDeclined, resp.Code, resp.Message)
+	}
+
+	err = s.closeAuthorization(ctx, auth, model.TransactionStatusVoided, model.AuthorizationVoided, "transaction.voided", map[string]any{
+		"void_response": resp.Metadata(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("transaction voided", zap.String("transaction_id", tx.ID.String()))
+
+	tx.Status = model.TransactionStatusVoided
+	return tx, nil
+}
+
+// ExpireDue expires up to limit authorizations past their expiry and
+// releases their holds. The connector is not called: acquirers drop stale
+// authorizations on their side.
+func (s *AuthorizationService) ExpireDue(ctx context.Context, limit int) (int, error) {
+	expired := 0
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		due, err := r.Authorizations.ListExpiredForUpdate(ctx, time.Now().UTC(), limit)
+		if err != nil {
+			return err
+		}
+		for _, auth := range due {
+			if err := s.expire(ctx, r, auth); err != nil {
+				return err
+			}
+			expired++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+func (s *AuthorizationService) expire(ctx context.Context, r *repo.TxRepositories, auth *model.Authorization) error {
+	err := r.Transactions.TransitionStatus(ctx, auth.TransactionID, model.TransactionStatusAuthorized, model.TransactionStatusExpired)
+	if errors.Is(err, model.ErrInvalidTransition) {
+		// The transaction moved on without the authorization being closed;
+		// close it so the sweeper stops picking it up.
+		s.logger.Warn("expiring authorization for non-authorized transaction", zap.String("transaction_id", auth.TransactionID.String()), zap.Error(err))
+		return r.Authorizations.UpdateStatus(ctx, auth.ID, model.AuthorizationExpired, auth.CapturedAmount)
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.Authorizations.UpdateStatus(ctx, auth.ID, model.AuthorizationExpired, 0); err != nil {
+		return err
+	}
+	if err := s.ledger.ReleaseHold(ctx, r, auth, "expired"); err != nil {
+		return err
+	}
+
+	s.logger.Info("authorization expired", zap.String("transaction_id", auth.TransactionID.String()))
+
+	return enqueueEvent(ctx, r, "transaction.expired", auth.TransactionID.String(), map[string]any{
+		"transaction_id":   auth.TransactionID.String(),
+		"authorization_id": auth.ID.String(),
+		"amount":           auth.Amount,
+		"currency":         auth.Currency,
+		"expired_at":       time.Now().UTC(),
+	})
+}
+
+// RunSweeper expires due authorizations every interval until ctx is done.
+func (s *AuthorizationService) RunSweeper(ctx context.Context, interval time.Duration) {
+	s.logger.Info("authorization sweeper started", zap.Duration("interval", interval))
+	const batchSize = 100
+	for {
+		n, err := s.ExpireDue(ctx, batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("authorization sweep failed", zap.Error(err))
+		}
+		if n > 0 {
+			s.logger.Info("authorizations expired", zap.Int("count", n))
+		}
+
+		// Keep sweeping without delay while full batches are coming back
+		if err == nil && n == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("authorization sweeper stopped")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *AuthorizationService) GetAuthorization(ctx context.Context, transactionID uuid.UUID) (*model.Authorization, error) {
+	return s.authRepo.GetByTransactionID(ctx, transactionID)
+}
+
+// loadAuthorized returns the transaction and its open authorization, or an
+// error saying why it can't be captured or voided.
+func (s *AuthorizationService) loadAuthorized(ctx context.Context, transactionID uuid.UUID) (*model.Transaction, *model.Authorization, error) {
+	tx, err := s.txRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if tx == nil {
+		return nil, nil, ErrTransactionNotFound
+	}
+	if tx.Status != model.TransactionStatusAuthorized {
+		return nil, nil, fmt.Errorf("%w: status is %s", ErrTransactionNotAuthorized, tx.Status)
+	}
+
+	auth, err := s.authRepo.GetByTransactionID(ctx, transactionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch authorization: %w", err)
+	}
+	if auth == nil || auth.Status != model.AuthorizationAuthorized {
+		return nil, nil, ErrTransactionNotAuthorized
+	}
+	if !time.Now().Before(auth.ExpiresAt) {
+		return nil, nil, ErrAuthorizationExpired
+	}
+	return tx, auth, nil
+}
+
+// closeAuthorization moves an authorized transaction to a final status,
+// releases the hold and emits topic, all in one SQL transaction.
+func (s *AuthorizationService) closeAuthorization(
+	ctx context.Context,
+	auth *model.Authorization,
+	txStatus model.TransactionStatus,
+	authStatus model.AuthorizationStatus,
+	topic string,
+	metadata map[string]any,
+) error {
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		locked, err := r.Authorizations.GetByTransactionIDForUpdate(ctx, auth.TransactionID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch authorization: %w", err)
+		}
+		if locked == nil || locked.Status != model.AuthorizationAuthorized {
+			return ErrTransactionNotAuthorized
+		}
+		if err := r.Transactions.TransitionStatus(ctx, locked.TransactionID, model.TransactionStatusAuthorized, txStatus); err != nil {
+			return err
+		}
+		if err := r.Authorizations.UpdateStatus(ctx, locked.ID, authStatus, 0); err != nil {
+			return fmt.Errorf("failed to update authorization: %w", err)
+		}
+		if len(metadata) > 0 {
+			if err := r.Authorizations.MergeMetadata(ctx, locked.ID, metadata); err != nil {
+				return fmt.Errorf("failed to update authorization metadata: %w", err)
+			}
+		}
+		if err := s.ledger.ReleaseHold(ctx, r, locked, string(authStatus)); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, r, topic, locked.TransactionID.String(), map[string]any{
+			"transaction_id":   locked.TransactionID.String(),
+			"authorization_id": locked.ID.String(),
+			"amount":           locked.Amount,
+			"currency":         locked.Currency,
+			"status":           string(txStatus),
+			"occurred_at":      time.Now().UTC(),
+		})
+	})
+	if errors.Is(err, model.ErrInvalidTransition) {
+		return ErrTransactionNotAuthorized
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+// LoadCurrencyRegistry starts from the built-in ISO 4217 list, overlays the
+// currencies table (when currencyRepo is set) and then enables any codes
+// listed in CURRENCIES_ENABLED (comma-separated).
+func LoadCurrencyRegistry(ctx context.Context, currencyRepo repo.CurrencyRepository) (*model.CurrencyRegistry, error) {
+	registry := model.DefaultCurrencyRegistry()
+
+	if currencyRepo != nil {
+		currencies, err := currencyRepo.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load currencies: %w", err)
+		}
+		for _, c := range currencies {
+			registry.Set(c)
+		}
+	}
+
+	if v := os.Getenv("CURRENCIES_ENABLED"); v != "" {
+		if err := registry.Enable(strings.Split(v, ",")...); err != nil {
+			return nil, fmt.Errorf("CURRENCIES_ENABLED: %w", err)
+		}
+	}
+
+	return registry, nil
+}
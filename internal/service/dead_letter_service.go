@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrDeadLetterNotFound     = errors.New("dead letter not found")
+	ErrInvalidDeadLetterQuery = errors.New("invalid dead letter query")
+	ErrReplayUnavailable      = errors.New("dead letter replay unavailable")
+)
+
+// DeadLetterTopics are the topics the gateway consumes. dlq-monitor drains
+// the dead-letter topic of each.
+var DeadLetterTopics = append([]string{"transaction.created", "settlement.requested", "refund.requested"}, WebhookTopics...)
+
+// MaxBulkReplay bounds how many dead letters one bulk replay publishes.
+const MaxBulkReplay = 500
+
+// DeadLetterService keeps the messages taken off the dead-letter queues and
+// replays them onto their original topic. Every replay is recorded with
+// its outcome.
+type DeadLetterService struct {
+	letters  repo.DeadLetterRepository
+	producer bus.Producer
+	logger   *zap.Logger
+}
+
+// NewDeadLetterService creates the service. producer publishes replays;
+// with nil, dead letters can be recorded and inspected but not replayed.
+func NewDeadLetterService(letterRepo repo.DeadLetterRepository, producer bus.Producer, logger *zap.Logger) *DeadLetterService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &DeadLetterService{letters: letterRepo, producer: producer, logger: logger}
+}
+
+// Record stores a message received on dlqTopic with what the transport
+// knows about why it was dead-lettered.
+func (s *DeadLetterService) Record(ctx context.Context, dlqTopic, key string, payload []byte, info bus.DeadLetterInfo) (*model.DeadLetter, error) {
+	topic := info.Topic
+	if topic == "" {
+		topic = bus.OriginalTopic(dlqTopic)
+	}
+	d := &model.DeadLetter{
+		ID:          uuid.New(),
+		Fingerprint: model.DeadLetterFingerprint(topic, key, payload),
+		Topic:       topic,
+		Key:         key,
+		Payload:     payload,
+		RetryCount:  info.RetryCount,
+		Reason:      info.Reason,
+		LastError:   info.Error,
+		LastSeenAt:  time.Now().UTC(),
+	}
+	if err := s.letters.Record(ctx, d); err != nil {
+		return nil, fmt.Errorf("failed to record dead letter: %w", err)
+	}
+
+	s.logger.Error("dead letter recorded",
+		zap.String("dead_letter_id", d.ID.String()),
+		zap.String("topic", d.Topic),
+		zap.String("key", d.Key),
+		zap.Int("retry_count", d.RetryCount),
+		zap.String("reason", d.Reason),
+		zap.String("last_error", d.LastError),
+		zap.Int("occurrences", d.Occurrences),
+	)
+	return d, nil
+}
+
+func (s *DeadLetterService) List(ctx context.Context, filter model.DeadLetterFilter) ([]*model.DeadLetter, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidDeadLetterQuery, filter.Status)
+	}
+	return s.letters.List(ctx, filter)
+}
+
+// Get returns a dead letter with its replay history.
+func (s *DeadLetterService) Get(ctx context.Context, id uuid.UUID) (*model.DeadLetterDetail, error) {
+	d, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	replays, err := s.letters.ListReplays(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeadLetterDetail{DeadLetter: d, Replays: replays}, nil
+}
+
+// Replay publishes a dead letter back onto its original topic. A failed
+// publish is not an error: it is recorded and returned as the replay's
+// outcome.
+func (s *DeadLetterService) Replay(ctx context.Context, id uuid.UUID, actor string) (*model.DeadLetterReplay, error) {
+	if s.producer == nil {
+		return nil, ErrReplayUnavailable
+	}
+	d, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.replay(ctx, d, actor)
+}
+
+// DeadLetterReplaySummary is the outcome of a bulk replay.
+type DeadLetterReplaySummary struct {
+	Matched   int                       `json:"matched"`
+	Published int                       `json:"published"`
+	Failed    int                       `json:"failed"`
+	Replays   []*model.DeadLetterReplay `json:"replays"`
+}
+
+// ReplayMatching replays up to MaxBulkReplay dead letters matching filter,
+// most recently seen first. Without a status only pending ones are
+// replayed. Limit and Offset are ignored.
+func (s *DeadLetterService) ReplayMatching(ctx context.Context, filter model.DeadLetterFilter, actor string) (*DeadLetterReplaySummary, error) {
+	if s.producer == nil {
+		return nil, ErrReplayUnavailable
+	}
+	if filter.Status == "" {
+		filter.Status = model.DeadLetterPending
+	}
+	if !filter.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidDeadLetterQuery, filter.Status)
+	}
+
+	// Collect the matches before replaying, since replaying changes their
+	// status and would shift the pages
+	var matched []*model.DeadLetter
+	filter.Limit, filter.Offset = 100, 0
+	for len(matched) < MaxBulkReplay {
+		page, err := s.letters.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.Offset += len(page)
+	}
+	if len(matched) > MaxBulkReplay {
+		matched = matched[:MaxBulkReplay]
+	}
+
+	summary := &DeadLetterReplaySummary{Matched: len(matched), Replays: []*model.DeadLetterReplay{}}
+	for _, d := range matched {
+		replay, err := s.replay(ctx, d, actor)
+		if err != nil {
+			return summary, err
+		}
+		if replay.Status == model.DeadLetterReplayPublished {
+			summary.Published++
+		} else {
+			summary.Failed++
+		}
+		summary.Replays = append(summary.Replays, replay)
+	}
+
+	s.logger.Info("dead letters replayed",
+		zap.String("actor", actor),
+		zap.Int("matched", summary.Matched),
+		zap.Int("published", summary.Published),
+		zap.Int("failed", summary.Failed),
+	)
+	return summary, nil
+}
+
+func (s *DeadLetterService) replay(ctx context.Context, d *model.DeadLetter, actor string) (*model.DeadLetterReplay, error) {
+	replay := &model.DeadLetterReplay{
+		ID:           uuid.New(),
+		DeadLetterID: d.ID,
+		Status:       model.DeadLetterReplayPublished,
+		Actor:        actor,
+	}
+	if err := s.producer.Publish(ctx, d.Topic, d.Key, d.Payload); err != nil {
+		replay.Status = model.DeadLetterReplayError
+		replay.Error = err.Error()
+		s.logger.Warn("dead letter replay failed",
+			zap.String("dead_letter_id", d.ID.String()),
+			zap.String("topic", d.Topic),
+			zap.Error(err),
+		)
+	}
+	replay.CreatedAt = time.Now().UTC()
+
+	// Record the outcome even if the caller has gone away
+	if err := s.letters.RecordReplay(context.WithoutCancel(ctx), replay); err != nil {
+		return nil, fmt.Errorf("failed to record replay of %s: %w", d.ID, err)
+	}
+	return replay, nil
+}
+
+func (s *DeadLetterService) get(ctx context.Context, id uuid.UUID) (*model.DeadLetter, error) {
+	d, err := s.letters.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return d, nil
+}
@@ -0,0 +1,5 @@
+package dto
+
+type CaptureTransactionDTO struct {
+	Amount int64 `json:"amount"` // 0 captures the full authorized amount
+}
@@ -0,0 +1,12 @@
+package dto
+
+// ReplayDeadLettersDTO selects the dead letters a bulk replay publishes.
+// Without a status only pending ones are replayed; from and to (a date or
+// RFC 3339) bound when they were last seen.
+type ReplayDeadLettersDTO struct {
+	Topic  string `json:"topic"`
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
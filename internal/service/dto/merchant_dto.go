@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type CreateMerchantDTO struct {
+	Name               string              `json:"name"`
+	Status             string              `json:"status"` // "active" (default) or "suspended"
+	SettlementCurrency string              `json:"settlement_currency"`
+	BankDetails        model.BankDetails   `json:"bank_details"`
+	WebhookURL         string              `json:"webhook_url"`
+	FeePlanID          *uuid.UUID          `json:"fee_plan_id"`
+	Payout             *model.PayoutPolicy `json:"payout"` // defaults to daily
+	Metadata           map[string]any      `json:"metadata"`
+}
+
+// UpdateMerchantDTO is a partial update; nil fields are left unchanged.
+type UpdateMerchantDTO struct {
+	Name               *string             `json:"name"`
+	Status             *string             `json:"status"`
+	SettlementCurrency *string             `json:"settlement_currency"`
+	BankDetails        *model.BankDetails  `json:"bank_details"`
+	WebhookURL         *string             `json:"webhook_url"`
+	FeePlanID          *uuid.UUID          `json:"fee_plan_id"`
+	ClearFeePlan       bool                `json:"clear_fee_plan"`        // revert to the default plan
+	RotateSecret       bool                `json:"rotate_webhook_secret"` // issue a new webhook signing secret
+	Payout             *model.PayoutPolicy `json:"payout"`
+	Metadata           map[string]any      `json:"metadata"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+type AssignReconciliationDTO struct {
+	Assignee string `json:"assignee"` // empty unassigns
+}
+
+type ReconciliationNoteDTO struct {
+	Body string `json:"body"`
+}
+
+type ResolveReconciliationDTO struct {
+	Resolution model.ReconResolution `json:"resolution"`
+	Reason     string                `json:"reason"`
+	// Write-off and adjustment: amount in minor units (0 uses the case's
+	// difference) and currency (defaults to the settlement's or statement
+	// line's)
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// Adjustment only
+	DebitAccountID  *uuid.UUID `json:"debit_account_id"`
+	CreditAccountID *uuid.UUID `json:"credit_account_id"`
+	// Refund only
+	RefundID *uuid.UUID `json:"refund_id"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type CreateRefundDTO struct {
+	Amount   int64          `json:"amount"` // 0 refunds the full remaining amount
+	Reason   string         `json:"reason"`
+	Metadata map[string]any `json:"metadata"`
+}
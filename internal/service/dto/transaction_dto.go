@@ -3,9 +3,11 @@ package dto
 import "github.com/google/uuid"
 
 type CreateTransactionDTO struct {
-	Amount     int64          `json:"amount"`
-	Currency   string         `json:"currency"`
-	UserID     uuid.UUID      `json:"user_id"`
-	MerchantID uuid.UUID      `json:"merchant_id"`
-	Metadata   map[string]any `json:"metadata"`
+	Amount             int64          `json:"amount"`
+	Currency           string         `json:"currency"`
+	UserID             uuid.UUID      `json:"user_id"`
+	MerchantID         uuid.UUID      `json:"merchant_id"`
+	Metadata           map[string]any `json:"metadata"`
+	CaptureMethod      string         `json:"capture_method"`      // "automatic" (default) or "manual"
+	SettlementCurrency string         `json:"settlement_currency"` // defaults to Currency
 }
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// FeeService prices settlements using the merchant's fee plan. Volume tiers
+// are based on the gross amount settled to the merchant account in the
+// current calendar month (UTC).
+type FeeService struct {
+	plans       repo.FeePlanRepository
+	settlements repo.SettlementRepository
+	logger      *zap.Logger
+}
+
+func NewFeeService(plans repo.FeePlanRepository, settlements repo.SettlementRepository, logger *zap.Logger) *FeeService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &FeeService{plans: plans, settlements: settlements, logger: logger}
+}
+
+// Quote returns the fee breakdown for settling gross to a merchant account.
+// Merchants without a plan, or whose plan has no rule for the currency, are
+// not charged.
+func (s *FeeService) Quote(ctx context.Context, merchantID, merchantAccountID uuid.UUID, gross model.Money) (*model.FeeQuote, error) {
+	noFee := &model.FeeQuote{
+		Gross: gross,
+		Fee:   model.NewMoney(0, gross.Currency),
+		Net:   gross,
+	}
+	if s == nil || s.plans == nil {
+		return noFee, nil
+	}
+
+	plan, err := s.plans.GetForMerchant(ctx, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fee plan: %w", err)
+	}
+	if plan == nil {
+		return noFee, nil
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	volume, err := s.settlements.SumSuccessfulSince(ctx, merchantAccountID, monthStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum monthly volume: %w", err)
+	}
+
+	quote, err := plan.Quote(gross, volume)
+	if err != nil {
+		s.logger.Warn("fee plan has no rule for currency, settling without fee",
+			zap.String("merchant_id", merchantID.String()),
+			zap.String("fee_plan", plan.Name),
+			zap.String("currency", gross.Currency),
+		)
+		noFee.PlanID = plan.ID
+		return noFee, nil
+	}
+	return quote, nil
+}
+
+// applyFeeQuote records the fee breakdown on a settlement row.
+func applyFeeQuote(settlement *model.Settlements, quote *model.FeeQuote) {
+	settlement.FeeAmount = quote.Fee.Amount
+	settlement.NetAmount = quote.Net.Amount
+	if settlement.Metadata == nil {
+		settlement.Metadata = map[string]any{}
+	}
+	settlement.Metadata["fee"] = quote
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/fx"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidPosting = errors.New("invalid ledger posting")
+
+// Posting moves Amount from DebitAccountID to CreditAccountID.
+type Posting struct {
+	DebitAccountID  uuid.UUID
+	CreditAccountID uuid.UUID
+	Amount          int64
+	Currency        string
+	TransactionID   uuid.UUID
+	Description     string
+	Metadata        map[string]any
+}
+
+// LedgerService writes double-entry postings. Balances follow a credit-normal
+// convention: a credit leg increases the account balance, a debit leg
+// decreases it.
+type LedgerService struct {
+	fx     *fx.Converter
+	logger *zap.Logger
+}
+
+// NewLedgerService creates a ledger service. converter prices cross-currency
+// settlements; with nil only same-currency settlement is possible.
+func NewLedgerService(converter *fx.Converter, logger *zap.Logger) *LedgerService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if converter == nil {
+		converter = fx.NewConverter(nil, nil)
+	}
+	return &LedgerService{fx: converter, logger: logger}
+}
+
+// SettlementAmount converts the transaction amount into its settlement
+// currency. The conversion is nil when no FX is involved.
+func (s *LedgerService) SettlementAmount(ctx context.Context, tx *model.Transaction) (model.Money, *model.FXConversion, error) {
+	if tx.SettlementCurrency == "" || tx.SettlementCurrency == tx.Currency {
+		return tx.Money(), nil, nil
+	}
+	settled, conversion, err := s.fx.Convert(ctx, tx.Money(), tx.SettlementCurrency)
+	if err != nil {
+		return model.Money{}, nil, fmt.Errorf("convert %s to %s: %w", tx.Currency, tx.SettlementCurrency, err)
+	}
+	return settled, conversion, nil
+}
+
+// RefundAmount is the merchant-side amount of a refund: the refund converted
+// at the rate recorded on the settlement it reverses, so FX moves between
+// settlement and refund don't leave a residue on the merchant account.
+func (s *LedgerService) RefundAmount(refund *model.Refund, settlement *model.Settlements) (model.Money, error) {
+	if settlement == nil || settlement.Currency == "" || settlement.Currency == refund.Currency {
+		return model.NewMoney(refund.Amount, refund.Currency), nil
+	}
+	if settlement.FXRate == "" {
+		return model.Money{}, fmt.Errorf("settlement %s in %s has no fx rate", settlement.ID, settlement.Currency)
+	}
+	return s.fx.ConvertAt(model.NewMoney(refund.Amount, refund.Currency), settlement.Currency, settlement.FXRate)
+}
+
+// Post writes every posting as a debit leg and a matching credit leg and
+// updates both account balances. r must come from TxManager.WithTx so the
+// legs, the balances and the caller's state change commit together.
+func (s *LedgerService) Post(ctx context.Context, r *repo.TxRepositories, postings ...Posting) error {
+	now := time.Now().UTC()
+	entries := make([]*model.LedgerEntries, 0, len(postings)*2)
+	deltas := make(map[uuid.UUID]int64)
+
+	for _, p := range postings {
+		if err := validatePosting(p); err != nil {
+			return err
+		}
+
+		metadata := p.Metadata
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+
+		entries = append(entries,
+			&model.LedgerEntries{
+				ID:             uuid.New(),
+				DebitAccountID: p.DebitAccountID,
+				Amount:         p.Amount,
+				Currency:       p.Currency,
+				TransactionID:  p.TransactionID,
+				Description:    p.Description,
+				Metadata:       metadata,
+				EntryType:      model.LedgerDebit,
+				CreatedAt:      now,
+			},
+			&model.LedgerEntries{
+				ID:              uuid.New(),
+				CreditAccountID: p.CreditAccountID,
+				Amount:          p.Amount,
+				Currency:        p.Currency,
+				TransactionID:   p.TransactionID,
+				Description:     p.Description,
+				Metadata:        metadata,
+				EntryType:       model.LedgerCredit,
+				CreatedAt:       now,
+			},
+		)
+
+		deltas[p.DebitAccountID] -= p.Amount
+		deltas[p.CreditAccountID] += p.Amount
+	}
+
+	if err := r.Ledger.CreateEntries(ctx, entries); err != nil {
+		return fmt.Errorf("failed to create ledger entries: %w", err)
+	}
+
+	for accountID, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		if err := r.Ledger.ApplyBalanceDelta(ctx, accountID, delta); err != nil {
+			return fmt.Errorf("failed to update balance for account %s: %w", accountID, err)
+		}
+	}
+
+	s.logger.Debug("ledger postings written", zap.Int("postings", len(postings)), zap.Int("entries", len(entries)))
+	return nil
+}
+
+// PostSettlement moves a settled transaction's amount from the clearing
+// account for its currency into the merchant's account. When settled is in
+// another currency the amount passes through the FX accounts of both
+// currencies so each currency balances on its own.
+func (s *LedgerService) PostSettlement(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	tx *model.Transaction,
+	settlementID uuid.UUID,
+	merchantAccountID uuid.UUID,
+	settled model.Money,
+) error {
+	clearing, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemClearingOwnerID, tx.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create clearing account: %w", err)
+	}
+
+	metadata := map[string]any{
+		"settlement_id": settlementID.String(),
+	}
+	return s.postConverted(ctx, r, tx.ID, "settlement", metadata,
+		clearing.ID, tx.Money(),
+		merchantAccountID, settled,
+	)
+}
+
+// PostFee charges a settlement fee to the merchant, moving it from the
+// merchant's account to the fee revenue account in the same currency. It is
+// posted as its own lines after PostSettlement so statements show gross and
+// fee separately.
+func (s *LedgerService) PostFee(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	tx *model.Transaction,
+	settlementID uuid.UUID,
+	merchantAccountID uuid.UUID,
+	quote *model.FeeQuote,
+) error {
+	if quote == nil || quote.Fee.IsZero() {
+		return nil
+	}
+	revenue, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemFeeRevenueOwnerID, quote.Fee.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create fee revenue account: %w", err)
+	}
+
+	return s.Post(ctx, r, Posting{
+		DebitAccountID:  merchantAccountID,
+		CreditAccountID: revenue.ID,
+		Amount:          quote.Fee.Amount,
+		Currency:        quote.Fee.Currency,
+		TransactionID:   tx.ID,
+		Description:     "fee",
+		Metadata: map[string]any{
+			"settlement_id": settlementID.String(),
+			"fee_plan_id":   quote.PlanID.String(),
+			"fee_rule_id":   quote.RuleID.String(),
+		},
+	})
+}
+
+// PostRefund reverses (part of) a settlement: the refunded amount moves from
+// the merchant's account back to the clearing account for its currency.
+// merchantAmount is the refund in the merchant's settlement currency (see
+// RefundAmount).
+func (s *LedgerService) PostRefund(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	refund *model.Refund,
+	merchantAccountID uuid.UUID,
+	merchantAmount model.Money,
+) error {
+	clearing, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemClearingOwnerID, refund.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create clearing account: %w", err)
+	}
+
+	metadata := map[string]any{
+		"refund_id": refund.ID.String(),
+	}
+	return s.postConverted(ctx, r, refund.TransactionID, "refund", metadata,
+		merchantAccountID, merchantAmount,
+		clearing.ID, model.NewMoney(refund.Amount, refund.Currency),
+	)
+}
+
+// postConverted debits debitAmount from debitAccount and credits
+// creditAmount to creditAccount. Same-currency amounts must be equal and are
+// posted directly; otherwise each side is balanced against the FX account of
+// its own currency.
+func (s *LedgerService) postConverted(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	transactionID uuid.UUID,
+	description string,
+	metadata map[string]any,
+	debitAccountID uuid.UUID, debitAmount model.Money,
+	creditAccountID uuid.UUID, creditAmount model.Money,
+) error {
+	if debitAmount.Currency == creditAmount.Currency {
+		if debitAmount.Amount != creditAmount.Amount {
+			return fmt.Errorf("%w: %s posting of %d against %d", ErrInvalidPosting, description, debitAmount.Amount, creditAmount.Amount)
+		}
+		return s.Post(ctx, r, Posting{
+			DebitAccountID:  debitAccountID,
+			CreditAccountID: creditAccountID,
+			Amount:          debitAmount.Amount,
+			Currency:        debitAmount.Currency,
+			TransactionID:   transactionID,
+			Description:     description,
+			Metadata:        metadata,
+		})
+	}
+
+	fxDebit, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemFXOwnerID, debitAmount.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create fx account: %w", err)
+	}
+	fxCredit, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemFXOwnerID, creditAmount.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create fx account: %w", err)
+	}
+
+	withFX := make(map[string]any, len(metadata)+2)
+	for k, v := range metadata {
+		withFX[k] = v
+	}
+	withFX["fx_from"] = debitAmount
+	withFX["fx_to"] = creditAmount
+
+	return s.Post(ctx, r,
+		Posting{
+			DebitAccountID:  debitAccountID,
+			CreditAccountID: fxDebit.ID,
+			Amount:          debitAmount.Amount,
+			Currency:        debitAmount.Currency,
+			TransactionID:   transactionID,
+			Description:     description + " fx",
+			Metadata:        withFX,
+		},
+		Posting{
+			DebitAccountID:  fxCredit.ID,
+			CreditAccountID: creditAccountID,
+			Amount:          creditAmount.Amount,
+			Currency:        creditAmount.Currency,
+			TransactionID:   transactionID,
+			Description:     description,
+			Metadata:        withFX,
+		},
+	)
+}
+
+// PostPayout moves a payout's amount from the merchant's account to the
+// payout clearing account for its currency, where it stays while the bank
+// transfer is in flight and after it is paid.
+func (s *LedgerService) PostPayout(ctx context.Context, r *repo.TxRepositories, payout *model.Payout) error {
+	clearing, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemPayoutClearingOwnerID, payout.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create payout clearing account: %w", err)
+	}
+
+	return s.Post(ctx, r, Posting{
+		DebitAccountID:  payout.MerchantAccountID,
+		CreditAccountID: clearing.ID,
+		Amount:          payout.Amount,
+		Currency:        payout.Currency,
+		Description:     "payout",
+		Metadata: map[string]any{
+			"payout_id": payout.ID.String(),
+		},
+	})
+}
+
+// ReversePayout returns a declined payout to the merchant's account.
+func (s *LedgerService) ReversePayout(ctx context.Context, r *repo.TxRepositories, payout *model.Payout, reason string) error {
+	clearing, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemPayoutClearingOwnerID, payout.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create payout clearing account: %w", err)
+	}
+
+	return s.Post(ctx, r, Posting{
+		DebitAccountID:  clearing.ID,
+		CreditAccountID: payout.MerchantAccountID,
+		Amount:          payout.Amount,
+		Currency:        payout.Currency,
+		Description:     "payout reversal",
+		Metadata: map[string]any{
+			"payout_id": payout.ID.String(),
+			"reason":    reason,
+		},
+	})
+}
+
+// PostWriteOff books a reconciliation difference to the write-off account
+// for its currency. A shortfall (less money arrived than the ledger
+// expects) moves amount from the write-off account into clearing; a surplus
+// moves it the other way.
+func (s *LedgerService) PostWriteOff(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	amount model.Money,
+	shortfall bool,
+	transactionID uuid.UUID,
+	metadata map[string]any,
+) error {
+	writeOff, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemWriteOffOwnerID, amount.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create write-off account: %w", err)
+	}
+	clearing, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemClearingOwnerID, amount.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create clearing account: %w", err)
+	}
+
+	posting := Posting{
+		DebitAccountID:  writeOff.ID,
+		CreditAccountID: clearing.ID,
+		Amount:          amount.Amount,
+		Currency:        amount.Currency,
+		TransactionID:   transactionID,
+		Description:     "reconciliation write-off",
+		Metadata:        metadata,
+	}
+	if !shortfall {
+		posting.DebitAccountID, posting.CreditAccountID = clearing.ID, writeOff.ID
+	}
+	return s.Post(ctx, r, posting)
+}
+
+// PostHold reserves an authorized amount by moving it from the customer's
+// account to the holds account for its currency.
+func (s *LedgerService) PostHold(ctx context.Context, r *repo.TxRepositories, auth *model.Authorization) error {
+	holds, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemHoldsOwnerID, auth.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create holds account: %w", err)
+	}
+
+	return s.Post(ctx, r, Posting{
+		DebitAccountID:  auth.HoldAccountID,
+		CreditAccountID: holds.ID,
+		Amount:          auth.Amount,
+		Currency:        auth.Currency,
+		TransactionID:   auth.TransactionID,
+		Description:     "authorization hold",
+		Metadata: map[string]any{
+			"authorization_id": auth.ID.String(),
+		},
+	})
+}
+
+// ReleaseHold returns the full authorized amount to the customer's account.
+// It is posted on capture (before the settlement posting), void and expiry.
+func (s *LedgerService) ReleaseHold(ctx context.Context, r *repo.TxRepositories, auth *model.Authorization, reason string) error {
+	holds, err := r.Accounts.GetOrCreateSystemAccount(ctx, model.SystemHoldsOwnerID, auth.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get/create holds account: %w", err)
+	}
+
+	return s.Post(ctx, r, Posting{
+		DebitAccountID:  holds.ID,
+		CreditAccountID: auth.HoldAccountID,
+		Amount:          auth.Amount,
+		Currency:        auth.Currency,
+		TransactionID:   auth.TransactionID,
+		Description:     "hold release",
+		Metadata: map[string]any{
+			"authorization_id": auth.ID.String(),
+			"reason":           reason,
+		},
+	})
+}
+
+func validatePosting(p Posting) error {
+	switch {
+	case p.Amount <= 0:
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPosting)
+	case p.Currency == "":
+		return fmt.Errorf("%w: currency required", ErrInvalidPosting)
+	case p.DebitAccountID == uuid.Nil || p.CreditAccountID == uuid.Nil:
+		return fmt.Errorf("%w: debit and credit accounts required", ErrInvalidPosting)
+	case p.DebitAccountID == p.CreditAccountID:
+		return fmt.Errorf("%w: debit and credit accounts must differ", ErrInvalidPosting)
+	}
+	return nil
+}
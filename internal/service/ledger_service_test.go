@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+// memLedger records the legs written and every balance delta applied.
+type memLedger struct {
+	repo.LedgerRepository
+	entries  []*model.LedgerEntries
+	deltas   map[uuid.UUID][]int64
+	balances map[uuid.UUID]int64
+}
+
+func newMemLedger() *memLedger {
+	return &memLedger{deltas: map[uuid.UUID][]int64{}, balances: map[uuid.UUID]int64{}}
+}
+
+func (l *memLedger) CreateEntries(ctx context.Context, entries []*model.LedgerEntries) error {
+	l.entries = append(l.entries, entries...)
+	return nil
+}
+
+func (l *memLedger) ApplyBalanceDelta(ctx context.Context, accountID uuid.UUID, delta int64) error {
+	l.deltas[accountID] = append(l.deltas[accountID], delta)
+	l.balances[accountID] += delta
+	return nil
+}
+
+func TestLedgerPostWritesEqualLegs(t *testing.T) {
+	ledger := newMemLedger()
+	r := &repo.TxRepositories{Ledger: ledger}
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	err := NewLedgerService(nil, nil).Post(context.Background(), r,
+		Posting{DebitAccountID: a, CreditAccountID: b, Amount: 700, Currency: "NGN"},
+		Posting{DebitAccountID: b, CreditAccountID: c, Amount: 250, Currency: "NGN"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ledger.entries) != 4 {
+		t.Fatalf("wrote %d entries, want 4", len(ledger.entries))
+	}
+	var debits, credits int64
+	for i := 0; i < len(ledger.entries); i += 2 {
+		debit, credit := ledger.entries[i], ledger.entries[i+1]
+		if debit.EntryType != model.LedgerDebit || credit.EntryType != model.LedgerCredit {
+			t.Fatalf("legs %d are %s and %s, want debit then credit", i/2, debit.EntryType, credit.EntryType)
+		}
+		if debit.Amount != credit.Amount || debit.Currency != credit.Currency {
+			t.Errorf("legs %d: debit %d %s, credit %d %s", i/2, debit.Amount, debit.Currency, credit.Amount, credit.Currency)
+		}
+		debits += debit.Amount
+		credits += credit.Amount
+	}
+	if debits != credits {
+		t.Errorf("debits %d, credits %d", debits, credits)
+	}
+}
+
+func TestLedgerPostNetsDeltasPerAccount(t *testing.T) {
+	ledger := newMemLedger()
+	r := &repo.TxRepositories{Ledger: ledger}
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	err := NewLedgerService(nil, nil).Post(context.Background(), r,
+		Posting{DebitAccountID: a, CreditAccountID: b, Amount: 100, Currency: "USD"},
+		Posting{DebitAccountID: a, CreditAccountID: b, Amount: 10, Currency: "USD"},
+		Posting{DebitAccountID: b, CreditAccountID: c, Amount: 40, Currency: "USD"},
+		// Moves that cancel out leave the balance alone
+		Posting{DebitAccountID: c, CreditAccountID: d, Amount: 5, Currency: "USD"},
+		Posting{DebitAccountID: d, CreditAccountID: c, Amount: 5, Currency: "USD"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uuid.UUID]int64{a: -110, b: 70, c: 40}
+	for id, delta := range want {
+		if got := ledger.deltas[id]; len(got) != 1 || got[0] != delta {
+			t.Errorf("account deltas %v, want [%d]", got, delta)
+		}
+	}
+	if got, ok := ledger.deltas[d]; ok {
+		t.Errorf("netted-out account got deltas %v", got)
+	}
+}
+
+func TestLedgerPostRejectsInvalidPostings(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	cases := map[string]Posting{
+		"zero amount":      {DebitAccountID: a, CreditAccountID: b, Amount: 0, Currency: "USD"},
+		"negative amount":  {DebitAccountID: a, CreditAccountID: b, Amount: -5, Currency: "USD"},
+		"same account":     {DebitAccountID: a, CreditAccountID: a, Amount: 5, Currency: "USD"},
+		"missing currency": {DebitAccountID: a, CreditAccountID: b, Amount: 5},
+		"missing account":  {DebitAccountID: a, Amount: 5, Currency: "USD"},
+	}
+	for name, p := range cases {
+		t.Run(name, func(t *testing.T) {
+			ledger := newMemLedger()
+			valid := Posting{DebitAccountID: a, CreditAccountID: b, Amount: 1, Currency: "USD"}
+			err := NewLedgerService(nil, nil).Post(context.Background(), &repo.TxRepositories{Ledger: ledger}, valid, p)
+			if !errors.Is(err, ErrInvalidPosting) {
+				t.Fatalf("got %v, want ErrInvalidPosting", err)
+			}
+			if len(ledger.entries) != 0 || len(ledger.deltas) != 0 {
+				t.Error("wrote to the ledger despite an invalid posting")
+			}
+		})
+	}
+}
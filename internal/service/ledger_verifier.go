@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"go.uber.org/zap"
+)
+
+// DefaultLedgerViolationLimit is how many violations of each kind a report
+// keeps.
+const DefaultLedgerViolationLimit = 100
+
+// LedgerVerifier proves the ledger is internally sound, independently of
+// the transactions and settlements it records: every transaction's legs
+// net to zero per currency, account_balances equals the net of each
+// account's legs, and every leg posts to an existing account in its own
+// currency.
+type LedgerVerifier struct {
+	ledger repo.LedgerRepository
+	limit  int
+	logger *zap.Logger
+}
+
+// NewLedgerVerifier creates a verifier whose reports keep up to limit
+// violations of each kind; 0 uses DefaultLedgerViolationLimit.
+func NewLedgerVerifier(ledgerRepo repo.LedgerRepository, limit int, logger *zap.Logger) *LedgerVerifier {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if limit <= 0 {
+		limit = DefaultLedgerViolationLimit
+	}
+	return &LedgerVerifier{ledger: ledgerRepo, limit: limit, logger: logger}
+}
+
+// Verify checks every invariant and reports the violations. An error means
+// a check could not run, not that the ledger is unsound.
+func (v *LedgerVerifier) Verify(ctx context.Context) (*model.LedgerReport, error) {
+	checks := map[model.LedgerViolationKind]func(context.Context) ([]*model.LedgerViolation, error){
+		model.LedgerUnbalanced:       v.ledger.ListUnbalanced,
+		model.LedgerBalanceDrift:     v.ledger.ListBalanceDrift,
+		model.LedgerMissingAccount:   v.ledger.ListMissingAccounts,
+		model.LedgerCurrencyMismatch: v.ledger.ListCurrencyMismatches,
+	}
+
+	report := model.NewLedgerReport(v.limit)
+	for _, kind := range model.LedgerViolations {
+		violations, err := checks[kind](ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ledger check %s: %w", kind, err)
+		}
+		for _, violation := range violations {
+			report.Add(violation)
+		}
+		if len(violations) > 0 {
+			v.logger.Error("ledger invariant violated",
+				zap.String("check", string(kind)),
+				zap.Int("violations", len(violations)),
+			)
+		}
+	}
+
+	v.logger.Info("ledger verified", zap.Int("violations", report.Total()))
+	return report, nil
+}
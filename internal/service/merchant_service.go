@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/webhook"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidMerchant = errors.New("invalid merchant")
+	ErrMerchantInUse   = errors.New("merchant has transactions or accounts")
+)
+
+type MerchantService struct {
+	repo       repo.MerchantRepository
+	feePlans   repo.FeePlanRepository
+	currencies *model.CurrencyRegistry
+	logger     *zap.Logger
+}
+
+func NewMerchantService(
+	merchantRepo repo.MerchantRepository,
+	feePlans repo.FeePlanRepository,
+	currencies *model.CurrencyRegistry,
+	logger *zap.Logger,
+) *MerchantService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	return &MerchantService{repo: merchantRepo, feePlans: feePlans, currencies: currencies, logger: logger}
+}
+
+func (s *MerchantService) CreateMerchant(ctx context.Context, input dto.CreateMerchantDTO) (*model.Merchant, error) {
+	now := time.Now().UTC()
+	m := &model.Merchant{
+		ID:                 uuid.New(),
+		Name:               strings.TrimSpace(input.Name),
+		Status:             model.MerchantStatus(strings.ToLower(input.Status)),
+		SettlementCurrency: input.SettlementCurrency,
+		BankDetails:        input.BankDetails,
+		WebhookURL:         strings.TrimSpace(input.WebhookURL),
+		FeePlanID:          input.FeePlanID,
+		Payout:             model.DefaultPayoutPolicy(),
+		Metadata:           input.Metadata,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if m.Status == "" {
+		m.Status = model.MerchantActive
+	}
+	if m.Metadata == nil {
+		m.Metadata = map[string]any{}
+	}
+	if input.Payout != nil {
+		m.Payout = *input.Payout
+	}
+
+	if err := s.validate(ctx, m); err != nil {
+		return nil, err
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	m.WebhookSecret = secret
+	if err := s.repo.Create(ctx, m); err != nil {
+		return nil, fmt.Errorf("failed to create merchant: %w", err)
+	}
+
+	s.logger.Info("merchant created", zap.String("merchant_id", m.ID.String()), zap.String("name", m.Name))
+	return m, nil
+}
+
+// GetMerchant returns the merchant or model.ErrMerchantNotFound. The
+// webhook secret is never included.
+func (s *MerchantService) GetMerchant(ctx context.Context, id uuid.UUID) (*model.Merchant, error) {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, model.ErrMerchantNotFound
+	}
+	m.WebhookSecret = ""
+	return m, nil
+}
+
+func (s *MerchantService) ListMerchants(ctx context.Context, status string, limit, offset int) ([]*model.Merchant, error) {
+	st := model.MerchantStatus(strings.ToLower(status))
+	if st != "" && !st.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidMerchant, status)
+	}
+	merchants, err := s.repo.List(ctx, st, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range merchants {
+		m.WebhookSecret = ""
+	}
+	return merchants, nil
+}
+
+func (s *MerchantService) UpdateMerchant(ctx context.Context, id uuid.UUID, input dto.UpdateMerchantDTO) (*model.Merchant, error) {
+	m, err := s.GetMerchant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		m.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Status != nil {
+		m.Status = model.MerchantStatus(strings.ToLower(*input.Status))
+	}
+	if input.SettlementCurrency != nil {
+		m.SettlementCurrency = *input.SettlementCurrency
+	}
+	if input.BankDetails != nil {
+		m.BankDetails = *input.BankDetails
+	}
+	if input.WebhookURL != nil {
+		m.WebhookURL = strings.TrimSpace(*input.WebhookURL)
+	}
+	if input.FeePlanID != nil {
+		m.FeePlanID = input.FeePlanID
+	}
+	if input.ClearFeePlan {
+		m.FeePlanID = nil
+	}
+	if input.Metadata != nil {
+		m.Metadata = input.Metadata
+	}
+	if input.Payout != nil {
+		m.Payout = *input.Payout
+	}
+
+	if err := s.validate(ctx, m); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, m); err != nil {
+		return nil, err
+	}
+	if input.RotateSecret {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		if err := s.repo.SetWebhookSecret(ctx, m.ID, secret); err != nil {
+			return nil, err
+		}
+		m.WebhookSecret = secret
+	}
+
+	s.logger.Info("merchant updated", zap.String("merchant_id", m.ID.String()), zap.String("status", string(m.Status)))
+	return m, nil
+}
+
+// DeleteMerchant removes a merchant that has never transacted. Merchants
+// with history should be suspended instead.
+func (s *MerchantService) DeleteMerchant(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.GetMerchant(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrMerchantInUse
+	}
+	s.logger.Info("merchant deleted", zap.String("merchant_id", id.String()))
+	return nil
+}
+
+// ActiveMerchant returns the merchant if it exists and may transact.
+func (s *MerchantService) ActiveMerchant(ctx context.Context, id uuid.UUID) (*model.Merchant, error) {
+	return activeMerchant(ctx, s.repo, id)
+}
+
+func activeMerchant(ctx context.Context, merchants repo.MerchantRepository, id uuid.UUID) (*model.Merchant, error) {
+	m, err := merchants.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load merchant: %w", err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrMerchantNotFound, id)
+	}
+	if m.Status != model.MerchantActive {
+		return nil, fmt.Errorf("%w: %s", model.ErrMerchantSuspended, id)
+	}
+	return m, nil
+}
+
+func (s *MerchantService) validate(ctx context.Context, m *model.Merchant) error {
+	if m.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidMerchant)
+	}
+	if !m.Status.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidMerchant, m.Status)
+	}
+
+	c, err := s.currencies.Validate(m.SettlementCurrency)
+	if err != nil {
+		return fmt.Errorf("%w: settlement currency: %v", ErrInvalidMerchant, err)
+	}
+	m.SettlementCurrency = c.Code
+
+	if m.WebhookURL != "" {
+		u, err := url.Parse(m.WebhookURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: webhook_url must be an absolute http(s) URL", ErrInvalidMerchant)
+		}
+	}
+
+	if err := m.Payout.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMerchant, err)
+	}
+
+	if m.FeePlanID != nil && s.feePlans != nil {
+		plan, err := s.feePlans.GetByID(ctx, *m.FeePlanID)
+		if err != nil {
+			return fmt.Errorf("failed to load fee plan: %w", err)
+		}
+		if plan == nil {
+			return fmt.Errorf("%w: fee plan %s not found", ErrInvalidMerchant, *m.FeePlanID)
+		}
+	}
+	return nil
+}
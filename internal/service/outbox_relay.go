@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const defaultOutboxBatchSize = 100
+
+// outboxPublishTimeout bounds each publish, as the relay holds the batch's
+// row locks until it is done.
+const outboxPublishTimeout = 10 * time.Second
+
+// outboxUnroutableRetry spaces out the attempts at an unroutable message, from
+// 5s and doubling up to outboxMaxRetryDelay, until a queue subscribes to its
+// topic.
+var outboxUnroutableRetry = bus.RetryPolicy{BaseDelay: 5 * time.Second, Multiplier: 2}
+
+const outboxMaxRetryDelay = 10 * time.Minute
+
+// OutboxRelay drains the outbox table onto a bus.Producer. Delivery is
+// at-least-once: a message is only marked sent after Publish returns nil, so
+// a crash between the two re-sends it on the next pass.
+type OutboxRelay struct {
+	txManager repo.TxManager
+	producer  bus.Producer
+	logger    *zap.Logger
+	batchSize int
+}
+
+func NewOutboxRelay(txManager repo.TxManager, producer bus.Producer, logger *zap.Logger) *OutboxRelay {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &OutboxRelay{
+		txManager: txManager,
+		producer:  producer,
+		logger:    logger,
+		batchSize: defaultOutboxBatchSize,
+	}
+}
+
+// RelayBatch publishes up to one batch of unsent messages in insertion order
+// and returns how many it took off the outbox. It stops at the first publish
+// failure so a broker outage doesn't burn through the whole batch. An
+// unroutable message is set aside until its next attempt is due instead: no
+// queue subscribes to its topic yet (e.g. its consumers have never started),
+// and retrying it straight away would hold up the messages behind it.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	relayed := 0
+	err := r.txManager.WithTx(ctx, func(tx *repo.TxRepositories) error {
+		msgs, err := tx.Outbox.FetchUnsent(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range msgs {
+			// The outbox ID is the message ID, so a message published again
+			// after a crash before MarkSent is a duplicate consumers can spot
+			pubCtx, cancel := context.WithTimeout(bus.WithMessageID(ctx, m.ID.String()), outboxPublishTimeout)
+			err := r.producer.Publish(pubCtx, m.Topic, m.Key, m.Payload)
+			cancel()
+			if errors.Is(err, bus.ErrUnroutable) {
+				next := time.Now().UTC().Add(unroutableRetryDelay(m.Attempts + 1))
+				r.logger.Error("outbox message unroutable, no queue subscribes to its topic",
+					zap.String("outbox_id", m.ID.String()),
+					zap.String("topic", m.Topic),
+					zap.Int("attempts", m.Attempts+1),
+					zap.Time("next_attempt_at", next),
+				)
+				if err := tx.Outbox.MarkFailed(ctx, m.ID, err.Error(), &next); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				r.logger.Warn("outbox publish failed",
+					zap.String("outbox_id", m.ID.String()),
+					zap.String("topic", m.Topic),
+					zap.Int("attempts", m.Attempts+1),
+					zap.Error(err),
+				)
+				return tx.Outbox.MarkFailed(ctx, m.ID, err.Error(), nil)
+			}
+			if err := tx.Outbox.MarkSent(ctx, m.ID); err != nil {
+				return err
+			}
+			relayed++
+		}
+		return nil
+	})
+	return relayed, err
+}
+
+// unroutableRetryDelay is the wait before retrying an unroutable message
+// that has failed attempts times.
+func unroutableRetryDelay(attempts int) time.Duration {
+	// Backoff overflows to a negative duration after enough attempts
+	if d := outboxUnroutableRetry.Backoff(attempts); d > 0 && d < outboxMaxRetryDelay {
+		return d
+	}
+	return outboxMaxRetryDelay
+}
+
+// Run relays batches until ctx is cancelled, sleeping for interval whenever
+// the outbox is empty or a pass fails.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	r.logger.Info("outbox relay started", zap.Duration("interval", interval))
+	for {
+		sent, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("outbox relay pass failed", zap.Error(err))
+		}
+		if sent > 0 {
+			r.logger.Debug("outbox messages relayed", zap.Int("count", sent))
+		}
+
+		// Keep draining without delay while full batches are coming back
+		if err == nil && sent == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("outbox relay stopped")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// newOutboxMessage marshals v as the payload of a message for topic.
+func newOutboxMessage(topic, key string, v any) (*model.OutboxMessage, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OutboxMessage{
+		ID:        uuid.New(),
+		Topic:     topic,
+		Key:       key,
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// enqueueEvent marshals v and writes it to the outbox bound to r.
+func enqueueEvent(ctx context.Context, r *repo.TxRepositories, topic, key string, v any) error {
+	msg, err := newOutboxMessage(topic, key, v)
+	if err != nil {
+		return err
+	}
+	return r.Outbox.Enqueue(ctx, msg)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+// queueOutbox keeps messages in insertion order, as FetchUnsent returns them.
+type queueOutbox struct {
+	msgs   []*model.OutboxMessage
+	sent   map[uuid.UUID]bool
+	failed map[uuid.UUID]string
+	due    map[uuid.UUID]time.Time
+}
+
+func newQueueOutbox() *queueOutbox {
+	return &queueOutbox{sent: map[uuid.UUID]bool{}, failed: map[uuid.UUID]string{}, due: map[uuid.UUID]time.Time{}}
+}
+
+func (o *queueOutbox) Enqueue(ctx context.Context, msg *model.OutboxMessage) error {
+	o.msgs = append(o.msgs, msg)
+	return nil
+}
+
+func (o *queueOutbox) FetchUnsent(ctx context.Context, limit int) ([]*model.OutboxMessage, error) {
+	var out []*model.OutboxMessage
+	for _, m := range o.msgs {
+		if len(out) == limit {
+			break
+		}
+		if due, ok := o.due[m.ID]; !o.sent[m.ID] && (!ok || !due.After(time.Now())) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (o *queueOutbox) MarkSent(ctx context.Context, id uuid.UUID) error {
+	o.sent[id] = true
+	return nil
+}
+
+func (o *queueOutbox) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error {
+	o.failed[id] = lastError
+	delete(o.due, id)
+	if nextAttemptAt != nil {
+		o.due[id] = *nextAttemptAt
+	}
+	for _, m := range o.msgs {
+		if m.ID == id {
+			m.Attempts++
+		}
+	}
+	return nil
+}
+
+// topicProducer fails publishes to the topics in errs and records the rest.
+type topicProducer struct {
+	errs      map[string]error
+	published []string
+}
+
+func (p *topicProducer) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("publish without a deadline")
+	}
+	if err := p.errs[topic]; err != nil {
+		return err
+	}
+	p.published = append(p.published, topic)
+	return nil
+}
+
+func enqueueTopics(t *testing.T, o *queueOutbox, topics ...string) {
+	t.Helper()
+	for _, topic := range topics {
+		msg, err := newOutboxMessage(topic, "", map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Enqueue(context.Background(), msg)
+	}
+}
+
+func TestRelayBatchRetriesUnroutableLater(t *testing.T) {
+	outbox := newQueueOutbox()
+	producer := &topicProducer{errs: map[string]error{
+		"transaction.captured": fmt.Errorf("%w: no queue", bus.ErrUnroutable),
+	}}
+	relay := NewOutboxRelay(&fakeTxManager{repos: &repo.TxRepositories{Outbox: outbox}}, producer, nil)
+	relay.batchSize = 2
+
+	// A full batch of unroutable messages ahead of one that has subscribers
+	enqueueTopics(t, outbox, "transaction.captured", "transaction.captured", "settlement.requested")
+
+	for pass := 0; pass < 3; pass++ {
+		if _, err := relay.RelayBatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(producer.published) != 1 || producer.published[0] != "settlement.requested" {
+		t.Fatalf("published %v, want [settlement.requested]", producer.published)
+	}
+	if len(outbox.due) != 2 {
+		t.Fatalf("%d messages set aside for later, want 2", len(outbox.due))
+	}
+	for id, due := range outbox.due {
+		if wait := time.Until(due); wait <= 0 || wait > unroutableRetryDelay(1) {
+			t.Errorf("message %s retried in %s, want within %s", id, wait, unroutableRetryDelay(1))
+		}
+	}
+
+	// Once a queue subscribes and the retry is due, the messages go out
+	delete(producer.errs, "transaction.captured")
+	for id := range outbox.due {
+		outbox.due[id] = time.Now()
+	}
+	relayed, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relayed != 2 || len(producer.published) != 3 {
+		t.Fatalf("relayed %d, published %v; want both unroutable messages sent", relayed, producer.published)
+	}
+	if left, _ := outbox.FetchUnsent(context.Background(), 10); len(left) != 0 {
+		t.Fatalf("%d messages still unsent", len(left))
+	}
+}
+
+func TestUnroutableRetryDelayIsCapped(t *testing.T) {
+	prev := time.Duration(0)
+	for attempts := 1; attempts <= 100; attempts++ {
+		d := unroutableRetryDelay(attempts)
+		if d < prev || d > outboxMaxRetryDelay {
+			t.Fatalf("attempt %d waits %s after %s, want growing up to %s", attempts, d, prev, outboxMaxRetryDelay)
+		}
+		prev = d
+	}
+	if prev != outboxMaxRetryDelay {
+		t.Fatalf("delay settles at %s, want %s", prev, outboxMaxRetryDelay)
+	}
+}
+
+func TestRelayBatchStopsAtPublishFailure(t *testing.T) {
+	outbox := newQueueOutbox()
+	producer := &topicProducer{errs: map[string]error{"payout.created": errors.New("connection reset")}}
+	relay := NewOutboxRelay(&fakeTxManager{repos: &repo.TxRepositories{Outbox: outbox}}, producer, nil)
+
+	enqueueTopics(t, outbox, "settlement.requested", "payout.created", "settlement.requested")
+
+	relayed, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relayed != 1 {
+		t.Fatalf("relayed %d, want 1", relayed)
+	}
+	if len(outbox.failed) != 1 || len(outbox.due) != 0 {
+		t.Fatalf("failed %d, set aside %d; want 1 and 0", len(outbox.failed), len(outbox.due))
+	}
+	if left, _ := outbox.FetchUnsent(context.Background(), 10); len(left) != 2 {
+		t.Fatalf("%d messages still unsent, want 2", len(left))
+	}
+}
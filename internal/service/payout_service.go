@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var ErrPayoutNotFound = errors.New("payout not found")
+
+// payoutResendBatch bounds how many pending payouts one run re-sends.
+const payoutResendBatch = 100
+
+// PayoutService pays merchant balances out to their bank accounts. A run
+// takes each merchant account that is due under its payout schedule, moves
+// its whole balance to the payout clearing account and sends a payout
+// through the connector. Payouts whose outcome is unknown stay pending and
+// are re-sent (or polled) on the next run with the same reference.
+type PayoutService struct {
+	payouts    repo.PayoutRepository
+	txManager  repo.TxManager
+	ledger     *LedgerService
+	connectors *connector.Registry
+	connector  string
+	logger     *zap.Logger
+}
+
+// NewPayoutService creates a payout service sending payouts through the
+// named connector; an empty name uses the registry default.
+func NewPayoutService(
+	payoutRepo repo.PayoutRepository,
+	txManager repo.TxManager,
+	ledger *LedgerService,
+	connectors *connector.Registry,
+	connectorName string,
+	logger *zap.Logger,
+) *PayoutService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if ledger == nil {
+		ledger = NewLedgerService(nil, logger)
+	}
+	if connectors == nil {
+		connectors = connector.NewRegistry()
+	}
+	if _, err := connectors.Default(); err != nil {
+		connectors.Register(connector.NewSandboxConnector(connector.DefaultSandboxConfig()))
+	}
+	return &PayoutService{
+		payouts:    payoutRepo,
+		txManager:  txManager,
+		ledger:     ledger,
+		connectors: connectors,
+		connector:  connectorName,
+		logger:     logger,
+	}
+}
+
+// PayoutRunSummary counts what a run did. Pending includes payouts created
+// in the run whose outcome is not known yet.
+type PayoutRunSummary struct {
+	Created int
+	Paid    int
+	Failed  int
+	Pending int
+	Errors  int
+}
+
+// RunOnce re-sends payouts left pending by earlier runs, then creates and
+// sends a payout for every merchant account that is due at now. A failure
+// for one account is logged and counted without stopping the run.
+func (s *PayoutService) RunOnce(ctx context.Context, now time.Time) (*PayoutRunSummary, error) {
+	summary := &PayoutRunSummary{}
+
+	pending, err := s.payouts.ListPending(ctx, now, payoutResendBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending payouts: %w", err)
+	}
+	for _, p := range pending {
+		s.send(ctx, p, summary)
+	}
+
+	accounts, err := s.payouts.ListPayable(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payable accounts: %w", err)
+	}
+	for _, a := range accounts {
+		if !a.Policy.Due(now, a.LastPayoutAt, a.Balance) {
+			continue
+		}
+		if a.BankDetails.Empty() {
+			s.logger.Warn("merchant has no bank details, skipping payout",
+				zap.String("merchant_id", a.MerchantID.String()),
+				zap.String("account_id", a.AccountID.String()),
+			)
+			continue
+		}
+
+		p, err := s.create(ctx, a, now)
+		if err != nil {
+			s.logger.Error("failed to create payout", zap.String("account_id", a.AccountID.String()), zap.Error(err))
+			summary.Errors++
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		summary.Created++
+		s.send(ctx, p, summary)
+	}
+
+	return summary, nil
+}
+
+// create debits the account's balance into payout clearing and records the
+// payout with the settlements it covers. The balance row is locked and the
+// schedule re-checked first, so concurrent runs can't pay the same balance
+// twice. It returns nil if the account is no longer due.
+func (s *PayoutService) create(ctx context.Context, a *model.PayableAccount, now time.Time) (*model.Payout, error) {
+	var payout *model.Payout
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		balance, err := r.Ledger.LockBalance(ctx, a.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to lock balance: %w", err)
+		}
+		last, err := r.Payouts.LastCreatedAt(ctx, a.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch last payout: %w", err)
+		}
+		if !a.Policy.Due(now, last, balance) {
+			return nil
+		}
+
+		p := &model.Payout{
+			ID:                uuid.New(),
+			MerchantID:        a.MerchantID,
+			MerchantAccountID: a.AccountID,
+			Amount:            balance,
+			Currency:          a.Currency,
+			Status:            model.PayoutPending,
+			Schedule:          a.Policy.Schedule,
+			BankDetails:       a.BankDetails,
+			Metadata:          map[string]any{},
+			CreatedAt:         now.UTC(),
+			UpdatedAt:         now.UTC(),
+		}
+		if err := r.Payouts.Create(ctx, p); err != nil {
+			return fmt.Errorf("failed to create payout: %w", err)
+		}
+		if err := s.ledger.PostPayout(ctx, r, p); err != nil {
+			return err
+		}
+		p.SettlementCount, err = r.Payouts.AssignSettlements(ctx, p.ID, a.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to batch settlements: %w", err)
+		}
+
+		payout = p
+		return enqueueEvent(ctx, r, "payout.created", p.MerchantID.String(), payoutEvent(p))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if payout != nil {
+		s.logger.Info("payout created",
+			zap.String("payout_id", payout.ID.String()),
+			zap.String("merchant_id", payout.MerchantID.String()),
+			zap.Int64("amount", payout.Amount),
+			zap.String("currency", payout.Currency),
+			zap.Int("settlements", payout.SettlementCount),
+		)
+	}
+	return payout, nil
+}
+
+// send submits a pending payout, or polls the provider when it has already
+// accepted the payout for processing, and applies the outcome.
+func (s *PayoutService) send(ctx context.Context, p *model.Payout, summary *PayoutRunSummary) {
+	conn, err := s.resolveConnector(p)
+	if err != nil {
+		s.logger.Error("no connector for payout", zap.String("payout_id", p.ID.String()), zap.Error(err))
+		summary.Errors++
+		return
+	}
+
+	var resp *connector.Response
+	if p.ExternalID != "" {
+		resp, err = conn.Status(ctx, p.ExternalID)
+	} else {
+		resp, err = conn.Payout(ctx, &connector.Request{
+			Reference:  p.ID.String(),
+			MerchantID: p.MerchantID,
+			Amount:     p.Amount,
+			Currency:   p.Currency,
+			Metadata: map[string]any{
+				"payout_id":    p.ID.String(),
+				"bank_details": p.BankDetails,
+			},
+		})
+	}
+	if err != nil {
+		// Outcome unknown: keep it pending and try again next run
+		s.logger.Warn("connector payout failed",
+			zap.String("connector", conn.Name()),
+			zap.String("payout_id", p.ID.String()),
+			zap.Error(err),
+		)
+		if err := s.payouts.SetConnector(ctx, p.ID, conn.Name(), ""); err != nil {
+			s.logger.Error("failed to record payout connector", zap.String("payout_id", p.ID.String()), zap.Error(err))
+		}
+		summary.Pending++
+		return
+	}
+
+	resultMetadata := connectorMetadata(conn, []*connector.Response{resp}, nil)
+	switch {
+	case resp.Approved():
+		err = s.complete(ctx, p, conn.Name(), resp.ExternalID, resultMetadata)
+		if err == nil {
+			summary.Paid++
+		}
+	case resp.Status == connector.ResultPending:
+		err = s.payouts.SetConnector(ctx, p.ID, conn.Name(), resp.ExternalID)
+		if err == nil {
+			summary.Pending++
+		}
+	default:
+		reason := resp.Message
+		if reason == "" {
+			reason = resp.Code
+		}
+		err = s.fail(ctx, p, conn.Name(), reason, resultMetadata)
+		if err == nil {
+			summary.Failed++
+		}
+	}
+	if err != nil {
+		s.logger.Error("failed to record payout outcome", zap.String("payout_id", p.ID.String()), zap.Error(err))
+		summary.Errors++
+	}
+}
+
+// complete marks the payout paid and emits payout.paid.
+func (s *PayoutService) complete(ctx context.Context, p *model.Payout, connectorName, externalID string, resultMetadata map[string]any) error {
+	return s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		if err := r.Payouts.SetConnector(ctx, p.ID, connectorName, externalID); err != nil {
+			return err
+		}
+		paidAt := time.Now().UTC()
+		ok, err := r.Payouts.MarkPaid(ctx, p.ID, externalID, paidAt, resultMetadata)
+		if err != nil || !ok {
+			return err
+		}
+		p.Status, p.PaidAt, p.ExternalID = model.PayoutPaid, &paidAt, externalID
+
+		s.logger.Info("payout paid", zap.String("payout_id", p.ID.String()), zap.String("external_id", externalID))
+		return enqueueEvent(ctx, r, "payout.paid", p.MerchantID.String(), payoutEvent(p))
+	})
+}
+
+// fail records a decline, returns the amount to the merchant account and
+// emits payout.failed. The payout's settlements are released for the next
+// payout.
+func (s *PayoutService) fail(ctx context.Context, p *model.Payout, connectorName, reason string, resultMetadata map[string]any) error {
+	return s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		if err := r.Payouts.SetConnector(ctx, p.ID, connectorName, ""); err != nil {
+			return err
+		}
+		ok, err := r.Payouts.MarkFailed(ctx, p.ID, reason, resultMetadata)
+		if err != nil || !ok {
+			return err
+		}
+		if err := s.ledger.ReversePayout(ctx, r, p, reason); err != nil {
+			return err
+		}
+		p.Status, p.FailureReason = model.PayoutFailed, reason
+
+		s.logger.Warn("payout declined", zap.String("payout_id", p.ID.String()), zap.String("reason", reason))
+		return enqueueEvent(ctx, r, "payout.failed", p.MerchantID.String(), payoutEvent(p))
+	})
+}
+
+// resolveConnector returns the connector a payout was first sent through,
+// so retries and polls go to the same provider, or the configured one.
+func (s *PayoutService) resolveConnector(p *model.Payout) (connector.Connector, error) {
+	name := p.Connector
+	if name == "" {
+		name = s.connector
+	}
+	if name != "" {
+		return s.connectors.Get(name)
+	}
+	return s.connectors.Default()
+}
+
+func (s *PayoutService) ListPayouts(ctx context.Context, filter model.PayoutFilter) ([]*model.Payout, error) {
+	return s.payouts.List(ctx, filter)
+}
+
+func (s *PayoutService) GetPayout(ctx context.Context, id uuid.UUID) (*model.Payout, error) {
+	p, err := s.payouts.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPayoutNotFound
+	}
+	return p, nil
+}
+
+func payoutEvent(p *model.Payout) map[string]any {
+	return map[string]any{
+		"payout_id":        p.ID.String(),
+		"merchant_id":      p.MerchantID.String(),
+		"amount":           p.Amount,
+		"currency":         p.Currency,
+		"status":           string(p.Status),
+		"settlement_count": p.SettlementCount,
+		"external_id":      p.ExternalID,
+		"failure_reason":   p.FailureReason,
+		"paid_at":          p.PaidAt,
+	}
+}
@@ -0,0 +1,473 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrReconciliationNotFound = errors.New("reconciliation case not found")
+	ErrReconciliationClosed   = errors.New("reconciliation case is not open")
+	ErrInvalidReconciliation  = errors.New("invalid reconciliation request")
+)
+
+// auditEntityReconciliation is the audit_logs entity for case actions.
+const auditEntityReconciliation = "reconciliation_logs"
+
+// ReconciliationCaseService lets operators work reconciliation exceptions:
+// mismatch and unmatched rows are open cases that can be assigned,
+// annotated and resolved by a write-off, a correcting ledger entry or a
+// linked refund. Every action is written to audit_logs with its actor.
+type ReconciliationCaseService struct {
+	logs       repo.ReconciliationRepository
+	audits     repo.AuditRepository
+	txManager  repo.TxManager
+	ledger     *LedgerService
+	currencies *model.CurrencyRegistry
+	logger     *zap.Logger
+}
+
+func NewReconciliationCaseService(
+	logRepo repo.ReconciliationRepository,
+	auditRepo repo.AuditRepository,
+	txManager repo.TxManager,
+	ledger *LedgerService,
+	currencies *model.CurrencyRegistry,
+	logger *zap.Logger,
+) *ReconciliationCaseService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if ledger == nil {
+		ledger = NewLedgerService(nil, logger)
+	}
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	return &ReconciliationCaseService{
+		logs:       logRepo,
+		audits:     auditRepo,
+		txManager:  txManager,
+		ledger:     ledger,
+		currencies: currencies,
+		logger:     logger,
+	}
+}
+
+func (s *ReconciliationCaseService) ListCases(ctx context.Context, filter model.ReconciliationFilter) ([]*model.ReconciliationLogs, error) {
+	return s.logs.List(ctx, filter)
+}
+
+// GetCase returns a case with its notes and audit trail.
+func (s *ReconciliationCaseService) GetCase(ctx context.Context, id uuid.UUID) (*model.ReconciliationCase, error) {
+	l, err := s.logs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, ErrReconciliationNotFound
+	}
+	notes, err := s.logs.ListNotes(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	audit, err := s.audits.ListByEntity(ctx, auditEntityReconciliation, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit trail: %w", err)
+	}
+	if notes == nil {
+		notes = []*model.ReconciliationNote{}
+	}
+	if audit == nil {
+		audit = []*model.AuditLogs{}
+	}
+	return &model.ReconciliationCase{ReconciliationLogs: l, Notes: notes, Audit: audit}, nil
+}
+
+// Assign hands an open case to assignee, or unassigns it when assignee is
+// empty.
+func (s *ReconciliationCaseService) Assign(ctx context.Context, id uuid.UUID, actor string, input dto.AssignReconciliationDTO) (*model.ReconciliationLogs, error) {
+	assignee := strings.TrimSpace(input.Assignee)
+	if len(assignee) > 255 {
+		return nil, fmt.Errorf("%w: assignee longer than 255 characters", ErrInvalidReconciliation)
+	}
+
+	var updated *model.ReconciliationLogs
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		l, err := s.lockOpen(ctx, r, id)
+		if err != nil {
+			return err
+		}
+		if _, err := r.Reconciliation.Assign(ctx, id, assignee, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to assign case: %w", err)
+		}
+		if err := s.audit(ctx, r, id, actor, "reconciliation.assigned", map[string]any{
+			"assignee": assignee,
+			"previous": l.AssignedTo,
+		}); err != nil {
+			return err
+		}
+		updated, err = r.Reconciliation.GetByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("reconciliation case assigned",
+		zap.String("reconciliation_id", id.String()),
+		zap.String("assignee", assignee),
+		zap.String("actor", actor),
+	)
+	return updated, nil
+}
+
+// AddNote records a note on a case, open or not.
+func (s *ReconciliationCaseService) AddNote(ctx context.Context, id uuid.UUID, actor string, input dto.ReconciliationNoteDTO) (*model.ReconciliationNote, error) {
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: note body is required", ErrInvalidReconciliation)
+	}
+
+	note := &model.ReconciliationNote{
+		ID:               uuid.New(),
+		ReconciliationID: id,
+		Author:           actor,
+		Body:             body,
+		CreatedAt:        time.Now().UTC(),
+	}
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		l, err := r.Reconciliation.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			return ErrReconciliationNotFound
+		}
+		if err := r.Reconciliation.AddNote(ctx, note); err != nil {
+			return fmt.Errorf("failed to add note: %w", err)
+		}
+		return s.audit(ctx, r, id, actor, "reconciliation.note_added", map[string]any{"note_id": note.ID.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Resolve closes an open case:
+//   - write_off books the difference (or amount) to the write-off account
+//   - adjustment posts amount between the given debit and credit accounts
+//   - refund links an existing refund of the case's transaction
+//
+// Ledger postings, the status change and the audit row commit together.
+func (s *ReconciliationCaseService) Resolve(ctx context.Context, id uuid.UUID, actor string, input dto.ResolveReconciliationDTO) (*model.ReconciliationLogs, error) {
+	if !input.Resolution.Valid() {
+		return nil, fmt.Errorf("%w: resolution must be write_off, adjustment or refund", ErrInvalidReconciliation)
+	}
+	if strings.TrimSpace(input.Reason) == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidReconciliation)
+	}
+	if input.Amount < 0 {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidReconciliation)
+	}
+
+	var updated *model.ReconciliationLogs
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		l, err := s.lockOpen(ctx, r, id)
+		if err != nil {
+			return err
+		}
+
+		details := map[string]any{
+			"type":   string(input.Resolution),
+			"reason": input.Reason,
+		}
+		switch input.Resolution {
+		case model.ReconWriteOff:
+			err = s.writeOff(ctx, r, l, input, details)
+		case model.ReconAdjustment:
+			err = s.adjust(ctx, r, l, input, details)
+		case model.ReconRefund:
+			err = s.linkRefund(ctx, r, l, input, details)
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		ok, err := r.Reconciliation.Resolve(ctx, id, input.Resolution, actor, now, map[string]any{"resolution": details})
+		if err != nil {
+			return fmt.Errorf("failed to resolve case: %w", err)
+		}
+		if !ok {
+			return ErrReconciliationClosed
+		}
+		if err := s.audit(ctx, r, id, actor, "reconciliation.resolved", details); err != nil {
+			return err
+		}
+		updated, err = r.Reconciliation.GetByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("reconciliation case resolved",
+		zap.String("reconciliation_id", id.String()),
+		zap.String("resolution", string(input.Resolution)),
+		zap.String("actor", actor),
+	)
+	return updated, nil
+}
+
+func (s *ReconciliationCaseService) writeOff(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	l *model.ReconciliationLogs,
+	input dto.ResolveReconciliationDTO,
+	details map[string]any,
+) error {
+	currency, transactionID, err := s.caseCurrency(ctx, r, l, input.Currency)
+	if err != nil {
+		return err
+	}
+	if currency == "" {
+		return fmt.Errorf("%w: currency is required for this case", ErrInvalidReconciliation)
+	}
+
+	// A positive difference is money expected but not received
+	diff := l.ExpectedAmount - l.ActualAmount
+	amount := input.Amount
+	if amount == 0 {
+		amount = diff
+		if amount < 0 {
+			amount = -amount
+		}
+	}
+	if amount == 0 {
+		return fmt.Errorf("%w: the case has no difference to write off", ErrInvalidReconciliation)
+	}
+
+	money := model.NewMoney(amount, currency)
+	details["amount"] = money.Amount
+	details["currency"] = money.Currency
+	details["shortfall"] = diff >= 0
+	err = s.ledger.PostWriteOff(ctx, r, money, diff >= 0, transactionID, map[string]any{
+		"reconciliation_id": l.ID.String(),
+		"reason":            input.Reason,
+	})
+	if errors.Is(err, ErrInvalidPosting) {
+		return fmt.Errorf("%w: %v", ErrInvalidReconciliation, err)
+	}
+	return err
+}
+
+func (s *ReconciliationCaseService) adjust(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	l *model.ReconciliationLogs,
+	input dto.ResolveReconciliationDTO,
+	details map[string]any,
+) error {
+	if input.DebitAccountID == nil || input.CreditAccountID == nil {
+		return fmt.Errorf("%w: debit_account_id and credit_account_id are required", ErrInvalidReconciliation)
+	}
+	// Without a currency from the case or the request, the accounts' is used
+	currency, transactionID, err := s.caseCurrency(ctx, r, l, input.Currency)
+	if err != nil {
+		return err
+	}
+	amount := input.Amount
+	if amount == 0 {
+		amount = l.ExpectedAmount - l.ActualAmount
+		if amount < 0 {
+			amount = -amount
+		}
+	}
+
+	for _, accountID := range []uuid.UUID{*input.DebitAccountID, *input.CreditAccountID} {
+		account, err := r.Accounts.GetByID(ctx, accountID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch account: %w", err)
+		}
+		if account == nil {
+			return fmt.Errorf("%w: account %s not found", ErrInvalidReconciliation, accountID)
+		}
+		if currency == "" {
+			currency = account.Currency
+		}
+		if account.Currency != currency {
+			return fmt.Errorf("%w: account %s is in %s, not %s", ErrInvalidReconciliation, accountID, account.Currency, currency)
+		}
+	}
+
+	details["amount"] = amount
+	details["currency"] = currency
+	details["debit_account_id"] = input.DebitAccountID.String()
+	details["credit_account_id"] = input.CreditAccountID.String()
+	err = s.ledger.Post(ctx, r, Posting{
+		DebitAccountID:  *input.DebitAccountID,
+		CreditAccountID: *input.CreditAccountID,
+		Amount:          amount,
+		Currency:        currency,
+		TransactionID:   transactionID,
+		Description:     "reconciliation adjustment",
+		Metadata: map[string]any{
+			"reconciliation_id": l.ID.String(),
+			"reason":            input.Reason,
+		},
+	})
+	if errors.Is(err, ErrInvalidPosting) {
+		return fmt.Errorf("%w: %v", ErrInvalidReconciliation, err)
+	}
+	return err
+}
+
+func (s *ReconciliationCaseService) linkRefund(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	l *model.ReconciliationLogs,
+	input dto.ResolveReconciliationDTO,
+	details map[string]any,
+) error {
+	if input.RefundID == nil {
+		return fmt.Errorf("%w: refund_id is required", ErrInvalidReconciliation)
+	}
+	// Locking the refund keeps two cases from claiming it at once
+	refund, err := r.Refunds.GetByIDForUpdate(ctx, *input.RefundID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch refund: %w", err)
+	}
+	if refund == nil {
+		return fmt.Errorf("%w: refund %s not found", ErrInvalidReconciliation, *input.RefundID)
+	}
+	if refund.Status != model.RefundCompleted {
+		return fmt.Errorf("%w: refund %s is %s, not completed", ErrInvalidReconciliation, refund.ID, refund.Status)
+	}
+	linked, err := r.Reconciliation.CaseForRefund(ctx, refund.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check refund links: %w", err)
+	}
+	if linked != uuid.Nil {
+		return fmt.Errorf("%w: refund %s already resolves case %s", ErrInvalidReconciliation, refund.ID, linked)
+	}
+	_, transactionID, err := caseContext(ctx, r, l)
+	if err != nil {
+		return err
+	}
+	if transactionID != uuid.Nil && refund.TransactionID != transactionID {
+		return fmt.Errorf("%w: refund %s belongs to another transaction", ErrInvalidReconciliation, refund.ID)
+	}
+
+	details["refund_id"] = refund.ID.String()
+	details["amount"] = refund.Amount
+	details["currency"] = refund.Currency
+	return nil
+}
+
+// caseCurrency returns the currency a write-off or adjustment is booked in
+// with the case's transaction. A requested currency must match the case's,
+// and either must be known to the registry; it is "" when neither is set.
+func (s *ReconciliationCaseService) caseCurrency(
+	ctx context.Context,
+	r *repo.TxRepositories,
+	l *model.ReconciliationLogs,
+	requested string,
+) (string, uuid.UUID, error) {
+	currency, transactionID, err := caseContext(ctx, r, l)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+	if requested != "" {
+		requested = strings.ToUpper(requested)
+		if currency != "" && !strings.EqualFold(currency, requested) {
+			return "", uuid.Nil, fmt.Errorf("%w: the case is in %s, not %s", ErrInvalidReconciliation, strings.ToUpper(currency), requested)
+		}
+		currency = requested
+	}
+	if currency == "" {
+		return "", transactionID, nil
+	}
+	// Known but disabled currencies are fine: the case may predate disabling
+	c, err := s.currencies.Get(currency)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("%w: %v", ErrInvalidReconciliation, err)
+	}
+	return c.Code, transactionID, nil
+}
+
+// lockOpen locks a case and checks it is still open.
+func (s *ReconciliationCaseService) lockOpen(ctx context.Context, r *repo.TxRepositories, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	l, err := r.Reconciliation.GetByIDForUpdate(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch case: %w", err)
+	}
+	if l == nil {
+		return nil, ErrReconciliationNotFound
+	}
+	if l.Status != model.ReconMismatch && l.Status != model.ReconUnmatched {
+		return nil, fmt.Errorf("%w: status is %s", ErrReconciliationClosed, l.Status)
+	}
+	return l, nil
+}
+
+func (s *ReconciliationCaseService) audit(ctx context.Context, r *repo.TxRepositories, id uuid.UUID, actor, action string, details map[string]any) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	actorID, _ := uuid.Parse(actor)
+	if err := r.Audit.Create(ctx, &model.AuditLogs{
+		ID:        uuid.New(),
+		ActorID:   actorID,
+		Actor:     actor,
+		Action:    action,
+		Entity:    auditEntityReconciliation,
+		EntityID:  id,
+		Details:   string(detailsJSON),
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// caseContext works out the currency and transaction a case is about from
+// the entity it points to, or from the statement line for unmatched lines.
+func caseContext(ctx context.Context, r *repo.TxRepositories, l *model.ReconciliationLogs) (string, uuid.UUID, error) {
+	switch {
+	case l.EntityType == model.ReconEntitySettlement && l.EntityID != uuid.Nil:
+		settlement, err := r.Settlements.GetByID(ctx, l.EntityID.String())
+		if err != nil {
+			return "", uuid.Nil, fmt.Errorf("failed to fetch settlement: %w", err)
+		}
+		if settlement != nil {
+			transactionID, _ := uuid.Parse(settlement.ExternalReference)
+			return settlement.Currency, transactionID, nil
+		}
+	case l.EntityType == model.ReconEntityTransaction && l.EntityID != uuid.Nil:
+		tx, err := r.Transactions.GetByID(ctx, l.EntityID)
+		if err != nil {
+			return "", uuid.Nil, fmt.Errorf("failed to fetch transaction: %w", err)
+		}
+		if tx != nil {
+			return tx.Currency, tx.ID, nil
+		}
+	}
+	if line, ok := l.Metadata["statement_line"].(map[string]any); ok {
+		currency, _ := line["currency"].(string)
+		return currency, uuid.Nil, nil
+	}
+	return "", uuid.Nil, nil
+}
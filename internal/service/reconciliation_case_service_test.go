@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/google/uuid"
+)
+
+type memReconciliation struct {
+	repo.ReconciliationRepository
+	cases map[uuid.UUID]*model.ReconciliationLogs
+}
+
+func (m *memReconciliation) GetByID(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	if l, ok := m.cases[id]; ok {
+		cp := *l
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (m *memReconciliation) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.ReconciliationLogs, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *memReconciliation) Resolve(ctx context.Context, id uuid.UUID, resolution model.ReconResolution, resolvedBy string, at time.Time, metadata map[string]any) (bool, error) {
+	l := m.cases[id]
+	if l.Status != model.ReconMismatch && l.Status != model.ReconUnmatched {
+		return false, nil
+	}
+	l.Status = model.ReconResolved
+	l.Resolution = resolution
+	l.ResolvedBy = resolvedBy
+	l.ResolvedAt = &at
+	for k, v := range metadata {
+		l.Metadata[k] = v
+	}
+	return true, nil
+}
+
+func (m *memReconciliation) CaseForRefund(ctx context.Context, refundID uuid.UUID) (uuid.UUID, error) {
+	for id, l := range m.cases {
+		resolution, _ := l.Metadata["resolution"].(map[string]any)
+		if l.Status == model.ReconResolved && resolution["refund_id"] == refundID.String() {
+			return id, nil
+		}
+	}
+	return uuid.Nil, nil
+}
+
+type memAudit struct {
+	repo.AuditRepository
+	logs []*model.AuditLogs
+}
+
+func (m *memAudit) Create(ctx context.Context, l *model.AuditLogs) error {
+	m.logs = append(m.logs, l)
+	return nil
+}
+
+type caseFixture struct {
+	svc      *ReconciliationCaseService
+	tx       *model.Transaction
+	cases    *memReconciliation
+	refunds  *memRefunds
+	accounts *memAccounts
+	ledger   *memLedger
+	audit    *memAudit
+}
+
+// newCaseFixture sets up a completed NGN transaction with no cases yet.
+func newCaseFixture() *caseFixture {
+	tx := &model.Transaction{
+		ID:         uuid.New(),
+		Amount:     10000,
+		Currency:   "NGN",
+		MerchantID: uuid.New(),
+		Status:     model.TransactionStatusCompleted,
+	}
+	f := &caseFixture{
+		tx:       tx,
+		cases:    &memReconciliation{cases: map[uuid.UUID]*model.ReconciliationLogs{}},
+		refunds:  &memRefunds{},
+		accounts: &memAccounts{accounts: map[string]*model.Accounts{}},
+		ledger:   newMemLedger(),
+		audit:    &memAudit{},
+	}
+	txManager := &fakeTxManager{repos: &repo.TxRepositories{
+		Transactions:   &memTransactions{txs: map[uuid.UUID]*model.Transaction{tx.ID: tx}},
+		Refunds:        f.refunds,
+		Accounts:       f.accounts,
+		Ledger:         f.ledger,
+		Reconciliation: f.cases,
+		Audit:          f.audit,
+	}}
+	f.svc = NewReconciliationCaseService(f.cases, f.audit, txManager, nil, nil, nil)
+	return f
+}
+
+// openCase opens a mismatch case on the transaction.
+func (f *caseFixture) openCase(expected, actual int64) uuid.UUID {
+	l := &model.ReconciliationLogs{
+		ID:             uuid.New(),
+		EntityType:     model.ReconEntityTransaction,
+		EntityID:       f.tx.ID,
+		ExpectedAmount: expected,
+		ActualAmount:   actual,
+		Status:         model.ReconMismatch,
+		Metadata:       map[string]any{},
+	}
+	f.cases.cases[l.ID] = l
+	return l.ID
+}
+
+func (f *caseFixture) balance(ownerID uuid.UUID) int64 {
+	return f.ledger.balances[f.accounts.get(ownerID, model.AccountTypeSystem, "NGN").ID]
+}
+
+func TestResolveWriteOff(t *testing.T) {
+	cases := map[string]struct {
+		expected, actual int64
+		writeOff         int64 // balance moved on the write-off account
+	}{
+		"shortfall": {expected: 10000, actual: 9000, writeOff: -1000},
+		"excess":    {expected: 10000, actual: 10250, writeOff: 250},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := newCaseFixture()
+			id := f.openCase(c.expected, c.actual)
+
+			l, err := f.svc.Resolve(context.Background(), id, "ops", dto.ResolveReconciliationDTO{
+				Resolution: model.ReconWriteOff,
+				Reason:     "bank fee",
+				Currency:   "ngn",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.Status != model.ReconResolved || l.Resolution != model.ReconWriteOff || l.ResolvedBy != "ops" {
+				t.Fatalf("case is %s/%s by %q, want resolved write_off by ops", l.Status, l.Resolution, l.ResolvedBy)
+			}
+			if got := f.balance(model.SystemWriteOffOwnerID); got != c.writeOff {
+				t.Errorf("write-off balance moved %d, want %d", got, c.writeOff)
+			}
+			if got := f.balance(model.SystemClearingOwnerID); got != -c.writeOff {
+				t.Errorf("clearing balance moved %d, want %d", got, -c.writeOff)
+			}
+			if len(f.audit.logs) != 1 || f.audit.logs[0].Action != "reconciliation.resolved" {
+				t.Fatalf("%d audit rows, want reconciliation.resolved", len(f.audit.logs))
+			}
+		})
+	}
+}
+
+func TestResolveRejectsCurrencyMismatch(t *testing.T) {
+	debit, credit := uuid.New(), uuid.New()
+	for _, input := range []dto.ResolveReconciliationDTO{
+		{Resolution: model.ReconWriteOff, Reason: "fx", Currency: "USD"},
+		{Resolution: model.ReconAdjustment, Reason: "fx", Currency: "usd", Amount: 100, DebitAccountID: &debit, CreditAccountID: &credit},
+	} {
+		f := newCaseFixture()
+		id := f.openCase(10000, 9000)
+
+		if _, err := f.svc.Resolve(context.Background(), id, "ops", input); !errors.Is(err, ErrInvalidReconciliation) {
+			t.Fatalf("%s in USD on an NGN case: got %v, want ErrInvalidReconciliation", input.Resolution, err)
+		}
+		if len(f.ledger.entries) != 0 || f.cases.cases[id].Status != model.ReconMismatch {
+			t.Fatalf("%s in USD touched the ledger or closed the case", input.Resolution)
+		}
+	}
+}
+
+func TestResolveRejectsClosedCase(t *testing.T) {
+	f := newCaseFixture()
+	id := f.openCase(10000, 9000)
+	input := dto.ResolveReconciliationDTO{Resolution: model.ReconWriteOff, Reason: "bank fee"}
+
+	if _, err := f.svc.Resolve(context.Background(), id, "ops", input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.Resolve(context.Background(), id, "ops", input); !errors.Is(err, ErrReconciliationClosed) {
+		t.Fatalf("second resolve: got %v, want ErrReconciliationClosed", err)
+	}
+	if len(f.ledger.entries) != 2 {
+		t.Fatalf("wrote %d entries, want the first write-off's 2", len(f.ledger.entries))
+	}
+}
+
+func TestResolveLinksCompletedRefundOnce(t *testing.T) {
+	f := newCaseFixture()
+	refund := &model.Refund{ID: uuid.New(), TransactionID: f.tx.ID, Amount: 1000, Currency: "NGN", Status: model.RefundPending}
+	f.refunds.Create(context.Background(), refund)
+	link := dto.ResolveReconciliationDTO{Resolution: model.ReconRefund, Reason: "refunded", RefundID: &refund.ID}
+
+	first := f.openCase(10000, 9000)
+	if _, err := f.svc.Resolve(context.Background(), first, "ops", link); !errors.Is(err, ErrInvalidReconciliation) {
+		t.Fatalf("linking a pending refund: got %v, want ErrInvalidReconciliation", err)
+	}
+
+	f.refunds.UpdateStatus(context.Background(), refund.ID, model.RefundCompleted)
+	l, err := f.svc.Resolve(context.Background(), first, "ops", link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Resolution != model.ReconRefund || len(f.ledger.entries) != 0 {
+		t.Fatalf("case resolved by %s with %d ledger entries, want refund and none", l.Resolution, len(f.ledger.entries))
+	}
+
+	second := f.openCase(10000, 9000)
+	if _, err := f.svc.Resolve(context.Background(), second, "ops", link); !errors.Is(err, ErrInvalidReconciliation) {
+		t.Fatalf("linking the refund again: got %v, want ErrInvalidReconciliation", err)
+	}
+	if f.cases.cases[second].Status != model.ReconMismatch {
+		t.Fatal("second case was closed")
+	}
+}
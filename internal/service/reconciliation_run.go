@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultReconChunkSize is how many transactions a run checks per query.
+const DefaultReconChunkSize = 500
+
+// reconRunLag keeps a run behind transactions updated in the last few
+// minutes, whose settlements may not be written yet, and behind commits
+// still in flight with an earlier updated_at.
+const reconRunLag = 15 * time.Minute
+
+// RunTransactionCheck compares finished transactions with the sum of their
+// successful settlements, starting after the high-water mark of the previous
+// run and walking (updated_at, id) in chunks of chunkSize. Each mismatch is
+// written to reconciliation_logs once: re-checking a transaction whose
+// amounts have not changed writes nothing.
+//
+// A failed log insert is counted and the run carries on, but the
+// high-water mark stops at the last transaction before the failure so the
+// next run picks the rest up again. The run is checkpointed after every
+// chunk; a run that dies is marked interrupted by the next one, which
+// resumes from its last checkpoint.
+func (s *ReconciliationService) RunTransactionCheck(ctx context.Context, chunkSize int) (*model.ReconciliationRun, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultReconChunkSize
+	}
+
+	stale, err := s.runs.InterruptRunning(ctx, model.ReconRunTransactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close earlier runs: %w", err)
+	}
+	if stale > 0 {
+		s.logger.Warn("earlier reconciliation runs did not finish", zap.Int("runs", stale))
+	}
+
+	from, err := s.runs.LastHighWater(ctx, model.ReconRunTransactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load high-water mark: %w", err)
+	}
+	run := &model.ReconciliationRun{
+		ID:         uuid.New(),
+		Kind:       model.ReconRunTransactions,
+		Status:     model.ReconRunRunning,
+		From:       from,
+		HighWater:  from,
+		Currencies: make(map[string]*model.ReconCurrencySummary),
+		StartedAt:  time.Now().UTC(),
+	}
+	if err := s.runs.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record run: %w", err)
+	}
+
+	s.logger.Info("reconciliation run started",
+		zap.String("run_id", run.ID.String()),
+		zap.Time("from_updated_at", from.UpdatedAt),
+		zap.String("from_id", from.ID.String()),
+	)
+
+	before := run.StartedAt.Add(-reconRunLag)
+	cursor := from
+	advancing := true
+	for {
+		totals, err := s.logs.ListTransactionTotals(ctx, cursor, before, chunkSize)
+		if err != nil {
+			return run, s.finishRun(ctx, run, fmt.Errorf("failed to list transactions: %w", err))
+		}
+
+		for _, t := range totals {
+			if err := s.checkTransaction(ctx, run, t); err != nil {
+				run.Errors++
+				advancing = false
+				s.logger.Error("failed to record reconciliation result",
+					zap.String("run_id", run.ID.String()),
+					zap.String("transaction_id", t.TransactionID.String()),
+					zap.Error(err),
+				)
+			}
+			if advancing {
+				run.HighWater = t.Cursor()
+			}
+		}
+		if len(totals) > 0 {
+			cursor = totals[len(totals)-1].Cursor()
+		}
+
+		if err := s.runs.Checkpoint(ctx, run); err != nil {
+			return run, s.finishRun(ctx, run, fmt.Errorf("failed to checkpoint run: %w", err))
+		}
+		if len(totals) < chunkSize {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return run, s.finishRun(ctx, run, err)
+		}
+	}
+
+	return run, s.finishRun(ctx, run, nil)
+}
+
+// checkTransaction counts one transaction in the run and writes a log row
+// when its settlements do not add up.
+func (s *ReconciliationService) checkTransaction(ctx context.Context, run *model.ReconciliationRun, t *model.TransactionTotal) error {
+	if run.Record(t.Currency, t.Amount, t.Settled) {
+		return nil
+	}
+
+	s.logger.Warn("reconciliation mismatch detected",
+		zap.String("transaction_id", t.TransactionID.String()),
+		zap.String("currency", t.Currency),
+		zap.Int64("expected", t.Amount),
+		zap.Int64("actual", t.Settled),
+		zap.Int64("difference", t.Amount-t.Settled),
+	)
+
+	_, err := s.logs.Create(ctx, &model.ReconciliationLogs{
+		ID:             uuid.New(),
+		EntityType:     model.ReconEntityTransaction,
+		EntityID:       t.TransactionID,
+		ExpectedAmount: t.Amount,
+		ActualAmount:   t.Settled,
+		Status:         model.ReconMismatch,
+		Notes:          "automated-check",
+		Metadata: map[string]any{
+			"run_id":   run.ID,
+			"currency": t.Currency,
+		},
+		// The same difference on the same transaction is one case
+		DedupeKey: fmt.Sprintf("txn:%s:%d:%d", t.TransactionID, t.Amount, t.Settled),
+		CreatedAt: time.Now().UTC(),
+	})
+	return err
+}
+
+// finishRun records the run's outcome and returns runErr. The run is saved
+// even when the context has been cancelled.
+func (s *ReconciliationService) finishRun(ctx context.Context, run *model.ReconciliationRun, runErr error) error {
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.Status = model.ReconRunCompleted
+	if runErr != nil {
+		run.Status = model.ReconRunFailed
+		run.Error = runErr.Error()
+	}
+
+	if err := s.runs.Finish(context.WithoutCancel(ctx), run); err != nil {
+		s.logger.Error("failed to record run outcome", zap.String("run_id", run.ID.String()), zap.Error(err))
+		if runErr == nil {
+			return fmt.Errorf("failed to record run outcome: %w", err)
+		}
+	}
+
+	s.logger.Info("reconciliation run finished",
+		zap.String("run_id", run.ID.String()),
+		zap.String("status", string(run.Status)),
+		zap.Int("checked", run.Checked),
+		zap.Int("matched", run.Matched),
+		zap.Int("mismatched", run.Mismatched),
+		zap.Int("errors", run.Errors),
+	)
+	return runErr
+}
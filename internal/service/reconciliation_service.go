@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/statement"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ReconciliationService checks settlements against what the bank actually
+// booked. Every credit on an imported statement is matched to a settlement
+// by external reference, amount and value date, and every settlement that
+// should have arrived during the statement's period but did not is flagged.
+// The outcome is written to reconciliation_logs with the statement line in
+// the metadata. It also runs the incremental check of transactions against
+// the sum of their settlements.
+type ReconciliationService struct {
+	settlements repo.SettlementRepository
+	logs        repo.ReconciliationRepository
+	runs        repo.ReconciliationRunRepository
+	tolerance   model.MatchTolerance
+	logger      *zap.Logger
+}
+
+func NewReconciliationService(
+	settlementRepo repo.SettlementRepository,
+	logRepo repo.ReconciliationRepository,
+	runRepo repo.ReconciliationRunRepository,
+	tolerance model.MatchTolerance,
+	logger *zap.Logger,
+) *ReconciliationService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ReconciliationService{
+		settlements: settlementRepo,
+		logs:        logRepo,
+		runs:        runRepo,
+		tolerance:   tolerance,
+		logger:      logger,
+	}
+}
+
+// StatementSummary counts the log rows an import wrote. Debits are not
+// settlement money and are only counted; Duplicates are lines already
+// imported from an earlier file.
+type StatementSummary struct {
+	Lines      int
+	Matched    int
+	Mismatched int
+	Unmatched  int // credits with no settlement
+	Missing    int // settlements not on the statement
+	Debits     int
+	Duplicates int
+}
+
+// ImportStatement reconciles one statement. Importing the same statement
+// again writes nothing new.
+func (s *ReconciliationService) ImportStatement(ctx context.Context, st *model.Statement) (*StatementSummary, error) {
+	summary := &StatementSummary{Lines: len(st.Lines)}
+	keys := statement.Keys(st)
+
+	for i, line := range st.Lines {
+		if line.Direction != model.StatementCredit {
+			summary.Debits++
+			continue
+		}
+		entry, err := s.reconcileLine(ctx, st, line, keys[i])
+		if err != nil {
+			return nil, fmt.Errorf("statement line %d: %w", line.Line, err)
+		}
+		if err := s.record(ctx, entry, summary); err != nil {
+			return nil, fmt.Errorf("statement line %d: %w", line.Line, err)
+		}
+	}
+
+	missing, err := s.missingSettlements(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range missing {
+		if err := s.record(ctx, m, summary); err != nil {
+			return nil, fmt.Errorf("settlement %s: %w", m.EntityID, err)
+		}
+	}
+
+	s.logger.Info("statement reconciled",
+		zap.String("statement_id", st.ID),
+		zap.String("account", st.Account),
+		zap.String("source", st.Source),
+		zap.Int("lines", summary.Lines),
+		zap.Int("matched", summary.Matched),
+		zap.Int("mismatched", summary.Mismatched),
+		zap.Int("unmatched", summary.Unmatched),
+		zap.Int("missing", summary.Missing),
+		zap.Int("duplicates", summary.Duplicates),
+	)
+	return summary, nil
+}
+
+// reconcileLine builds the log row for a credit line.
+func (s *ReconciliationService) reconcileLine(
+	ctx context.Context,
+	st *model.Statement,
+	line *model.StatementLine,
+	key string,
+) (*model.ReconciliationLogs, error) {
+	entry := &model.ReconciliationLogs{
+		ID:           uuid.New(),
+		EntityType:   model.ReconEntityStatementLine,
+		ActualAmount: line.Amount,
+		DedupeKey:    key,
+		Metadata:     statementMetadata(st, line),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	refs := line.References()
+	var settlement *model.Settlements
+	for _, ref := range refs {
+		found, err := s.settlements.GetSuccessfulByReference(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up settlement: %w", err)
+		}
+		if found != nil {
+			settlement = found
+			break
+		}
+	}
+	if settlement == nil {
+		entry.Status = model.ReconUnmatched
+		entry.Notes = "no settlement for statement line"
+		if len(refs) == 0 {
+			entry.Notes = "statement line has no reference"
+		}
+		return entry, nil
+	}
+
+	entry.EntityType = model.ReconEntitySettlement
+	entry.EntityID = settlement.ID
+	entry.ExpectedAmount = settlement.Amount
+	entry.Metadata["transaction_id"] = settlement.ExternalReference
+
+	prior, err := s.logs.MatchedKey(ctx, settlement.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check earlier matches: %w", err)
+	}
+	if prior != "" && prior != key {
+		// The same settlement credited twice
+		entry.Status = model.ReconMismatch
+		entry.Notes = "settlement already reconciled against another statement line"
+		return entry, nil
+	}
+
+	result := statement.Match(line, settlement, s.tolerance)
+	entry.Status = result.Status
+	entry.Notes = strings.Join(result.Reasons, "; ")
+	return entry, nil
+}
+
+// missingSettlements flags the settlements that should have been credited
+// by the end of the statement: those made in the period shifted back by the
+// date tolerance that no statement line has been matched to.
+func (s *ReconciliationService) missingSettlements(ctx context.Context, st *model.Statement) ([]*model.ReconciliationLogs, error) {
+	if st.Currency == "" || st.From.IsZero() {
+		return nil, nil
+	}
+	from := st.From.Add(-s.tolerance.Date)
+	to := st.To.AddDate(0, 0, 1).Add(-s.tolerance.Date)
+	if !to.After(from) {
+		return nil, nil
+	}
+
+	settlements, err := s.settlements.ListUnreconciled(ctx, st.Currency, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unreconciled settlements: %w", err)
+	}
+	entries := make([]*model.ReconciliationLogs, 0, len(settlements))
+	for _, settlement := range settlements {
+		metadata := statementMetadata(st, nil)
+		metadata["transaction_id"] = settlement.ExternalReference
+		entries = append(entries, &model.ReconciliationLogs{
+			ID:             uuid.New(),
+			EntityType:     model.ReconEntitySettlement,
+			EntityID:       settlement.ID,
+			ExpectedAmount: settlement.Amount,
+			Status:         model.ReconUnmatched,
+			Notes:          "settlement not on bank statement",
+			Metadata:       metadata,
+			DedupeKey:      "missing:" + settlement.ID.String(),
+			CreatedAt:      time.Now().UTC(),
+		})
+	}
+	return entries, nil
+}
+
+func (s *ReconciliationService) record(ctx context.Context, entry *model.ReconciliationLogs, summary *StatementSummary) error {
+	inserted, err := s.logs.Create(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to write reconciliation log: %w", err)
+	}
+	if !inserted {
+		summary.Duplicates++
+		return nil
+	}
+
+	switch {
+	case entry.Status == model.ReconMatched:
+		summary.Matched++
+		return nil
+	case entry.Status == model.ReconMismatch:
+		summary.Mismatched++
+	case entry.EntityType == model.ReconEntitySettlement:
+		summary.Missing++
+	default:
+		summary.Unmatched++
+	}
+	s.logger.Warn("statement reconciliation exception",
+		zap.String("status", entry.Status),
+		zap.String("entity_type", entry.EntityType),
+		zap.String("entity_id", entry.EntityID.String()),
+		zap.Int64("expected", entry.ExpectedAmount),
+		zap.Int64("actual", entry.ActualAmount),
+		zap.String("notes", entry.Notes),
+	)
+	return nil
+}
+
+// statementMetadata describes the statement and, when given, the line a
+// log row came from.
+func statementMetadata(st *model.Statement, line *model.StatementLine) map[string]any {
+	metadata := map[string]any{
+		"statement": map[string]any{
+			"id":       st.ID,
+			"account":  st.Account,
+			"currency": st.Currency,
+			"from":     st.From.Format("2006-01-02"),
+			"to":       st.To.Format("2006-01-02"),
+			"format":   st.Format,
+			"source":   st.Source,
+		},
+	}
+	if line != nil {
+		metadata["statement_line"] = line
+	}
+	return metadata
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrTransactionNotFound      = errors.New("transaction not found")
+	ErrTransactionNotRefundable = errors.New("transaction is not refundable")
+	ErrRefundExceedsRemaining   = errors.New("refund amount exceeds refundable remaining amount")
+)
+
+type RefundService struct {
+	refundRepo     repo.RefundRepository
+	settlementRepo repo.SettlementRepository
+	txManager      repo.TxManager
+	ledger         *LedgerService
+	connectors     *connector.Registry
+	logger         *zap.Logger
+}
+
+func NewRefundService(
+	refundRepo repo.RefundRepository,
+	settlementRepo repo.SettlementRepository,
+	txManager repo.TxManager,
+	ledger *LedgerService,
+	connectors *connector.Registry,
+	logger *zap.Logger,
+) *RefundService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if ledger == nil {
+		ledger = NewLedgerService(nil, logger)
+	}
+	if connectors == nil {
+		connectors = connector.NewRegistry()
+	}
+	if _, err := connectors.Default(); err != nil {
+		connectors.Register(connector.NewSandboxConnector(connector.DefaultSandboxConfig()))
+	}
+	return &RefundService{
+		refundRepo:     refundRepo,
+		settlementRepo: settlementRepo,
+		txManager:      txManager,
+		ledger:         ledger,
+		connectors:     connectors,
+		logger:         logger,
+	}
+}
+
+type RefundPayload struct {
+	RefundID      uuid.UUID `json:"refund_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	RequestedAt   time.Time `json:"requested_at"`
+}
+
+// CreateRefund validates the request against the transaction's refundable
+// remaining amount, stores a pending refund and emits refund.requested. An
+// amount of 0 refunds everything that is left.
+func (s *RefundService) CreateRefund(ctx context.Context, transactionID uuid.UUID, input dto.CreateRefundDTO) (*model.Refund, error) {
+	if input.Amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	metadata := input.Metadata
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+
+	var refund *model.Refund
+	err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		// Lock the transaction so concurrent refunds can't both pass the check
+		tx, err := r.Transactions.GetByIDForUpdate(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+		if tx == nil {
+			return ErrTransactionNotFound
+		}
+		if tx.Status != model.TransactionStatusCompleted && tx.Status != model.TransactionStatusPartiallyRefunded {
+			return ErrTransactionNotRefundable
+		}
+
+		refunded, err := r.Refunds.SumActiveByTransaction(ctx, tx.ID)
+		if err != nil {
+			return err
+		}
+		remaining := tx.Amount - refunded
+
+		amount := input.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return ErrRefundExceedsRemaining
+		}
+
+		now := time.Now().UTC()
+		refund = &model.Refund{
+			ID:            uuid.New(),
+			TransactionID: tx.ID,
+			Amount:        amount,
+			Currency:      tx.Currency,
+			Status:        model.RefundPending,
+			Reason:        strings.TrimSpace(input.Reason),
+			Metadata:      metadata,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := r.Refunds.Create(ctx, refund); err != nil {
+			return err
+		}
+
+		return enqueueEvent(ctx, r, "refund.requested", tx.ID.String(), RefundPayload{
+			RefundID:      refund.ID,
+			TransactionID: tx.ID,
+			Amount:        refund.Amount,
+			Currency:      refund.Currency,
+			RequestedAt:   now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("refund requested",
+		zap.String("refund_id", refund.ID.String()),
+		zap.String("transaction_id", transactionID.String()),
+		zap.Int64("amount", refund.Amount),
+	)
+
+	return refund, nil
+}
+
+// ProcessRefund handles refund.requested: it sends the refund to the
+// connector that settled the transaction, then posts the reversing ledger
+// entries, completes the refund and emits refund.completed. A decline fails
+// the refund and emits refund.failed. Redelivered messages for refunds that
+// are no longer pending are ignored.
+func (s *RefundService) ProcessRefund(ctx context.Context, payload []byte) error {
+	var rp RefundPayload
+	if err := json.Unmarshal(payload, &rp); err != nil {
+		s.logger.Error("failed to unmarshal refund payload", zap.Error(err))
+		return fmt.Errorf("invalid refund payload: %w", err)
+	}
+
+	s.logger.Info("processing refund", zap.String("refund_id", rp.RefundID.String()))
+
+	refund, err := s.refundRepo.GetByID(ctx, rp.RefundID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch refund: %w", err)
+	}
+	if refund == nil {
+		s.logger.Warn("refund not found, dropping message", zap.String("refund_id", rp.RefundID.String()))
+		return nil
+	}
+	if refund.Status != model.RefundPending {
+		s.logger.Info("refund already processed", zap.String("refund_id", refund.ID.String()), zap.String("status", string(refund.Status)))
+		return nil
+	}
+
+	conn, externalID, err := s.resolveConnector(ctx, refund.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := conn.Refund(ctx, &connector.Request{
+		Reference:     refund.ID.String(),
+		TransactionID: refund.TransactionID,
+		Amount:        refund.Amount,
+		Currency:      refund.Currency,
+		ExternalID:    externalID,
+		Metadata:      refund.Metadata,
+	})
+	if err != nil {
+		// Outcome unknown: leave the refund pending and let the bus retry
+		s.logger.Warn("connector refund failed",
+			zap.String("connector", conn.Name()),
+			zap.String("refund_id", refund.ID.String()),
+			zap.Error(err),
+		)
+		return fmt.Errorf("connector %s: %w", conn.Name(), err)
+	}
+	resultMetadata := connectorMetadata(conn, []*connector.Response{resp}, nil)
+
+	if !resp.Approved() {
+		return s.failRefund(ctx, refund.ID, resultMetadata)
+	}
+
+	return s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		refund, err := r.Refunds.GetByIDForUpdate(ctx, rp.RefundID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch refund: %w", err)
+		}
+		if refund == nil || refund.Status != model.RefundPending {
+			return nil
+		}
+
+		tx, err := r.Transactions.GetByIDForUpdate(ctx, refund.TransactionID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch transaction: %w", err)
+		}
+		if tx == nil {
+			return ErrTransactionNotFound
+		}
+
+		// Reverse at the rate the transaction settled at, out of the account
+		// in the merchant's settlement currency.
+		settlement, err := r.Settlements.GetSuccessfulByReference(ctx, tx.ID.String())
+		if err != nil {
+			return fmt.Errorf("failed to fetch settlement: %w", err)
+		}
+		merchantAmount, err := s.ledger.RefundAmount(refund, settlement)
+		if err != nil {
+			return err
+		}
+
+		merchantAccount, err := r.Accounts.GetOrCreateMerchantAccount(ctx, tx.MerchantID, merchantAmount.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to get merchant account: %w", err)
+		}
+
+		if err := s.ledger.PostRefund(ctx, r, refund, merchantAccount.ID, merchantAmount); err != nil {
+			return err
+		}
+		if err := r.Refunds.UpdateStatus(ctx, refund.ID, model.RefundCompleted); err != nil {
+			return fmt.Errorf("failed to update refund status: %w", err)
+		}
+		if err := r.Refunds.MergeMetadata(ctx, refund.ID, resultMetadata); err != nil {
+			return fmt.Errorf("failed to record connector response: %w", err)
+		}
+
+		refunded, err := r.Refunds.SumCompletedByTransaction(ctx, tx.ID)
+		if err != nil {
+			return err
+		}
+		next := model.TransactionStatusPartiallyRefunded
+		if refunded >= tx.Amount {
+			next = model.TransactionStatusRefunded
+		}
+		if err := r.Transactions.TransitionStatus(ctx, tx.ID, tx.Status, next); err != nil {
+			return fmt.Errorf("failed to update transaction status: %w", err)
+		}
+
+		s.logger.Info("refund completed", zap.String("refund_id", refund.ID.String()), zap.String("transaction_id", tx.ID.String()))
+
+		return enqueueEvent(ctx, r, "refund.completed", tx.ID.String(), map[string]any{
+			"refund_id":      refund.ID.String(),
+			"transaction_id": tx.ID.String(),
+			"amount":         refund.Amount,
+			"currency":       refund.Currency,
+			"status":         string(model.RefundCompleted),
+			"completed_at":   time.Now().UTC(),
+		})
+	})
+}
+
+// failRefund records a connector decline and emits refund.failed.
+func (s *RefundService) failRefund(ctx context.Context, refundID uuid.UUID, resultMetadata map[string]any) error {
+	return s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		refund, err := r.Refunds.GetByIDForUpdate(ctx, refundID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch refund: %w", err)
+		}
+		if refund == nil || refund.Status != model.RefundPending {
+			return nil
+		}
+		if err := r.Refunds.UpdateStatus(ctx, refund.ID, model.RefundFailed); err != nil {
+			return fmt.Errorf("failed to update refund status: %w", err)
+		}
+		if err := r.Refunds.MergeMetadata(ctx, refund.ID, resultMetadata); err != nil {
+			return fmt.Errorf("failed to record connector response: %w", err)
+		}
+
+		s.logger.Warn("refund declined", zap.String("refund_id", refund.ID.String()), zap.String("transaction_id", refund.TransactionID.String()))
+
+		return enqueueEvent(ctx, r, "refund.failed", refund.TransactionID.String(), map[string]any{
+			"refund_id":      refund.ID.String(),
+			"transaction_id": refund.TransactionID.String(),
+			"amount":         refund.Amount,
+			"currency":       refund.Currency,
+			"status":         string(model.RefundFailed),
+			"failed_at":      time.Now().UTC(),
+		})
+	})
+}
+
+// resolveConnector returns the connector that settled the transaction and
+// its reference there, falling back to the default connector.
+func (s *RefundService) resolveConnector(ctx context.Context, transactionID uuid.UUID) (connector.Connector, string, error) {
+	var name, externalID string
+	if s.settlementRepo != nil {
+		settlement, err := s.settlementRepo.GetSuccessfulByReference(ctx, transactionID.String())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch settlement: %w", err)
+		}
+		if settlement != nil {
+			name, _ = settlement.Metadata["connector"].(string)
+			externalID, _ = settlement.Metadata["external_id"].(string)
+		}
+	}
+	if name != "" {
+		if c, err := s.connectors.Get(name); err == nil {
+			return c, externalID, nil
+		}
+		s.logger.Warn("settling connector not registered, using default", zap.String("connector", name))
+	}
+	c, err := s.connectors.Default()
+	return c, externalID, err
+}
+
+func (s *RefundService) GetRefund(ctx context.Context, id uuid.UUID) (*model.Refund, error) {
+	return s.refundRepo.GetByID(ctx, id)
+}
+
+func (s *RefundService) ListRefunds(ctx context.Context, transactionID uuid.UUID) ([]*model.Refund, error) {
+	return s.refundRepo.ListByTransaction(ctx, transactionID)
+}
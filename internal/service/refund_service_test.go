@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/google/uuid"
+)
+
+// fakeTxManager runs the unit of work against in-memory repositories.
+type fakeTxManager struct {
+	repos *repo.TxRepositories
+}
+
+func (m *fakeTxManager) WithTx(ctx context.Context, fn func(r *repo.TxRepositories) error) error {
+	return fn(m.repos)
+}
+
+// memOutbox collects the messages enqueued.
+type memOutbox struct {
+	repo.OutboxRepository
+	msgs []*model.OutboxMessage
+}
+
+func (o *memOutbox) Enqueue(ctx context.Context, msg *model.OutboxMessage) error {
+	o.msgs = append(o.msgs, msg)
+	return nil
+}
+
+type memTransactions struct {
+	repo.TransactionRepository
+	txs map[uuid.UUID]*model.Transaction
+}
+
+func (m *memTransactions) GetByID(ctx context.Context, id uuid.UUID) (*model.Transaction, error) {
+	if tx, ok := m.txs[id]; ok {
+		cp := *tx
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (m *memTransactions) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Transaction, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *memTransactions) TransitionStatus(ctx context.Context, id uuid.UUID, from, to model.TransactionStatus) error {
+	if err := model.ValidateTransition(from, to); err != nil {
+		return err
+	}
+	tx := m.txs[id]
+	if tx.Status != from {
+		return model.ErrInvalidTransition
+	}
+	tx.Status = to
+	return nil
+}
+
+type memRefunds struct {
+	repo.RefundRepository
+	refunds []*model.Refund
+}
+
+func (m *memRefunds) Create(ctx context.Context, refund *model.Refund) error {
+	cp := *refund
+	m.refunds = append(m.refunds, &cp)
+	return nil
+}
+
+func (m *memRefunds) GetByID(ctx context.Context, id uuid.UUID) (*model.Refund, error) {
+	for _, r := range m.refunds {
+		if r.ID == id {
+			cp := *r
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memRefunds) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Refund, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *memRefunds) sum(transactionID uuid.UUID, counts func(model.RefundStatus) bool) int64 {
+	var total int64
+	for _, r := range m.refunds {
+		if r.TransactionID == transactionID && counts(r.Status) {
+			total += r.Amount
+		}
+	}
+	return total
+}
+
+func (m *memRefunds) SumActiveByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error) {
+	return m.sum(transactionID, func(s model.RefundStatus) bool { return s != model.RefundFailed }), nil
+}
+
+func (m *memRefunds) SumCompletedByTransaction(ctx context.Context, transactionID uuid.UUID) (int64, error) {
+	return m.sum(transactionID, func(s model.RefundStatus) bool { return s == model.RefundCompleted }), nil
+}
+
+func (m *memRefunds) UpdateStatus(ctx context.Context, id uuid.UUID, status model.RefundStatus) error {
+	for _, r := range m.refunds {
+		if r.ID == id {
+			r.Status = status
+		}
+	}
+	return nil
+}
+
+func (m *memRefunds) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error {
+	return nil
+}
+
+// noSettlements has no successful settlement for any transaction, so
+// refunds go back in the transaction currency through the default connector.
+type noSettlements struct {
+	repo.SettlementRepository
+}
+
+func (noSettlements) GetSuccessfulByReference(ctx context.Context, externalReference string) (*model.Settlements, error) {
+	return nil, nil
+}
+
+// memAccounts hands out one account per owner and currency.
+type memAccounts struct {
+	repo.AccountRepository
+	accounts map[string]*model.Accounts
+}
+
+func (a *memAccounts) get(ownerID uuid.UUID, accountType model.AccountType, currency string) *model.Accounts {
+	key := ownerID.String() + "/" + currency
+	if acc, ok := a.accounts[key]; ok {
+		return acc
+	}
+	acc := &model.Accounts{ID: uuid.New(), OwnerID: ownerID, AccountType: accountType, Currency: currency}
+	a.accounts[key] = acc
+	return acc
+}
+
+func (a *memAccounts) GetOrCreateMerchantAccount(ctx context.Context, merchantID uuid.UUID, currency string) (*model.Accounts, error) {
+	return a.get(merchantID, model.AccountTypeMerchant, currency), nil
+}
+
+func (a *memAccounts) GetOrCreateSystemAccount(ctx context.Context, ownerID uuid.UUID, currency string) (*model.Accounts, error) {
+	return a.get(ownerID, model.AccountTypeSystem, currency), nil
+}
+
+type refundFixture struct {
+	svc      *RefundService
+	tx       *model.Transaction
+	refunds  *memRefunds
+	ledger   *memLedger
+	accounts *memAccounts
+	outbox   *memOutbox
+}
+
+// newRefundFixture sets up a completed transaction of amount NGN minor
+// units.
+func newRefundFixture(amount int64) *refundFixture {
+	tx := &model.Transaction{
+		ID:         uuid.New(),
+		Amount:     amount,
+		Currency:   "NGN",
+		MerchantID: uuid.New(),
+		Status:     model.TransactionStatusCompleted,
+	}
+	f := &refundFixture{
+		tx:       tx,
+		refunds:  &memRefunds{},
+		ledger:   newMemLedger(),
+		accounts: &memAccounts{accounts: map[string]*model.Accounts{}},
+		outbox:   &memOutbox{},
+	}
+	txManager := &fakeTxManager{repos: &repo.TxRepositories{
+		Transactions: &memTransactions{txs: map[uuid.UUID]*model.Transaction{tx.ID: tx}},
+		Refunds:      f.refunds,
+		Settlements:  noSettlements{},
+		Accounts:     f.accounts,
+		Ledger:       f.ledger,
+		Outbox:       f.outbox,
+	}}
+	f.svc = NewRefundService(f.refunds, nil, txManager, nil, nil, nil)
+	return f
+}
+
+// refund requests amount and processes the refund.requested it emits.
+func (f *refundFixture) refund(t *testing.T, amount int64) *model.Refund {
+	t.Helper()
+	refund, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{Amount: amount})
+	if err != nil {
+		t.Fatalf("CreateRefund(%d): %v", amount, err)
+	}
+	payload, err := json.Marshal(RefundPayload{RefundID: refund.ID, TransactionID: f.tx.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.svc.ProcessRefund(context.Background(), payload); err != nil {
+		t.Fatalf("ProcessRefund: %v", err)
+	}
+	return refund
+}
+
+func TestCreateRefundZeroAmountRefundsRemaining(t *testing.T) {
+	f := newRefundFixture(10000)
+	f.refund(t, 2500)
+
+	refund, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Amount != 7500 || refund.Currency != "NGN" || refund.Status != model.RefundPending {
+		t.Fatalf("refund = %d %s %s, want 7500 NGN pending", refund.Amount, refund.Currency, refund.Status)
+	}
+	if n := len(f.outbox.msgs); n != 3 || f.outbox.msgs[2].Topic != "refund.requested" {
+		t.Fatalf("outbox has %d messages, want refund.requested last of 3", n)
+	}
+}
+
+func TestCreateRefundRejectsOverRefund(t *testing.T) {
+	f := newRefundFixture(10000)
+	// A pending refund counts against what is left too
+	if _, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{Amount: 6000}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, amount := range []int64{4001, 10000} {
+		if _, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{Amount: amount}); !errors.Is(err, ErrRefundExceedsRemaining) {
+			t.Errorf("refund of %d: got %v, want ErrRefundExceedsRemaining", amount, err)
+		}
+	}
+	if len(f.refunds.refunds) != 1 {
+		t.Fatalf("%d refunds stored, want 1", len(f.refunds.refunds))
+	}
+
+	// Nothing left once the rest is refunded, so a full refund is refused
+	if _, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{Amount: 4000}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.CreateRefund(context.Background(), f.tx.ID, dto.CreateRefundDTO{}); !errors.Is(err, ErrRefundExceedsRemaining) {
+		t.Errorf("full refund of nothing: got %v, want ErrRefundExceedsRemaining", err)
+	}
+}
+
+func TestProcessRefundTransitionsTransaction(t *testing.T) {
+	f := newRefundFixture(10000)
+
+	f.refund(t, 4000)
+	if f.tx.Status != model.TransactionStatusPartiallyRefunded {
+		t.Fatalf("after a partial refund status = %s, want partially_refunded", f.tx.Status)
+	}
+
+	f.refund(t, 0)
+	if f.tx.Status != model.TransactionStatusRefunded {
+		t.Fatalf("after refunding the rest status = %s, want refunded", f.tx.Status)
+	}
+	for _, r := range f.refunds.refunds {
+		if r.Status != model.RefundCompleted {
+			t.Errorf("refund %d is %s, want completed", r.Amount, r.Status)
+		}
+	}
+
+	// The full amount went from the merchant back to clearing
+	merchant := f.accounts.get(f.tx.MerchantID, model.AccountTypeMerchant, "NGN")
+	clearing := f.accounts.get(model.SystemClearingOwnerID, model.AccountTypeSystem, "NGN")
+	if got := f.ledger.balances[merchant.ID]; got != -10000 {
+		t.Errorf("merchant balance moved %d, want -10000", got)
+	}
+	if got := f.ledger.balances[clearing.ID]; got != 10000 {
+		t.Errorf("clearing balance moved %d, want 10000", got)
+	}
+}
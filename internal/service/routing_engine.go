@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RoutingRuleSource supplies enabled routing rules. Both
+// repo.PostgresRoutingRuleRepository and FileRoutingRuleSource satisfy it.
+type RoutingRuleSource interface {
+	ListEnabled(ctx context.Context) ([]*model.RoutingRule, error)
+}
+
+// FileRoutingRuleSource reads rules from a JSON file holding an array of
+// model.RoutingRule. The file is re-read on every call so edits are picked
+// up on the engine's next refresh.
+type FileRoutingRuleSource struct {
+	Path string
+}
+
+func (f *FileRoutingRuleSource) ListEnabled(ctx context.Context) ([]*model.RoutingRule, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read routing rules: %w", err)
+	}
+
+	var rules []*model.RoutingRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse routing rules %s: %w", f.Path, err)
+	}
+
+	enabled := rules[:0]
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		// Give file rules a stable ID so transactions can reference them
+		if r.ID == uuid.Nil {
+			r.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte("routing-rule:"+r.Name))
+		}
+		enabled = append(enabled, r)
+	}
+	sort.SliceStable(enabled, func(i, j int) bool { return enabled[i].Priority < enabled[j].Priority })
+	return enabled, nil
+}
+
+// RouteDecision is the outcome of evaluating the rules for a transaction.
+type RouteDecision struct {
+	Connector string
+	Fallbacks []string
+	Rule      *model.RoutingRule // nil when no rule matched
+}
+
+// RuleID returns the matching rule's ID, or nil for the default route.
+func (d *RouteDecision) RuleID() *uuid.UUID {
+	if d.Rule == nil {
+		return nil
+	}
+	return &d.Rule.ID
+}
+
+// Routing returns the routing section of the settlement.requested payload.
+func (d *RouteDecision) Routing() map[string]any {
+	m := map[string]any{
+		"route":     d.Connector,
+		"fallbacks": d.Fallbacks,
+	}
+	if d.Rule != nil {
+		m["rule_id"] = d.Rule.ID.String()
+		m["rule_name"] = d.Rule.Name
+	}
+	return m
+}
+
+// RoutingEngine chooses a connector for a transaction from declarative
+// rules. Rules are cached and reloaded from the source every refresh
+// interval, so ops can move traffic without a deploy.
+type RoutingEngine struct {
+	source     RoutingRuleSource
+	connectors *connector.Registry
+	refresh    time.Duration
+	logger     *zap.Logger
+	now        func() time.Time
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	rules    []*model.RoutingRule
+	loadedAt time.Time
+}
+
+func NewRoutingEngine(source RoutingRuleSource, connectors *connector.Registry, refresh time.Duration, logger *zap.Logger) *RoutingEngine {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if connectors == nil {
+		connectors = connector.NewRegistry()
+	}
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+	return &RoutingEngine{
+		source:     source,
+		connectors: connectors,
+		refresh:    refresh,
+		logger:     logger,
+		now:        time.Now,
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// NewRoutingEngineFromEnv loads rules from ROUTING_RULES_FILE when set and
+// from the routing_rules table otherwise, reloading them every
+// ROUTING_RULES_REFRESH (default 30s).
+func NewRoutingEngineFromEnv(db repo.DBTX, connectors *connector.Registry, logger *zap.Logger) (*RoutingEngine, error) {
+	var refresh time.Duration
+	if v := os.Getenv("ROUTING_RULES_REFRESH"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROUTING_RULES_REFRESH: %w", err)
+		}
+		refresh = d
+	}
+
+	var source RoutingRuleSource = repo.NewPostgresRoutingRuleRepository(db)
+	if path := os.Getenv("ROUTING_RULES_FILE"); path != "" {
+		file := &FileRoutingRuleSource{Path: path}
+		// Fail fast on a broken file instead of routing everything to the default
+		if _, err := file.ListEnabled(context.Background()); err != nil {
+			return nil, err
+		}
+		source = file
+	}
+
+	return NewRoutingEngine(source, connectors, refresh, logger), nil
+}
+
+// Route evaluates the rules in priority order and returns the first match.
+// Targets whose connector isn't registered are skipped; when no rule yields
+// a usable target the registry default is used.
+func (e *RoutingEngine) Route(ctx context.Context, tx *model.Transaction) (*RouteDecision, error) {
+	rules := e.currentRules(ctx)
+	now := e.now()
+
+	for _, rule := range rules {
+		if !rule.Matches(tx, now) {
+			continue
+		}
+		if d := e.pick(rule); d != nil {
+			return d, nil
+		}
+		e.logger.Warn("routing rule matched but no target connector is registered", zap.String("rule", rule.Name))
+	}
+
+	name := e.connectors.DefaultName()
+	if name == "" {
+		return nil, fmt.Errorf("%w: no default connector", connector.ErrUnknownConnector)
+	}
+	return &RouteDecision{Connector: name, Fallbacks: []string{}}, nil
+}
+
+// pick chooses the primary target at random, weighted by Weight, and orders
+// the remaining targets by descending weight as fallbacks.
+func (e *RoutingEngine) pick(rule *model.RoutingRule) *RouteDecision {
+	targets := make([]model.RouteTarget, 0, len(rule.Targets))
+	total := 0
+	for _, t := range rule.Targets {
+		if !e.connectors.Has(t.Connector) {
+			continue
+		}
+		targets = append(targets, t)
+		total += t.Weight
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	primary := 0
+	if total > 0 {
+		e.mu.Lock()
+		n := e.rng.Intn(total)
+		e.mu.Unlock()
+		for i, t := range targets {
+			if n < t.Weight {
+				primary = i
+				break
+			}
+			n -= t.Weight
+		}
+	}
+
+	fallbacks := make([]model.RouteTarget, 0, len(targets)-1)
+	fallbacks = append(fallbacks, targets[:primary]...)
+	fallbacks = append(fallbacks, targets[primary+1:]...)
+	sort.SliceStable(fallbacks, func(i, j int) bool { return fallbacks[i].Weight > fallbacks[j].Weight })
+
+	d := &RouteDecision{Connector: targets[primary].Connector, Fallbacks: make([]string, 0, len(fallbacks)), Rule: rule}
+	for _, t := range fallbacks {
+		d.Fallbacks = append(d.Fallbacks, t.Connector)
+	}
+	return d
+}
+
+// currentRules returns the cached rules, reloading them once the refresh
+// interval has passed. A failed reload keeps the previous rules.
+func (e *RoutingEngine) currentRules(ctx context.Context) []*model.RoutingRule {
+	if e.source == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.loadedAt.IsZero() && e.now().Sub(e.loadedAt) < e.refresh {
+		return e.rules
+	}
+
+	rules, err := e.source.ListEnabled(ctx)
+	if err != nil {
+		e.logger.Error("failed to load routing rules, keeping previous set", zap.Error(err))
+		e.loadedAt = e.now()
+		return e.rules
+	}
+
+	valid := make([]*model.RoutingRule, 0, len(rules))
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			e.logger.Warn("ignoring invalid routing rule", zap.Error(err))
+			continue
+		}
+		valid = append(valid, r)
+	}
+	e.rules = valid
+	e.loadedAt = e.now()
+	return e.rules
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+type staticRuleSource []*model.RoutingRule
+
+func (s staticRuleSource) ListEnabled(ctx context.Context) ([]*model.RoutingRule, error) {
+	return s, nil
+}
+
+func newTestRegistry(names ...string) *connector.Registry {
+	r := connector.NewRegistry()
+	for _, n := range names {
+		r.Register(connector.NewSandboxConnector(connector.SandboxConfig{Name: n}))
+	}
+	return r
+}
+
+func TestRoutingEngineFirstMatchWins(t *testing.T) {
+	rules := staticRuleSource{
+		{Name: "usd", Priority: 1, Enabled: true, Conditions: model.RoutingConditions{Currencies: []string{"USD"}}, Targets: []model.RouteTarget{{Connector: "acq-a", Weight: 1}}},
+		{Name: "catch-all", Priority: 2, Enabled: true, Targets: []model.RouteTarget{{Connector: "acq-b", Weight: 1}}},
+	}
+	e := NewRoutingEngine(rules, newTestRegistry("sandbox", "acq-a", "acq-b"), 0, nil)
+
+	d, err := e.Route(context.Background(), &model.Transaction{Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Connector != "acq-a" || d.Rule.Name != "usd" {
+		t.Fatalf("got %s via %s, want acq-a via usd", d.Connector, d.Rule.Name)
+	}
+
+	d, _ = e.Route(context.Background(), &model.Transaction{Currency: "EUR"})
+	if d.Connector != "acq-b" {
+		t.Fatalf("got %s, want acq-b", d.Connector)
+	}
+}
+
+func TestRoutingEngineWeightsAndFallbacks(t *testing.T) {
+	rules := staticRuleSource{{
+		Name:    "split",
+		Enabled: true,
+		Targets: []model.RouteTarget{
+			{Connector: "acq-a", Weight: 0},
+			{Connector: "acq-b", Weight: 10},
+			{Connector: "unregistered", Weight: 90},
+		},
+	}}
+	e := NewRoutingEngine(rules, newTestRegistry("sandbox", "acq-a", "acq-b"), 0, nil)
+
+	for i := 0; i < 20; i++ {
+		d, err := e.Route(context.Background(), &model.Transaction{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Connector != "acq-b" {
+			t.Fatalf("zero-weight or unregistered target chosen as primary: %s", d.Connector)
+		}
+		if len(d.Fallbacks) != 1 || d.Fallbacks[0] != "acq-a" {
+			t.Fatalf("fallbacks = %v, want [acq-a]", d.Fallbacks)
+		}
+	}
+}
+
+func TestRoutingEngineDefaultsWithoutMatch(t *testing.T) {
+	rules := staticRuleSource{{
+		Name:       "gbp-only",
+		Enabled:    true,
+		Conditions: model.RoutingConditions{Currencies: []string{"GBP"}},
+		Targets:    []model.RouteTarget{{Connector: "acq-a", Weight: 1}},
+	}}
+	e := NewRoutingEngine(rules, newTestRegistry("sandbox", "acq-a"), 0, nil)
+
+	d, err := e.Route(context.Background(), &model.Transaction{Currency: "NGN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Connector != "sandbox" || d.RuleID() != nil {
+		t.Fatalf("got %s (rule %v), want default sandbox", d.Connector, d.RuleID())
+	}
+}
@@ -2,23 +2,24 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
 	"strings"
 	"time"
 
-	"github.com/BjornOnGit/payment-gateway/internal/bus"
 	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
 	"github.com/google/uuid"
 )
 
 type RoutingService struct {
-	repo repo.TransactionRepository
-	bus  bus.Bus
+	repo      repo.TransactionRepository
+	txManager repo.TxManager
+	engine    *RoutingEngine
 }
 
-func NewRoutingService(r repo.TransactionRepository, b bus.Bus) *RoutingService {
-	return &RoutingService{repo: r, bus: b}
+func NewRoutingService(r repo.TransactionRepository, txManager repo.TxManager, engine *RoutingEngine) *RoutingService {
+	return &RoutingService{repo: r, txManager: txManager, engine: engine}
 }
 
 func (s *RoutingService) ProcessTransaction(ctx context.Context, transaction_id string) error {
@@ -27,27 +28,42 @@ func (s *RoutingService) ProcessTransaction(ctx context.Context, transaction_id
 		return err
 	}
 
-	if err := s.repo.UpdateStatus(ctx, id, "processing"); err != nil {
+	tx, err := s.repo.GetByID(ctx, id)
+	if err != nil {
 		return err
 	}
+	if tx == nil {
+		log.Printf("[routing] transaction %s not found, dropping", id)
+		return nil
+	}
 
-	routingInfo := map[string]any{
-		"route":    "simulated-acquirer",
-		"priority": "normal",
+	decision, err := s.engine.Route(ctx, tx)
+	if err != nil {
+		return err
 	}
 
 	payload := map[string]any{
 		"transaction_id": id.String(),
-		"routing":        routingInfo,
+		"routing":        decision.Routing(),
 		"requested_at":   time.Now().UTC(),
 	}
 
-	bs, _ := json.Marshal(payload)
-
-	if s.bus != nil {
-		if err := s.bus.Publish(ctx, "settlement.requested", id.String(), bs); err != nil {
-			log.Printf("[routing] publish settlement.requested failed: %v", err)
+	// The status change, the recorded route and the settlement.requested
+	// event commit together
+	err = s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		if err := r.Transactions.TransitionStatus(ctx, id, model.TransactionStatusPending, model.TransactionStatusProcessing); err != nil {
+			return err
+		}
+		if err := r.Transactions.SetRoute(ctx, id, decision.Connector, decision.RuleID()); err != nil {
+			return err
 		}
+		return enqueueEvent(ctx, r, "settlement.requested", id.String(), payload)
+	})
+	if errors.Is(err, model.ErrInvalidTransition) {
+		// Duplicate or late transaction.created: the transaction has already
+		// been routed, so acknowledge without doing anything.
+		log.Printf("[routing] skipping transaction %s: %v", id, err)
+		return nil
 	}
-	return nil
+	return err
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/export"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidExport = errors.New("invalid settlement export")
+
+// SettlementExportService writes successful settlements as bank files (see
+// package export) for a payout batch or a date range.
+type SettlementExportService struct {
+	settlements repo.SettlementRepository
+	currencies  *model.CurrencyRegistry
+	debtor      model.BankDetails
+	logger      *zap.Logger
+}
+
+// NewSettlementExportService creates an exporter paying from the debtor
+// account, which pain.001 files require.
+func NewSettlementExportService(
+	settlementRepo repo.SettlementRepository,
+	currencies *model.CurrencyRegistry,
+	debtor model.BankDetails,
+	logger *zap.Logger,
+) *SettlementExportService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	return &SettlementExportService{settlements: settlementRepo, currencies: currencies, debtor: debtor, logger: logger}
+}
+
+// Export writes the selected settlements to w. Each file gets a new message
+// id, returned in the summary with the control totals and checksum.
+func (s *SettlementExportService) Export(
+	ctx context.Context,
+	w io.Writer,
+	format export.Format,
+	filter model.SettlementExportFilter,
+) (*export.Summary, error) {
+	if filter.PayoutID == nil {
+		if filter.From.IsZero() || filter.To.IsZero() {
+			return nil, fmt.Errorf("%w: a payout_id or a from/to range is required", ErrInvalidExport)
+		}
+		if !filter.To.After(filter.From) {
+			return nil, fmt.Errorf("%w: to must be after from", ErrInvalidExport)
+		}
+	}
+
+	lines, err := s.settlements.ListForExport(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settlements: %w", err)
+	}
+
+	now := time.Now().UTC()
+	summary, err := export.Write(w, format, lines, export.Options{
+		MessageID:  "STL" + now.Format("20060102150405") + "-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8],
+		CreatedAt:  now,
+		Debtor:     s.debtor,
+		Currencies: s.currencies,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("settlements exported",
+		zap.String("format", string(format)),
+		zap.String("message_id", summary.MessageID),
+		zap.Int("count", summary.Count),
+		zap.String("checksum", summary.Checksum),
+	)
+	return summary, nil
+}
@@ -3,21 +3,32 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/BjornOnGit/payment-gateway/internal/bus"
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
 	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
 	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// ErrSettlementPending is returned while the connector has yet to decide a
+// charge, so the bus tries again later.
+var ErrSettlementPending = errors.New("settlement pending at connector")
+
 type SettlementService struct {
 	txRepo         repo.TransactionRepository
 	settlementRepo repo.SettlementRepository
 	accountRepo    repo.AccountRepository
-	bus            bus.Bus
+	txManager      repo.TxManager
+	ledger         *LedgerService
+	connectors     *connector.Registry
+	authorizations *AuthorizationService
+	fees           *FeeService
 	logger         *zap.Logger
 }
 
@@ -25,21 +36,47 @@ func NewSettlementService(
 	txRepo repo.TransactionRepository,
 	settlementRepo repo.SettlementRepository,
 	accountRepo repo.AccountRepository,
-	b bus.Bus,
+	txManager repo.TxManager,
+	ledger *LedgerService,
+	connectors *connector.Registry,
+	authorizations *AuthorizationService,
+	fees *FeeService,
 	logger *zap.Logger,
 ) *SettlementService {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	if ledger == nil {
+		ledger = NewLedgerService(nil, logger)
+	}
+	if connectors == nil {
+		connectors = connector.NewRegistry()
+	}
+	if _, err := connectors.Default(); err != nil {
+		connectors.Register(connector.NewSandboxConnector(connector.DefaultSandboxConfig()))
+	}
 	return &SettlementService{
 		txRepo:         txRepo,
 		settlementRepo: settlementRepo,
 		accountRepo:    accountRepo,
-		bus:            b,
+		txManager:      txManager,
+		ledger:         ledger,
+		connectors:     connectors,
+		authorizations: authorizations,
+		fees:           fees,
 		logger:         logger,
 	}
 }
 
+// SettlementRetryPolicy is how the bus retries settlement.requested. Its
+// waits are longer than the default's to ride out connector outages.
+var SettlementRetryPolicy = bus.RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   5 * time.Second,
+	Multiplier:  3,
+	Jitter:      0.2,
+}
+
 type SettlementPayload struct {
 	TransactionID uuid.UUID      `json:"transaction_id"`
 	Routing       map[string]any `json:"routing"`
@@ -53,35 +90,13 @@ func (s *SettlementService) ProcessSettlement(ctx context.Context, payload []byt
 		return fmt.Errorf("invalid settlement payload: %w", err)
 	}
 
-	// Extract retry count from context (set by RabbitMQ bus from x-death header)
-	retryCount := 0
-	if rc, ok := ctx.Value("retry_count").(int); ok {
-		retryCount = rc
-	}
-
+	// The bus retries a failed settlement and dead-letters it after the
+	// last attempt of SettlementRetryPolicy
 	s.logger.Info("processing settlement",
 		zap.String("transaction_id", sp.TransactionID.String()),
-		zap.Int("retry_count", retryCount),
+		zap.Int("attempt", bus.AttemptFromContext(ctx)),
 	)
 
-	// Check max retry limit
-	const maxRetries = 3
-	if retryCount >= maxRetries {
-		s.logger.Error("settlement exhausted max retries",
-			zap.String("transaction_id", sp.TransactionID.String()),
-			zap.Int("retry_count", retryCount),
-		)
-		// Publish to DLQ
-		if s.bus != nil {
-			if err := s.bus.Publish(context.Background(), "dlq.settlement.requested", sp.TransactionID.String(), payload); err != nil {
-				s.logger.Error("failed to publish to DLQ", zap.Error(err))
-			} else {
-				s.logger.Info("published to DLQ", zap.String("transaction_id", sp.TransactionID.String()))
-			}
-		}
-		return fmt.Errorf("settlement failed permanently after %d retries", maxRetries)
-	}
-
 	// Fetch the transaction
 	tx, err := s.txRepo.GetByID(ctx, sp.TransactionID)
 	if err != nil {
@@ -89,9 +104,31 @@ func (s *SettlementService) ProcessSettlement(ctx context.Context, payload []byt
 		// Transient error - trigger retry
 		return fmt.Errorf("failed to fetch transaction: %w", err)
 	}
+	if tx == nil {
+		s.logger.Warn("transaction not found, dropping settlement request", zap.String("transaction_id", sp.TransactionID.String()))
+		return nil
+	}
+
+	// Only transactions that have been routed can settle. Anything else is a
+	// duplicate or out-of-order message and must not touch the transaction.
+	if tx.Status != model.TransactionStatusProcessing {
+		s.logger.Info("skipping settlement request",
+			zap.String("transaction_id", tx.ID.String()),
+			zap.String("status", string(tx.Status)),
+		)
+		return nil
+	}
+
+	// Price the settlement in the merchant's currency up front so a missing
+	// rate fails before the connector is charged.
+	settled, conversion, err := s.ledger.SettlementAmount(ctx, tx)
+	if err != nil {
+		s.logger.Error("failed to price settlement", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		return err
+	}
 
 	// Get or create merchant account
-	merchantAccount, err := s.accountRepo.GetOrCreateMerchantAccount(ctx, tx.MerchantID, tx.Currency)
+	merchantAccount, err := s.accountRepo.GetOrCreateMerchantAccount(ctx, tx.MerchantID, settled.Currency)
 	if err != nil {
 		s.logger.Error("failed to get/create merchant account",
 			zap.String("merchant_id", tx.MerchantID.String()),
@@ -100,94 +137,403 @@ func (s *SettlementService) ProcessSettlement(ctx context.Context, payload []byt
 		return fmt.Errorf("failed to get/create merchant account: %w", err)
 	}
 
-	// Create settlement attempt
-	settlement := &model.Settlements{
-		ID:                uuid.New(),
-		MerchantAccountID: merchantAccount.ID,
-		ExternalReference: sp.TransactionID.String(),
-		Status:            string(model.SettlementPending),
-		Amount:            tx.Amount,
-		Metadata: map[string]any{
-			"routing":      sp.Routing,
-			"requested_at": sp.RequestedAt,
-		},
-		Attempts:  1,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+	quote, err := s.fees.Quote(ctx, tx.MerchantID, merchantAccount.ID, settled)
+	if err != nil {
+		s.logger.Error("failed to price settlement fee", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		return err
+	}
+
+	candidates, err := s.resolveConnectors(sp.Routing)
+	if err != nil {
+		s.logger.Error("no connector available", zap.Error(err))
+		return err
 	}
 
-	if err := s.settlementRepo.CreateSettlementAttempt(ctx, settlement); err != nil {
-		s.logger.Error("failed to create settlement attempt", zap.String("settlement_id", settlement.ID.String()), zap.Error(err))
-		return fmt.Errorf("failed to create settlement attempt: %w", err)
+	// Manual-capture transactions stop at authorized; the merchant captures
+	// or voids them through the API.
+	if tx.CaptureMethod == model.CaptureManual {
+		if s.authorizations == nil {
+			return fmt.Errorf("manual capture requested for %s but authorizations are not configured", tx.ID)
+		}
+		return s.authorizations.Authorize(ctx, tx, candidates)
 	}
 
-	s.logger.Info("settlement attempt created", zap.String("settlement_id", settlement.ID.String()))
+	// An earlier delivery may have left an attempt pending after reaching the
+	// connector. Resume it as it was priced rather than charging again.
+	settlement, err := s.settlementRepo.GetPendingByReference(ctx, sp.TransactionID.String())
+	if err != nil {
+		s.logger.Error("failed to fetch pending settlement attempt", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		return fmt.Errorf("failed to fetch pending settlement attempt: %w", err)
+	}
 
-	// Simulate settlement processing (in real world, this would call external APIs)
-	// For now, mark as success and update transaction status
-	success := s.simulateSettlementProcessing(ctx, settlement)
+	var conn connector.Connector
+	var responses []*connector.Response
+	if settlement != nil {
+		settled = model.NewMoney(settlement.Amount, settlement.Currency)
+		quote = attemptFeeQuote(settlement)
+		s.logger.Info("resuming settlement attempt", zap.String("settlement_id", settlement.ID.String()))
 
-	if success {
-		// Update transaction status to completed
-		if err := s.txRepo.UpdateStatus(ctx, tx.ID, "completed"); err != nil {
-			s.logger.Error("failed to update transaction status", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
-			return err
+		conn, responses, err = s.resume(ctx, settlement, candidates, tx)
+	} else {
+		// Create settlement attempt
+		settlement = &model.Settlements{
+			ID:                uuid.New(),
+			MerchantAccountID: merchantAccount.ID,
+			ExternalReference: sp.TransactionID.String(),
+			Status:            string(model.SettlementPending),
+			Metadata: map[string]any{
+				"routing":      sp.Routing,
+				"requested_at": sp.RequestedAt,
+			},
+			Attempts:  1,
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
 		}
+		applySettlementAmount(settlement, settled, conversion)
+		applyFeeQuote(settlement, quote)
 
-		s.logger.Info("settlement successful", zap.String("transaction_id", tx.ID.String()), zap.String("settlement_id", settlement.ID.String()))
+		if err := s.settlementRepo.CreateSettlementAttempt(ctx, settlement); err != nil {
+			s.logger.Error("failed to create settlement attempt", zap.String("settlement_id", settlement.ID.String()), zap.Error(err))
+			return fmt.Errorf("failed to create settlement attempt: %w", err)
+		}
 
-		// Publish settlement.completed event
-		if s.bus != nil {
-			completedPayload := map[string]any{
-				"transaction_id": tx.ID.String(),
-				"settlement_id":  settlement.ID.String(),
-				"status":         "completed",
-				"completed_at":   time.Now().UTC(),
+		s.logger.Info("settlement attempt created", zap.String("settlement_id", settlement.ID.String()))
+
+		conn, responses, err = s.chargeWithFallbacks(ctx, candidates, tx)
+	}
+	if conn == nil {
+		s.logger.Error("connector of pending settlement attempt not registered", zap.String("settlement_id", settlement.ID.String()), zap.Error(err))
+		return err
+	}
+	if err != nil {
+		s.logger.Warn("connector call failed",
+			zap.String("connector", conn.Name()),
+			zap.String("settlement_id", settlement.ID.String()),
+			zap.Error(err),
+		)
+		if mErr := s.settlementRepo.MergeMetadata(ctx, settlement.ID, attemptMetadata(conn, responses, err)); mErr != nil {
+			s.logger.Error("failed to record connector error", zap.Error(mErr))
+		}
+		// A connector that was unreachable never saw the request, so the
+		// attempt failed and the retry starts a new one. Any other error
+		// leaves the outcome unknown: the attempt stays pending for the
+		// retry to resolve.
+		if errors.Is(err, connector.ErrUnavailable) {
+			if uErr := s.settlementRepo.UpdateStatus(ctx, settlement.ID, model.SettlementFailed); uErr != nil {
+				s.logger.Error("failed to mark settlement attempt failed", zap.Error(uErr))
+			}
+		}
+		return fmt.Errorf("connector %s: %w", conn.Name(), err)
+	}
+
+	// Record the outcome before acting on it, so a retry after a failed
+	// completion resumes from it instead of charging again
+	if err := s.settlementRepo.MergeMetadata(ctx, settlement.ID, attemptMetadata(conn, responses, nil)); err != nil {
+		s.logger.Error("failed to record connector response", zap.String("settlement_id", settlement.ID.String()), zap.Error(err))
+		return fmt.Errorf("failed to record connector response: %w", err)
+	}
+
+	if last := responses[len(responses)-1]; last.Approved() {
+		// Complete the transaction, mark the settlement successful and post the
+		// ledger entries atomically so balances never drift from statuses.
+		err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+			if err := r.Transactions.TransitionStatus(ctx, tx.ID, model.TransactionStatusProcessing, model.TransactionStatusCompleted); err != nil {
+				return fmt.Errorf("failed to update transaction status: %w", err)
+			}
+			if err := r.Settlements.UpdateStatus(ctx, settlement.ID, model.SettlementSuccess); err != nil {
+				return fmt.Errorf("failed to update settlement status: %w", err)
+			}
+			if err := s.ledger.PostSettlement(ctx, r, tx, settlement.ID, settlement.MerchantAccountID, settled); err != nil {
+				return err
 			}
-			bs, _ := json.Marshal(completedPayload)
-			if err := s.bus.Publish(ctx, "settlement.completed", tx.ID.String(), bs); err != nil {
-				s.logger.Warn("failed to publish settlement.completed event", zap.Error(err))
+			if err := s.ledger.PostFee(ctx, r, tx, settlement.ID, settlement.MerchantAccountID, quote); err != nil {
+				return err
 			}
+			return enqueueEvent(ctx, r, "settlement.completed", tx.ID.String(), settlementCompletedEvent(tx, settlement))
+		})
+		if errors.Is(err, model.ErrInvalidTransition) {
+			return s.abandonAttempt(ctx, settlement, err)
 		}
-	} else {
-		// Update transaction status to failed
-		if err := s.txRepo.UpdateStatus(ctx, tx.ID, "failed"); err != nil {
-			s.logger.Error("failed to update transaction status to failed", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+		if err != nil {
+			s.logger.Error("failed to complete settlement", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
 			return err
 		}
+		util.SettlementsSucceededTotal.Inc()
 
-		s.logger.Warn("settlement failed", zap.String("transaction_id", tx.ID.String()), zap.String("settlement_id", settlement.ID.String()))
-
-		// Publish settlement.failed event
-		if s.bus != nil {
-			failedPayload := map[string]any{
+		s.logger.Info("settlement successful", zap.String("transaction_id", tx.ID.String()), zap.String("settlement_id", settlement.ID.String()))
+	} else {
+		// Update transaction and settlement status to failed
+		err := s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+			if err := r.Transactions.TransitionStatus(ctx, tx.ID, model.TransactionStatusProcessing, model.TransactionStatusFailed); err != nil {
+				return err
+			}
+			if err := r.Settlements.UpdateStatus(ctx, settlement.ID, model.SettlementFailed); err != nil {
+				return err
+			}
+			return enqueueEvent(ctx, r, "settlement.failed", tx.ID.String(), map[string]any{
 				"transaction_id": tx.ID.String(),
 				"settlement_id":  settlement.ID.String(),
 				"status":         "failed",
 				"failed_at":      time.Now().UTC(),
-			}
-			bs, _ := json.Marshal(failedPayload)
-			if err := s.bus.Publish(ctx, "settlement.failed", tx.ID.String(), bs); err != nil {
-				s.logger.Warn("failed to publish settlement.failed event", zap.Error(err))
-			}
+			})
+		})
+		if errors.Is(err, model.ErrInvalidTransition) {
+			return s.abandonAttempt(ctx, settlement, err)
+		}
+		if err != nil {
+			s.logger.Error("failed to update transaction status to failed", zap.String("transaction_id", tx.ID.String()), zap.Error(err))
+			return err
 		}
+
+		s.logger.Warn("settlement failed", zap.String("transaction_id", tx.ID.String()), zap.String("settlement_id", settlement.ID.String()))
 	}
 
 	return nil
 }
 
-// simulateSettlementProcessing simulates calling external settlement APIs
-// In a real system, this would make HTTP calls to payment processors
-func (s *SettlementService) simulateSettlementProcessing(ctx context.Context, settlement *model.Settlements) bool {
-	// Simulate realistic success rate with occasional failures
-	// In production, this would actually call external APIs
-	s.logger.Info("simulating settlement API call", zap.String("settlement_id", settlement.ID.String()))
+// abandonAttempt marks a settlement attempt failed after another worker won
+// the race to move the transaction out of processing. The message is
+// acknowledged since the transaction has already reached a final state.
+func (s *SettlementService) abandonAttempt(ctx context.Context, settlement *model.Settlements, cause error) error {
+	s.logger.Warn("settlement attempt superseded",
+		zap.String("settlement_id", settlement.ID.String()),
+		zap.String("transaction_id", settlement.ExternalReference),
+		zap.Error(cause),
+	)
+	if err := s.settlementRepo.UpdateStatus(ctx, settlement.ID, model.SettlementFailed); err != nil {
+		s.logger.Error("failed to mark superseded settlement attempt", zap.String("settlement_id", settlement.ID.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// resolveConnectors returns the routed connector followed by its
+// fallbacks, skipping any that aren't registered. It falls back to the
+// registry default when none of them are.
+func (s *SettlementService) resolveConnectors(routing map[string]any) ([]connector.Connector, error) {
+	names := []string{}
+	if name, ok := routing["route"].(string); ok && name != "" {
+		names = append(names, name)
+	}
+	if fallbacks, ok := routing["fallbacks"].([]any); ok {
+		for _, f := range fallbacks {
+			if name, ok := f.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var candidates []connector.Connector
+	for _, name := range names {
+		c, err := s.connectors.Get(name)
+		if err != nil {
+			s.logger.Warn("routed connector not registered", zap.String("route", name))
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	c, err := s.connectors.Default()
+	if err != nil {
+		return nil, err
+	}
+	return []connector.Connector{c}, nil
+}
+
+// chargeWithFallbacks charges through the routed connector. A connector
+// that is unreachable never saw the request, so the next fallback can safely
+// be tried; any other error leaves the outcome unknown.
+func (s *SettlementService) chargeWithFallbacks(ctx context.Context, candidates []connector.Connector, tx *model.Transaction) (connector.Connector, []*connector.Response, error) {
+	var conn connector.Connector
+	var responses []*connector.Response
+	var err error
+	for i, c := range candidates {
+		conn = c
+		responses, err = s.charge(ctx, conn, tx, "")
+		if !errors.Is(err, connector.ErrUnavailable) || i == len(candidates)-1 {
+			break
+		}
+		s.logger.Warn("connector unavailable, trying fallback",
+			zap.String("connector", conn.Name()),
+			zap.String("fallback", candidates[i+1].Name()),
+			zap.String("transaction_id", tx.ID.String()),
+		)
+	}
+	return conn, responses, err
+}
+
+// resume picks up a pending attempt from the outcome an earlier delivery
+// recorded on it. A charge the connector answered is looked up with Status;
+// one it never answered is sent again with the same reference, which the
+// connector treats as the same operation. A recorded decline is final.
+func (s *SettlementService) resume(ctx context.Context, settlement *model.Settlements, candidates []connector.Connector, tx *model.Transaction) (connector.Connector, []*connector.Response, error) {
+	name, _ := settlement.Metadata["connector"].(string)
+	if name == "" {
+		// Nothing was recorded, the connector may not have been called
+		return s.chargeWithFallbacks(ctx, candidates, tx)
+	}
+	conn, err := s.connectors.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	externalID, _ := settlement.Metadata["external_id"].(string)
+	outcome, _ := settlement.Metadata["connector_outcome"].(string)
+	switch {
+	case externalID == "":
+		responses, err := s.charge(ctx, conn, tx, "")
+		return conn, responses, err
+	case outcome == attemptOutcomeUnknown:
+		// The authorization was approved but its capture went unanswered
+		responses, err := s.charge(ctx, conn, tx, externalID)
+		return conn, responses, err
+	case outcome == string(connector.ResultDeclined):
+		return conn, []*connector.Response{{
+			Connector:  name,
+			Operation:  connector.OperationStatus,
+			Status:     connector.ResultDeclined,
+			ExternalID: externalID,
+		}}, nil
+	}
+
+	s.logger.Info("checking settlement attempt status",
+		zap.String("connector", name),
+		zap.String("settlement_id", settlement.ID.String()),
+		zap.String("external_id", externalID),
+	)
+	resp, err := conn.Status(ctx, externalID)
+	if err != nil {
+		return conn, nil, err
+	}
+	if resp.Status == connector.ResultPending {
+		return conn, []*connector.Response{resp}, fmt.Errorf("%w: %s is still pending", ErrSettlementPending, externalID)
+	}
+	return conn, []*connector.Response{resp}, nil
+}
+
+// charge runs a one-step sale: authorize then capture the full amount. It
+// returns every response received, stopping at the first decline. Given an
+// authorizationID it only captures that authorization.
+func (s *SettlementService) charge(ctx context.Context, conn connector.Connector, tx *model.Transaction, authorizationID string) ([]*connector.Response, error) {
+	req := &connector.Request{
+		Reference:     tx.ID.String(),
+		TransactionID: tx.ID,
+		MerchantID:    tx.MerchantID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		ExternalID:    authorizationID,
+		Metadata:      tx.Metadata,
+	}
+
+	s.logger.Info("calling connector", zap.String("connector", conn.Name()), zap.String("transaction_id", tx.ID.String()))
+
+	var responses []*connector.Response
+	if authorizationID == "" {
+		auth, err := conn.Authorize(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, auth)
+		if !auth.Approved() {
+			return responses, nil
+		}
+		req.ExternalID = auth.ExternalID
+	}
+
+	capture, err := conn.Capture(ctx, req)
+	if err != nil {
+		return responses, err
+	}
+	return append(responses, capture), nil
+}
+
+// settlementCompletedEvent is the settlement.completed payload, carrying the
+// gross, fee and net amounts in the settlement currency.
+func settlementCompletedEvent(tx *model.Transaction, settlement *model.Settlements) map[string]any {
+	return map[string]any{
+		"transaction_id": tx.ID.String(),
+		"settlement_id":  settlement.ID.String(),
+		"status":         "completed",
+		"amount":         settlement.Amount,
+		"fee_amount":     settlement.FeeAmount,
+		"net_amount":     settlement.NetAmount,
+		"currency":       settlement.Currency,
+		"completed_at":   time.Now().UTC(),
+	}
+}
+
+// applySettlementAmount records the amount in the settlement currency and,
+// for cross-currency settlements, the rate that was applied.
+func applySettlementAmount(settlement *model.Settlements, settled model.Money, conversion *model.FXConversion) {
+	settlement.Amount = settled.Amount
+	settlement.Currency = settled.Currency
+	settlement.SourceAmount = settled.Amount
+	if conversion == nil {
+		return
+	}
+	settlement.FXRate = conversion.Rate
+	settlement.SourceAmount = conversion.From.Amount
+	if settlement.Metadata == nil {
+		settlement.Metadata = map[string]any{}
+	}
+	settlement.Metadata["fx"] = conversion
+}
+
+// connectorMetadata is what gets merged into settlements.metadata for an
+// attempt: the connector used, every response and the error, if any.
+func connectorMetadata(conn connector.Connector, responses []*connector.Response, callErr error) map[string]any {
+	recorded := make([]map[string]any, 0, len(responses))
+	for _, r := range responses {
+		recorded = append(recorded, r.Metadata())
+	}
+	m := map[string]any{
+		"connector":           conn.Name(),
+		"connector_responses": recorded,
+	}
+	if n := len(responses); n > 0 {
+		m["external_id"] = responses[n-1].ExternalID
+	}
+	if callErr != nil {
+		m["connector_error"] = callErr.Error()
+	}
+	return m
+}
+
+// attemptOutcomeUnknown is the connector_outcome of an attempt whose last
+// connector call got no answer.
+const attemptOutcomeUnknown = "unknown"
+
+// attemptMetadata is connectorMetadata plus the connector_outcome a retry
+// resumes the attempt from: the status of the last response, or unknown
+// when the call failed.
+func attemptMetadata(conn connector.Connector, responses []*connector.Response, callErr error) map[string]any {
+	m := connectorMetadata(conn, responses, callErr)
+	m["connector_outcome"] = attemptOutcomeUnknown
+	if callErr == nil && len(responses) > 0 {
+		m["connector_outcome"] = string(responses[len(responses)-1].Status)
+	}
+	return m
+}
 
-	// Simulate 90% success rate (10% failure for testing DLQ)
-	// In real world: make HTTP calls, handle retries, timeouts, etc.
-	// For testing: uncomment next line to force failures
-	// return false
-	return true // Change to false to test DLQ flow
+// attemptFeeQuote is the fee quote a settlement attempt was priced with.
+func attemptFeeQuote(settlement *model.Settlements) *model.FeeQuote {
+	quote := &model.FeeQuote{
+		Gross: model.NewMoney(settlement.Amount, settlement.Currency),
+		Fee:   model.NewMoney(settlement.FeeAmount, settlement.Currency),
+		Net:   model.NewMoney(settlement.NetAmount, settlement.Currency),
+	}
+	// The plan and rule come from the quote stored by applyFeeQuote
+	if raw, err := json.Marshal(settlement.Metadata["fee"]); err == nil {
+		var stored model.FeeQuote
+		if json.Unmarshal(raw, &stored) == nil {
+			quote.PlanID = stored.PlanID
+			quote.RuleID = stored.RuleID
+			quote.MonthlyVolume = stored.MonthlyVolume
+		}
+	}
+	return quote
 }
 
 func (s *SettlementService) GetSettlement(ctx context.Context, id string) (*model.Settlements, error) {
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/BjornOnGit/payment-gateway/internal/connector"
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+// memSettlements keeps settlement attempts in creation order.
+type memSettlements struct {
+	repo.SettlementRepository
+	attempts []*model.Settlements
+}
+
+func (m *memSettlements) CreateSettlementAttempt(ctx context.Context, s *model.Settlements) error {
+	cp := *s
+	cp.Metadata = map[string]any{}
+	for k, v := range s.Metadata {
+		cp.Metadata[k] = v
+	}
+	m.attempts = append(m.attempts, &cp)
+	return nil
+}
+
+func (m *memSettlements) GetPendingByReference(ctx context.Context, externalReference string) (*model.Settlements, error) {
+	for i := len(m.attempts) - 1; i >= 0; i-- {
+		if s := m.attempts[i]; s.ExternalReference == externalReference && s.Status == string(model.SettlementPending) {
+			cp := *s
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memSettlements) UpdateStatus(ctx context.Context, id uuid.UUID, status model.SettlementStatus) error {
+	for _, s := range m.attempts {
+		if s.ID == id {
+			s.Status = string(status)
+		}
+	}
+	return nil
+}
+
+func (m *memSettlements) MergeMetadata(ctx context.Context, id uuid.UUID, patch map[string]any) error {
+	for _, s := range m.attempts {
+		if s.ID == id {
+			for k, v := range patch {
+				s.Metadata[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// failOnceTransactions fails the first status transition, as a completion
+// that hits a database error would.
+type failOnceTransactions struct {
+	*memTransactions
+	failed bool
+}
+
+func (m *failOnceTransactions) TransitionStatus(ctx context.Context, id uuid.UUID, from, to model.TransactionStatus) error {
+	if !m.failed {
+		m.failed = true
+		return errors.New("connection reset")
+	}
+	return m.memTransactions.TransitionStatus(ctx, id, from, to)
+}
+
+// countingConnector counts the calls made to the sandbox.
+type countingConnector struct {
+	*connector.SandboxConnector
+	calls map[connector.Operation]int
+}
+
+func (c *countingConnector) Authorize(ctx context.Context, req *connector.Request) (*connector.Response, error) {
+	c.calls[connector.OperationAuthorize]++
+	return c.SandboxConnector.Authorize(ctx, req)
+}
+
+func (c *countingConnector) Capture(ctx context.Context, req *connector.Request) (*connector.Response, error) {
+	c.calls[connector.OperationCapture]++
+	return c.SandboxConnector.Capture(ctx, req)
+}
+
+func (c *countingConnector) Status(ctx context.Context, externalID string) (*connector.Response, error) {
+	c.calls[connector.OperationStatus]++
+	return c.SandboxConnector.Status(ctx, externalID)
+}
+
+type settlementFixture struct {
+	svc         *SettlementService
+	tx          *model.Transaction
+	settlements *memSettlements
+	conn        *countingConnector
+	accounts    *memAccounts
+	ledger      *memLedger
+}
+
+// newSettlementFixture sets up a processing transaction of 10000 NGN minor
+// units routed to a sandbox that answers at once.
+func newSettlementFixture(transactions func(*memTransactions) repo.TransactionRepository) *settlementFixture {
+	tx := &model.Transaction{
+		ID:         uuid.New(),
+		Amount:     10000,
+		Currency:   "NGN",
+		MerchantID: uuid.New(),
+		Status:     model.TransactionStatusProcessing,
+		Metadata:   map[string]any{},
+	}
+	f := &settlementFixture{
+		tx:          tx,
+		settlements: &memSettlements{},
+		conn: &countingConnector{
+			SandboxConnector: connector.NewSandboxConnector(connector.SandboxConfig{}),
+			calls:            map[connector.Operation]int{},
+		},
+		accounts: &memAccounts{accounts: map[string]*model.Accounts{}},
+		ledger:   newMemLedger(),
+	}
+	txs := &memTransactions{txs: map[uuid.UUID]*model.Transaction{tx.ID: tx}}
+	connectors := connector.NewRegistry()
+	connectors.Register(f.conn)
+
+	txManager := &fakeTxManager{repos: &repo.TxRepositories{
+		Transactions: transactions(txs),
+		Settlements:  f.settlements,
+		Accounts:     f.accounts,
+		Ledger:       f.ledger,
+		Outbox:       &memOutbox{},
+	}}
+	f.svc = NewSettlementService(txs, f.settlements, f.accounts, txManager, nil, connectors, nil, nil, nil)
+	return f
+}
+
+func (f *settlementFixture) process(t *testing.T) error {
+	t.Helper()
+	payload, err := json.Marshal(SettlementPayload{TransactionID: f.tx.ID, Routing: map[string]any{"route": connector.SandboxName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.svc.ProcessSettlement(context.Background(), payload)
+}
+
+// settledOnce checks the transaction completed with one successful attempt
+// crediting the merchant once.
+func (f *settlementFixture) settledOnce(t *testing.T) {
+	t.Helper()
+	if f.tx.Status != model.TransactionStatusCompleted {
+		t.Fatalf("transaction is %s, want completed", f.tx.Status)
+	}
+	if n := len(f.settlements.attempts); n != 1 || f.settlements.attempts[0].Status != string(model.SettlementSuccess) {
+		t.Fatalf("%d settlement attempts, want one successful", n)
+	}
+	merchant := f.accounts.get(f.tx.MerchantID, model.AccountTypeMerchant, "NGN")
+	if got := f.ledger.balances[merchant.ID]; got != 10000 {
+		t.Errorf("merchant balance moved %d, want 10000", got)
+	}
+}
+
+func TestProcessSettlementResumesApprovedCharge(t *testing.T) {
+	f := newSettlementFixture(func(m *memTransactions) repo.TransactionRepository {
+		return &failOnceTransactions{memTransactions: m}
+	})
+
+	if err := f.process(t); err == nil {
+		t.Fatal("completion failure was not returned")
+	}
+	attempt := f.settlements.attempts[0]
+	if attempt.Status != string(model.SettlementPending) || attempt.Metadata["connector_outcome"] != "approved" {
+		t.Fatalf("attempt is %s with outcome %v, want pending and approved", attempt.Status, attempt.Metadata["connector_outcome"])
+	}
+
+	// The retry asks the connector about the charge instead of making another
+	if err := f.process(t); err != nil {
+		t.Fatal(err)
+	}
+	f.settledOnce(t)
+	if a, c, s := f.conn.calls[connector.OperationAuthorize], f.conn.calls[connector.OperationCapture], f.conn.calls[connector.OperationStatus]; a != 1 || c != 1 || s != 1 {
+		t.Fatalf("authorize %d, capture %d, status %d; want one of each", a, c, s)
+	}
+}
+
+func TestProcessSettlementKeepsTimedOutAttemptPending(t *testing.T) {
+	f := newSettlementFixture(func(m *memTransactions) repo.TransactionRepository { return m })
+	f.tx.Metadata["sandbox_capture_outcome"] = string(connector.SandboxTimeout)
+
+	if err := f.process(t); !errors.Is(err, connector.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	attempt := f.settlements.attempts[0]
+	if attempt.Status != string(model.SettlementPending) || attempt.Metadata["connector_outcome"] != attemptOutcomeUnknown {
+		t.Fatalf("attempt is %s with outcome %v, want pending and unknown", attempt.Status, attempt.Metadata["connector_outcome"])
+	}
+
+	// The retry captures the same authorization
+	delete(f.tx.Metadata, "sandbox_capture_outcome")
+	if err := f.process(t); err != nil {
+		t.Fatal(err)
+	}
+	f.settledOnce(t)
+	if a, c := f.conn.calls[connector.OperationAuthorize], f.conn.calls[connector.OperationCapture]; a != 1 || c != 2 {
+		t.Fatalf("authorize %d, capture %d; want 1 and 2", a, c)
+	}
+}
+
+func TestProcessSettlementFailsAttemptWhenUnavailable(t *testing.T) {
+	f := newSettlementFixture(func(m *memTransactions) repo.TransactionRepository { return m })
+	f.tx.Metadata[connector.SandboxOutcomeKey] = string(connector.SandboxUnavailable)
+
+	if err := f.process(t); !errors.Is(err, connector.ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if attempt := f.settlements.attempts[0]; attempt.Status != string(model.SettlementFailed) {
+		t.Fatalf("attempt is %s, want failed", attempt.Status)
+	}
+
+	// Nothing reached the connector, so the retry charges on a new attempt
+	delete(f.tx.Metadata, connector.SandboxOutcomeKey)
+	if err := f.process(t); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.settlements.attempts); n != 2 || f.settlements.attempts[1].Status != string(model.SettlementSuccess) {
+		t.Fatalf("%d attempts, want a second, successful one", n)
+	}
+}
@@ -2,34 +2,39 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
-	"github.com/BjornOnGit/payment-gateway/internal/bus"
 	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
 	"github.com/BjornOnGit/payment-gateway/internal/model"
 	"github.com/BjornOnGit/payment-gateway/internal/service/dto"
+	"github.com/BjornOnGit/payment-gateway/internal/util"
 	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidAmount   = errors.New("invalid amount")
 	ErrInvalidCurrency = errors.New("unsupported currency")
+	ErrInvalidCapture  = errors.New("invalid capture method")
 )
 
-var allowedCurrencies = map[string]bool{
-	"NGN": true,
-}
-
 type TransactionService struct {
-	repo repo.TransactionRepository
-	bus  bus.Bus
+	repo       repo.TransactionRepository
+	txManager  repo.TxManager
+	currencies *model.CurrencyRegistry
+	merchants  repo.MerchantRepository
 }
 
-func NewTransactionService(r repo.TransactionRepository, b bus.Bus) *TransactionService {
-	return &TransactionService{repo: r, bus: b}
+// NewTransactionService creates a transaction service accepting the enabled
+// currencies in the registry; nil uses the built-in defaults. When merchants
+// is set, transactions for unknown or suspended merchants are rejected.
+func NewTransactionService(r repo.TransactionRepository, txManager repo.TxManager, currencies *model.CurrencyRegistry, merchants repo.MerchantRepository) *TransactionService {
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	return &TransactionService{repo: r, txManager: txManager, currencies: currencies, merchants: merchants}
 }
 
 func (s *TransactionService) CreateTransaction(ctx context.Context,
@@ -40,8 +45,40 @@ func (s *TransactionService) CreateTransaction(ctx context.Context,
 		return uuid.Nil, ErrInvalidAmount
 	}
 
-	if !allowedCurrencies[strings.ToUpper(input.Currency)] {
-		return uuid.Nil, ErrInvalidCurrency
+	currency, err := s.currencies.Validate(input.Currency)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrInvalidCurrency, err)
+	}
+
+	// Merchants may settle in another enabled currency; the amount is
+	// converted when the transaction settles. Without an explicit choice the
+	// merchant's configured settlement currency applies.
+	settlementCurrency := currency.Code
+	requested := input.SettlementCurrency
+	if s.merchants != nil {
+		merchant, err := activeMerchant(ctx, s.merchants, input.MerchantID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if requested == "" {
+			requested = merchant.SettlementCurrency
+		}
+	}
+	if requested != "" {
+		c, err := s.currencies.Validate(requested)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%w: settlement %v", ErrInvalidCurrency, err)
+		}
+		settlementCurrency = c.Code
+	}
+
+	captureMethod := model.CaptureMethod(strings.ToLower(input.CaptureMethod))
+	switch captureMethod {
+	case "":
+		captureMethod = model.CaptureAutomatic
+	case model.CaptureAutomatic, model.CaptureManual:
+	default:
+		return uuid.Nil, ErrInvalidCapture
 	}
 
 	// Initialize metadata if nil
@@ -51,29 +88,33 @@ func (s *TransactionService) CreateTransaction(ctx context.Context,
 	}
 
 	tx := &model.Transaction{
-		ID:         uuid.New(),
-		Amount:     input.Amount,
-		Currency:   strings.ToUpper(input.Currency),
-		UserID:     input.UserID,
-		MerchantID: input.MerchantID,
-		Status:     model.TransactionStatusPending,
-		Metadata:   metadata,
-		CreatedAt:  time.Now().UTC(),
-		UpdatedAt:  time.Now().UTC(),
+		ID:                 uuid.New(),
+		Amount:             input.Amount,
+		Currency:           currency.Code,
+		SettlementCurrency: settlementCurrency,
+		UserID:             input.UserID,
+		MerchantID:         input.MerchantID,
+		Status:             model.TransactionStatusPending,
+		Metadata:           metadata,
+		CaptureMethod:      captureMethod,
+		CreatedAt:          time.Now().UTC(),
+		UpdatedAt:          time.Now().UTC(),
 	}
 
 	// ---- Persist ----
-	if err := s.repo.CreateTransaction(ctx, tx); err != nil {
+	// The row and its transaction.created event are written atomically; the
+	// outbox relay publishes the event once the commit succeeds.
+	err = s.txManager.WithTx(ctx, func(r *repo.TxRepositories) error {
+		if err := r.Transactions.CreateTransaction(ctx, tx); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, r, "transaction.created", tx.ID.String(), tx)
+	})
+	if err != nil {
 		return uuid.Nil, err
 	}
 
-	// Publish event if bus is configured
-	if s.bus != nil {
-		go func() {
-			payload, _ := json.Marshal(tx)
-			_ = s.bus.Publish(context.Background(), "transaction.created", tx.ID.String(), payload)
-		}()
-	}
+	util.TransactionsCreatedTotal.Inc()
 
 	return tx.ID, nil
 }
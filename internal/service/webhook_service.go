@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/db/repo"
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/BjornOnGit/payment-gateway/internal/webhook"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// WebhookTopics are the bus topics forwarded to merchants.
+var WebhookTopics = []string{"settlement.completed", "settlement.failed"}
+
+// webhookLease bounds how long a claimed delivery stays invisible to other
+// dispatchers while it is being sent.
+const webhookLease = time.Minute
+
+// WebhookService turns settlement events into signed merchant webhooks. Each
+// event is recorded in webhook_deliveries before it is sent; failed sends
+// are retried with exponential backoff by RunRetries.
+type WebhookService struct {
+	deliveries repo.WebhookDeliveryRepository
+	merchants  repo.MerchantRepository
+	txRepo     repo.TransactionRepository
+	sender     *webhook.Sender
+	policy     webhook.RetryPolicy
+	logger     *zap.Logger
+}
+
+func NewWebhookService(
+	deliveries repo.WebhookDeliveryRepository,
+	merchants repo.MerchantRepository,
+	txRepo repo.TransactionRepository,
+	sender *webhook.Sender,
+	policy webhook.RetryPolicy,
+	logger *zap.Logger,
+) *WebhookService {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if sender == nil {
+		sender = webhook.NewSender(0)
+	}
+	def := webhook.DefaultRetryPolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = def.MaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = def.BaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = def.MaxDelay
+	}
+	return &WebhookService{
+		deliveries: deliveries,
+		merchants:  merchants,
+		txRepo:     txRepo,
+		sender:     sender,
+		policy:     policy,
+		logger:     logger,
+	}
+}
+
+// webhookEvent is the body POSTed to merchants.
+type webhookEvent struct {
+	ID        uuid.UUID      `json:"id"` // the delivery id
+	Type      string         `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      map[string]any `json:"data"`
+}
+
+// HandleEvent records a delivery for a settlement event and makes the first
+// attempt. Redelivered bus messages are deduplicated on the settlement id.
+// Merchants without a webhook URL are skipped.
+func (s *WebhookService) HandleEvent(ctx context.Context, topic string, payload []byte) error {
+	var data map[string]any
+	if err := json.Unmarshal(payload, &data); err != nil {
+		s.logger.Error("invalid event payload, dropping", zap.String("topic", topic), zap.Error(err))
+		return nil
+	}
+
+	txIDStr, _ := data["transaction_id"].(string)
+	txID, err := uuid.Parse(txIDStr)
+	if err != nil {
+		s.logger.Error("event without transaction_id, dropping", zap.String("topic", topic))
+		return nil
+	}
+	tx, err := s.txRepo.GetByID(ctx, txID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if tx == nil {
+		s.logger.Warn("transaction not found for webhook event", zap.String("transaction_id", txIDStr))
+		return nil
+	}
+
+	merchant, err := s.merchants.GetByID(ctx, tx.MerchantID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch merchant: %w", err)
+	}
+	if merchant == nil || merchant.WebhookURL == "" {
+		return nil
+	}
+
+	eventKey, _ := data["settlement_id"].(string)
+	if eventKey == "" {
+		eventKey = txIDStr
+	}
+	data["merchant_id"] = merchant.ID.String()
+	data["reference"] = tx.ID.String()
+
+	// Recorded already claimed, so the retry loop leaves it alone while it
+	// is sent below and only picks it up if this process dies first
+	now := time.Now().UTC()
+	leaseUntil := now.Add(webhookLease)
+	delivery := &model.WebhookDelivery{
+		ID:            uuid.New(),
+		MerchantID:    merchant.ID,
+		EventType:     topic,
+		EventKey:      eventKey,
+		URL:           merchant.WebhookURL,
+		Status:        model.WebhookPending,
+		NextAttemptAt: &leaseUntil,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	delivery.Payload, err = json.Marshal(webhookEvent{ID: delivery.ID, Type: topic, CreatedAt: now, Data: data})
+	if err != nil {
+		return err
+	}
+
+	created, err := s.deliveries.Create(ctx, delivery)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	if !created {
+		s.logger.Info("webhook already recorded for event", zap.String("event", topic), zap.String("event_key", eventKey))
+		return nil
+	}
+
+	s.attempt(ctx, delivery, merchant.WebhookSecret, false)
+	return nil
+}
+
+// RunRetries sends due deliveries every interval until ctx is cancelled.
+func (s *WebhookService) RunRetries(ctx context.Context, interval time.Duration) {
+	s.logger.Info("webhook retry loop started", zap.Duration("interval", interval))
+	const batchSize = 50
+	for {
+		n, err := s.RetryDue(ctx, batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("webhook retry pass failed", zap.Error(err))
+		}
+
+		// Keep going without delay while full batches are coming back
+		if err == nil && n == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("webhook retry loop stopped")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RetryDue claims up to limit due deliveries and attempts each once.
+func (s *WebhookService) RetryDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.deliveries.ClaimDue(ctx, time.Now().UTC(), webhookLease, limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		merchant, err := s.merchants.GetByID(ctx, d.MerchantID)
+		if err != nil {
+			s.logger.Error("failed to fetch merchant for webhook", zap.String("delivery_id", d.ID.String()), zap.Error(err))
+			continue
+		}
+		secret := ""
+		if merchant != nil {
+			secret = merchant.WebhookSecret
+		}
+		s.attempt(ctx, d, secret, false)
+	}
+	return len(due), nil
+}
+
+// Redeliver sends a delivery again right away, whatever its status. The
+// attempt is recorded like any other; if it fails the normal retry schedule
+// continues from there.
+func (s *WebhookService) Redeliver(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	d, err := s.deliveries.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	merchant, err := s.merchants.GetByID(ctx, d.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant == nil {
+		return nil, model.ErrMerchantNotFound
+	}
+
+	// Claim it so the retry loop doesn't send it concurrently
+	if err := s.deliveries.Reschedule(ctx, d.ID, time.Now().UTC().Add(webhookLease)); err != nil {
+		return nil, err
+	}
+	// Send to the merchant's current endpoint
+	if merchant.WebhookURL != "" {
+		d.URL = merchant.WebhookURL
+	}
+	s.attempt(ctx, d, merchant.WebhookSecret, true)
+
+	return s.deliveries.GetByID(ctx, id)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, filter model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, error) {
+	return s.deliveries.List(ctx, filter)
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	d, err := s.deliveries.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return d, nil
+}
+
+// attempt sends d once and records the outcome: succeeded on a 2xx,
+// otherwise pending with a backoff or failed once attempts run out.
+func (s *WebhookService) attempt(ctx context.Context, d *model.WebhookDelivery, secret string, manual bool) {
+	n := d.Attempts + 1
+	rec := model.WebhookAttempt{Attempt: n, At: time.Now().UTC(), Manual: manual}
+	log := s.logger.With(
+		zap.String("delivery_id", d.ID.String()),
+		zap.String("merchant_id", d.MerchantID.String()),
+		zap.String("event", d.EventType),
+		zap.Int("attempt", n),
+	)
+
+	var res *webhook.Result
+	var err error
+	if secret == "" {
+		err = errors.New("merchant has no webhook secret")
+	} else {
+		res, err = s.sender.Send(ctx, d.URL, secret, d.ID.String(), d.EventType, d.Payload)
+	}
+	if res != nil {
+		rec.StatusCode = res.StatusCode
+		rec.Response = res.Body
+		rec.DurationMs = res.Duration.Milliseconds()
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else if !res.Delivered() {
+		rec.Error = fmt.Sprintf("endpoint returned %d", res.StatusCode)
+	}
+
+	status := model.WebhookSucceeded
+	var next *time.Time
+	if rec.Error != "" {
+		status = model.WebhookPending
+		if n >= s.policy.MaxAttempts && !manual {
+			status = model.WebhookFailed
+		} else {
+			at := time.Now().UTC().Add(s.policy.Backoff(n))
+			next = &at
+		}
+	}
+
+	if err := s.deliveries.RecordAttempt(ctx, d.ID, rec, status, next); err != nil {
+		log.Error("failed to record webhook attempt", zap.Error(err))
+		return
+	}
+
+	switch status {
+	case model.WebhookSucceeded:
+		log.Info("webhook delivered", zap.Int("status_code", rec.StatusCode))
+	case model.WebhookFailed:
+		log.Error("webhook delivery failed permanently", zap.String("error", rec.Error))
+	default:
+		log.Warn("webhook attempt failed, will retry", zap.String("error", rec.Error), zap.Timep("next_attempt_at", next))
+	}
+}
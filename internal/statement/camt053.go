@@ -0,0 +1,228 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+// camt.053 elements, matched by local name so any schema version
+// (camt.053.001.02 onwards) parses. Only the fields used are modelled.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID      string      `xml:"Id"`
+	Account camtAccount `xml:"Acct"`
+	FromTo  struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+	Ccy   string `xml:"Ccy"`
+}
+
+type camtEntry struct {
+	Amount      camtAmount    `xml:"Amt"`
+	Indicator   string        `xml:"CdtDbtInd"`
+	Reversal    bool          `xml:"RvslInd"`
+	Status      camtStatus    `xml:"Sts"`
+	BookingDate camtDate      `xml:"BookgDt"`
+	ValueDate   camtDate      `xml:"ValDt"`
+	BankRef     string        `xml:"AcctSvcrRef"`
+	Details     []camtDetails `xml:"NtryDtls>TxDtls"`
+	Info        string        `xml:"AddtlNtryInf"`
+}
+
+type camtDetails struct {
+	Amount     *camtAmount `xml:"Amt"`
+	TxAmount   *camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	Indicator  string      `xml:"CdtDbtInd"`
+	EndToEndID string      `xml:"Refs>EndToEndId"`
+	BankRef    string      `xml:"Refs>AcctSvcrRef"`
+	Remittance []string    `xml:"RmtInf>Ustrd"`
+	Info       string      `xml:"AddtlTxInf"`
+}
+
+// camtStatus is a plain code before camt.053.001.08 and <Cd> inside it
+// from then on.
+type camtStatus struct {
+	Code  string `xml:"Cd"`
+	Value string `xml:",chardata"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d camtDate) String() string {
+	if d.Date != "" {
+		return d.Date
+	}
+	return d.DateTime
+}
+
+// parseCamt053 reads an ISO 20022 bank-to-customer statement. Booked
+// entries become statement lines; pending and informational entries are
+// skipped. A batch entry whose transaction details carry their own amounts
+// is split into one line per transaction.
+func parseCamt053(r io.Reader, currencies *model.CurrencyRegistry) ([]*model.Statement, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, fmt.Errorf("%w: no camt.053 statement found", ErrInvalid)
+	}
+
+	statements := make([]*model.Statement, 0, len(doc.Statements))
+	for _, s := range doc.Statements {
+		st := &model.Statement{
+			ID:       s.ID,
+			Account:  s.Account.IBAN,
+			Currency: s.Account.Ccy,
+		}
+		if st.Account == "" {
+			st.Account = s.Account.Other
+		}
+		if s.FromTo.From != "" {
+			from, err := parseDate(s.FromTo.From)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseDate(s.FromTo.To)
+			if err != nil {
+				return nil, err
+			}
+			st.From, st.To = from, to
+		}
+
+		for _, e := range s.Entries {
+			status := e.Status.Code
+			if status == "" {
+				status = strings.TrimSpace(e.Status.Value)
+			}
+			if status != "" && status != "BOOK" {
+				continue
+			}
+			lines, err := camtLines(e, currencies)
+			if err != nil {
+				return nil, err
+			}
+			st.Lines = append(st.Lines, lines...)
+		}
+		if st.Currency == "" && len(st.Lines) > 0 {
+			st.Currency = st.Lines[0].Currency
+		}
+		statements = append(statements, st)
+	}
+	return statements, nil
+}
+
+func camtLines(e camtEntry, currencies *model.CurrencyRegistry) ([]*model.StatementLine, error) {
+	valueDate, err := camtParseDate(e.ValueDate, e.BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	bookingDate, err := camtParseDate(e.BookingDate, e.ValueDate)
+	if err != nil {
+		return nil, err
+	}
+
+	line := func(amount camtAmount, indicator string, d *camtDetails) (*model.StatementLine, error) {
+		minor, err := parseAmount(currencies, amount.Value, amount.Ccy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: camt.053 entry %s: %v", ErrInvalid, e.BankRef, err)
+		}
+		l := &model.StatementLine{
+			BankReference: e.BankRef,
+			Amount:        minor,
+			Currency:      strings.ToUpper(amount.Ccy),
+			Direction:     model.StatementCredit,
+			ValueDate:     valueDate,
+			BookingDate:   bookingDate,
+			Description:   e.Info,
+		}
+		// A reversed credit takes money out, and vice versa
+		if (indicator == "DBIT") != e.Reversal {
+			l.Direction = model.StatementDebit
+		}
+		if d != nil {
+			if d.EndToEndID != "" && !strings.EqualFold(d.EndToEndID, "NOTPROVIDED") {
+				l.Reference = d.EndToEndID
+			}
+			if d.BankRef != "" {
+				l.BankReference = d.BankRef
+			}
+			if text := strings.TrimSpace(strings.Join(append(d.Remittance, d.Info), " ")); text != "" {
+				l.Description = text
+			}
+		}
+		return l, nil
+	}
+
+	split := len(e.Details) > 1
+	for _, d := range e.Details {
+		if d.Amount == nil && d.TxAmount == nil {
+			split = false
+		}
+	}
+	if !split {
+		var d *camtDetails
+		if len(e.Details) > 0 {
+			d = &e.Details[0]
+		}
+		l, err := line(e.Amount, e.Indicator, d)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.StatementLine{l}, nil
+	}
+
+	lines := make([]*model.StatementLine, 0, len(e.Details))
+	for i := range e.Details {
+		d := &e.Details[i]
+		amount := d.Amount
+		if amount == nil {
+			amount = d.TxAmount
+		}
+		indicator := d.Indicator
+		if indicator == "" {
+			indicator = e.Indicator
+		}
+		l, err := line(*amount, indicator, d)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// camtParseDate parses d, falling back to other when d is absent.
+func camtParseDate(d, other camtDate) (time.Time, error) {
+	s := d.String()
+	if s == "" {
+		s = other.String()
+	}
+	if s == "" {
+		return time.Time{}, fmt.Errorf("%w: camt.053 entry without a date", ErrInvalid)
+	}
+	return parseDate(s)
+}
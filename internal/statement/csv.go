@@ -0,0 +1,123 @@
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+// csvColumns maps the accepted header names (case-insensitive) to fields.
+var csvColumns = map[string]string{
+	"date":               "value_date",
+	"value_date":         "value_date",
+	"valuedate":          "value_date",
+	"booking_date":       "booking_date",
+	"bookingdate":        "booking_date",
+	"amount":             "amount",
+	"currency":           "currency",
+	"reference":          "reference",
+	"external_reference": "reference",
+	"end_to_end_id":      "reference",
+	"bank_reference":     "bank_reference",
+	"description":        "description",
+	"narrative":          "description",
+	"direction":          "direction",
+	"type":               "direction",
+	"account":            "account",
+}
+
+// parseCSV reads a statement exported as CSV with a header row. It needs
+// date, amount and currency columns. A direction column (credit/debit or
+// C/D) is optional; without one a negative amount is a debit. All rows form
+// a single statement.
+func parseCSV(r io.Reader, currencies *model.CurrencyRegistry) ([]*model.Statement, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalid, err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\xef\xbb\xbf")))
+		if field, ok := csvColumns[h]; ok {
+			if _, dup := cols[field]; !dup {
+				cols[field] = i
+			}
+		}
+	}
+	for _, required := range []string{"value_date", "amount", "currency"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("%w: csv has no %s column", ErrInvalid, required)
+		}
+	}
+
+	st := &model.Statement{}
+	for row := 2; ; row++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		get := func(field string) string {
+			if i, ok := cols[field]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if get("amount") == "" && get("value_date") == "" {
+			continue
+		}
+
+		l := &model.StatementLine{
+			Reference:     get("reference"),
+			BankReference: get("bank_reference"),
+			Currency:      strings.ToUpper(get("currency")),
+			Description:   get("description"),
+		}
+		if l.ValueDate, err = parseDate(get("value_date")); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		if s := get("booking_date"); s != "" {
+			if l.BookingDate, err = parseDate(s); err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+		}
+		if l.Amount, err = parseAmount(currencies, get("amount"), l.Currency); err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalid, row, err)
+		}
+
+		l.Direction = model.StatementCredit
+		switch strings.ToLower(get("direction")) {
+		case "":
+			if l.Amount < 0 {
+				l.Direction = model.StatementDebit
+			}
+		case "c", "cr", "crdt", "credit":
+		case "d", "dr", "dbit", "debit":
+			l.Direction = model.StatementDebit
+		default:
+			return nil, fmt.Errorf("%w: row %d: unknown direction %q", ErrInvalid, row, get("direction"))
+		}
+		if l.Amount < 0 {
+			l.Amount = -l.Amount
+		}
+
+		if st.Account == "" {
+			st.Account = get("account")
+		}
+		if st.Currency == "" {
+			st.Currency = l.Currency
+		}
+		st.Lines = append(st.Lines, l)
+	}
+	return []*model.Statement{st}, nil
+}
@@ -0,0 +1,70 @@
+package statement
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+// DefaultDateTolerance allows for bank processing between settling a
+// payment and the money showing on the statement.
+const DefaultDateTolerance = 3 * 24 * time.Hour
+
+// ToleranceFromEnv reads RECON_AMOUNT_TOLERANCE (minor units, default 0)
+// and RECON_DATE_TOLERANCE_DAYS (default 3).
+func ToleranceFromEnv() (model.MatchTolerance, error) {
+	tol := model.MatchTolerance{Date: DefaultDateTolerance}
+	if v := os.Getenv("RECON_AMOUNT_TOLERANCE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return tol, fmt.Errorf("invalid RECON_AMOUNT_TOLERANCE %q", v)
+		}
+		tol.Amount = n
+	}
+	if v := os.Getenv("RECON_DATE_TOLERANCE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return tol, fmt.Errorf("invalid RECON_DATE_TOLERANCE_DAYS %q", v)
+		}
+		tol.Date = time.Duration(n) * 24 * time.Hour
+	}
+	return tol, nil
+}
+
+// Result is the outcome of comparing a statement line with the settlement
+// its reference points to.
+type Result struct {
+	Status  string   // model.ReconMatched or model.ReconMismatch
+	Reasons []string // why it is a mismatch
+}
+
+// Match compares a credit line with a settlement: the currencies must be
+// equal, the amount (the settlement's gross amount) within tol.Amount and
+// the value date within tol.Date of the day the settlement was made.
+func Match(l *model.StatementLine, s *model.Settlements, tol model.MatchTolerance) Result {
+	var reasons []string
+	if !strings.EqualFold(l.Currency, s.Currency) {
+		reasons = append(reasons, fmt.Sprintf("currency %s, settlement is %s", l.Currency, s.Currency))
+	} else if diff := abs(l.Amount - s.Amount); diff > tol.Amount {
+		reasons = append(reasons, fmt.Sprintf("amount differs by %d", l.Amount-s.Amount))
+	}
+	if diff := l.ValueDate.Sub(day(s.CreatedAt)); diff > tol.Date || -diff > tol.Date {
+		reasons = append(reasons, fmt.Sprintf("value date %s is %d days from settlement",
+			l.ValueDate.Format("2006-01-02"), int(diff.Hours()/24)))
+	}
+	if len(reasons) > 0 {
+		return Result{Status: model.ReconMismatch, Reasons: reasons}
+	}
+	return Result{Status: model.ReconMatched}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var (
+	// {1:...}{2:...}{4: wrappers in front of the text block
+	mt940BlockHeader = regexp.MustCompile(`^(\{\d:[^{}]*\})*(\{4:)?`)
+	mt940Tag         = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+	// :60F:/:62F: balances, e.g. C260303EUR1000,00
+	mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+	// :61: value date, entry date, mark, funds code, amount, type,
+	// customer reference, //bank reference, supplementary details
+	mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})([^/\n]*)(?://([^\n]*))?(?:\n([\s\S]*))?$`)
+	// Structured :86: subfield with the end-to-end reference
+	mt940EndToEnd = regexp.MustCompile(`/EREF/([^/\n]+)`)
+)
+
+type mt940Field struct {
+	tag, value string
+}
+
+// parseMT940 reads SWIFT MT940 customer statements. Each :20: starts a
+// statement; :61: lines become statement lines and the :86: that follows
+// one is its description. The end-to-end reference is taken from an /EREF/
+// subfield of :86: when present, otherwise from the :61: customer
+// reference (unless it is NONREF).
+func parseMT940(r io.Reader, currencies *model.CurrencyRegistry) ([]*model.Statement, error) {
+	fields, err := mt940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		statements []*model.Statement
+		st         *model.Statement
+		last       *model.StatementLine
+	)
+	for _, f := range fields {
+		if f.tag == "20" {
+			st = &model.Statement{ID: f.value}
+			statements = append(statements, st)
+			last = nil
+			continue
+		}
+		if st == nil {
+			return nil, fmt.Errorf("%w: mt940 field :%s: before :20:", ErrInvalid, f.tag)
+		}
+
+		switch f.tag {
+		case "25":
+			st.Account = f.value
+		case "60F", "60M":
+			currency, date, err := mt940ParseBalance(f.value)
+			if err != nil {
+				return nil, err
+			}
+			st.Currency, st.From = currency, date
+		case "62F", "62M":
+			_, date, err := mt940ParseBalance(f.value)
+			if err != nil {
+				return nil, err
+			}
+			st.To = date
+			last = nil
+		case "61":
+			if st.Currency == "" {
+				return nil, fmt.Errorf("%w: mt940 :61: before the opening balance", ErrInvalid)
+			}
+			last, err = mt940ParseLine(f.value, st.Currency, currencies)
+			if err != nil {
+				return nil, err
+			}
+			st.Lines = append(st.Lines, last)
+		case "86":
+			if last == nil {
+				continue // information for the whole statement
+			}
+			last.Description = strings.TrimSpace(strings.ReplaceAll(f.value, "\n", " "))
+			if m := mt940EndToEnd.FindStringSubmatch(f.value); m != nil && !strings.EqualFold(m[1], "NOTPROVIDED") {
+				last.Reference = strings.TrimSpace(m[1])
+			}
+		}
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("%w: no mt940 statement found", ErrInvalid)
+	}
+	return statements, nil
+}
+
+// mt940Fields splits the text into tagged fields, joining continuation
+// lines and dropping SWIFT block wrappers and trailers.
+func mt940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r ")
+		line = strings.TrimPrefix(line, "\xef\xbb\xbf")
+		line = mt940BlockHeader.ReplaceAllString(line, "")
+		if line == "" || line == "-" || strings.HasPrefix(line, "-}") || strings.HasPrefix(line, "{") {
+			continue
+		}
+		if m := mt940Tag.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: m[2]})
+			continue
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: mt940 text before the first field", ErrInvalid)
+		}
+		fields[len(fields)-1].value += "\n" + line
+	}
+	return fields, sc.Err()
+}
+
+func mt940ParseBalance(s string) (string, time.Time, error) {
+	m := mt940Balance.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", time.Time{}, fmt.Errorf("%w: bad mt940 balance %q", ErrInvalid, s)
+	}
+	date, err := parseDate(m[2])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return m[3], date, nil
+}
+
+func mt940ParseLine(s, currency string, currencies *model.CurrencyRegistry) (*model.StatementLine, error) {
+	m := mt940Line.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%w: bad mt940 :61: %q", ErrInvalid, s)
+	}
+	valueDate, err := parseDate(m[1])
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseAmount(currencies, m[5], currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: mt940 :61: %v", ErrInvalid, err)
+	}
+
+	l := &model.StatementLine{
+		Amount:        amount,
+		Currency:      currency,
+		ValueDate:     valueDate,
+		BankReference: strings.TrimSpace(m[8]),
+		Description:   strings.TrimSpace(m[9]),
+	}
+	// A reversal of a credit takes money out, and vice versa
+	switch m[3] {
+	case "C", "RD":
+		l.Direction = model.StatementCredit
+	default:
+		l.Direction = model.StatementDebit
+	}
+	if ref := strings.TrimSpace(m[7]); !strings.EqualFold(ref, "NONREF") {
+		l.Reference = ref
+	}
+	if m[2] != "" {
+		// The entry date has no year; take the value date's, across a new year
+		booking, err := time.Parse("0102", m[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad mt940 entry date %q", ErrInvalid, m[2])
+		}
+		year := valueDate.Year()
+		switch {
+		case booking.Month() == time.January && valueDate.Month() == time.December:
+			year++
+		case booking.Month() == time.December && valueDate.Month() == time.January:
+			year--
+		}
+		l.BookingDate = time.Date(year, booking.Month(), booking.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return l, nil
+}
@@ -0,0 +1,176 @@
+// Package statement reads bank account statements (CSV, SWIFT MT940 and ISO
+// 20022 camt.053) and matches their lines against settlements.
+package statement
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown statement format")
+	ErrInvalid       = errors.New("invalid statement")
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatMT940   Format = "mt940"
+	FormatCamt053 Format = "camt.053"
+)
+
+// ParseFormat accepts "csv", "mt940", "camt.053" and "camt053". An empty
+// string returns "" so the caller can detect the format instead.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return "", nil
+	case "csv":
+		return FormatCSV, nil
+	case "mt940", "sta":
+		return FormatMT940, nil
+	case "camt.053", "camt053":
+		return FormatCamt053, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// Detect guesses the format of a statement from its file name and first
+// bytes: XML is camt.053, a leading MT940 tag (or SWIFT block header) is
+// MT940, anything else is CSV.
+func Detect(name string, head []byte) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xml":
+		return FormatCamt053
+	case ".sta", ".mt940", ".940":
+		return FormatMT940
+	}
+	head = bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(head, []byte("<")):
+		return FormatCamt053
+	case bytes.HasPrefix(head, []byte(":20:")), bytes.HasPrefix(head, []byte("{1:")):
+		return FormatMT940
+	}
+	return FormatCSV
+}
+
+// Parse reads every statement in r. A file may hold several (MT940 files
+// often carry one per day). Lines are numbered from 1 within each statement.
+// A nil registry uses the default currencies.
+func Parse(r io.Reader, format Format, currencies *model.CurrencyRegistry) ([]*model.Statement, error) {
+	if currencies == nil {
+		currencies = model.DefaultCurrencyRegistry()
+	}
+	var (
+		statements []*model.Statement
+		err        error
+	)
+	switch format {
+	case FormatCSV:
+		statements, err = parseCSV(r, currencies)
+	case FormatMT940:
+		statements, err = parseMT940(r, currencies)
+	case FormatCamt053:
+		statements, err = parseCamt053(r, currencies)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range statements {
+		st.Format = string(format)
+		for i, l := range st.Lines {
+			l.Line = i + 1
+		}
+		period(st)
+	}
+	return statements, nil
+}
+
+// period fills a statement's date range from its lines when the file does
+// not carry one.
+func period(st *model.Statement) {
+	for _, l := range st.Lines {
+		if st.From.IsZero() || l.ValueDate.Before(st.From) {
+			st.From = l.ValueDate
+		}
+		if l.ValueDate.After(st.To) {
+			st.To = l.ValueDate
+		}
+	}
+}
+
+// Keys returns a stable identifier for each line of st, in order. It is
+// derived from the account and the line's content rather than its position,
+// so the same entry gets the same key when it appears again in a later or
+// overlapping export; identical entries are told apart by occurrence.
+func Keys(st *model.Statement) []string {
+	keys := make([]string, len(st.Lines))
+	seen := make(map[string]int)
+	for i, l := range st.Lines {
+		content := strings.Join([]string{
+			st.Account,
+			l.ValueDate.Format("2006-01-02"),
+			string(l.Direction),
+			fmt.Sprint(l.Amount),
+			l.Currency,
+			l.Reference,
+			l.BankReference,
+			l.Description,
+		}, "|")
+		seen[content]++
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", content, seen[content])))
+		keys[i] = "stmt:" + hex.EncodeToString(sum[:16])
+	}
+	return keys
+}
+
+// parseAmount converts a decimal amount in major units, with a dot or comma
+// as the decimal separator, into minor units of currency. Zeros past the
+// currency's exponent are dropped.
+func parseAmount(currencies *model.CurrencyRegistry, s, currency string) (int64, error) {
+	c, err := currencies.Get(currency)
+	if err != nil {
+		return 0, err
+	}
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	if whole, frac, ok := strings.Cut(s, "."); ok {
+		for len(frac) > c.Exponent && strings.HasSuffix(frac, "0") {
+			frac = frac[:len(frac)-1]
+		}
+		s = whole
+		if frac != "" {
+			s += "." + frac
+		}
+	}
+	return c.ParseAmount(s)
+}
+
+// parseDate accepts ISO dates and timestamps and the common day-first and
+// compact bank formats. The result is midnight UTC of that day.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05", "02/01/2006", "02.01.2006", "20060102", "060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return day(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: unrecognised date %q", ErrInvalid, s)
+}
+
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
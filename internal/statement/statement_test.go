@@ -0,0 +1,246 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/model"
+	"github.com/google/uuid"
+)
+
+const txnID = "3f2a8c1e-5b7d-4e9a-9c3b-2d1e0f4a6b8c"
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name, head string
+		want       Format
+	}{
+		{"stmt.xml", "", FormatCamt053},
+		{"stmt.sta", "", FormatMT940},
+		{"stmt.txt", "{1:F01BANKBEBBAXXX0000000000}{2:I940}{4:\n:20:X", FormatMT940},
+		{"stmt.txt", ":20:STMT1", FormatMT940},
+		{"stmt", "<?xml version=\"1.0\"?>", FormatCamt053},
+		{"stmt.csv", "date,amount,currency", FormatCSV},
+	}
+	for _, c := range cases {
+		if got := Detect(c.name, []byte(c.head)); got != c.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", c.name, c.head, got, c.want)
+		}
+	}
+	if _, err := ParseFormat("bai2"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseFormat(bai2) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	in := "Value_Date,Amount,Currency,Reference,Description,Direction\n" +
+		"2026-03-04,9750.00,ngn," + txnID + ",card settlement,C\n" +
+		"05/03/2026,120.5,NGN,,bank charges,debit\n" +
+		"2026-03-06,-15.00,NGN,,fee,\n"
+	statements, err := Parse(strings.NewReader(in), FormatCSV, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(statements) != 1 || len(statements[0].Lines) != 3 {
+		t.Fatalf("got %+v", statements)
+	}
+	st := statements[0]
+	if st.Currency != "NGN" || !st.From.Equal(date(2026, 3, 4)) || !st.To.Equal(date(2026, 3, 6)) {
+		t.Errorf("statement = %+v", st)
+	}
+
+	l := st.Lines[0]
+	if l.Line != 1 || l.Amount != 975000 || l.Currency != "NGN" || l.Reference != txnID || l.Direction != model.StatementCredit {
+		t.Errorf("line 1 = %+v", l)
+	}
+	if l := st.Lines[1]; l.Amount != 12050 || l.Direction != model.StatementDebit || !l.ValueDate.Equal(date(2026, 3, 5)) {
+		t.Errorf("line 2 = %+v", l)
+	}
+	if l := st.Lines[2]; l.Amount != 1500 || l.Direction != model.StatementDebit {
+		t.Errorf("negative amount = %+v, want a debit of 1500", l)
+	}
+
+	if _, err := Parse(strings.NewReader("date,amount\n2026-03-04,1.00\n"), FormatCSV, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("missing currency column: error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestParseMT940(t *testing.T) {
+	in := "{1:F01BANKNGLAXXX0000000000}{2:I940BANKNGLAXXXXN}{4:\r\n" +
+		":20:STMT20260304\r\n" +
+		":25:0123456789\r\n" +
+		":28C:00001/001\r\n" +
+		":60F:C260303NGN1000,00\r\n" +
+		":61:2603040304C9750,NTRFNONREF//BK-1\r\n" +
+		":86:/EREF/" + txnID + "/REMI/Card settlement\r\n" +
+		":61:2603050306DR120,50NCHGCHARGES\r\n" +
+		"monthly fee\r\n" +
+		":62F:C260305NGN10629,50\r\n" +
+		"-}\r\n"
+	statements, err := Parse(strings.NewReader(in), FormatMT940, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements", len(statements))
+	}
+	st := statements[0]
+	if st.ID != "STMT20260304" || st.Account != "0123456789" || st.Currency != "NGN" ||
+		!st.From.Equal(date(2026, 3, 3)) || !st.To.Equal(date(2026, 3, 5)) {
+		t.Errorf("statement = %+v", st)
+	}
+	if len(st.Lines) != 2 {
+		t.Fatalf("got %d lines", len(st.Lines))
+	}
+
+	l := st.Lines[0]
+	if l.Amount != 975000 || l.Direction != model.StatementCredit || l.Reference != txnID ||
+		l.BankReference != "BK-1" || !l.ValueDate.Equal(date(2026, 3, 4)) {
+		t.Errorf("credit line = %+v", l)
+	}
+	if !strings.Contains(l.Description, "Card settlement") {
+		t.Errorf("description = %q", l.Description)
+	}
+	l = st.Lines[1]
+	if l.Amount != 12050 || l.Direction != model.StatementDebit || l.Reference != "CHARGES" ||
+		!l.BookingDate.Equal(date(2026, 3, 6)) || l.Description != "monthly fee" {
+		t.Errorf("debit line = %+v", l)
+	}
+
+	if _, err := Parse(strings.NewReader(":20:X\n:61:garbage\n"), FormatMT940, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("bad :61: error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestParseCamt053(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>CAMT-1</Id>
+      <FrToDt><FrDtTm>2026-03-04T00:00:00</FrDtTm><ToDtTm>2026-03-04T23:59:59</ToDtTm></FrToDt>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">123.45</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2026-03-04</Dt></BookgDt>
+        <ValDt><Dt>2026-03-04</Dt></ValDt>
+        <AcctSvcrRef>BK-9</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>` + txnID + `</EndToEndId></Refs>
+          <RmtInf><Ustrd>Order 42</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">30.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <ValDt><DtTm>2026-03-04T10:00:00Z</DtTm></ValDt>
+        <NtryDtls>
+          <TxDtls><Amt Ccy="EUR">10.00</Amt><Refs><EndToEndId>A</EndToEndId></Refs></TxDtls>
+          <TxDtls><Amt Ccy="EUR">20.00</Amt><Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs></TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">5.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <ValDt><Dt>2026-03-04</Dt></ValDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+	statements, err := Parse(strings.NewReader(in), FormatCamt053, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	st := statements[0]
+	if st.ID != "CAMT-1" || st.Account != "DE89370400440532013000" || st.Currency != "EUR" || !st.From.Equal(date(2026, 3, 4)) {
+		t.Errorf("statement = %+v", st)
+	}
+	// The batch entry is split and the pending one skipped
+	if len(st.Lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(st.Lines))
+	}
+	l := st.Lines[0]
+	if l.Amount != 12345 || l.Currency != "EUR" || l.Reference != txnID || l.BankReference != "BK-9" ||
+		l.Description != "Order 42" || l.Direction != model.StatementCredit {
+		t.Errorf("line 1 = %+v", l)
+	}
+	if l := st.Lines[1]; l.Amount != 1000 || l.Reference != "A" || !l.BookingDate.Equal(date(2026, 3, 4)) {
+		t.Errorf("line 2 = %+v", l)
+	}
+	if l := st.Lines[2]; l.Amount != 2000 || l.Reference != "" || l.Line != 3 {
+		t.Errorf("line 3 = %+v", l)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s := &model.Settlements{
+		ID:                uuid.New(),
+		ExternalReference: txnID,
+		Amount:            975000,
+		Currency:          "NGN",
+		CreatedAt:         time.Date(2026, 3, 3, 22, 15, 0, 0, time.UTC),
+	}
+	tol := model.MatchTolerance{Amount: 100, Date: 2 * 24 * time.Hour}
+	line := func(amount int64, currency string, valueDate time.Time) *model.StatementLine {
+		return &model.StatementLine{Reference: txnID, Amount: amount, Currency: currency, ValueDate: valueDate}
+	}
+
+	cases := []struct {
+		name    string
+		line    *model.StatementLine
+		status  string
+		reasons int
+	}{
+		{"exact", line(975000, "NGN", date(2026, 3, 4)), model.ReconMatched, 0},
+		{"within tolerance", line(974950, "ngn", date(2026, 3, 5)), model.ReconMatched, 0},
+		{"short", line(970000, "NGN", date(2026, 3, 4)), model.ReconMismatch, 1},
+		{"late", line(975000, "NGN", date(2026, 3, 9)), model.ReconMismatch, 1},
+		{"wrong currency and late", line(975000, "USD", date(2026, 3, 9)), model.ReconMismatch, 2},
+	}
+	for _, c := range cases {
+		got := Match(c.line, s, tol)
+		if got.Status != c.status || len(got.Reasons) != c.reasons {
+			t.Errorf("%s: Match = %+v, want %s with %d reasons", c.name, got, c.status, c.reasons)
+		}
+	}
+}
+
+func TestToleranceFromEnv(t *testing.T) {
+	t.Setenv("RECON_AMOUNT_TOLERANCE", "50")
+	t.Setenv("RECON_DATE_TOLERANCE_DAYS", "")
+	tol, err := ToleranceFromEnv()
+	if err != nil || tol.Amount != 50 || tol.Date != DefaultDateTolerance {
+		t.Errorf("ToleranceFromEnv = %+v, %v", tol, err)
+	}
+	t.Setenv("RECON_DATE_TOLERANCE_DAYS", "-1")
+	if _, err := ToleranceFromEnv(); err == nil {
+		t.Error("accepted a negative date tolerance")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	line := func(amount int64) *model.StatementLine {
+		return &model.StatementLine{Amount: amount, Currency: "NGN", Direction: model.StatementCredit, ValueDate: date(2026, 3, 4)}
+	}
+	st := &model.Statement{Account: "0123", Lines: []*model.StatementLine{line(100), line(200), line(100)}}
+	keys := Keys(st)
+	if keys[0] == keys[1] || keys[0] == keys[2] {
+		t.Errorf("keys not unique: %v", keys)
+	}
+
+	// The same entries in a later export keep their keys
+	again := Keys(&model.Statement{Account: "0123", Lines: []*model.StatementLine{line(50), line(100), line(100)}})
+	if again[1] != keys[0] || again[2] != keys[2] {
+		t.Errorf("keys changed between exports: %v vs %v", keys, again)
+	}
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,4 +18,34 @@ func LoadEnv() {
 	if err := godotenv.Load(".env.local"); err != nil {
 		log.Printf("No .env.local file found: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// DurationEnv parses key as a time.Duration, returning def when it is unset
+// or not a positive duration.
+func DurationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s=%q, using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// IntEnv parses key as a positive int, returning def when it is unset or
+// invalid.
+func IntEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("invalid %s=%q, using default %d", key, v, def)
+		return def
+	}
+	return n
+}
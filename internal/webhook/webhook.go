@@ -0,0 +1,192 @@
+// Package webhook signs and sends merchant webhooks.
+//
+// Each request carries the event body and a signature over the timestamp and
+// the exact bytes sent:
+//
+//	X-Webhook-Timestamp: 1735689600
+//	X-Webhook-Signature: t=1735689600,v1=<hex HMAC-SHA256(secret, "1735689600." + body)>
+//
+// Receivers should recompute the HMAC with their secret, compare in constant
+// time and reject timestamps outside a small tolerance to prevent replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BjornOnGit/payment-gateway/internal/util"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrTimestampSkew    = errors.New("webhook timestamp outside tolerance")
+)
+
+// NewSecret returns a random signing secret for a merchant.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at ts.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + mac(secret, t, body)
+}
+
+// Verify checks a signature header against body. A zero tolerance skips the
+// timestamp check.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+	if t == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, t, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+			return ErrTimestampSkew
+		}
+	}
+	return nil
+}
+
+func mac(secret, t string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RetryPolicy spaces out failed deliveries exponentially:
+// BaseDelay, 2*BaseDelay, 4*BaseDelay, ... capped at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: 6 * time.Hour}
+}
+
+// RetryPolicyFromEnv reads WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_BASE_DELAY and
+// WEBHOOK_RETRY_MAX_DELAY over the defaults.
+func RetryPolicyFromEnv() RetryPolicy {
+	def := DefaultRetryPolicy()
+	return RetryPolicy{
+		MaxAttempts: util.IntEnv("WEBHOOK_MAX_ATTEMPTS", def.MaxAttempts),
+		BaseDelay:   util.DurationEnv("WEBHOOK_RETRY_BASE_DELAY", def.BaseDelay),
+		MaxDelay:    util.DurationEnv("WEBHOOK_RETRY_MAX_DELAY", def.MaxDelay),
+	}
+}
+
+// Backoff is the wait before the attempt following attempt (1-based).
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// Result is the outcome of one delivery attempt.
+type Result struct {
+	StatusCode int
+	Body       string // response body, truncated
+	Duration   time.Duration
+}
+
+// Delivered reports whether the endpoint acknowledged with a 2xx.
+func (r *Result) Delivered() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender POSTs signed webhooks.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+// maxResponseBody bounds how much of a response is kept for the delivery log.
+const maxResponseBody = 1024
+
+// Send delivers body to url. A non-2xx response is returned as a Result, not
+// an error; errors mean no response was received.
+func (s *Sender) Send(ctx context.Context, url, secret, deliveryID, event string, body []byte) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build webhook request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "payment-gateway-webhooks/1")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, now, body))
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return &Result{
+		StatusCode: resp.StatusCode,
+		Body:       string(respBody),
+		Duration:   time.Since(now),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"settlement.completed"}`)
+	now := time.Unix(1735689600, 0)
+	header := Sign("whsec_test", now, body)
+
+	if err := Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := Verify("whsec_other", header, body, 0, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("wrong secret err = %v, want ErrInvalidSignature", err)
+	}
+	if err := Verify("whsec_test", header, []byte(`{}`), 0, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered body err = %v, want ErrInvalidSignature", err)
+	}
+	if err := Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Hour)); !errors.Is(err, ErrTimestampSkew) {
+		t.Fatalf("stale timestamp err = %v, want ErrTimestampSkew", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestSenderSignsRequest(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if err := Verify("whsec_test", r.Header.Get(SignatureHeader), got, time.Minute, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(EventHeader) != "settlement.completed" || r.Header.Get(DeliveryHeader) != "d1" {
+			http.Error(w, "missing headers", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	res, err := NewSender(time.Second).Send(context.Background(), srv.URL, "whsec_test", "d1", "settlement.completed", body)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !res.Delivered() {
+		t.Fatalf("Send status = %d (%s), want 2xx", res.StatusCode, res.Body)
+	}
+}
@@ -22,7 +22,12 @@ import (
 
 func StartTestServer(t *testing.T, db *sql.DB, mem bus.Bus) (addr string, shutdown func()) {
 	txRepo := repo.NewPostgresTransactionRepository(db)
-	svc := service.NewTransactionService(txRepo, mem)
+	txManager := repo.NewPostgresTxManager(db)
+	svc := service.NewTransactionService(txRepo, txManager, nil, nil)
+
+	// relay outbox events onto the in-memory bus
+	ctx, cancel := context.WithCancel(context.Background())
+	go service.NewOutboxRelay(txManager, mem, nil).Run(ctx, 50*time.Millisecond)
 
 	mux := api.NewRouter(svc)
 	// wrap with metrics middleware
@@ -37,6 +42,7 @@ func StartTestServer(t *testing.T, db *sql.DB, mem bus.Bus) (addr string, shutdo
 	go srv.Serve(ln)
 
 	return ln.Addr().String(), func() {
+		cancel()
 		_ = srv.Shutdown(context.Background())
 	}
 }